@@ -0,0 +1,497 @@
+package main
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ProposalStatus is the state of a proposed resolution waiting for a second person
+type ProposalStatus string
+
+const (
+	ProposalPending  ProposalStatus = "pending"
+	ProposalApproved ProposalStatus = "approved"
+	ProposalRejected ProposalStatus = "rejected"
+	ProposalFailed   ProposalStatus = "failed" // approved, but the resolution could not be applied
+	ProposalStale    ProposalStatus = "stale"  // the amount or currency of the case changed before the approval
+)
+
+var (
+	ErrProposalNotFound = errors.New("proposal not found")
+	ErrProposalDecided  = errors.New("proposal has already been decided")
+	ErrProposalPending  = errors.New("case already has a pending proposal")
+	ErrSelfApproval     = errors.New("a proposal cannot be decided by the user who proposed it")
+	ErrMissingIdentity  = errors.New("a user identity is required")
+	ErrProposalStale    = errors.New("the case amount or currency changed since the proposal")
+)
+
+// Proposal is a resolution proposed by a maker that a checker has to approve before it is applied
+type Proposal struct {
+	ID           string         `json:"id"`
+	CaseID       string         `json:"caseId"`
+	Resolution   Resolution     `json:"resolution"`
+	Amount       float64        `json:"amount"`
+	Currency     string         `json:"currency"`
+	Note         string         `json:"note,omitempty"`
+	ProposedBy   string         `json:"proposedBy"`
+	ProposedAt   time.Time      `json:"proposedAt"`
+	Status       ProposalStatus `json:"status"`
+	DecidedBy    string         `json:"decidedBy,omitempty"`
+	DecidedAt    time.Time      `json:"decidedAt,omitzero"`
+	DecisionNote string         `json:"decisionNote,omitempty"`
+}
+
+// ApprovalRecord is one line of the append-only approval log
+type ApprovalRecord struct {
+	At         time.Time  `json:"at"`
+	Action     string     `json:"action"` // proposed, approved, rejected, failed or stale
+	ProposalID string     `json:"proposalId"`
+	CaseID     string     `json:"caseId"`
+	Actor      string     `json:"actor"`
+	Resolution Resolution `json:"resolution"`
+	Amount     float64    `json:"amount"`
+	Currency   string     `json:"currency"`
+	Note       string     `json:"note,omitempty"`
+}
+
+// ApprovalPolicy decides which resolutions need a second person
+type ApprovalPolicy struct {
+	Thresholds  map[string]float64 `json:"thresholds"`  // per currency, resolutions on cases with an amount at or above it need approval
+	Resolutions []Resolution       `json:"resolutions"` // the resolutions the thresholds apply to
+}
+
+// DefaultApprovalPolicy requires approval for write-offs and forced matches from 500 USD, and in any
+// amount of other currencies
+func DefaultApprovalPolicy() ApprovalPolicy {
+	return ApprovalPolicy{
+		Thresholds:  map[string]float64{"USD": defaultApprovalThreshold},
+		Resolutions: []Resolution{ResolutionWriteOff, ResolutionForceMatch},
+	}
+}
+
+// approvalPolicyPath is where the policy of a case store is kept, next to it so that it goes wherever
+// the cases go and cannot be swapped for another on the command line
+func approvalPolicyPath(storePath string) string {
+	return strings.TrimSuffix(storePath, filepath.Ext(storePath)) + ".policy.json"
+}
+
+// LoadApprovalPolicy reads the approval policy of the case store at storePath, the default policy
+// applying when the store has none
+func LoadApprovalPolicy(storePath string) (ApprovalPolicy, error) {
+	path := approvalPolicyPath(storePath)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return DefaultApprovalPolicy(), nil
+	}
+	if err != nil {
+		return ApprovalPolicy{}, fmt.Errorf("failed to read approval policy: %w", err)
+	}
+
+	var policy ApprovalPolicy
+	if err := json.Unmarshal(data, &policy); err != nil {
+		return ApprovalPolicy{}, fmt.Errorf("failed to parse approval policy %s: %w", path, err)
+	}
+	thresholds := make(map[string]float64, len(policy.Thresholds))
+	for currency, threshold := range policy.Thresholds {
+		thresholds[strings.ToUpper(currency)] = threshold
+	}
+	policy.Thresholds = thresholds
+	for _, r := range policy.Resolutions {
+		if !r.valid() {
+			return ApprovalPolicy{}, fmt.Errorf("invalid approval policy %s: %w: %q", path, ErrInvalidResolution, r)
+		}
+	}
+
+	return policy, nil
+}
+
+// RequiresApproval reports whether resolving the case this way has to go through propose/approve.
+// Amounts are only compared with the threshold of their own currency, cases in a currency without one
+// always need approval.
+func (p ApprovalPolicy) RequiresApproval(c Case, resolution Resolution) bool {
+	if !slices.Contains(p.Resolutions, resolution) {
+		return false
+	}
+	threshold, ok := p.Thresholds[strings.ToUpper(c.Currency)]
+	return !ok || math.Abs(c.Amount) >= threshold
+}
+
+// ApprovalLog is an append-only file of approval records, the proposals are rebuilt from it
+type ApprovalLog struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewApprovalLog opens the approval log at path, the file is created on the first append
+func NewApprovalLog(path string) *ApprovalLog {
+	return &ApprovalLog{path: path}
+}
+
+// Append writes a record to the end of the log and syncs it to disk
+func (l *ApprovalLog) Append(record ApprovalRecord) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	line, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal approval record: %w", err)
+	}
+
+	file, err := os.OpenFile(l.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open approval log: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to append to approval log: %w", err)
+	}
+	if err := file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync approval log: %w", err)
+	}
+
+	return nil
+}
+
+// Records reads every record in the log in the order they were written
+func (l *ApprovalLog) Records() ([]ApprovalRecord, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	file, err := os.Open(l.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open approval log: %w", err)
+	}
+	defer file.Close()
+
+	var records []ApprovalRecord
+	scanner := bufio.NewScanner(file)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var record ApprovalRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, fmt.Errorf("invalid approval record at line %d: %w", line, err)
+		}
+		records = append(records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read approval log: %w", err)
+	}
+
+	return records, nil
+}
+
+// Proposals replays the log into the current state of every proposal
+func (l *ApprovalLog) Proposals() (map[string]*Proposal, error) {
+	records, err := l.Records()
+	if err != nil {
+		return nil, err
+	}
+
+	proposals := make(map[string]*Proposal)
+	for _, record := range records {
+		switch record.Action {
+		case "proposed":
+			proposals[record.ProposalID] = &Proposal{
+				ID:         record.ProposalID,
+				CaseID:     record.CaseID,
+				Resolution: record.Resolution,
+				Amount:     record.Amount,
+				Currency:   record.Currency,
+				Note:       record.Note,
+				ProposedBy: record.Actor,
+				ProposedAt: record.At,
+				Status:     ProposalPending,
+			}
+		case "approved", "rejected":
+			p, ok := proposals[record.ProposalID]
+			if !ok {
+				continue
+			}
+			p.Status = ProposalApproved
+			if record.Action == "rejected" {
+				p.Status = ProposalRejected
+			}
+			p.DecidedBy = record.Actor
+			p.DecidedAt = record.At
+			p.DecisionNote = record.Note
+		case "failed":
+			if p, ok := proposals[record.ProposalID]; ok {
+				p.Status = ProposalFailed
+				p.DecisionNote = record.Note
+			}
+		case "stale":
+			if p, ok := proposals[record.ProposalID]; ok {
+				p.Status = ProposalStale
+				p.DecidedBy = record.Actor
+				p.DecidedAt = record.At
+				p.DecisionNote = record.Note
+			}
+		}
+	}
+
+	return proposals, nil
+}
+
+// ApprovalWorkflow resolves cases, routing the resolutions the policy covers through a maker-checker step
+type ApprovalWorkflow struct {
+	cases  *CaseStore
+	log    *ApprovalLog
+	policy ApprovalPolicy
+	mu     sync.Mutex
+	now    func() time.Time
+}
+
+// NewApprovalWorkflow creates a workflow over the case store and approval log
+func NewApprovalWorkflow(cases *CaseStore, log *ApprovalLog, policy ApprovalPolicy) *ApprovalWorkflow {
+	return &ApprovalWorkflow{
+		cases:  cases,
+		log:    log,
+		policy: policy,
+		now:    func() time.Time { return time.Now().UTC() },
+	}
+}
+
+// Resolve applies the resolution straight away when the policy allows it, otherwise it files a
+// proposal and returns it; the case is then left open until the proposal is approved
+func (w *ApprovalWorkflow) Resolve(caseID string, resolution Resolution, actor, note string) (Case, *Proposal, error) {
+	if actor == "" {
+		return Case{}, nil, ErrMissingIdentity
+	}
+	if !resolution.valid() {
+		return Case{}, nil, fmt.Errorf("%w: %q", ErrInvalidResolution, resolution)
+	}
+
+	c, err := w.cases.Get(caseID)
+	if err != nil {
+		return Case{}, nil, err
+	}
+	if c.IsClosed() {
+		return Case{}, nil, fmt.Errorf("%w: %s", ErrCaseClosed, c.ID)
+	}
+
+	if !w.policy.RequiresApproval(c, resolution) {
+		c, err = w.cases.Resolve(caseID, resolution, actor, note)
+		return c, nil, err
+	}
+
+	proposal, err := w.Propose(caseID, resolution, actor, note)
+	if err != nil {
+		return Case{}, nil, err
+	}
+	c, err = w.cases.Get(caseID)
+	return c, proposal, err
+}
+
+// Propose files a resolution proposal for the case, only one proposal per case can be pending
+func (w *ApprovalWorkflow) Propose(caseID string, resolution Resolution, actor, note string) (*Proposal, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if actor == "" {
+		return nil, ErrMissingIdentity
+	}
+
+	var record ApprovalRecord
+	err := w.cases.locked(func() error {
+		c, ok := w.cases.cases[caseID]
+		if !ok {
+			return fmt.Errorf("%w: %s", ErrCaseNotFound, caseID)
+		}
+		if c.IsClosed() {
+			return fmt.Errorf("%w: %s", ErrCaseClosed, c.ID)
+		}
+
+		proposals, err := w.log.Proposals()
+		if err != nil {
+			return err
+		}
+		for _, p := range proposals {
+			if p.CaseID == caseID && p.Status == ProposalPending {
+				return fmt.Errorf("%w: %s", ErrProposalPending, p.ID)
+			}
+		}
+
+		record = ApprovalRecord{
+			At:         w.now(),
+			Action:     "proposed",
+			ProposalID: newProposalID(),
+			CaseID:     caseID,
+			Actor:      actor,
+			Resolution: resolution,
+			Amount:     c.Amount,
+			Currency:   c.Currency,
+			Note:       note,
+		}
+		if err := w.log.Append(record); err != nil {
+			return err
+		}
+		_, err = w.cases.modify(caseID, func(c *Case, now time.Time) error {
+			c.History = append(c.History, CaseEvent{At: now, Actor: actor, Action: "proposed:" + string(resolution), Note: record.ProposalID})
+			return nil
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &Proposal{
+		ID:         record.ProposalID,
+		CaseID:     caseID,
+		Resolution: resolution,
+		Amount:     record.Amount,
+		Currency:   record.Currency,
+		Note:       note,
+		ProposedBy: actor,
+		ProposedAt: record.At,
+		Status:     ProposalPending,
+	}, nil
+}
+
+// Approve records the checker's approval and applies the proposed resolution to the case. A case whose
+// amount or currency changed since the proposal, as a later run may do, marks the proposal stale
+// instead, and when the resolution cannot be applied the proposal is marked failed; either way the
+// case stays open for a new one. The decision and the resolution are made under the lock of the case
+// store, so that processes sharing it cannot both approve a proposal.
+func (w *ApprovalWorkflow) Approve(proposalID, actor, note string) (Case, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var resolved Case
+	err := w.cases.locked(func() error {
+		p, err := w.pending(proposalID, actor)
+		if err != nil {
+			return err
+		}
+		if c, ok := w.cases.cases[p.CaseID]; ok && (c.Amount != p.Amount || !strings.EqualFold(c.Currency, p.Currency)) {
+			stale := fmt.Errorf("%w: %s was proposed for %.2f %s, the case is now %.2f %s", ErrProposalStale, p.ID, p.Amount, p.Currency, c.Amount, c.Currency)
+			return errors.Join(stale, w.log.Append(w.record(p, "stale", actor, stale.Error())))
+		}
+		if err := w.log.Append(w.record(p, "approved", actor, note)); err != nil {
+			return err
+		}
+
+		resolutionNote := p.Note
+		if resolutionNote == "" {
+			resolutionNote = note
+		}
+		resolved, err = w.cases.modify(p.CaseID, resolveCase(p.Resolution, actor, strings.TrimSpace(fmt.Sprintf("%s (proposal %s by %s)", resolutionNote, p.ID, p.ProposedBy))))
+		if err != nil {
+			return errors.Join(err, w.log.Append(w.record(p, "failed", actor, err.Error())))
+		}
+		return nil
+	})
+	if err != nil {
+		return Case{}, err
+	}
+	return resolved, nil
+}
+
+// Reject records the checker's rejection, the case stays open
+func (w *ApprovalWorkflow) Reject(proposalID, actor, note string) (Case, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var c Case
+	err := w.cases.locked(func() error {
+		p, err := w.pending(proposalID, actor)
+		if err != nil {
+			return err
+		}
+		if err := w.log.Append(w.record(p, "rejected", actor, note)); err != nil {
+			return err
+		}
+		c, err = w.cases.modify(p.CaseID, func(c *Case, now time.Time) error {
+			c.History = append(c.History, CaseEvent{At: now, Actor: actor, Action: "rejected:" + string(p.Resolution), Note: note})
+			return nil
+		})
+		return err
+	})
+	if err != nil {
+		return Case{}, err
+	}
+	return c, nil
+}
+
+// Proposals returns the proposals with the given status, or all of them when status is empty
+func (w *ApprovalWorkflow) Proposals(status ProposalStatus) ([]Proposal, error) {
+	proposals, err := w.log.Proposals()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]Proposal, 0, len(proposals))
+	for _, p := range proposals {
+		if status == "" || p.Status == status {
+			result = append(result, *p)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ProposedAt.Before(result[j].ProposedAt) })
+
+	return result, nil
+}
+
+// pending returns the proposal if the actor may decide it, callers must hold the locks of the case store
+func (w *ApprovalWorkflow) pending(proposalID, actor string) (*Proposal, error) {
+	if actor == "" {
+		return nil, ErrMissingIdentity
+	}
+
+	proposals, err := w.log.Proposals()
+	if err != nil {
+		return nil, err
+	}
+	p, ok := proposals[proposalID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrProposalNotFound, proposalID)
+	}
+	if p.Status != ProposalPending {
+		return nil, fmt.Errorf("%w: %s is %s", ErrProposalDecided, p.ID, p.Status)
+	}
+	if p.ProposedBy == actor {
+		return nil, ErrSelfApproval
+	}
+	return p, nil
+}
+
+// record is the approval record of an action on the proposal
+func (w *ApprovalWorkflow) record(p *Proposal, action, actor, note string) ApprovalRecord {
+	return ApprovalRecord{
+		At:         w.now(),
+		Action:     action,
+		ProposalID: p.ID,
+		CaseID:     p.CaseID,
+		Actor:      actor,
+		Resolution: p.Resolution,
+		Amount:     p.Amount,
+		Currency:   p.Currency,
+		Note:       note,
+	}
+}
+
+// newProposalID returns a random proposal ID
+func newProposalID() string {
+	b := make([]byte, 6)
+	rand.Read(b)
+	return "PRP-" + hex.EncodeToString(b)
+}
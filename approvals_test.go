@@ -0,0 +1,184 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestApprovalPolicyRequiresApproval(t *testing.T) {
+	policy := ApprovalPolicy{
+		Thresholds:  map[string]float64{"USD": 500, "JPY": 75000},
+		Resolutions: []Resolution{ResolutionWriteOff, ResolutionForceMatch},
+	}
+
+	tests := []struct {
+		name       string
+		amount     float64
+		currency   string
+		resolution Resolution
+		want       bool
+	}{
+		{"below the threshold", 499.99, "USD", ResolutionWriteOff, false},
+		{"at the threshold", 500, "USD", ResolutionForceMatch, true},
+		{"negative amount", -800, "USD", ResolutionWriteOff, true},
+		{"lowercase currency", 600, "usd", ResolutionWriteOff, true},
+		{"threshold of the case currency", 5000, "JPY", ResolutionWriteOff, false},
+		{"currency without a threshold", 1, "EUR", ResolutionWriteOff, true},
+		{"resolution not covered", 10000, "USD", ResolutionBookSource, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := Case{Amount: tt.amount, Currency: tt.currency}
+			if got := policy.RequiresApproval(c, tt.resolution); got != tt.want {
+				t.Errorf("RequiresApproval(%.2f %s, %s) = %t, want %t", tt.amount, tt.currency, tt.resolution, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadApprovalPolicy(t *testing.T) {
+	storePath := filepath.Join(t.TempDir(), "cases.json")
+
+	policy, err := LoadApprovalPolicy(storePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := policy.Thresholds["USD"]; got != defaultApprovalThreshold {
+		t.Errorf("default USD threshold = %.2f, want %.2f", got, defaultApprovalThreshold)
+	}
+
+	data := `{"thresholds": {"eur": 450}, "resolutions": ["write_off"]}`
+	if err := os.WriteFile(filepath.Join(filepath.Dir(storePath), "cases.policy.json"), []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	policy, err = LoadApprovalPolicy(storePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := policy.Thresholds["EUR"]; !ok || got != 450 {
+		t.Errorf("EUR threshold = %.2f, %t, want 450", got, ok)
+	}
+	if policy.RequiresApproval(Case{Amount: 10000, Currency: "EUR"}, ResolutionForceMatch) {
+		t.Error("forced match needs approval although the policy only covers write-offs")
+	}
+}
+
+func TestApprovalWorkflowApproveMarksProposalFailed(t *testing.T) {
+	store, id := syncedStore(t)
+	approvals := NewApprovalLog(filepath.Join(t.TempDir(), "approvals.log"))
+	workflow := NewApprovalWorkflow(store, approvals, ApprovalPolicy{Resolutions: []Resolution{ResolutionWriteOff}})
+
+	_, proposal, err := workflow.Resolve(id, ResolutionWriteOff, "alice", "")
+	if err != nil || proposal == nil {
+		t.Fatalf("Resolve() = %v, %v, want a proposal", proposal, err)
+	}
+	if _, err := workflow.Approve(proposal.ID, "alice", ""); !errors.Is(err, ErrSelfApproval) {
+		t.Errorf("self approval: got %v, want %v", err, ErrSelfApproval)
+	}
+
+	store.write = func(string, []byte) error { return errors.New("disk full") }
+	if _, err := workflow.Approve(proposal.ID, "bob", ""); err == nil {
+		t.Fatal("Approve() succeeded although the case could not be resolved")
+	}
+
+	proposals, err := workflow.Proposals(ProposalFailed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(proposals) != 1 || proposals[0].ID != proposal.ID {
+		t.Fatalf("failed proposals = %v, want %s", proposals, proposal.ID)
+	}
+	if c, err := store.Get(id); err != nil || c.IsClosed() {
+		t.Errorf("case is closed (%v) although its resolution failed", err)
+	}
+
+	// The case is open for a new proposal
+	store.write = writeFileAtomic
+	if _, proposal, err = workflow.Resolve(id, ResolutionWriteOff, "alice", ""); err != nil || proposal == nil {
+		t.Errorf("Resolve() after a failed approval = %v, %v, want a new proposal", proposal, err)
+	}
+}
+
+func TestApprovalWorkflowApproveMarksProposalStale(t *testing.T) {
+	store, id := syncedStore(t)
+	approvals := NewApprovalLog(filepath.Join(t.TempDir(), "approvals.log"))
+	workflow := NewApprovalWorkflow(store, approvals, ApprovalPolicy{Resolutions: []Resolution{ResolutionWriteOff}})
+
+	_, proposal, err := workflow.Resolve(id, ResolutionWriteOff, "alice", "")
+	if err != nil || proposal == nil {
+		t.Fatalf("Resolve() = %v, %v, want a proposal", proposal, err)
+	}
+	// A later run finds the transaction again with a much larger amount
+	result := &ReconciliationResult{MissingInInternal: []SourceTransaction{{ProviderTransactionID: "txn-1", Amount: 50000, Currency: "USD"}}}
+	if _, err := store.Sync(result, "tester"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := workflow.Approve(proposal.ID, "bob", ""); !errors.Is(err, ErrProposalStale) {
+		t.Fatalf("Approve() = %v, want %v", err, ErrProposalStale)
+	}
+	if c, err := store.Get(id); err != nil || c.IsClosed() {
+		t.Errorf("case is closed (%v) although its proposal was stale", err)
+	}
+	proposals, err := workflow.Proposals(ProposalStale)
+	if err != nil || len(proposals) != 1 || proposals[0].ID != proposal.ID {
+		t.Fatalf("stale proposals = %v, %v, want %s", proposals, err, proposal.ID)
+	}
+	if _, err := workflow.Approve(proposal.ID, "bob", ""); !errors.Is(err, ErrProposalDecided) {
+		t.Errorf("second Approve() = %v, want %v", err, ErrProposalDecided)
+	}
+}
+
+func TestApprovalWorkflowConcurrentApprovals(t *testing.T) {
+	store, id := syncedStore(t)
+	logPath := filepath.Join(t.TempDir(), "approvals.log")
+	policy := ApprovalPolicy{Resolutions: []Resolution{ResolutionWriteOff}}
+	_, proposal, err := NewApprovalWorkflow(store, NewApprovalLog(logPath), policy).Resolve(id, ResolutionWriteOff, "alice", "")
+	if err != nil || proposal == nil {
+		t.Fatalf("Resolve() = %v, %v, want a proposal", proposal, err)
+	}
+
+	// Each workflow has its own store and log on the same files, standing for a process of its own
+	const checkers = 8
+	errs := make(chan error, checkers)
+	var wg sync.WaitGroup
+	for i := range checkers {
+		other, err := NewCaseStore(store.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		workflow := NewApprovalWorkflow(other, NewApprovalLog(logPath), policy)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := workflow.Approve(proposal.ID, fmt.Sprintf("checker-%d", i), "")
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	approved := 0
+	for err := range errs {
+		switch {
+		case err == nil:
+			approved++
+		case !errors.Is(err, ErrProposalDecided):
+			t.Errorf("Approve() = %v, want nil or %v", err, ErrProposalDecided)
+		}
+	}
+	if approved != 1 {
+		t.Errorf("%d approvals succeeded, want 1", approved)
+	}
+	records, err := NewApprovalLog(logPath).Records()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[1].Action != "approved" {
+		t.Errorf("approval log = %+v, want the proposal and one approval", records)
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// ErrUnauthenticated is returned when a request carries no valid API token
+var ErrUnauthenticated = errors.New("a valid API token is required")
+
+// APIUsers maps API tokens to the users they identify. Only SHA-256 digests of the tokens are kept, so
+// the users file does not hold the secrets themselves.
+type APIUsers struct {
+	digests map[string][sha256.Size]byte // by user
+}
+
+// LoadAPIUsers reads a users file, a JSON object of user names and the hex SHA-256 digests of their
+// tokens, e.g. {"alice": "9f86d08188…"}
+func LoadAPIUsers(path string) (*APIUsers, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read API users: %w", err)
+	}
+	var users map[string]string
+	if err := json.Unmarshal(data, &users); err != nil {
+		return nil, fmt.Errorf("failed to parse API users %s: %w", path, err)
+	}
+
+	digests := make(map[string][sha256.Size]byte, len(users))
+	for user, digest := range users {
+		decoded, err := hex.DecodeString(strings.TrimPrefix(digest, "sha256:"))
+		if err != nil || len(decoded) != sha256.Size || user == "" {
+			return nil, fmt.Errorf("invalid API users %s: %q needs a name and the hex SHA-256 digest of a token", path, user)
+		}
+		digests[user] = [sha256.Size]byte(decoded)
+	}
+	return &APIUsers{digests: digests}, nil
+}
+
+// Authenticate returns the user of the bearer token in the Authorization header
+func (u *APIUsers) Authenticate(r *http.Request) (string, error) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		return "", ErrUnauthenticated
+	}
+
+	digest := sha256.Sum256([]byte(token))
+	for user, expected := range u.digests {
+		if subtle.ConstantTimeCompare(digest[:], expected[:]) == 1 {
+			return user, nil
+		}
+	}
+	return "", ErrUnauthenticated
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/devhindo/TransactionReconcilerService/reconcile"
+)
+
+// runBench generates a dataset of the requested size and times the sequential and the parallel reconciliation on it
+func runBench(args []string) {
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+	rows := fs.Int("rows", 1000000, "source transactions to generate, the system side gets about as many")
+	workers := fs.Int("workers", 0, "workers of the parallel run, 0 uses every CPU")
+	dir := fs.String("dir", "", "directory for the generated CSV files, a temporary one removed afterwards when empty")
+	seed := fs.Uint64("seed", 1, "seed of the generated dataset")
+	fs.Parse(args)
+
+	dataDir := *dir
+	if dataDir == "" {
+		tmp, err := os.MkdirTemp("", "reconcile-bench-")
+		if err != nil {
+			log.Fatalf("Failed to create dataset directory: %v", err)
+		}
+		defer os.RemoveAll(tmp)
+		dataDir = tmp
+	}
+
+	start := time.Now()
+	sourcePath, systemPath, err := writeBenchDataset(dataDir, *rows, *seed)
+	if err != nil {
+		log.Fatalf("Failed to generate dataset: %v", err)
+	}
+	fmt.Printf("Generated %d source rows in %s (%s)\n", *rows, dataDir, time.Since(start).Round(time.Millisecond))
+
+	// The library logs every phase, which would only add noise to the timings
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	service := NewTransactionReconciliationService()
+	ctx := context.Background()
+
+	start = time.Now()
+	sequential, err := service.ProcessReconciliationContext(ctx, sourcePath, systemPath, nil)
+	if err != nil {
+		log.Fatalf("Sequential reconciliation failed: %v", err)
+	}
+	sequentialTime := time.Since(start)
+	summary := sequential.Summary
+	sequential = nil // only one result is held at a time
+
+	start = time.Now()
+	parallel, err := service.ProcessReconciliationParallel(ctx, sourcePath, systemPath, *workers, nil)
+	if err != nil {
+		log.Fatalf("Parallel reconciliation failed: %v", err)
+	}
+	parallelTime := time.Since(start)
+
+	fmt.Printf("%-22s %12s %14s\n", "mode", "time", "rows/s")
+	total := float64(summary.TotalSourceTransactions + summary.TotalSystemTransactions)
+	fmt.Printf("%-22s %12s %14.0f\n", "sequential", sequentialTime.Round(time.Millisecond), total/sequentialTime.Seconds())
+	fmt.Printf("%-22s %12s %14.0f\n", fmt.Sprintf("parallel (%d workers)", parallelWorkers(*workers)), parallelTime.Round(time.Millisecond), total/parallelTime.Seconds())
+	fmt.Printf("speedup: %.2fx\n", sequentialTime.Seconds()/parallelTime.Seconds())
+
+	if summary != parallel.Summary {
+		log.SetOutput(os.Stderr)
+		log.Fatalf("Parallel summary %+v differs from sequential %+v", parallel.Summary, summary)
+	}
+	fmt.Println("summaries match")
+}
+
+// writeBenchDataset writes rows source transactions and their system counterparts to dir. About 95% of the
+// rows exist on both sides, 2% of them with a different amount, and 5% of each side has no counterpart.
+func writeBenchDataset(dir string, rows int, seed uint64) (string, string, error) {
+	sourcePath := filepath.Join(dir, "source_transactions.csv")
+	systemPath := filepath.Join(dir, "system_transactions.csv")
+
+	sourceFile, err := os.Create(sourcePath)
+	if err != nil {
+		return "", "", err
+	}
+	defer sourceFile.Close()
+	systemFile, err := os.Create(systemPath)
+	if err != nil {
+		return "", "", err
+	}
+	defer systemFile.Close()
+
+	sourceBuf := bufio.NewWriterSize(sourceFile, 1<<20)
+	systemBuf := bufio.NewWriterSize(systemFile, 1<<20)
+	source := csv.NewWriter(sourceBuf)
+	system := csv.NewWriter(systemBuf)
+	source.Write(reconcile.SourceCSVHeader)
+	system.Write(reconcile.SystemCSVHeader)
+
+	rng := rand.New(rand.NewPCG(seed, seed))
+	currencies := []string{"USD", "EUR", "GBP"}
+	methods := []string{"credit_card", "paypal_balance", "bank_transfer"}
+	epoch := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	id := func(prefix string, n int) string { return fmt.Sprintf("%s-%010d-%08x", prefix, n, rng.Uint32()) }
+
+	writeSystem := func(txnID, userID string, amount float64, currency, method string, createdAt, updatedAt time.Time, reference string) {
+		system.Write([]string{
+			txnID, userID, strconv.FormatFloat(amount, 'f', 2, 64), currency, "completed", method,
+			createdAt.Format(time.RFC3339Nano), updatedAt.Format(time.RFC3339Nano),
+			reference, id("ord", 0), "generated order",
+		})
+	}
+
+	for n := range rows {
+		txnID := id("txn", n)
+		userID := id("usr", rng.IntN(rows/10+1))
+		amount := float64(rng.IntN(100000)) / 100
+		currency := currencies[rng.IntN(len(currencies))]
+		method := methods[rng.IntN(len(methods))]
+		createdAt := epoch.Add(time.Duration(rng.Int64N(int64(365 * 24 * time.Hour)))).Truncate(time.Millisecond)
+		updatedAt := createdAt.Add(time.Duration(rng.IntN(72)) * time.Hour)
+		// The provider reference is the internal referenceId, so only the planted differences mismatch
+		reference := id("ref", n)
+
+		source.Write([]string{
+			txnID, "customer@example.com", userID, "Stripe", strconv.FormatFloat(amount, 'f', 2, 64), currency,
+			"completed", "payment", method, createdAt.Format(time.RFC3339Nano), updatedAt.Format(time.RFC3339Nano),
+			reference, "low", id("inv", n), "Generated Customer", "generated payment",
+		})
+
+		switch roll := rng.IntN(100); {
+		case roll < 5:
+			// Only on the source side, and one system transaction the source side never saw
+			writeSystem(id("sys", n), userID, amount, currency, method, createdAt, updatedAt, id("ref", rows+n))
+		case roll < 7:
+			writeSystem(txnID, userID, amount+1, currency, method, createdAt, updatedAt, reference)
+		default:
+			writeSystem(txnID, userID, amount, currency, method, createdAt, updatedAt, reference)
+		}
+	}
+
+	source.Flush()
+	system.Flush()
+	if err := source.Error(); err != nil {
+		return "", "", err
+	}
+	if err := system.Error(); err != nil {
+		return "", "", err
+	}
+	if err := sourceBuf.Flush(); err != nil {
+		return "", "", err
+	}
+	if err := systemBuf.Flush(); err != nil {
+		return "", "", err
+	}
+	return sourcePath, systemPath, nil
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+// benchRows is the size of the dataset the benchmarks reconcile
+const benchRows = 100000
+
+// loadBenchDataset writes a bench dataset of rows rows and reads it back
+func loadBenchDataset(tb testing.TB, rows int) (string, string, []SourceTransaction, []SystemTransaction) {
+	tb.Helper()
+	sourcePath, systemPath, err := writeBenchDataset(tb.TempDir(), rows, 1)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	reader := NewCSVReader()
+	source, err := reader.ReadSourceTransactions(sourcePath)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	system, err := reader.ReadSystemTransactions(systemPath)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return sourcePath, systemPath, source, system
+}
+
+func TestWriteBenchDataset(t *testing.T) {
+	const rows = 10000
+	_, _, source, system := loadBenchDataset(t, rows)
+	result, err := NewTransactionReconciliationService().reconciler.Reconcile(context.Background(), source, system)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// About 5% of each side has no counterpart and 2% of the pairs differ in amount only
+	within := func(got, percent int) bool { return got > rows*percent/100*8/10 && got < rows*percent/100*12/10 }
+	if !within(len(result.MissingInInternal), 5) || !within(len(result.MissingInSource), 5) || !within(len(result.MismatchedTransactions), 2) {
+		t.Errorf("summary = %+v, want about 5%% missing on each side and 2%% mismatched", result.Summary)
+	}
+	for _, m := range result.MismatchedTransactions {
+		if _, ok := m.Discrepancies["amount"]; !ok || len(m.Discrepancies) != 1 {
+			t.Fatalf("%s differs in %v, want the amount only", m.TransactionID, m.Discrepancies)
+		}
+	}
+}
+
+func BenchmarkReconcileSequential(b *testing.B) {
+	_, _, source, system := loadBenchDataset(b, benchRows)
+	reconciler := NewTransactionReconciliationService().reconciler
+	for b.Loop() {
+		if _, err := reconciler.Reconcile(context.Background(), source, system); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkReconcileParallel(b *testing.B) {
+	_, _, source, system := loadBenchDataset(b, benchRows)
+	reconciler := NewTransactionReconciliationService().reconciler
+	for b.Loop() {
+		if _, err := reconciler.ReconcileParallel(context.Background(), source, system, 0); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkReadSequential(b *testing.B) {
+	sourcePath, systemPath, _, _ := loadBenchDataset(b, benchRows)
+	reader := NewCSVReader()
+	for b.Loop() {
+		if _, err := reader.ReadSourceTransactions(sourcePath); err != nil {
+			b.Fatal(err)
+		}
+		if _, err := reader.ReadSystemTransactions(systemPath); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkReadParallel(b *testing.B) {
+	sourcePath, systemPath, _, _ := loadBenchDataset(b, benchRows)
+	reader := NewCSVReader()
+	for b.Loop() {
+		if _, err := reader.ReadSourceTransactionsParallel(context.Background(), sourcePath, 0); err != nil {
+			b.Fatal(err)
+		}
+		if _, err := reader.ReadSystemTransactionsParallel(context.Background(), systemPath, 0); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
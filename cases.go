@@ -0,0 +1,469 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/devhindo/TransactionReconcilerService/reconcile"
+)
+
+// CaseStatus is the lifecycle state of an exception case
+type CaseStatus string
+
+const (
+	CaseStatusOpen          CaseStatus = "open"
+	CaseStatusInvestigating CaseStatus = "investigating"
+	CaseStatusResolved      CaseStatus = "resolved"
+	CaseStatusWrittenOff    CaseStatus = "written-off"
+)
+
+// ExceptionKind tells which section of the ReconciliationResult a case was raised from
+type ExceptionKind = reconcile.ExceptionKind
+
+const (
+	ExceptionMissingInInternal = reconcile.ExceptionMissingInInternal
+	ExceptionMissingInSource   = reconcile.ExceptionMissingInSource
+	ExceptionMismatched        = reconcile.ExceptionMismatched
+)
+
+// Resolution records how a case was closed
+type Resolution string
+
+const (
+	ResolutionBookSource Resolution = "book_source" // the provider's version is correct, the internal system must follow
+	ResolutionBookSystem Resolution = "book_system" // the internal system is correct, nothing to book
+	ResolutionForceMatch Resolution = "force_match" // accepted as matched despite the discrepancies
+	ResolutionWriteOff   Resolution = "write_off"   // the amount at stake is written off
+)
+
+var (
+	ErrCaseNotFound      = errors.New("case not found")
+	ErrCaseClosed        = errors.New("case is already closed")
+	ErrInvalidStatus     = errors.New("invalid case status")
+	ErrInvalidResolution = errors.New("invalid resolution")
+	ErrCaseIDCollision   = errors.New("case ID is already taken by another exception")
+)
+
+// CaseComment is a free-text note left on a case
+type CaseComment struct {
+	Author    string    `json:"author"`
+	Text      string    `json:"text"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// CaseEvent is one entry in the history of a case
+type CaseEvent struct {
+	At     time.Time `json:"at"`
+	Actor  string    `json:"actor"`
+	Action string    `json:"action"`
+	From   string    `json:"from,omitempty"`
+	To     string    `json:"to,omitempty"`
+	Note   string    `json:"note,omitempty"`
+}
+
+// Case tracks a single reconciliation exception until it is resolved
+type Case struct {
+	ID             string             `json:"id"`
+	Kind           ExceptionKind      `json:"kind"`
+	TransactionID  string             `json:"transactionId"`
+	Amount         float64            `json:"amount"` // the amount at stake
+	Currency       string             `json:"currency"`
+	Source         *SourceTransaction `json:"source,omitempty"`
+	System         *SystemTransaction `json:"system,omitempty"`
+	Discrepancies  DiscrepancyMap     `json:"discrepancies,omitempty"`
+	Owner          string             `json:"owner,omitempty"`
+	Status         CaseStatus         `json:"status"`
+	Resolution     Resolution         `json:"resolution,omitempty"`
+	ResolutionNote string             `json:"resolutionNote,omitempty"`
+	Comments       []CaseComment      `json:"comments,omitempty"`
+	History        []CaseEvent        `json:"history"`
+	CreatedAt      time.Time          `json:"createdAt"`
+	UpdatedAt      time.Time          `json:"updatedAt"`
+	LastSeenAt     time.Time          `json:"lastSeenAt"`
+}
+
+// IsClosed reports whether the case no longer needs any work
+func (c *Case) IsClosed() bool {
+	return c.Status == CaseStatusResolved || c.Status == CaseStatusWrittenOff
+}
+
+// CaseFilter narrows down the cases returned by CaseStore.List, empty fields match everything
+type CaseFilter struct {
+	Status CaseStatus
+	Owner  string
+	Kind   ExceptionKind
+}
+
+// CaseID derives a stable case ID from the exception kind and transaction ID, so that
+// the same exception found by a later run maps to the same case. Its 80 bits make a collision
+// unlikely even across billions of exceptions.
+func CaseID(kind ExceptionKind, transactionID string) string {
+	sum := sha256.Sum256([]byte(string(kind) + ":" + transactionID))
+	return "CASE-" + strings.ToUpper(hex.EncodeToString(sum[:10]))
+}
+
+// CaseStore keeps exception cases in a local JSON file. Every change is made under a lock file and on
+// the cases as last saved, so processes sharing the file, such as concurrent cases commands and a
+// server, do not lose each other's updates.
+type CaseStore struct {
+	path  string
+	mu    sync.Mutex
+	cases map[string]*Case
+	now   func() time.Time
+	write func(path string, data []byte) error
+}
+
+// NewCaseStore opens the case store at path, starting empty if the file does not exist yet
+func NewCaseStore(path string) (*CaseStore, error) {
+	store := &CaseStore{
+		path:  path,
+		cases: make(map[string]*Case),
+		now:   func() time.Time { return time.Now().UTC() },
+		write: writeFileAtomic,
+	}
+	if err := store.locked(func() error { return nil }); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// locked runs fn holding both the store mutex and the lock file, on the cases reloaded from the file
+func (cs *CaseStore) locked(fn func() error) error {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	unlock, err := lockFile(cs.path + ".lock")
+	if err != nil {
+		return fmt.Errorf("failed to lock case store %s: %w", cs.path, err)
+	}
+	defer unlock()
+
+	if err := cs.reload(); err != nil {
+		return err
+	}
+	return fn()
+}
+
+// reload reads the cases as last saved by any process, callers must hold the locks
+func (cs *CaseStore) reload() error {
+	data, err := os.ReadFile(cs.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read case store %s: %w", cs.path, err)
+	}
+	var cases []*Case
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &cases); err != nil {
+			return fmt.Errorf("failed to parse case store %s: %w", cs.path, err)
+		}
+	}
+	cs.cases = make(map[string]*Case, len(cases))
+	for _, c := range cases {
+		cs.cases[c.ID] = c
+	}
+	return nil
+}
+
+// Sync opens a case for every exception in the result that is not tracked yet and refreshes
+// the snapshot of the ones still open. It returns the number of newly opened cases.
+func (cs *CaseStore) Sync(result *ReconciliationResult, actor string) (int, error) {
+	opened := 0
+	err := cs.locked(func() error {
+		next := maps.Clone(cs.cases)
+		var err error
+		if opened, err = cs.syncInto(next, result, actor); err != nil {
+			return err
+		}
+		return cs.swap(next)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return opened, nil
+}
+
+// syncInto opens and refreshes the cases of the result in next, replacing the cases it changes with
+// updated copies so the cases in the store stay as they are until next is saved. It returns the number
+// of newly opened cases, and ErrCaseIDCollision rather than overwrite the case of another exception.
+func (cs *CaseStore) syncInto(next map[string]*Case, result *ReconciliationResult, actor string) (int, error) {
+	now := cs.now()
+	opened := 0
+
+	var collision error
+	track := func(c *Case) {
+		existing, ok := next[c.ID]
+		if !ok {
+			c.Status = CaseStatusOpen
+			c.CreatedAt = now
+			c.UpdatedAt = now
+			c.LastSeenAt = now
+			c.History = []CaseEvent{{At: now, Actor: actor, Action: "opened", To: string(CaseStatusOpen)}}
+			next[c.ID] = c
+			opened++
+			return
+		}
+		if existing.Kind != c.Kind || existing.TransactionID != c.TransactionID {
+			collision = errors.Join(collision, fmt.Errorf("%w: %s is the case of %s %s, not of %s %s",
+				ErrCaseIDCollision, c.ID, existing.Kind, existing.TransactionID, c.Kind, c.TransactionID))
+			return
+		}
+
+		updated := *existing
+		updated.LastSeenAt = now
+		next[c.ID] = &updated
+		if updated.IsClosed() {
+			return
+		}
+		// Keep the snapshot in line with the latest run while the case is being worked on
+		updated.Amount = c.Amount
+		updated.Currency = c.Currency
+		updated.Source = c.Source
+		updated.System = c.System
+		updated.Discrepancies = c.Discrepancies
+	}
+
+	for _, txn := range result.MissingInInternal {
+		source := txn
+		track(&Case{
+			ID:            CaseID(ExceptionMissingInInternal, txn.ProviderTransactionID),
+			Kind:          ExceptionMissingInInternal,
+			TransactionID: txn.ProviderTransactionID,
+			Amount:        txn.Amount,
+			Currency:      txn.Currency,
+			Source:        &source,
+		})
+	}
+
+	for _, txn := range result.MissingInSource {
+		system := txn
+		track(&Case{
+			ID:            CaseID(ExceptionMissingInSource, txn.TransactionID),
+			Kind:          ExceptionMissingInSource,
+			TransactionID: txn.TransactionID,
+			Amount:        txn.Amount,
+			Currency:      txn.Currency,
+			System:        &system,
+		})
+	}
+
+	for _, mismatch := range result.MismatchedTransactions {
+		c := &Case{
+			ID:            CaseID(ExceptionMismatched, mismatch.TransactionID),
+			Kind:          ExceptionMismatched,
+			TransactionID: mismatch.TransactionID,
+			Source:        mismatch.Source,
+			System:        mismatch.System,
+			Discrepancies: mismatch.Discrepancies,
+		}
+		if mismatch.Source != nil && mismatch.System != nil {
+			c.Currency = mismatch.Source.Currency
+			c.Amount = mismatch.AmountAtStake()
+		}
+		track(c)
+	}
+	if collision != nil {
+		return 0, collision
+	}
+	return opened, nil
+}
+
+// List returns the cases matching the filter, ordered by creation time and ID
+func (cs *CaseStore) List(filter CaseFilter) []Case {
+	// A store that cannot be read again is listed as last read
+	_ = cs.locked(func() error { return nil })
+
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	cases := make([]Case, 0, len(cs.cases))
+	for _, c := range cs.cases {
+		if filter.Status != "" && c.Status != filter.Status {
+			continue
+		}
+		if filter.Owner != "" && c.Owner != filter.Owner {
+			continue
+		}
+		if filter.Kind != "" && c.Kind != filter.Kind {
+			continue
+		}
+		cases = append(cases, *c)
+	}
+
+	sort.Slice(cases, func(i, j int) bool {
+		if !cases[i].CreatedAt.Equal(cases[j].CreatedAt) {
+			return cases[i].CreatedAt.Before(cases[j].CreatedAt)
+		}
+		return cases[i].ID < cases[j].ID
+	})
+
+	return cases
+}
+
+// Get returns a copy of the case with the given ID
+func (cs *CaseStore) Get(id string) (Case, error) {
+	var c Case
+	err := cs.locked(func() error {
+		found, ok := cs.cases[id]
+		if !ok {
+			return fmt.Errorf("%w: %s", ErrCaseNotFound, id)
+		}
+		c = *found
+		return nil
+	})
+	return c, err
+}
+
+// Assign hands the case over to a new owner
+func (cs *CaseStore) Assign(id, owner, actor string) (Case, error) {
+	return cs.update(id, func(c *Case, now time.Time) error {
+		c.History = append(c.History, CaseEvent{At: now, Actor: actor, Action: "assigned", From: c.Owner, To: owner})
+		c.Owner = owner
+		return nil
+	})
+}
+
+// SetStatus moves an open case between open and investigating, closing a case goes through Resolve
+func (cs *CaseStore) SetStatus(id string, status CaseStatus, actor, note string) (Case, error) {
+	if status != CaseStatusOpen && status != CaseStatusInvestigating {
+		return Case{}, fmt.Errorf("%w: %q, use resolve to close a case", ErrInvalidStatus, status)
+	}
+
+	return cs.update(id, func(c *Case, now time.Time) error {
+		if c.IsClosed() {
+			return fmt.Errorf("%w: %s", ErrCaseClosed, c.ID)
+		}
+		c.History = append(c.History, CaseEvent{At: now, Actor: actor, Action: "status_changed", From: string(c.Status), To: string(status), Note: note})
+		c.Status = status
+		return nil
+	})
+}
+
+// AddComment appends a comment to the case
+func (cs *CaseStore) AddComment(id, author, text string) (Case, error) {
+	if strings.TrimSpace(text) == "" {
+		return Case{}, fmt.Errorf("comment text is empty")
+	}
+
+	return cs.update(id, func(c *Case, now time.Time) error {
+		c.Comments = append(c.Comments, CaseComment{Author: author, Text: text, CreatedAt: now})
+		c.History = append(c.History, CaseEvent{At: now, Actor: author, Action: "commented"})
+		return nil
+	})
+}
+
+// Resolve closes the case with the given resolution, a write-off leaves the case written-off
+func (cs *CaseStore) Resolve(id string, resolution Resolution, actor, note string) (Case, error) {
+	if !resolution.valid() {
+		return Case{}, fmt.Errorf("%w: %q", ErrInvalidResolution, resolution)
+	}
+
+	return cs.update(id, resolveCase(resolution, actor, note))
+}
+
+// resolveCase closes a case with the given resolution, for update and modify
+func resolveCase(resolution Resolution, actor, note string) func(c *Case, now time.Time) error {
+	return func(c *Case, now time.Time) error {
+		if c.IsClosed() {
+			return fmt.Errorf("%w: %s", ErrCaseClosed, c.ID)
+		}
+		status := CaseStatusResolved
+		if resolution == ResolutionWriteOff {
+			status = CaseStatusWrittenOff
+		}
+		c.History = append(c.History, CaseEvent{At: now, Actor: actor, Action: "resolved:" + string(resolution), From: string(c.Status), To: string(status), Note: note})
+		c.Status = status
+		c.Resolution = resolution
+		c.ResolutionNote = note
+		return nil
+	}
+}
+
+// Record adds an event to the history of the case without changing its state
+func (cs *CaseStore) Record(id, actor, action, note string) (Case, error) {
+	return cs.update(id, func(c *Case, now time.Time) error {
+		c.History = append(c.History, CaseEvent{At: now, Actor: actor, Action: action, Note: note})
+		return nil
+	})
+}
+
+// update applies fn to a copy of the case and saves the store with it, the case in the store being
+// replaced only once the store is saved
+func (cs *CaseStore) update(id string, fn func(c *Case, now time.Time) error) (Case, error) {
+	var updated Case
+	err := cs.locked(func() error {
+		var err error
+		updated, err = cs.modify(id, fn)
+		return err
+	})
+	if err != nil {
+		return Case{}, err
+	}
+	return updated, nil
+}
+
+// modify is update for callers already holding the locks, such as an approval that has to check the
+// case and log its decision in the same critical section
+func (cs *CaseStore) modify(id string, fn func(c *Case, now time.Time) error) (Case, error) {
+	c, ok := cs.cases[id]
+	if !ok {
+		return Case{}, fmt.Errorf("%w: %s", ErrCaseNotFound, id)
+	}
+
+	updated := *c
+	updated.Comments = slices.Clone(c.Comments)
+	updated.History = slices.Clone(c.History)
+	now := cs.now()
+	if err := fn(&updated, now); err != nil {
+		return Case{}, err
+	}
+	updated.UpdatedAt = now
+
+	next := maps.Clone(cs.cases)
+	next[id] = &updated
+	if err := cs.swap(next); err != nil {
+		return Case{}, err
+	}
+	return updated, nil
+}
+
+// swap saves next as the cases of the store and makes them current, leaving the store as it was when
+// saving fails; callers must hold the locks
+func (cs *CaseStore) swap(next map[string]*Case) error {
+	cases := make([]*Case, 0, len(next))
+	for _, c := range next {
+		cases = append(cases, c)
+	}
+	sort.Slice(cases, func(i, j int) bool { return cases[i].ID < cases[j].ID })
+
+	data, err := json.MarshalIndent(cases, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal cases: %w", err)
+	}
+
+	if err := cs.write(cs.path, data); err != nil {
+		return fmt.Errorf("failed to write case store: %w", err)
+	}
+
+	cs.cases = next
+	return nil
+}
+
+// valid reports whether r is one of the known resolutions
+func (r Resolution) valid() bool {
+	switch r {
+	case ResolutionBookSource, ResolutionBookSystem, ResolutionForceMatch, ResolutionWriteOff:
+		return true
+	}
+	return false
+}
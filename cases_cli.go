@@ -0,0 +1,198 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/user"
+	"strings"
+	"text/tabwriter"
+)
+
+const (
+	defaultCaseStorePath     = "cases.json"    // where cases are kept unless -cases says otherwise
+	defaultApprovalLogPath   = "approvals.log" // where approval decisions are appended unless -approvals says otherwise
+	defaultApprovalThreshold = 500.0           // write-offs and forced matches from this USD amount need a second person
+)
+
+// runCases dispatches the cases subcommands
+func runCases(args []string) {
+	if len(args) == 0 {
+		printCasesUsage()
+		os.Exit(2)
+	}
+
+	fs := flag.NewFlagSet("cases "+args[0], flag.ExitOnError)
+	storePath := fs.String("cases", defaultCaseStorePath, "path to the case store")
+	status := fs.String("status", "", "filter by status (list) or new status (status)")
+	owner := fs.String("owner", "", "filter by owner (list)")
+	kind := fs.String("kind", "", "filter by exception kind (list)")
+	note := fs.String("note", "", "note recorded with the change")
+	asJSON := fs.Bool("json", false, "print cases as JSON")
+	approvalsPath := fs.String("approvals", defaultApprovalLogPath, "path to the append-only approval log")
+
+	command := args[0]
+	fs.Parse(args[1:])
+	rest := fs.Args()
+
+	// Changes are recorded under the operating system account, which unlike a flag or an environment
+	// variable the maker cannot pick to approve their own proposal
+	account, err := user.Current()
+	if err != nil {
+		log.Fatalf("Failed to identify the current user: %v", err)
+	}
+	actor := account.Username
+
+	store, err := NewCaseStore(*storePath)
+	if err != nil {
+		log.Fatalf("Failed to open case store: %v", err)
+	}
+	policy, err := LoadApprovalPolicy(*storePath)
+	if err != nil {
+		log.Fatalf("Failed to load approval policy: %v", err)
+	}
+	workflow := NewApprovalWorkflow(store, NewApprovalLog(*approvalsPath), policy)
+
+	switch command {
+	case "list":
+		cases := store.List(CaseFilter{Status: CaseStatus(*status), Owner: *owner, Kind: ExceptionKind(*kind)})
+		if *asJSON {
+			printJSON(cases)
+			return
+		}
+		printCaseTable(cases)
+
+	case "show":
+		id := requireArgs(command, rest, 1, "<case-id>")[0]
+		c, err := store.Get(id)
+		if err != nil {
+			log.Fatalf("Failed to get case: %v", err)
+		}
+		printJSON(c)
+
+	case "assign":
+		a := requireArgs(command, rest, 2, "<case-id> <owner>")
+		c, err := store.Assign(a[0], a[1], actor)
+		if err != nil {
+			log.Fatalf("Failed to assign case: %v", err)
+		}
+		fmt.Printf("%s assigned to %s\n", c.ID, c.Owner)
+
+	case "status":
+		id := requireArgs(command, rest, 1, "<case-id> -status open|investigating")[0]
+		c, err := store.SetStatus(id, CaseStatus(*status), actor, *note)
+		if err != nil {
+			log.Fatalf("Failed to change case status: %v", err)
+		}
+		fmt.Printf("%s is now %s\n", c.ID, c.Status)
+
+	case "comment":
+		a := requireArgs(command, rest, 2, "<case-id> <text>")
+		c, err := store.AddComment(a[0], actor, strings.Join(a[1:], " "))
+		if err != nil {
+			log.Fatalf("Failed to comment on case: %v", err)
+		}
+		fmt.Printf("%s now has %d comments\n", c.ID, len(c.Comments))
+
+	case "resolve":
+		a := requireArgs(command, rest, 2, "<case-id> book_source|book_system|force_match|write_off")
+		c, proposal, err := workflow.Resolve(a[0], Resolution(a[1]), actor, *note)
+		if err != nil {
+			log.Fatalf("Failed to resolve case: %v", err)
+		}
+		if proposal != nil {
+			fmt.Printf("%s needs approval, proposal %s is pending\n", c.ID, proposal.ID)
+			return
+		}
+		fmt.Printf("%s is now %s (%s)\n", c.ID, c.Status, c.Resolution)
+
+	case "proposals":
+		proposals, err := workflow.Proposals(ProposalStatus(*status))
+		if err != nil {
+			log.Fatalf("Failed to read proposals: %v", err)
+		}
+		if *asJSON {
+			printJSON(proposals)
+			return
+		}
+		printProposalTable(proposals)
+
+	case "approve":
+		id := requireArgs(command, rest, 1, "<proposal-id>")[0]
+		c, err := workflow.Approve(id, actor, *note)
+		if err != nil {
+			log.Fatalf("Failed to approve proposal: %v", err)
+		}
+		fmt.Printf("%s approved, %s is now %s (%s)\n", id, c.ID, c.Status, c.Resolution)
+
+	case "reject":
+		id := requireArgs(command, rest, 1, "<proposal-id>")[0]
+		c, err := workflow.Reject(id, actor, *note)
+		if err != nil {
+			log.Fatalf("Failed to reject proposal: %v", err)
+		}
+		fmt.Printf("%s rejected, %s stays %s\n", id, c.ID, c.Status)
+
+	default:
+		fmt.Fprintf(os.Stderr, "unknown cases command %q\n\n", command)
+		printCasesUsage()
+		os.Exit(2)
+	}
+}
+
+// printCasesUsage lists the cases subcommands
+func printCasesUsage() {
+	fmt.Fprintln(os.Stderr, "usage: TransactionReconcilerService cases <command> [flags] [args]")
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(os.Stderr, "commands:")
+	fmt.Fprintln(os.Stderr, "  list                       list cases, filtered by -status, -owner and -kind")
+	fmt.Fprintln(os.Stderr, "  show <case-id>             print a case with its comments and history")
+	fmt.Fprintln(os.Stderr, "  assign <case-id> <owner>   assign a case")
+	fmt.Fprintln(os.Stderr, "  status <case-id> -status   move a case between open and investigating")
+	fmt.Fprintln(os.Stderr, "  comment <case-id> <text>   comment on a case")
+	fmt.Fprintln(os.Stderr, "  resolve <case-id> <how>    close a case as book_source, book_system, force_match or write_off,")
+	fmt.Fprintln(os.Stderr, "                             large write-offs and forced matches are proposed for approval instead")
+	fmt.Fprintln(os.Stderr, "  proposals                  list resolution proposals, filtered by -status")
+	fmt.Fprintln(os.Stderr, "  approve <proposal-id>      approve a proposal made by someone else and apply it")
+	fmt.Fprintln(os.Stderr, "  reject <proposal-id>       reject a proposal made by someone else")
+}
+
+// requireArgs exits with a usage message unless at least n positional arguments were given
+func requireArgs(command string, args []string, n int, usage string) []string {
+	if len(args) < n {
+		fmt.Fprintf(os.Stderr, "usage: TransactionReconcilerService cases %s [flags] %s\n", command, usage)
+		os.Exit(2)
+	}
+	return args
+}
+
+// printJSON prints v as indented JSON
+func printJSON(v interface{}) {
+	out, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		log.Fatalf("Failed to marshal JSON: %v", err)
+	}
+	fmt.Println(string(out))
+}
+
+// printCaseTable prints one line per case
+func printCaseTable(cases []Case) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tKIND\tTRANSACTION\tAMOUNT\tSTATUS\tOWNER\tCOMMENTS")
+	for _, c := range cases {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%.2f %s\t%s\t%s\t%d\n", c.ID, c.Kind, c.TransactionID, c.Amount, c.Currency, c.Status, c.Owner, len(c.Comments))
+	}
+	w.Flush()
+}
+
+// printProposalTable prints one line per proposal
+func printProposalTable(proposals []Proposal) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tCASE\tRESOLUTION\tAMOUNT\tPROPOSED BY\tSTATUS\tDECIDED BY")
+	for _, p := range proposals {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%.2f %s\t%s\t%s\t%s\n", p.ID, p.CaseID, p.Resolution, p.Amount, p.Currency, p.ProposedBy, p.Status, p.DecidedBy)
+	}
+	w.Flush()
+}
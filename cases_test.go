@@ -0,0 +1,111 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// syncedStore opens a store in a temporary directory with one missing-in-internal case
+func syncedStore(t *testing.T) (*CaseStore, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "cases.json")
+	store, err := NewCaseStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := &ReconciliationResult{MissingInInternal: []SourceTransaction{{ProviderTransactionID: "txn-1", Amount: 10, Currency: "USD"}}}
+	if opened, err := store.Sync(result, "tester"); err != nil || opened != 1 {
+		t.Fatalf("Sync() = %d, %v, want 1 case opened", opened, err)
+	}
+	return store, CaseID(ExceptionMissingInInternal, "txn-1")
+}
+
+func TestCaseStoreUpdateKeepsCaseWhenSaveFails(t *testing.T) {
+	store, id := syncedStore(t)
+	store.write = func(string, []byte) error { return errors.New("disk full") }
+
+	if _, err := store.Assign(id, "alice", "tester"); err == nil {
+		t.Fatal("Assign() succeeded although the store could not be saved")
+	}
+	if _, err := store.Resolve(id, ResolutionWriteOff, "tester", "small"); err == nil {
+		t.Fatal("Resolve() succeeded although the store could not be saved")
+	}
+
+	c, err := store.Get(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Owner != "" || c.Status != CaseStatusOpen || len(c.History) != 1 {
+		t.Errorf("case changed by failed updates: owner %q, status %q, %d history events", c.Owner, c.Status, len(c.History))
+	}
+}
+
+func TestCaseStoreConcurrentStoresKeepEveryUpdate(t *testing.T) {
+	first, id := syncedStore(t)
+	// A second store on the same file stands for another process
+	second, err := NewCaseStore(first.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const comments = 20
+	var wg sync.WaitGroup
+	for i, store := range []*CaseStore{first, second} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range comments {
+				if _, err := store.AddComment(id, fmt.Sprintf("user-%d", i), fmt.Sprintf("comment %d", j)); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	reopened, err := NewCaseStore(first.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := reopened.Get(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Comments) != 2*comments {
+		t.Errorf("got %d comments, want %d", len(c.Comments), 2*comments)
+	}
+	if got, err := first.Get(id); err != nil || len(got.Comments) != 2*comments {
+		t.Errorf("first store sees %d comments (%v), want the other store's too", len(got.Comments), err)
+	}
+}
+
+func TestCaseStoreSyncRejectsCaseIDCollision(t *testing.T) {
+	store, id := syncedStore(t)
+	// Stand in for another transaction whose ID hashes to the case of txn-1
+	if err := store.locked(func() error {
+		next := maps.Clone(store.cases)
+		other := *next[id]
+		other.TransactionID = "txn-other"
+		next[id] = &other
+		return store.swap(next)
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	result := &ReconciliationResult{MissingInInternal: []SourceTransaction{{ProviderTransactionID: "txn-1", Amount: 50000, Currency: "USD"}}}
+	if _, err := store.Sync(result, "tester"); !errors.Is(err, ErrCaseIDCollision) {
+		t.Fatalf("Sync() = %v, want %v", err, ErrCaseIDCollision)
+	}
+	c, err := store.Get(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.TransactionID != "txn-other" || c.Amount != 10 {
+		t.Errorf("case of txn-other overwritten: %s of %v", c.TransactionID, c.Amount)
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/devhindo/TransactionReconcilerService/reconcile"
+)
+
+// runCoordinate reconciles the source and system files on worker API servers and writes the merged report
+func runCoordinate(args []string) {
+	workingDir, err := os.Getwd()
+	if err != nil {
+		log.Fatalf("Failed to get working directory: %v", err)
+	}
+
+	fs := flag.NewFlagSet("coordinate", flag.ExitOnError)
+	sourcePath := fs.String("source", filepath.Join(workingDir, "assets", "data", "csvs", "source_transactions.csv"), "path to the source transactions CSV")
+	systemPath := fs.String("system", filepath.Join(workingDir, "assets", "data", "csvs", "system_transactions.csv"), "path to the system transactions CSV")
+	workers := fs.String("workers", "", "comma separated base URLs of the worker API servers, e.g. http://localhost:8081,http://localhost:8082")
+	strategy := fs.String("partition", string(PartitionByHash), "how the input is split: hash of the transaction ID, or createdAt date range")
+	partitions := fs.Int("partitions", defaultPartitions, "number of partitions with -partition hash")
+	span := fs.Duration("span", defaultDateSpan, "width of a partition with -partition date")
+	spoolDir := fs.String("spool-dir", "", "directory for the partition files, the system temp directory when empty")
+	attempts := fs.Int("attempts", defaultPartitionAttempts, "dispatches of a partition before the reconciliation fails")
+	timeout := fs.Duration("timeout", defaultDispatchTimeout, "limit of a single partition dispatch")
+	token := fs.String("token", os.Getenv("RECONCILER_API_TOKEN"), "API token for workers started with -users")
+	orderFlag := fs.String("order", DefaultReportOrder.String(), "sort order of the report sections: severity, amount, createdAt or id, - for descending")
+	fs.Parse(args)
+
+	order, err := ParseReportOrder(*orderFlag)
+	if err != nil {
+		log.Fatalf("Invalid -order: %v", err)
+	}
+
+	var urls []string
+	for _, url := range strings.Split(*workers, ",") {
+		if url = strings.TrimSpace(url); url != "" {
+			urls = append(urls, url)
+		}
+	}
+
+	service := NewTransactionReconciliationService(reconcile.WithOrder(order))
+	coordinator, err := NewCoordinator(service.reconciler, CoordinatorConfig{
+		Workers:     urls,
+		Strategy:    PartitionStrategy(*strategy),
+		Partitions:  *partitions,
+		DateSpan:    *span,
+		SpoolDir:    *spoolDir,
+		MaxAttempts: *attempts,
+		Timeout:     *timeout,
+		Token:       *token,
+	})
+	if err != nil {
+		log.Fatalf("Invalid coordinator configuration: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	fmt.Println("🔄 Starting Distributed Transaction Reconciliation")
+	fmt.Println("=================================================")
+
+	start := time.Now()
+	result, err := coordinator.Reconcile(ctx, *sourcePath, *systemPath)
+	if err != nil {
+		log.Fatalf("Reconciliation failed: %v", err)
+	}
+	log.Printf("Reconciled on %d workers in %s", len(urls), time.Since(start).Round(time.Millisecond))
+
+	service.PrintSummary(result)
+	if err := service.OutputReconciliationResult(result); err != nil {
+		log.Fatalf("Failed to output reconciliation result: %v", err)
+	}
+
+	fmt.Println("\n✅ Reconciliation completed successfully!")
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"text/tabwriter"
+)
+
+// defaultCorrectionLogDir is where the per-run correction logs are kept unless -log-dir says otherwise
+const defaultCorrectionLogDir = "corrections"
+
+// runCorrections dispatches the corrections subcommands
+func runCorrections(args []string) {
+	if len(args) == 0 {
+		printCorrectionsUsage()
+		os.Exit(2)
+	}
+
+	fs := flag.NewFlagSet("corrections "+args[0], flag.ExitOnError)
+	storePath := fs.String("cases", defaultCaseStorePath, "path to the case store")
+	logDir := fs.String("log-dir", defaultCorrectionLogDir, "directory holding the per-run correction logs")
+	baseURL := fs.String("url", os.Getenv("SYSTEM_API_URL"), "base URL of the internal system API")
+	token := fs.String("token", os.Getenv("SYSTEM_API_TOKEN"), "bearer token for the internal system API")
+	dryRun := fs.Bool("dry-run", false, "log the corrections without sending them")
+	user := fs.String("user", currentUser(), "identity recorded on the corrected cases")
+
+	command := args[0]
+	fs.Parse(args[1:])
+	rest := fs.Args()
+
+	store, err := NewCaseStore(*storePath)
+	if err != nil {
+		log.Fatalf("Failed to open case store: %v", err)
+	}
+
+	needsWriter := (command == "apply" || command == "rollback") && !*dryRun
+	if needsWriter && *baseURL == "" {
+		log.Fatalf("The internal system API URL is required, set -url or SYSTEM_API_URL, or use -dry-run")
+	}
+	corrector := NewCorrector(NewHTTPSystemWriter(*baseURL, *token, nil), store, *logDir, *dryRun)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	switch command {
+	case "apply":
+		run, err := corrector.Apply(ctx, *user)
+		if run != nil {
+			printCorrectionRun(run)
+		}
+		if err != nil {
+			log.Fatalf("Failed to apply corrections: %v", err)
+		}
+
+	case "rollback":
+		runID := requireArgs(command, rest, 1, "<run-id>")[0]
+		run, err := corrector.Rollback(ctx, runID, *user)
+		if run != nil {
+			printCorrectionRun(run)
+		}
+		if err != nil {
+			log.Fatalf("Failed to roll back corrections: %v", err)
+		}
+
+	case "runs":
+		runs, err := corrector.Runs()
+		if err != nil {
+			log.Fatalf("Failed to list correction runs: %v", err)
+		}
+		for _, runID := range runs {
+			fmt.Println(runID)
+		}
+
+	case "show":
+		runID := requireArgs(command, rest, 1, "<run-id>")[0]
+		run, err := corrector.LoadRun(runID)
+		if err != nil {
+			log.Fatalf("Failed to read correction run: %v", err)
+		}
+		printJSON(run)
+
+	default:
+		fmt.Fprintf(os.Stderr, "unknown corrections command %q\n\n", command)
+		printCorrectionsUsage()
+		os.Exit(2)
+	}
+}
+
+// printCorrectionsUsage lists the corrections subcommands
+func printCorrectionsUsage() {
+	fmt.Fprintln(os.Stderr, "usage: TransactionReconcilerService corrections <command> [flags] [args]")
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(os.Stderr, "commands:")
+	fmt.Fprintln(os.Stderr, "  apply              push the corrections for cases resolved as book_source, -dry-run only logs them")
+	fmt.Fprintln(os.Stderr, "  rollback <run-id>  undo the corrections applied by a run")
+	fmt.Fprintln(os.Stderr, "  runs               list the logged correction runs")
+	fmt.Fprintln(os.Stderr, "  show <run-id>      print the correction log of a run")
+}
+
+// printCorrectionRun prints one line per correction of a run
+func printCorrectionRun(run *CorrectionRun) {
+	mode := ""
+	if run.DryRun {
+		mode = " (dry-run)"
+	}
+	if run.Interrupted {
+		mode += " (interrupted)"
+	}
+	fmt.Printf("Run %s%s: %d corrections\n", run.RunID, mode, len(run.Corrections))
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CASE\tOP\tTRANSACTION\tSTATUS\tIDEMPOTENCY KEY\tERROR")
+	for _, c := range run.Corrections {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", c.CaseID, c.Op, c.TransactionID, c.Status, c.IdempotencyKey, c.Error)
+	}
+	w.Flush()
+}
@@ -1,146 +1,138 @@
 package main
 
 import (
-	"encoding/csv"
-	"fmt"
-	"os"
-	"strconv"
-	"time"
+	"context"
+	"iter"
+
+	"github.com/devhindo/TransactionReconcilerService/reconcile"
 )
 
 // creating CSVReader struct (class) handles reading and parsing CSV files
-type CSVReader struct{}
+type CSVReader struct {
+	SourceFormat reconcile.Format // format of the source files, e.g. a provider's settlement report; by extension when empty
+	SystemFormat reconcile.Format // format of the system files, by extension when empty
+	SourceSheet  string           // sheet of source .xlsx workbooks, the first when empty
+	SystemSheet  string           // sheet of system .xlsx workbooks, the first when empty
+
+	SourceDialect, SystemDialect reconcile.Dialect           // dialect of delimited files, comma-delimited RFC 4180 when zero
+	SourceLayout, SystemLayout   *reconcile.FixedWidthLayout // when set, files of that side are read as fixed-width in this layout
+	SourceValues, SystemValues   reconcile.ValueFormat       // timestamps and amounts of CSV, XLSX and fixed-width files, RFC 3339 and decimal points when zero
+}
 
 // NewCSVReader constructor to create a new CSV reader instance
 func NewCSVReader() *CSVReader {
 	return &CSVReader{}
 }
 
+// progressInterval is how many rows are parsed between progress callbacks and cancellation checks
+const progressInterval = 1000
+
 // ReadSourceTransactions reads and parses source transactions from CSV file
 func (r *CSVReader) ReadSourceTransactions(filePath string) ([]SourceTransaction, error) {
-	file, err := os.Open(filePath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open source transactions file: %w", err)
-	}
-	defer file.Close()
-
-	reader := csv.NewReader(file)
-	records, err := reader.ReadAll()
-	if err != nil {
-		return nil, fmt.Errorf("failed to read CSV records: %w", err)
-	}
-
-	if len(records) == 0 {
-		return nil, fmt.Errorf("CSV file is empty")
-	}
-
-	// Skip header row
-	records = records[1:]
-	transactions := make([]SourceTransaction, 0, len(records))
-
-	for i, record := range records {
-		if len(record) < 16 {
-			return nil, fmt.Errorf("invalid record at line %d: expected 16 fields, got %d", i+2, len(record))
-		}
-
-		amount, err := strconv.ParseFloat(record[4], 64)
-		if err != nil {
-			return nil, fmt.Errorf("invalid amount at line %d: %w", i+2, err)
-		}
-
-		createdAt, err := time.Parse(time.RFC3339, record[9])
-		if err != nil {
-			return nil, fmt.Errorf("invalid createdAt at line %d: %w", i+2, err)
-		}
+	return r.ReadSourceTransactionsContext(context.Background(), filePath, nil)
+}
 
-		updatedAt, err := time.Parse(time.RFC3339, record[10])
+// ReadSourceTransactionsContext reads source transactions like ReadSourceTransactions, stopping when ctx is done.
+// onRow, when not nil, is called with the number of rows parsed so far.
+func (r *CSVReader) ReadSourceTransactionsContext(ctx context.Context, filePath string, onRow func(rows int)) ([]SourceTransaction, error) {
+	var transactions []SourceTransaction
+	for transaction, err := range r.SourceTransactions(ctx, filePath) {
 		if err != nil {
-			return nil, fmt.Errorf("invalid updatedAt at line %d: %w", i+2, err)
+			return nil, err
 		}
-
-		transaction := SourceTransaction{
-			ProviderTransactionID: record[0],
-			Email:                 record[1],
-			UserID:                record[2],
-			Provider:              record[3],
-			Amount:                amount,
-			Currency:              record[5],
-			Status:                record[6],
-			TransactionType:       record[7],
-			PaymentMethod:         record[8],
-			CreatedAt:             createdAt,
-			UpdatedAt:             updatedAt,
-			ProviderReference:     record[11],
-			FraudRisk:             record[12],
-			DetailsInvoiceID:      record[13],
-			DetailsCustomerName:   record[14],
-			DetailsDescription:    record[15],
+		if len(transactions)%progressInterval == 0 && onRow != nil {
+			onRow(len(transactions))
 		}
-
 		transactions = append(transactions, transaction)
 	}
 
+	if onRow != nil {
+		onRow(len(transactions))
+	}
+
 	return transactions, nil
 }
 
-// ReadSystemTransactions reads and parses system transactions from CSV file
-func (r *CSVReader) ReadSystemTransactions(filePath string) ([]SystemTransaction, error) {
-	file, err := os.Open(filePath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open system transactions file: %w", err)
-	}
-	defer file.Close()
+// SourceTransactions streams the source transactions of a CSV, JSON, NDJSON or XLSX file, told apart by its
+// extension, or of a fixed-width file when a layout is set, one transaction at a time, so memory does not grow with the file. Iteration stops at the first error,
+// which is yielded with a zero transaction.
+func (r *CSVReader) SourceTransactions(ctx context.Context, filePath string) iter.Seq2[SourceTransaction, error] {
+	return r.sourceFile(filePath).Transactions(ctx)
+}
 
-	reader := csv.NewReader(file)
-	records, err := reader.ReadAll()
-	if err != nil {
-		return nil, fmt.Errorf("failed to read CSV records: %w", err)
+// sourceFile is the source transactions of a file read with the reader's settings
+func (r *CSVReader) sourceFile(filePath string) reconcile.TransactionSource[SourceTransaction] {
+	if r.SourceLayout != nil {
+		return reconcile.SourceFixedWidthFile(filePath, *r.SourceLayout).WithValues(r.SourceValues)
 	}
-
-	if len(records) == 0 {
-		return nil, fmt.Errorf("CSV file is empty")
+	switch format := r.sourceFormat(filePath); format {
+	case reconcile.FormatCSV:
+		return reconcile.SourceDelimitedFile(filePath, r.SourceDialect).WithValues(r.SourceValues)
+	case reconcile.FormatXLSX:
+		return reconcile.SourceXLSXFile(filePath, r.SourceSheet, nil).WithValues(r.SourceValues)
+	default:
+		return reconcile.SourceFileAs(filePath, format)
 	}
+}
 
-	// Skip header row
-	records = records[1:]
-	transactions := make([]SystemTransaction, 0, len(records))
+// sourceFormat is the format source files are read in
+func (r *CSVReader) sourceFormat(filePath string) reconcile.Format {
+	if r.SourceFormat != "" {
+		return r.SourceFormat
+	}
+	return reconcile.FormatOf(filePath)
+}
 
-	for i, record := range records {
-		if len(record) < 11 {
-			return nil, fmt.Errorf("invalid record at line %d: expected 11 fields, got %d", i+2, len(record))
-		}
+// ReadSystemTransactions reads and parses system transactions from CSV file
+func (r *CSVReader) ReadSystemTransactions(filePath string) ([]SystemTransaction, error) {
+	return r.ReadSystemTransactionsContext(context.Background(), filePath, nil)
+}
 
-		amount, err := strconv.ParseFloat(record[2], 64)
+// ReadSystemTransactionsContext reads system transactions like ReadSystemTransactions, stopping when ctx is done.
+// onRow, when not nil, is called with the number of rows parsed so far.
+func (r *CSVReader) ReadSystemTransactionsContext(ctx context.Context, filePath string, onRow func(rows int)) ([]SystemTransaction, error) {
+	var transactions []SystemTransaction
+	for transaction, err := range r.SystemTransactions(ctx, filePath) {
 		if err != nil {
-			return nil, fmt.Errorf("invalid amount at line %d: %w", i+2, err)
+			return nil, err
 		}
-
-		createdAt, err := time.Parse(time.RFC3339, record[6])
-		if err != nil {
-			return nil, fmt.Errorf("invalid createdAt at line %d: %w", i+2, err)
+		if len(transactions)%progressInterval == 0 && onRow != nil {
+			onRow(len(transactions))
 		}
+		transactions = append(transactions, transaction)
+	}
 
-		updatedAt, err := time.Parse(time.RFC3339, record[7])
-		if err != nil {
-			return nil, fmt.Errorf("invalid updatedAt at line %d: %w", i+2, err)
-		}
+	if onRow != nil {
+		onRow(len(transactions))
+	}
 
-		transaction := SystemTransaction{
-			TransactionID:       record[0],
-			UserID:              record[1],
-			Amount:              amount,
-			Currency:            record[3],
-			Status:              record[4],
-			PaymentMethod:       record[5],
-			CreatedAt:           createdAt,
-			UpdatedAt:           updatedAt,
-			ReferenceID:         record[8],
-			MetadataOrderID:     record[9],
-			MetadataDescription: record[10],
-		}
+	return transactions, nil
+}
 
-		transactions = append(transactions, transaction)
+// SystemTransactions streams the system transactions of a file one transaction at a time, like SourceTransactions
+func (r *CSVReader) SystemTransactions(ctx context.Context, filePath string) iter.Seq2[SystemTransaction, error] {
+	return r.systemFile(filePath).Transactions(ctx)
+}
+
+// systemFile is the system transactions of a file read with the reader's settings
+func (r *CSVReader) systemFile(filePath string) reconcile.TransactionSource[SystemTransaction] {
+	if r.SystemLayout != nil {
+		return reconcile.SystemFixedWidthFile(filePath, *r.SystemLayout).WithValues(r.SystemValues)
+	}
+	switch format := r.systemFormat(filePath); format {
+	case reconcile.FormatCSV:
+		return reconcile.SystemDelimitedFile(filePath, r.SystemDialect).WithValues(r.SystemValues)
+	case reconcile.FormatXLSX:
+		return reconcile.SystemXLSXFile(filePath, r.SystemSheet, nil).WithValues(r.SystemValues)
+	default:
+		return reconcile.SystemFileAs(filePath, format)
 	}
+}
 
-	return transactions, nil
+// systemFormat is the format system files are read in
+func (r *CSVReader) systemFormat(filePath string) reconcile.Format {
+	if r.SystemFormat != "" {
+		return r.SystemFormat
+	}
+	return reconcile.FormatOf(filePath)
 }
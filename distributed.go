@@ -0,0 +1,559 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/devhindo/TransactionReconcilerService/reconcile"
+)
+
+// PartitionStrategy is how the coordinator splits the inputs between workers
+type PartitionStrategy string
+
+const (
+	PartitionByHash PartitionStrategy = "hash" // by a hash of the transaction ID, pairs always share a partition
+	PartitionByDate PartitionStrategy = "date" // by createdAt range, pairs split across a boundary are joined when merging
+)
+
+var (
+	ErrNoWorkers           = errors.New("no worker left to dispatch partitions to")
+	ErrInvalidPartitioning = errors.New("invalid partitioning")
+	ErrPartitionFailed     = errors.New("partition could not be reconciled")
+	errPartitionRejected   = errors.New("worker rejected the partition")
+)
+
+// Defaults of the coordinator
+const (
+	defaultPartitions        = 16
+	defaultDateSpan          = 7 * 24 * time.Hour
+	defaultPartitionAttempts = 3
+	defaultDispatchTimeout   = 10 * time.Minute
+	workerFailureLimit       = 3 // consecutive failures after which a worker is no longer sent partitions
+	handBackDelay            = 100 * time.Millisecond
+)
+
+// CoordinatorConfig configures the distributed reconciliation
+type CoordinatorConfig struct {
+	Workers     []string          // base URLs of the worker API servers
+	Strategy    PartitionStrategy // hash by default
+	Partitions  int               // number of hash partitions
+	DateSpan    time.Duration     // width of a date partition
+	SpoolDir    string            // partition files are written under this directory, the system default when empty
+	MaxAttempts int               // dispatches of one partition before the reconciliation fails
+	Timeout     time.Duration     // limit of a single dispatch
+	Token       string            // API token for workers started with a users file
+	Client      *http.Client
+}
+
+// Coordinator splits a reconciliation into partitions, has worker processes reconcile them over HTTP and
+// merges their results. A partition whose worker fails or times out is dispatched again to another worker,
+// never to one that already failed it while others are left, and a worker failing repeatedly is left out
+// for the rest of the run.
+type Coordinator struct {
+	reconciler *TransactionReconciler
+	config     CoordinatorConfig
+}
+
+// NewCoordinator creates a coordinator, zero config fields use the defaults
+func NewCoordinator(reconciler *TransactionReconciler, config CoordinatorConfig) (*Coordinator, error) {
+	if len(config.Workers) == 0 {
+		return nil, ErrNoWorkers
+	}
+	switch config.Strategy {
+	case "":
+		config.Strategy = PartitionByHash
+	case PartitionByHash, PartitionByDate:
+	default:
+		return nil, fmt.Errorf("%w: unknown strategy %q", ErrInvalidPartitioning, config.Strategy)
+	}
+	if config.Partitions <= 0 {
+		config.Partitions = defaultPartitions
+	}
+	if config.DateSpan <= 0 {
+		config.DateSpan = defaultDateSpan
+	}
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = defaultPartitionAttempts
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = defaultDispatchTimeout
+	}
+	if config.Client == nil {
+		config.Client = &http.Client{}
+	}
+	for i, worker := range config.Workers {
+		config.Workers[i] = strings.TrimRight(worker, "/")
+	}
+	return &Coordinator{reconciler: reconciler, config: config}, nil
+}
+
+// partition is the pair of CSV files of one partition
+type partition struct {
+	key        int64
+	sourcePath string
+	systemPath string
+	attempts   int
+	failedOn   map[string]bool // workers the partition failed on, only changed while it is not queued
+}
+
+// Reconcile partitions both files, dispatches the partitions to the workers and returns the merged result,
+// with the exceptions in the report order of the reconciler
+func (c *Coordinator) Reconcile(ctx context.Context, sourcePath, systemPath string) (*ReconciliationResult, error) {
+	dir, err := os.MkdirTemp(c.config.SpoolDir, "reconcile-partitions-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create partition directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	partitions, err := c.split(ctx, dir, sourcePath, systemPath)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Split the input into %d partitions by %s", len(partitions), c.config.Strategy)
+
+	results, err := c.dispatch(ctx, partitions)
+	if err != nil {
+		return nil, err
+	}
+
+	result := reconcile.MergeResults(results, c.reconciler.Order())
+	if c.config.Strategy == PartitionByDate {
+		c.joinAcrossPartitions(result)
+		c.reconciler.Order().Sort(result)
+	}
+	return result, nil
+}
+
+// partitionWriters holds the open files of the partitions found so far
+type partitionWriters struct {
+	dir        string
+	partitions map[int64]*partition
+	files      []*os.File
+	writers    map[string]*csv.Writer
+	buffers    []*bufio.Writer
+}
+
+// writer returns the CSV writer of one side of a partition, creating both files of the partition on first use
+func (pw *partitionWriters) writer(key int64, system bool) (*csv.Writer, error) {
+	p, ok := pw.partitions[key]
+	if !ok {
+		p = &partition{
+			key:        key,
+			sourcePath: filepath.Join(pw.dir, fmt.Sprintf("p%d-source.csv", key)),
+			systemPath: filepath.Join(pw.dir, fmt.Sprintf("p%d-system.csv", key)),
+		}
+		for _, side := range []struct {
+			path   string
+			header []string
+		}{{p.sourcePath, reconcile.SourceCSVHeader}, {p.systemPath, reconcile.SystemCSVHeader}} {
+			file, err := os.Create(side.path)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create partition file: %w", err)
+			}
+			buffer := bufio.NewWriter(file)
+			writer := csv.NewWriter(buffer)
+			writer.Write(side.header)
+			pw.files = append(pw.files, file)
+			pw.buffers = append(pw.buffers, buffer)
+			pw.writers[side.path] = writer
+		}
+		pw.partitions[key] = p
+	}
+	if system {
+		return pw.writers[p.systemPath], nil
+	}
+	return pw.writers[p.sourcePath], nil
+}
+
+// close flushes and closes every partition file, once
+func (pw *partitionWriters) close() error {
+	defer func() { pw.writers, pw.buffers, pw.files = nil, nil, nil }()
+
+	var firstErr error
+	for _, writer := range pw.writers {
+		writer.Flush()
+		if err := writer.Error(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	for i, buffer := range pw.buffers {
+		if err := buffer.Flush(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		if err := pw.files[i].Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// split writes every transaction of both files to the partition it belongs to, transactions are parsed here
+// so errors point at the line of the original file rather than at a partition
+func (c *Coordinator) split(ctx context.Context, dir, sourcePath, systemPath string) ([]*partition, error) {
+	writers := &partitionWriters{dir: dir, partitions: make(map[int64]*partition), writers: make(map[string]*csv.Writer)}
+	defer writers.close()
+
+	// Rows are written back as CSV whatever the format of the input, which workers always receive as CSV
+	write := func(key int64, system bool, record []string) error {
+		writer, err := writers.writer(key, system)
+		if err != nil {
+			return err
+		}
+		return writer.Write(record)
+	}
+	for txn, err := range reconcile.SourceFile(sourcePath).Transactions(ctx) {
+		if err != nil {
+			return nil, fmt.Errorf("failed to read source transactions: %w", err)
+		}
+		if err := write(c.partitionKey(txn.ProviderTransactionID, txn.CreatedAt), false, reconcile.FormatSourceRecord(txn)); err != nil {
+			return nil, err
+		}
+	}
+	for txn, err := range reconcile.SystemFile(systemPath).Transactions(ctx) {
+		if err != nil {
+			return nil, fmt.Errorf("failed to read system transactions: %w", err)
+		}
+		if err := write(c.partitionKey(txn.TransactionID, txn.CreatedAt), true, reconcile.FormatSystemRecord(txn)); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := writers.close(); err != nil {
+		return nil, fmt.Errorf("failed to write partition files: %w", err)
+	}
+
+	partitions := make([]*partition, 0, len(writers.partitions))
+	for _, p := range writers.partitions {
+		partitions = append(partitions, p)
+	}
+	sort.Slice(partitions, func(i, j int) bool { return partitions[i].key < partitions[j].key })
+	return partitions, nil
+}
+
+// partitionKey places a transaction in a hash bucket or in the date range holding its createdAt
+func (c *Coordinator) partitionKey(id string, createdAt time.Time) int64 {
+	if c.config.Strategy == PartitionByDate {
+		return createdAt.UnixNano() / int64(c.config.DateSpan)
+	}
+	return int64(reconcile.Shard(id, c.config.Partitions))
+}
+
+// dispatchOutcome is what a worker reports for one partition
+type dispatchOutcome struct {
+	partition *partition
+	worker    string
+	result    *ReconciliationResult
+	err       error
+	retired   bool // the worker failed too often and takes no more partitions
+}
+
+// workerSet tracks the workers still taking partitions
+type workerSet struct {
+	mu      sync.Mutex
+	workers []string
+	retired map[string]bool
+}
+
+// retire leaves a worker out of the run
+func (ws *workerSet) retire(worker string) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	ws.retired[worker] = true
+}
+
+// othersCanTake tells whether a worker other than worker is still taking partitions and has not failed p
+func (ws *workerSet) othersCanTake(p *partition, worker string) bool {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	for _, other := range ws.workers {
+		if other != worker && !ws.retired[other] && !p.failedOn[other] {
+			return true
+		}
+	}
+	return false
+}
+
+// dispatch sends the partitions to the workers, one at a time per worker, and collects the results in partition order
+func (c *Coordinator) dispatch(ctx context.Context, partitions []*partition) ([]*ReconciliationResult, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Every partition is either queued or in flight, so the queue never blocks
+	queue := make(chan *partition, len(partitions))
+	for _, p := range partitions {
+		queue <- p
+	}
+	outcomes := make(chan dispatchOutcome)
+
+	workers := &workerSet{workers: c.config.Workers, retired: make(map[string]bool)}
+	for _, worker := range c.config.Workers {
+		go c.runWorker(ctx, worker, workers, queue, outcomes)
+	}
+
+	results := make(map[int64]*ReconciliationResult, len(partitions))
+	active := len(c.config.Workers)
+	for len(results) < len(partitions) {
+		var outcome dispatchOutcome
+		select {
+		case outcome = <-outcomes:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+
+		if outcome.retired {
+			active--
+			log.Printf("Worker %s failed %d times in a row and is left out", outcome.worker, workerFailureLimit)
+		}
+		if outcome.err == nil {
+			results[outcome.partition.key] = outcome.result
+		} else {
+			p := outcome.partition
+			p.attempts++
+			log.Printf("Partition %d failed on %s (attempt %d of %d): %v", p.key, outcome.worker, p.attempts, c.config.MaxAttempts, outcome.err)
+			if p.attempts >= c.config.MaxAttempts || errors.Is(outcome.err, errPartitionRejected) {
+				return nil, fmt.Errorf("%w: partition %d: %w", ErrPartitionFailed, p.key, outcome.err)
+			}
+			if p.failedOn == nil {
+				p.failedOn = make(map[string]bool)
+			}
+			p.failedOn[outcome.worker] = true
+			queue <- p
+		}
+		if active == 0 && len(results) < len(partitions) {
+			return nil, ErrNoWorkers
+		}
+	}
+
+	ordered := make([]*ReconciliationResult, 0, len(partitions))
+	for _, p := range partitions {
+		ordered = append(ordered, results[p.key])
+	}
+	return ordered, nil
+}
+
+// runWorker feeds partitions from the queue to one worker until the run ends or the worker fails too often.
+// A partition the worker already failed is handed back while another worker can take it, and after a
+// failure the worker waits before taking the next partition.
+func (c *Coordinator) runWorker(ctx context.Context, worker string, workers *workerSet, queue chan *partition, outcomes chan<- dispatchOutcome) {
+	failures := 0
+	for {
+		var p *partition
+		select {
+		case p = <-queue:
+		case <-ctx.Done():
+			return
+		}
+		if p.failedOn[worker] && workers.othersCanTake(p, worker) {
+			// Taking p freed its slot, so the queue has room for it
+			queue <- p
+			select {
+			case <-time.After(handBackDelay):
+				continue
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		result, err := c.send(ctx, worker, p)
+		outcome := dispatchOutcome{partition: p, worker: worker, result: result, err: err}
+		if err != nil {
+			failures++
+			if outcome.retired = failures >= workerFailureLimit; outcome.retired {
+				workers.retire(worker)
+			}
+		} else {
+			failures = 0
+		}
+
+		select {
+		case outcomes <- outcome:
+		case <-ctx.Done():
+			return
+		}
+		if outcome.retired {
+			return
+		}
+		if failures > 0 {
+			select {
+			case <-time.After(time.Duration(failures) * time.Second):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// send uploads a partition to a worker and decodes the result it returns
+func (c *Coordinator) send(ctx context.Context, worker string, p *partition) (*ReconciliationResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.config.Timeout)
+	defer cancel()
+
+	body, contentType := multipartPartition(p)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, worker+"/v1/partitions", body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	if c.config.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.config.Token)
+	}
+
+	resp, err := c.config.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&apiErr)
+		if resp.StatusCode == http.StatusBadRequest {
+			// The same partition would be rejected by any worker
+			return nil, fmt.Errorf("%w: %s", errPartitionRejected, apiErr.Error)
+		}
+		return nil, fmt.Errorf("worker answered %s: %s", resp.Status, apiErr.Error)
+	}
+
+	var result partitionResult
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("invalid worker response: %w", err)
+	}
+	return result.toResult(), nil
+}
+
+// multipartPartition streams both files of a partition as a multipart form with source and system fields
+func multipartPartition(p *partition) (io.Reader, string) {
+	reader, writer := io.Pipe()
+	form := multipart.NewWriter(writer)
+	go func() {
+		for _, field := range []struct{ name, path string }{{"source", p.sourcePath}, {"system", p.systemPath}} {
+			part, err := form.CreateFormFile(field.name, filepath.Base(field.path))
+			if err != nil {
+				writer.CloseWithError(err)
+				return
+			}
+			file, err := os.Open(field.path)
+			if err != nil {
+				writer.CloseWithError(err)
+				return
+			}
+			_, err = io.Copy(part, file)
+			file.Close()
+			if err != nil {
+				writer.CloseWithError(err)
+				return
+			}
+		}
+		writer.CloseWithError(form.Close())
+	}()
+	return reader, form.FormDataContentType()
+}
+
+// joinAcrossPartitions pairs the transactions reported missing on each side by different date partitions,
+// which happens when the two sides of a pair fall on either side of a partition boundary
+func (c *Coordinator) joinAcrossPartitions(result *ReconciliationResult) {
+	systemByID := make(map[string]SystemTransaction, len(result.MissingInSource))
+	for _, txn := range result.MissingInSource {
+		systemByID[txn.TransactionID] = txn
+	}
+
+	var missingInInternal []SourceTransaction
+	for _, sourceTxn := range result.MissingInInternal {
+		systemTxn, ok := systemByID[sourceTxn.ProviderTransactionID]
+		if !ok {
+			missingInInternal = append(missingInInternal, sourceTxn)
+			continue
+		}
+		delete(systemByID, sourceTxn.ProviderTransactionID)
+		result.Summary.MissingInInternalCount--
+		result.Summary.MissingInSourceCount--
+
+		discrepancies := c.reconciler.Compare(sourceTxn, systemTxn)
+		if len(discrepancies) == 0 {
+			result.Summary.SuccessfullyMatchedCount++
+			continue
+		}
+		sourceCopy, systemCopy := sourceTxn, systemTxn
+		result.MismatchedTransactions = append(result.MismatchedTransactions, MismatchedTransaction{
+			TransactionID: sourceTxn.ProviderTransactionID,
+			Discrepancies: discrepancies,
+			Source:        &sourceCopy,
+			System:        &systemCopy,
+		})
+		result.Summary.MismatchedTransactionsCount++
+	}
+	result.MissingInInternal = missingInInternal
+
+	var missingInSource []SystemTransaction
+	for _, txn := range result.MissingInSource {
+		if _, ok := systemByID[txn.TransactionID]; ok {
+			missingInSource = append(missingInSource, txn)
+		}
+	}
+	result.MissingInSource = missingInSource
+}
+
+// partitionResult is the wire form of a partition result, unlike ReconciliationResult it carries both
+// sides of every mismatch so the coordinator can open cases for them
+type partitionResult struct {
+	MissingInInternal      []SourceTransaction   `json:"missing_in_internal"`
+	MissingInSource        []SystemTransaction   `json:"missing_in_source"`
+	MismatchedTransactions []partitionMismatch   `json:"mismatched_transactions"`
+	Summary                ReconciliationSummary `json:"summary"`
+}
+
+// partitionMismatch is a mismatch with its source and system transactions
+type partitionMismatch struct {
+	MismatchedTransaction
+	Source *SourceTransaction `json:"source"`
+	System *SystemTransaction `json:"system"`
+}
+
+// newPartitionResult converts a result to its wire form
+func newPartitionResult(result *ReconciliationResult) partitionResult {
+	wire := partitionResult{
+		MissingInInternal:      result.MissingInInternal,
+		MissingInSource:        result.MissingInSource,
+		MismatchedTransactions: make([]partitionMismatch, 0, len(result.MismatchedTransactions)),
+		Summary:                result.Summary,
+	}
+	for _, mismatch := range result.MismatchedTransactions {
+		wire.MismatchedTransactions = append(wire.MismatchedTransactions, partitionMismatch{
+			MismatchedTransaction: mismatch,
+			Source:                mismatch.Source,
+			System:                mismatch.System,
+		})
+	}
+	return wire
+}
+
+// toResult converts the wire form back to a result
+func (wire partitionResult) toResult() *ReconciliationResult {
+	result := &ReconciliationResult{
+		MissingInInternal: wire.MissingInInternal,
+		MissingInSource:   wire.MissingInSource,
+		Summary:           wire.Summary,
+	}
+	for _, mismatch := range wire.MismatchedTransactions {
+		mismatch.MismatchedTransaction.Source = mismatch.Source
+		mismatch.MismatchedTransaction.System = mismatch.System
+		result.MismatchedTransactions = append(result.MismatchedTransactions, mismatch.MismatchedTransaction)
+	}
+	return result
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// startWorker serves the partition endpoint of a worker, wrapped by wrap when it is not nil
+func startWorker(t *testing.T, wrap func(server *httptest.Server, next http.Handler) http.Handler) string {
+	t.Helper()
+	handler := NewServer(NewTransactionReconciliationService(), ServerConfig{UploadDir: t.TempDir()}).Handler()
+	server := httptest.NewUnstartedServer(handler)
+	if wrap != nil {
+		server.Config.Handler = wrap(server, handler)
+	}
+	server.Start()
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+// exceptionIDs lists the transaction IDs of every exception category, in result order
+func exceptionIDs(result *ReconciliationResult) [3][]string {
+	var ids [3][]string
+	for _, txn := range result.MissingInInternal {
+		ids[0] = append(ids[0], txn.ProviderTransactionID)
+	}
+	for _, txn := range result.MissingInSource {
+		ids[1] = append(ids[1], txn.TransactionID)
+	}
+	for _, mismatch := range result.MismatchedTransactions {
+		ids[2] = append(ids[2], mismatch.TransactionID)
+	}
+	return ids
+}
+
+func TestCoordinatorSurvivesKilledWorker(t *testing.T) {
+	sourcePath, systemPath, err := writeBenchDataset(t.TempDir(), 3000, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	service := NewTransactionReconciliationService()
+	want, err := service.ProcessReconciliation(sourcePath, systemPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	service.reconciler.Order().Sort(want)
+
+	for _, strategy := range []PartitionStrategy{PartitionByHash, PartitionByDate} {
+		t.Run(string(strategy), func(t *testing.T) {
+			// The first worker reconciles one partition, then goes away in the middle of its second one
+			var once sync.Once
+			var served atomic.Int32
+			killed := startWorker(t, func(server *httptest.Server, next http.Handler) http.Handler {
+				return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					if served.Add(1) == 1 {
+						next.ServeHTTP(w, r)
+						return
+					}
+					once.Do(func() { go server.Close() })
+					panic(http.ErrAbortHandler)
+				})
+			})
+			workers := []string{killed, startWorker(t, nil), startWorker(t, nil)}
+
+			coordinator, err := NewCoordinator(service.reconciler, CoordinatorConfig{
+				Workers: workers, Strategy: strategy, Partitions: 12, DateSpan: 30 * 24 * time.Hour, SpoolDir: t.TempDir(),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := coordinator.Reconcile(context.Background(), sourcePath, systemPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if served.Load() < 2 {
+				t.Fatalf("the killed worker got %d partitions, want it killed in the middle of the run", served.Load())
+			}
+			if got.Summary != want.Summary {
+				t.Errorf("summary = %+v, want %+v", got.Summary, want.Summary)
+			}
+			gotIDs, wantIDs := exceptionIDs(got), exceptionIDs(want)
+			for i := range gotIDs {
+				if !slices.Equal(gotIDs[i], wantIDs[i]) {
+					t.Errorf("exceptions %d differ from a single-process run: %v, want %v", i, gotIDs[i], wantIDs[i])
+				}
+			}
+		})
+	}
+}
+
+func TestCoordinatorDoesNotRedispatchToFailedWorker(t *testing.T) {
+	sourcePath, systemPath, err := writeBenchDataset(t.TempDir(), 200, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The failing worker is free again before the slow one is, and must leave it the partition it failed
+	var mu sync.Mutex
+	seen := make(map[string]int)
+	failing := startWorker(t, func(_ *httptest.Server, _ http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if err := r.ParseMultipartForm(1 << 20); err == nil {
+				mu.Lock()
+				seen[r.MultipartForm.File["source"][0].Filename]++
+				mu.Unlock()
+			}
+			writeError(w, http.StatusServiceUnavailable, "worker unavailable")
+		})
+	})
+	slow := startWorker(t, func(_ *httptest.Server, next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(1200 * time.Millisecond)
+			next.ServeHTTP(w, r)
+		})
+	})
+
+	service := NewTransactionReconciliationService()
+	coordinator, err := NewCoordinator(service.reconciler, CoordinatorConfig{Workers: []string{failing, slow}, Partitions: 2, SpoolDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := coordinator.Reconcile(context.Background(), sourcePath, systemPath); err != nil {
+		t.Fatal(err)
+	}
+	for partition, n := range seen {
+		if n > 1 {
+			t.Errorf("%s was sent %d times to the worker that failed it", partition, n)
+		}
+	}
+}
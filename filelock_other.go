@@ -0,0 +1,34 @@
+//go:build !unix
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// lockFileTimeout is how long lockFile waits for another process before giving up
+const lockFileTimeout = 30 * time.Second
+
+// lockFile takes an exclusive lock on path by creating it, waiting while another process holds it. The
+// lock is released by the returned function; a process that dies holding it leaves the file behind, to
+// be removed by hand.
+func lockFile(path string) (unlock func() error, err error) {
+	deadline := time.Now().Add(lockFileTimeout)
+	for {
+		file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			file.Close()
+			return func() error { return os.Remove(path) }, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, err
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("%s is held by another process", path)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
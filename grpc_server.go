@@ -0,0 +1,566 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/devhindo/TransactionReconcilerService/reconcilerpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	defaultSessionTTL  = time.Hour // how long an upload session is kept after its last use
+	defaultMaxSession  = 1_000_000 // transactions a session holds per side unless configured otherwise
+	defaultMaxSessions = 100       // sessions open at the same time unless configured otherwise
+	defaultMaxStored   = 4_000_000 // transactions held across all sessions unless configured otherwise
+	maxGRPCMessageSize = 64 << 10  // bytes of a request, an upload message carrying a single transaction
+)
+
+// GRPCLimits bounds the memory the upload sessions take, a zero field uses the default
+type GRPCLimits struct {
+	SessionTTL  time.Duration // how long an unused session is kept
+	MaxSession  int           // transactions per side of a session
+	MaxSessions int           // sessions open at the same time
+	MaxStored   int           // transactions held across all sessions, including uploads in progress
+}
+
+// GRPCServer exposes the reconciler over gRPC, the service is defined in proto/reconciler/v1/reconciler.proto.
+// Both sides are streamed into an in-memory session which is then reconciled, so no CSV file is involved.
+type GRPCServer struct {
+	reconcilerpb.UnimplementedReconcilerServer
+
+	service *TransactionReconciliationService
+	limits  GRPCLimits
+
+	mu       sync.Mutex
+	sessions map[string]*ingestSession
+	stored   int // transactions in the sessions and reserved by uploads in progress
+	now      func() time.Time
+}
+
+// ingestSession holds the transactions uploaded for one reconciliation
+type ingestSession struct {
+	source   []SourceTransaction
+	system   []SystemTransaction
+	lastUsed time.Time
+}
+
+// NewGRPCServer creates the gRPC service around a reconciliation service
+func NewGRPCServer(service *TransactionReconciliationService, limits GRPCLimits) *GRPCServer {
+	if limits.SessionTTL <= 0 {
+		limits.SessionTTL = defaultSessionTTL
+	}
+	if limits.MaxSession <= 0 {
+		limits.MaxSession = defaultMaxSession
+	}
+	if limits.MaxSessions <= 0 {
+		limits.MaxSessions = defaultMaxSessions
+	}
+	if limits.MaxStored <= 0 {
+		limits.MaxStored = defaultMaxStored
+	}
+	return &GRPCServer{
+		service:  service,
+		limits:   limits,
+		sessions: make(map[string]*ingestSession),
+		now:      time.Now,
+	}
+}
+
+// grpcServerOptions are the options of the gRPC server the service is registered with, capping the size
+// of a request
+func grpcServerOptions() []grpc.ServerOption {
+	return []grpc.ServerOption{grpc.MaxRecvMsgSize(maxGRPCMessageSize)}
+}
+
+// Register adds the service to a gRPC server, which may listen on a real socket or on a bufconn listener in tests
+func (s *GRPCServer) Register(registrar grpc.ServiceRegistrar) {
+	reconcilerpb.RegisterReconcilerServer(registrar, s)
+}
+
+// UploadSourceTransactions stores the streamed provider transactions once the client closes the stream
+func (s *GRPCServer) UploadSourceTransactions(stream reconcilerpb.Reconciler_UploadSourceTransactionsServer) error {
+	var sessionID string
+	var batch []SourceTransaction
+	var stored int // transactions of the side already in the session
+	committed := false
+	defer func() {
+		if !committed {
+			s.release(len(batch))
+		}
+	}()
+	for {
+		req, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if sessionID, err = streamSessionID(sessionID, req.GetSessionId(), len(batch)); err != nil {
+			return err
+		}
+		if len(batch) == 0 && sessionID != "" {
+			if stored, err = s.sessionSize(sessionID, func(session *ingestSession) int { return len(session.source) }); err != nil {
+				return err
+			}
+		}
+		// Checked while receiving, so that an oversized upload is not held in memory
+		if stored+len(batch) >= s.limits.MaxSession {
+			return sessionFullError("source", s.limits.MaxSession)
+		}
+		txn, err := sourceFromProto(req.GetTransaction())
+		if err != nil {
+			return status.Errorf(codes.InvalidArgument, "transaction %d: %v", len(batch)+1, err)
+		}
+		if err := s.reserve(); err != nil {
+			return err
+		}
+		batch = append(batch, txn)
+	}
+
+	sessionID, total, err := s.updateSession(sessionID, func(session *ingestSession) (int, error) {
+		if len(session.source)+len(batch) > s.limits.MaxSession {
+			return 0, sessionFullError("source", s.limits.MaxSession)
+		}
+		session.source = append(session.source, batch...)
+		committed = true
+		return len(session.source), nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return stream.SendAndClose(&reconcilerpb.UploadResponse{
+		SessionId: sessionID,
+		Received:  int64(len(batch)),
+		Total:     int64(total),
+	})
+}
+
+// UploadSystemTransactions stores the streamed internal transactions once the client closes the stream
+func (s *GRPCServer) UploadSystemTransactions(stream reconcilerpb.Reconciler_UploadSystemTransactionsServer) error {
+	var sessionID string
+	var batch []SystemTransaction
+	var stored int // transactions of the side already in the session
+	committed := false
+	defer func() {
+		if !committed {
+			s.release(len(batch))
+		}
+	}()
+	for {
+		req, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if sessionID, err = streamSessionID(sessionID, req.GetSessionId(), len(batch)); err != nil {
+			return err
+		}
+		if len(batch) == 0 && sessionID != "" {
+			if stored, err = s.sessionSize(sessionID, func(session *ingestSession) int { return len(session.system) }); err != nil {
+				return err
+			}
+		}
+		// Checked while receiving, so that an oversized upload is not held in memory
+		if stored+len(batch) >= s.limits.MaxSession {
+			return sessionFullError("system", s.limits.MaxSession)
+		}
+		txn, err := systemFromProto(req.GetTransaction())
+		if err != nil {
+			return status.Errorf(codes.InvalidArgument, "transaction %d: %v", len(batch)+1, err)
+		}
+		if err := s.reserve(); err != nil {
+			return err
+		}
+		batch = append(batch, txn)
+	}
+
+	sessionID, total, err := s.updateSession(sessionID, func(session *ingestSession) (int, error) {
+		if len(session.system)+len(batch) > s.limits.MaxSession {
+			return 0, sessionFullError("system", s.limits.MaxSession)
+		}
+		session.system = append(session.system, batch...)
+		committed = true
+		return len(session.system), nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return stream.SendAndClose(&reconcilerpb.UploadResponse{
+		SessionId: sessionID,
+		Received:  int64(len(batch)),
+		Total:     int64(total),
+	})
+}
+
+// Reconcile reconciles the transactions uploaded to a session
+func (s *GRPCServer) Reconcile(ctx context.Context, req *reconcilerpb.ReconcileRequest) (*reconcilerpb.ReconciliationResult, error) {
+	source, system, err := s.sessionTransactions(req.GetSessionId())
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := s.service.reconciler.Reconcile(ctx, source, system)
+	if err != nil {
+		return nil, status.FromContextError(err).Err()
+	}
+	return resultToProto(result), nil
+}
+
+// StreamExceptions reconciles a session, sending every exception as it is found and the summary last
+func (s *GRPCServer) StreamExceptions(req *reconcilerpb.ReconcileRequest, stream reconcilerpb.Reconciler_StreamExceptionsServer) error {
+	source, system, err := s.sessionTransactions(req.GetSessionId())
+	if err != nil {
+		return err
+	}
+
+	result, err := s.service.reconciler.ReconcileStream(stream.Context(), source, system, func(exception Exception) error {
+		return stream.Send(exceptionToProto(exception))
+	})
+	if err != nil {
+		if _, ok := status.FromError(err); ok {
+			return err
+		}
+		return status.FromContextError(err).Err()
+	}
+
+	return stream.Send(&reconcilerpb.ReconciliationException{
+		Exception: &reconcilerpb.ReconciliationException_Summary{Summary: summaryToProto(result.Summary)},
+	})
+}
+
+// streamSessionID takes the session ID from the first message of an upload, later messages may repeat it or leave it empty
+func streamSessionID(current, requested string, received int) (string, error) {
+	if received == 0 {
+		return requested, nil
+	}
+	if requested != "" && requested != current {
+		return "", status.Errorf(codes.InvalidArgument, "session_id changed from %q to %q within the stream", current, requested)
+	}
+	return current, nil
+}
+
+// updateSession applies fn to a session, opening a new one when id is empty, and returns the session ID with fn's result
+func (s *GRPCServer) updateSession(id string, fn func(session *ingestSession) (int, error)) (string, int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pruneSessions()
+
+	session, ok := s.sessions[id]
+	if id == "" {
+		if len(s.sessions) >= s.limits.MaxSessions {
+			return "", 0, status.Errorf(codes.ResourceExhausted, "at most %d sessions are open at the same time", s.limits.MaxSessions)
+		}
+		id = "ses-" + randomHex(8)
+		session = &ingestSession{}
+		s.sessions[id] = session
+	} else if !ok {
+		return "", 0, status.Errorf(codes.NotFound, "session %s not found", id)
+	}
+
+	total, err := fn(session)
+	if err != nil {
+		return "", 0, err
+	}
+	session.lastUsed = s.now()
+	return id, total, nil
+}
+
+// sessionSize returns the number of transactions of a side already uploaded to a session
+func (s *GRPCServer) sessionSize(id string, side func(session *ingestSession) int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pruneSessions()
+
+	session, ok := s.sessions[id]
+	if !ok {
+		return 0, status.Errorf(codes.NotFound, "session %s not found", id)
+	}
+	return side(session), nil
+}
+
+// reserve counts a received transaction against the transactions held across all sessions, uploads
+// release what they reserved unless it ends up in a session
+func (s *GRPCServer) reserve() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stored >= s.limits.MaxStored {
+		s.pruneSessions()
+	}
+	if s.stored >= s.limits.MaxStored {
+		return status.Errorf(codes.ResourceExhausted, "the server holds at most %d uploaded transactions", s.limits.MaxStored)
+	}
+	s.stored++
+	return nil
+}
+
+// release gives back transactions reserved by an upload which failed
+func (s *GRPCServer) release(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stored -= n
+}
+
+// sessionFullError reports an upload going over the transactions a session holds
+func sessionFullError(side string, limit int) error {
+	return status.Errorf(codes.ResourceExhausted, "a session holds at most %d %s transactions", limit, side)
+}
+
+// sessionTransactions returns both sides of a session, later uploads do not change the returned slices
+func (s *GRPCServer) sessionTransactions(id string) ([]SourceTransaction, []SystemTransaction, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pruneSessions()
+
+	if id == "" {
+		return nil, nil, status.Error(codes.InvalidArgument, "session_id is required")
+	}
+	session, ok := s.sessions[id]
+	if !ok {
+		return nil, nil, status.Errorf(codes.NotFound, "session %s not found", id)
+	}
+
+	session.lastUsed = s.now()
+	return session.source[:len(session.source):len(session.source)], session.system[:len(session.system):len(session.system)], nil
+}
+
+// pruneSessions drops the sessions unused for longer than the TTL, callers must hold the lock
+func (s *GRPCServer) pruneSessions() {
+	cutoff := s.now().Add(-s.limits.SessionTTL)
+	for id, session := range s.sessions {
+		if session.lastUsed.Before(cutoff) {
+			s.stored -= len(session.source) + len(session.system)
+			delete(s.sessions, id)
+		}
+	}
+}
+
+// sourceFromProto converts an uploaded provider transaction
+func sourceFromProto(txn *reconcilerpb.SourceTransaction) (SourceTransaction, error) {
+	if txn == nil {
+		return SourceTransaction{}, errors.New("transaction is missing")
+	}
+	if txn.GetProviderTransactionId() == "" {
+		return SourceTransaction{}, errors.New("provider_transaction_id is required")
+	}
+	createdAt, err := timeFromProto(txn.GetCreatedAt())
+	if err != nil {
+		return SourceTransaction{}, fmt.Errorf("invalid created_at: %w", err)
+	}
+	updatedAt, err := timeFromProto(txn.GetUpdatedAt())
+	if err != nil {
+		return SourceTransaction{}, fmt.Errorf("invalid updated_at: %w", err)
+	}
+
+	return SourceTransaction{
+		ProviderTransactionID: txn.GetProviderTransactionId(),
+		Email:                 txn.GetEmail(),
+		UserID:                txn.GetUserId(),
+		Provider:              txn.GetProvider(),
+		Amount:                txn.GetAmount(),
+		Currency:              txn.GetCurrency(),
+		Status:                txn.GetStatus(),
+		TransactionType:       txn.GetTransactionType(),
+		PaymentMethod:         txn.GetPaymentMethod(),
+		CreatedAt:             createdAt,
+		UpdatedAt:             updatedAt,
+		ProviderReference:     txn.GetProviderReference(),
+		FraudRisk:             txn.GetFraudRisk(),
+		DetailsInvoiceID:      txn.GetDetailsInvoiceId(),
+		DetailsCustomerName:   txn.GetDetailsCustomerName(),
+		DetailsDescription:    txn.GetDetailsDescription(),
+		Fee:                   txn.GetFee(),
+		Net:                   txn.GetNet(),
+		PayoutID:              txn.GetPayoutId(),
+		ReportingCategory:     txn.GetReportingCategory(),
+	}, nil
+}
+
+// systemFromProto converts an uploaded internal transaction
+func systemFromProto(txn *reconcilerpb.SystemTransaction) (SystemTransaction, error) {
+	if txn == nil {
+		return SystemTransaction{}, errors.New("transaction is missing")
+	}
+	if txn.GetTransactionId() == "" {
+		return SystemTransaction{}, errors.New("transaction_id is required")
+	}
+	createdAt, err := timeFromProto(txn.GetCreatedAt())
+	if err != nil {
+		return SystemTransaction{}, fmt.Errorf("invalid created_at: %w", err)
+	}
+	updatedAt, err := timeFromProto(txn.GetUpdatedAt())
+	if err != nil {
+		return SystemTransaction{}, fmt.Errorf("invalid updated_at: %w", err)
+	}
+
+	return SystemTransaction{
+		TransactionID:       txn.GetTransactionId(),
+		UserID:              txn.GetUserId(),
+		Amount:              txn.GetAmount(),
+		Currency:            txn.GetCurrency(),
+		Status:              txn.GetStatus(),
+		PaymentMethod:       txn.GetPaymentMethod(),
+		CreatedAt:           createdAt,
+		UpdatedAt:           updatedAt,
+		ReferenceID:         txn.GetReferenceId(),
+		MetadataOrderID:     txn.GetMetadataOrderId(),
+		MetadataDescription: txn.GetMetadataDescription(),
+	}, nil
+}
+
+// timeFromProto converts a timestamp, an unset timestamp is the zero time
+func timeFromProto(ts *timestamppb.Timestamp) (time.Time, error) {
+	if ts == nil {
+		return time.Time{}, nil
+	}
+	if err := ts.CheckValid(); err != nil {
+		return time.Time{}, err
+	}
+	return ts.AsTime(), nil
+}
+
+// timeToProto converts a time, the zero time is left unset
+func timeToProto(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
+
+// sourceToProto converts a provider transaction for a response
+func sourceToProto(txn *SourceTransaction) *reconcilerpb.SourceTransaction {
+	if txn == nil {
+		return nil
+	}
+	return &reconcilerpb.SourceTransaction{
+		ProviderTransactionId: txn.ProviderTransactionID,
+		Email:                 txn.Email,
+		UserId:                txn.UserID,
+		Provider:              txn.Provider,
+		Amount:                txn.Amount,
+		Currency:              txn.Currency,
+		Status:                txn.Status,
+		TransactionType:       txn.TransactionType,
+		PaymentMethod:         txn.PaymentMethod,
+		CreatedAt:             timeToProto(txn.CreatedAt),
+		UpdatedAt:             timeToProto(txn.UpdatedAt),
+		ProviderReference:     txn.ProviderReference,
+		FraudRisk:             txn.FraudRisk,
+		DetailsInvoiceId:      txn.DetailsInvoiceID,
+		DetailsCustomerName:   txn.DetailsCustomerName,
+		DetailsDescription:    txn.DetailsDescription,
+		Fee:                   txn.Fee,
+		Net:                   txn.Net,
+		PayoutId:              txn.PayoutID,
+		ReportingCategory:     txn.ReportingCategory,
+	}
+}
+
+// systemToProto converts an internal transaction for a response
+func systemToProto(txn *SystemTransaction) *reconcilerpb.SystemTransaction {
+	if txn == nil {
+		return nil
+	}
+	return &reconcilerpb.SystemTransaction{
+		TransactionId:       txn.TransactionID,
+		UserId:              txn.UserID,
+		Amount:              txn.Amount,
+		Currency:            txn.Currency,
+		Status:              txn.Status,
+		PaymentMethod:       txn.PaymentMethod,
+		CreatedAt:           timeToProto(txn.CreatedAt),
+		UpdatedAt:           timeToProto(txn.UpdatedAt),
+		ReferenceId:         txn.ReferenceID,
+		MetadataOrderId:     txn.MetadataOrderID,
+		MetadataDescription: txn.MetadataDescription,
+	}
+}
+
+// mismatchToProto converts a mismatched transaction, discrepancy values keep their JSON type
+func mismatchToProto(mismatch *MismatchedTransaction) *reconcilerpb.MismatchedTransaction {
+	discrepancies := make(map[string]*reconcilerpb.Discrepancy, len(mismatch.Discrepancies))
+	for field, d := range mismatch.Discrepancies {
+		discrepancies[field] = &reconcilerpb.Discrepancy{
+			Source: valueToProto(d.Source),
+			System: valueToProto(d.System),
+		}
+	}
+	return &reconcilerpb.MismatchedTransaction{
+		TransactionId: mismatch.TransactionID,
+		Discrepancies: discrepancies,
+		Source:        sourceToProto(mismatch.Source),
+		System:        systemToProto(mismatch.System),
+	}
+}
+
+// valueToProto converts a discrepancy value, falling back to its string form for types protobuf has no value for
+func valueToProto(v interface{}) *structpb.Value {
+	value, err := structpb.NewValue(v)
+	if err != nil {
+		return structpb.NewStringValue(fmt.Sprint(v))
+	}
+	return value
+}
+
+// summaryToProto converts the reconciliation statistics
+func summaryToProto(summary ReconciliationSummary) *reconcilerpb.ReconciliationSummary {
+	return &reconcilerpb.ReconciliationSummary{
+		TotalSourceTransactions:     int64(summary.TotalSourceTransactions),
+		TotalSystemTransactions:     int64(summary.TotalSystemTransactions),
+		MissingInInternalCount:      int64(summary.MissingInInternalCount),
+		MissingInSourceCount:        int64(summary.MissingInSourceCount),
+		MismatchedTransactionsCount: int64(summary.MismatchedTransactionsCount),
+		SuccessfullyMatchedCount:    int64(summary.SuccessfullyMatchedCount),
+	}
+}
+
+// resultToProto converts a complete reconciliation result
+func resultToProto(result *ReconciliationResult) *reconcilerpb.ReconciliationResult {
+	out := &reconcilerpb.ReconciliationResult{
+		MissingInInternal:      make([]*reconcilerpb.SourceTransaction, len(result.MissingInInternal)),
+		MissingInSource:        make([]*reconcilerpb.SystemTransaction, len(result.MissingInSource)),
+		MismatchedTransactions: make([]*reconcilerpb.MismatchedTransaction, len(result.MismatchedTransactions)),
+		Summary:                summaryToProto(result.Summary),
+	}
+	for i := range result.MissingInInternal {
+		out.MissingInInternal[i] = sourceToProto(&result.MissingInInternal[i])
+	}
+	for i := range result.MissingInSource {
+		out.MissingInSource[i] = systemToProto(&result.MissingInSource[i])
+	}
+	for i := range result.MismatchedTransactions {
+		out.MismatchedTransactions[i] = mismatchToProto(&result.MismatchedTransactions[i])
+	}
+	return out
+}
+
+// exceptionToProto converts an exception found by ReconcileStream
+func exceptionToProto(exception Exception) *reconcilerpb.ReconciliationException {
+	switch exception.Kind {
+	case ExceptionMissingInInternal:
+		return &reconcilerpb.ReconciliationException{
+			Exception: &reconcilerpb.ReconciliationException_MissingInInternal{MissingInInternal: sourceToProto(exception.Source)},
+		}
+	case ExceptionMissingInSource:
+		return &reconcilerpb.ReconciliationException{
+			Exception: &reconcilerpb.ReconciliationException_MissingInSource{MissingInSource: systemToProto(exception.System)},
+		}
+	default:
+		return &reconcilerpb.ReconciliationException{
+			Exception: &reconcilerpb.ReconciliationException_Mismatched{Mismatched: mismatchToProto(exception.Mismatch)},
+		}
+	}
+}
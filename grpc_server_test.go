@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/devhindo/TransactionReconcilerService/reconcilerpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// startGRPC serves the gRPC service on an in-memory listener and returns a client for it
+func startGRPC(t *testing.T, service *TransactionReconciliationService, limits GRPCLimits) reconcilerpb.ReconcilerClient {
+	t.Helper()
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer(grpcServerOptions()...)
+	NewGRPCServer(service, limits).Register(server)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return reconcilerpb.NewReconcilerClient(conn)
+}
+
+// uploadSample streams both sides into a new session and returns its ID
+func uploadSample(t *testing.T, ctx context.Context, client reconcilerpb.ReconcilerClient, source []SourceTransaction, system []SystemTransaction) string {
+	t.Helper()
+	sourceStream, err := client.UploadSourceTransactions(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range source {
+		if err := sourceStream.Send(&reconcilerpb.UploadSourceTransactionsRequest{Transaction: sourceToProto(&source[i])}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	uploaded, err := sourceStream.CloseAndRecv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uploaded.GetReceived() != int64(len(source)) || uploaded.GetSessionId() == "" {
+		t.Fatalf("source upload = %v, want %d transactions in a new session", uploaded, len(source))
+	}
+
+	systemStream, err := client.UploadSystemTransactions(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range system {
+		req := &reconcilerpb.UploadSystemTransactionsRequest{Transaction: systemToProto(&system[i])}
+		if i == 0 {
+			req.SessionId = uploaded.GetSessionId()
+		}
+		if err := systemStream.Send(req); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if total, err := systemStream.CloseAndRecv(); err != nil || total.GetTotal() != int64(len(system)) {
+		t.Fatalf("system upload = %v, %v, want %d transactions", total, err, len(system))
+	}
+	return uploaded.GetSessionId()
+}
+
+func TestGRPCServerReconcilesUploadedSession(t *testing.T) {
+	service := NewTransactionReconciliationService()
+	source, err := service.csvReader.ReadSourceTransactions("assets/data/csvs/source_transactions.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	system, err := service.csvReader.ReadSystemTransactions("assets/data/csvs/system_transactions.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := service.reconciler.Reconcile(context.Background(), source, system)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	client := startGRPC(t, service, GRPCLimits{})
+	sessionID := uploadSample(t, ctx, client, source, system)
+
+	result, err := client.Reconcile(ctx, &reconcilerpb.ReconcileRequest{SessionId: sessionID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := result.GetSummary(); got.GetSuccessfullyMatchedCount() != int64(want.Summary.SuccessfullyMatchedCount) ||
+		got.GetMismatchedTransactionsCount() != int64(want.Summary.MismatchedTransactionsCount) ||
+		len(result.GetMissingInInternal()) != len(want.MissingInInternal) || len(result.GetMissingInSource()) != len(want.MissingInSource) {
+		t.Errorf("summary = %v, want %+v", got, want.Summary)
+	}
+
+	stream, err := client.StreamExceptions(ctx, &reconcilerpb.ReconcileRequest{SessionId: sessionID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var exceptions int
+	var summary *reconcilerpb.ReconciliationSummary
+	for {
+		msg, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if summary != nil {
+			t.Fatal("message received after the summary")
+		}
+		if summary = msg.GetSummary(); summary == nil {
+			exceptions++
+		}
+	}
+	wantExceptions := len(want.MissingInInternal) + len(want.MissingInSource) + len(want.MismatchedTransactions)
+	if exceptions != wantExceptions || summary == nil {
+		t.Errorf("streamed %d exceptions and summary %v, want %d and a summary", exceptions, summary, wantExceptions)
+	}
+}
+
+func TestGRPCSourceTransactionRoundTrip(t *testing.T) {
+	txn := SourceTransaction{
+		ProviderTransactionID: "txn-1",
+		Amount:                100,
+		Currency:              "USD",
+		CreatedAt:             time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC),
+		Fee:                   3.2,
+		Net:                   96.8,
+		PayoutID:              "po_1",
+		ReportingCategory:     "charge",
+	}
+	got, err := sourceFromProto(sourceToProto(&txn))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != txn {
+		t.Errorf("round trip = %+v, want %+v", got, txn)
+	}
+}
+
+func TestGRPCServerLimits(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	client := startGRPC(t, NewTransactionReconciliationService(), GRPCLimits{MaxSession: 3, MaxSessions: 2, MaxStored: 5})
+
+	upload := func(sessionID string, n int) (*reconcilerpb.UploadResponse, error) {
+		stream, err := client.UploadSystemTransactions(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for i := range n {
+			req := &reconcilerpb.UploadSystemTransactionsRequest{SessionId: sessionID, Transaction: &reconcilerpb.SystemTransaction{TransactionId: "txn-" + string(rune('a'+i))}}
+			if err := stream.Send(req); err != nil {
+				break // the server ended the stream, its status comes with CloseAndRecv
+			}
+		}
+		return stream.CloseAndRecv()
+	}
+
+	if _, err := upload("", 4); status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("uploading 4 transactions to a session of 3: %v, want %s", err, codes.ResourceExhausted)
+	}
+	first, err := upload("", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := upload(first.GetSessionId(), 2); status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("going over the session size in a second upload: %v, want %s", err, codes.ResourceExhausted)
+	}
+	if last, err := upload(first.GetSessionId(), 1); err != nil || last.GetTotal() != 3 {
+		t.Errorf("filling the session = %v, %v, want 3 transactions", last, err)
+	}
+
+	// The failed uploads gave back what they reserved, 3 of the 5 transactions held by the server are used
+	second, err := upload("", 2)
+	if err != nil {
+		t.Fatalf("uploading into a second session: %v", err)
+	}
+	if _, err := upload(second.GetSessionId(), 1); status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("going over the transactions held across sessions: %v, want %s", err, codes.ResourceExhausted)
+	}
+	if _, err := upload("", 0); status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("opening a third session: %v, want %s", err, codes.ResourceExhausted)
+	}
+
+	stream, err := client.UploadSystemTransactions(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	large := &reconcilerpb.SystemTransaction{TransactionId: "txn-large", MetadataDescription: strings.Repeat("x", maxGRPCMessageSize)}
+	stream.Send(&reconcilerpb.UploadSystemTransactionsRequest{Transaction: large})
+	if _, err := stream.CloseAndRecv(); status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("message over %d bytes: %v, want %s", maxGRPCMessageSize, err, codes.ResourceExhausted)
+	}
+}
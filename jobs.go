@@ -0,0 +1,601 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// JobStatus is the state of a reconciliation job
+type JobStatus string
+
+const (
+	JobQueued    JobStatus = "queued"
+	JobRunning   JobStatus = "running"
+	JobSucceeded JobStatus = "succeeded"
+	JobFailed    JobStatus = "failed"
+	JobCancelled JobStatus = "cancelled"
+)
+
+var (
+	ErrJobNotFound    = errors.New("job not found")
+	ErrJobFinished    = errors.New("job has already finished")
+	ErrJobNoResult    = errors.New("job has no result")
+	ErrQueueFull      = errors.New("job queue is full")
+	ErrQueueNotActive = errors.New("job queue is not running")
+)
+
+// ReconciliationJob is a reconciliation submitted to the job queue
+type ReconciliationJob struct {
+	ID          string                 `json:"id"`
+	Status      JobStatus              `json:"status"`
+	SourcePath  string                 `json:"sourcePath"`
+	SystemPath  string                 `json:"systemPath"`
+	SubmittedBy string                 `json:"submittedBy,omitempty"` // the authenticated user, empty for an anonymous caller
+	CreatedAt   time.Time              `json:"createdAt"`
+	StartedAt   time.Time              `json:"startedAt,omitzero"`
+	FinishedAt  time.Time              `json:"finishedAt,omitzero"`
+	Attempts    int                    `json:"attempts"`
+	MaxAttempts int                    `json:"maxAttempts"`
+	NextRetryAt time.Time              `json:"nextRetryAt,omitzero"`
+	Progress    Progress               `json:"progress"`
+	Error       string                 `json:"error,omitempty"`
+	Summary     *ReconciliationSummary `json:"summary,omitempty"`
+}
+
+// IsFinished reports whether the job reached a final state
+func (j *ReconciliationJob) IsFinished() bool {
+	return j.Status == JobSucceeded || j.Status == JobFailed || j.Status == JobCancelled
+}
+
+// Job event types pushed to subscribers
+const (
+	JobEventStatus   = "status"   // the job changed state
+	JobEventProgress = "progress" // the job moved to another phase or parsed and compared more rows
+)
+
+// JobEvent is pushed to the subscribers of a job as it runs
+type JobEvent struct {
+	Type string            `json:"type"`
+	Job  ReconciliationJob `json:"job"`
+}
+
+// progressEventInterval limits how often progress events are pushed within a phase
+const progressEventInterval = 200 * time.Millisecond
+
+// JobQueueConfig configures the job queue
+type JobQueueConfig struct {
+	Dir          string        // job records and results are kept here so they survive restarts
+	Workers      int           // number of jobs running at the same time
+	MaxAttempts  int           // attempts per job when the input fails transiently
+	RetryBackoff time.Duration // wait before the first retry, doubled on every further attempt
+	QueueSize    int           // jobs that can wait for a worker before Submit fails
+}
+
+// JobQueue runs reconciliation jobs on a pool of workers and persists every job record
+type JobQueue struct {
+	service *TransactionReconciliationService
+	config  JobQueueConfig
+
+	// OnSuccess, when set, is called after a job succeeds and its result is stored
+	OnSuccess func(job ReconciliationJob, result *ReconciliationResult)
+
+	mu      sync.RWMutex
+	jobs    map[string]*ReconciliationJob
+	results map[string]*ReconciliationResult
+	cancels map[string]context.CancelFunc
+	stopped map[string]bool // running jobs Cancel was called for, told apart from those stopped by a shutdown
+	watches map[string]map[chan JobEvent]struct{}
+	pending chan string
+	running bool
+	wg      sync.WaitGroup
+	seq     uint64 // numbers the snapshots of job records in the order they were taken
+
+	writeMu sync.Mutex        // orders the writes of job records, some being made outside mu
+	written map[string]uint64 // by job, the snapshot last written
+	write   func(path string, data []byte) error
+}
+
+// NewJobQueue loads the job records from the queue directory. Jobs that were queued or
+// running when the process stopped are queued again once Start is called.
+func NewJobQueue(service *TransactionReconciliationService, config JobQueueConfig) (*JobQueue, error) {
+	if config.Workers <= 0 {
+		config.Workers = 1
+	}
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = 1
+	}
+	if config.RetryBackoff <= 0 {
+		config.RetryBackoff = time.Second
+	}
+	if config.QueueSize <= 0 {
+		config.QueueSize = 1000
+	}
+	if err := os.MkdirAll(config.Dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create job directory: %w", err)
+	}
+
+	q := &JobQueue{
+		service: service,
+		config:  config,
+		jobs:    make(map[string]*ReconciliationJob),
+		results: make(map[string]*ReconciliationResult),
+		cancels: make(map[string]context.CancelFunc),
+		stopped: make(map[string]bool),
+		watches: make(map[string]map[chan JobEvent]struct{}),
+		pending: make(chan string, config.QueueSize),
+		written: make(map[string]uint64),
+		write:   writeFileAtomic,
+	}
+
+	paths, err := filepath.Glob(filepath.Join(config.Dir, "*.job.json"))
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read job record: %w", err)
+		}
+		var job ReconciliationJob
+		if err := json.Unmarshal(data, &job); err != nil {
+			return nil, fmt.Errorf("failed to parse job record %s: %w", path, err)
+		}
+		q.jobs[job.ID] = &job
+	}
+
+	return q, nil
+}
+
+// Start launches the workers and requeues the jobs interrupted by the last shutdown. The workers
+// stop when ctx is done, running jobs are cancelled and put back in the queue for the next start.
+func (q *JobQueue) Start(ctx context.Context) {
+	q.mu.Lock()
+	q.running = true
+
+	var interrupted []*ReconciliationJob
+	for _, job := range q.jobs {
+		if !job.IsFinished() {
+			interrupted = append(interrupted, job)
+		}
+	}
+	sort.Slice(interrupted, func(i, j int) bool { return interrupted[i].CreatedAt.Before(interrupted[j].CreatedAt) })
+	for _, job := range interrupted {
+		if job.Status == JobRunning {
+			log.Printf("Requeueing job %s interrupted by the last shutdown", job.ID)
+		}
+		job.Status = JobQueued
+		q.persist(job)
+	}
+	q.mu.Unlock()
+
+	go func() {
+		for _, job := range interrupted {
+			q.pending <- job.ID
+		}
+	}()
+
+	for i := 0; i < q.config.Workers; i++ {
+		q.wg.Add(1)
+		go q.worker(ctx)
+	}
+
+	go func() {
+		<-ctx.Done()
+		q.mu.Lock()
+		q.running = false
+		q.mu.Unlock()
+	}()
+}
+
+// Wait blocks until every worker has stopped
+func (q *JobQueue) Wait() {
+	q.wg.Wait()
+}
+
+// Submit queues a reconciliation of the given files on behalf of the user, empty when anonymous
+func (q *JobQueue) Submit(sourcePath, systemPath, user string) (ReconciliationJob, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if !q.running {
+		return ReconciliationJob{}, ErrQueueNotActive
+	}
+
+	job := &ReconciliationJob{
+		ID:          newJobID(),
+		Status:      JobQueued,
+		SourcePath:  sourcePath,
+		SystemPath:  systemPath,
+		SubmittedBy: user,
+		CreatedAt:   time.Now().UTC(),
+		MaxAttempts: q.config.MaxAttempts,
+	}
+
+	select {
+	case q.pending <- job.ID:
+	default:
+		return ReconciliationJob{}, ErrQueueFull
+	}
+
+	q.jobs[job.ID] = job
+	if err := q.persist(job); err != nil {
+		return ReconciliationJob{}, err
+	}
+	return *job, nil
+}
+
+// Cancel stops a queued or running job
+func (q *JobQueue) Cancel(id string) (ReconciliationJob, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	job, ok := q.jobs[id]
+	if !ok {
+		return ReconciliationJob{}, fmt.Errorf("%w: %s", ErrJobNotFound, id)
+	}
+	if job.IsFinished() {
+		return *job, fmt.Errorf("%w: %s is %s", ErrJobFinished, id, job.Status)
+	}
+
+	if cancel, ok := q.cancels[id]; ok {
+		// The worker records the cancellation once the job has stopped
+		q.stopped[id] = true
+		cancel()
+		return *job, nil
+	}
+
+	job.Status = JobCancelled
+	job.FinishedAt = time.Now().UTC()
+	q.persist(job)
+	q.publish(job, JobEventStatus)
+	return *job, nil
+}
+
+// Subscribe returns the current state of a job and a channel receiving its events. The channel
+// is closed once the job finishes; events are dropped for subscribers that do not keep up, so
+// the final state should be read with Get after the channel is closed. unsubscribe must be called
+// when the caller stops listening.
+func (q *JobQueue) Subscribe(id string) (ReconciliationJob, <-chan JobEvent, func(), error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	job, ok := q.jobs[id]
+	if !ok {
+		return ReconciliationJob{}, nil, nil, fmt.Errorf("%w: %s", ErrJobNotFound, id)
+	}
+
+	events := make(chan JobEvent, 64)
+	if job.IsFinished() {
+		close(events)
+		return *job, events, func() {}, nil
+	}
+
+	if q.watches[id] == nil {
+		q.watches[id] = make(map[chan JobEvent]struct{})
+	}
+	q.watches[id][events] = struct{}{}
+
+	unsubscribe := func() {
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		if _, ok := q.watches[id][events]; ok {
+			delete(q.watches[id], events)
+			close(events)
+		}
+	}
+	return *job, events, unsubscribe, nil
+}
+
+// publish pushes an event to the subscribers of the job and closes their channels once the
+// job has finished, callers must hold the lock
+func (q *JobQueue) publish(job *ReconciliationJob, eventType string) {
+	event := JobEvent{Type: eventType, Job: *job}
+	for events := range q.watches[job.ID] {
+		select {
+		case events <- event:
+		default:
+		}
+		if job.IsFinished() {
+			close(events)
+		}
+	}
+	if job.IsFinished() {
+		delete(q.watches, job.ID)
+	}
+}
+
+// Get returns a copy of the job with the given ID
+func (q *JobQueue) Get(id string) (ReconciliationJob, error) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	job, ok := q.jobs[id]
+	if !ok {
+		return ReconciliationJob{}, fmt.Errorf("%w: %s", ErrJobNotFound, id)
+	}
+	return *job, nil
+}
+
+// List returns every job, newest first
+func (q *JobQueue) List() []ReconciliationJob {
+	q.mu.RLock()
+	jobs := make([]ReconciliationJob, 0, len(q.jobs))
+	for _, job := range q.jobs {
+		jobs = append(jobs, *job)
+	}
+	q.mu.RUnlock()
+
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].CreatedAt.After(jobs[j].CreatedAt) })
+	return jobs
+}
+
+// Result returns the result of a succeeded job, loading it from disk after a restart
+func (q *JobQueue) Result(id string) (*ReconciliationResult, error) {
+	job, err := q.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if job.Status != JobSucceeded {
+		return nil, fmt.Errorf("%w: %s is %s", ErrJobNoResult, id, job.Status)
+	}
+
+	q.mu.RLock()
+	result, ok := q.results[id]
+	q.mu.RUnlock()
+	if ok {
+		return result, nil
+	}
+
+	data, err := os.ReadFile(q.resultPath(id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read job result: %w", err)
+	}
+	result = &ReconciliationResult{}
+	if err := json.Unmarshal(data, result); err != nil {
+		return nil, fmt.Errorf("failed to parse job result: %w", err)
+	}
+
+	q.mu.Lock()
+	q.results[id] = result
+	q.mu.Unlock()
+	return result, nil
+}
+
+// worker runs queued jobs until ctx is done
+func (q *JobQueue) worker(ctx context.Context) {
+	defer q.wg.Done()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case id := <-q.pending:
+			q.run(ctx, id)
+		}
+	}
+}
+
+// run executes one attempt of a job, scheduling a retry when the input failed transiently
+func (q *JobQueue) run(ctx context.Context, id string) {
+	q.mu.Lock()
+	job, ok := q.jobs[id]
+	if !ok || job.Status != JobQueued {
+		q.mu.Unlock()
+		return
+	}
+	jobCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	q.cancels[id] = cancel
+	job.Status = JobRunning
+	job.Attempts++
+	job.NextRetryAt = time.Time{}
+	if job.StartedAt.IsZero() {
+		job.StartedAt = time.Now().UTC()
+	}
+	job.Progress = Progress{}
+	sourcePath, systemPath := job.SourcePath, job.SystemPath
+	q.persist(job)
+	q.publish(job, JobEventStatus)
+	q.mu.Unlock()
+
+	lastPersist, lastPublish := time.Now(), time.Now()
+	result, err := q.service.ProcessReconciliationContext(jobCtx, sourcePath, systemPath, func(p Progress) {
+		var snapshot ReconciliationJob
+		var seq uint64
+
+		q.mu.Lock()
+		phaseChanged := job.Progress.Phase != p.Phase
+		job.Progress = p
+		// Progress is persisted on phase changes and at most once a second, outside the lock so that
+		// the queue is not held up by the disk
+		if phaseChanged || time.Since(lastPersist) > time.Second {
+			q.seq++
+			snapshot, seq = *job, q.seq
+			lastPersist = time.Now()
+		}
+		if phaseChanged || time.Since(lastPublish) > progressEventInterval {
+			q.publish(job, JobEventProgress)
+			lastPublish = time.Now()
+		}
+		q.mu.Unlock()
+
+		if seq != 0 {
+			q.save(snapshot, seq)
+		}
+	})
+
+	if err == nil {
+		q.setPhase(job, PhaseWritingReport)
+		err = q.storeResult(id, result)
+	}
+
+	q.mu.Lock()
+	delete(q.cancels, id)
+	stopped := q.stopped[id]
+	delete(q.stopped, id)
+
+	switch {
+	case err == nil:
+		job.Status = JobSucceeded
+		job.Summary = &result.Summary
+		job.Progress.Phase = PhaseDone
+		job.Progress.Percent = 100
+		job.FinishedAt = time.Now().UTC()
+		job.Error = ""
+		q.results[id] = result
+
+	case stopped:
+		job.Status = JobCancelled
+		job.Error = "cancelled"
+		job.FinishedAt = time.Now().UTC()
+
+	case ctx.Err() != nil:
+		// The queue is shutting down, the job runs again on the next start
+		job.Status = JobQueued
+		job.Attempts--
+		q.persist(job)
+		q.publish(job, JobEventStatus)
+		q.mu.Unlock()
+		return
+
+	case isTransientInputError(err) && job.Attempts < job.MaxAttempts:
+		backoff := q.config.RetryBackoff << (job.Attempts - 1)
+		job.Status = JobQueued
+		job.Error = err.Error()
+		job.NextRetryAt = time.Now().UTC().Add(backoff)
+		log.Printf("Job %s failed transiently (attempt %d of %d), retrying in %s: %v", id, job.Attempts, job.MaxAttempts, backoff, err)
+		q.persist(job)
+		q.publish(job, JobEventStatus)
+		q.mu.Unlock()
+		q.retryAfter(ctx, id, backoff)
+		return
+
+	default:
+		job.Status = JobFailed
+		job.Error = err.Error()
+		job.FinishedAt = time.Now().UTC()
+		log.Printf("Job %s failed: %v", id, err)
+	}
+
+	q.persist(job)
+	q.publish(job, JobEventStatus)
+	snapshot := *job
+	q.mu.Unlock()
+
+	if snapshot.Status == JobSucceeded && q.OnSuccess != nil {
+		q.OnSuccess(snapshot, result)
+	}
+}
+
+// retryAfter puts the job back in the queue once the backoff has passed
+func (q *JobQueue) retryAfter(ctx context.Context, id string, backoff time.Duration) {
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-time.After(backoff):
+			q.pending <- id
+		}
+	}()
+}
+
+// setPhase records a phase change of a running job
+func (q *JobQueue) setPhase(job *ReconciliationJob, phase string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	job.Progress.Phase = phase
+	q.persist(job)
+	q.publish(job, JobEventProgress)
+}
+
+// storeResult writes the result of a job next to its record
+func (q *JobQueue) storeResult(id string, result *ReconciliationResult) error {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("failed to marshal job result: %w", err)
+	}
+	return writeFileAtomic(q.resultPath(id), data)
+}
+
+// persist writes the job record, callers must hold the lock
+func (q *JobQueue) persist(job *ReconciliationJob) error {
+	q.seq++
+	return q.save(*job, q.seq)
+}
+
+// save writes a snapshot of a job record taken under the lock, unless a later snapshot of the job was
+// written already, so that a record written outside the lock never goes back in time. A failure is
+// logged, the in-memory state staying authoritative until the next successful write.
+func (q *JobQueue) save(job ReconciliationJob, seq uint64) error {
+	q.writeMu.Lock()
+	defer q.writeMu.Unlock()
+	if seq < q.written[job.ID] {
+		return nil
+	}
+	q.written[job.ID] = seq
+
+	data, err := json.MarshalIndent(job, "", "  ")
+	if err == nil {
+		err = q.write(filepath.Join(q.config.Dir, job.ID+".job.json"), data)
+	}
+	if err != nil {
+		log.Printf("Warning: could not persist job %s: %v", job.ID, err)
+	}
+	return err
+}
+
+// resultPath is where the result of a job is stored
+func (q *JobQueue) resultPath(id string) string {
+	return filepath.Join(q.config.Dir, id+".result.json")
+}
+
+// writeFileAtomic replaces path with data through a temporary file in the same directory
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+"-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	// CreateTemp makes the file private, the replaced file is readable like any other output
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
+
+// isTransientInputError reports whether reading the input failed in a way worth retrying, such as a
+// busy or stale network file system. A truncated file is not, reading it again gives the same result.
+func isTransientInputError(err error) bool {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	for _, errno := range []syscall.Errno{syscall.EAGAIN, syscall.EBUSY, syscall.EINTR, syscall.ETIMEDOUT, syscall.ESTALE, syscall.EIO} {
+		if errors.Is(err, errno) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestIsTransientInputError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&fs.PathError{Op: "read", Path: "source.csv", Err: syscall.ESTALE}, true},
+		{&fs.PathError{Op: "open", Path: "source.csv", Err: syscall.EBUSY}, true},
+		{fmt.Errorf("failed to read source transactions: %w", syscall.EIO), true},
+		{fmt.Errorf("truncated source transactions: %w", io.ErrUnexpectedEOF), false},
+		{&fs.PathError{Op: "open", Path: "source.csv", Err: fs.ErrNotExist}, false},
+		{errors.New("record on line 3: wrong number of fields"), false},
+	}
+	for _, tt := range tests {
+		if got := isTransientInputError(tt.err); got != tt.want {
+			t.Errorf("isTransientInputError(%v) = %t, want %t", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestJobQueueCancelDuringShutdownCancelsJob(t *testing.T) {
+	sourcePath, systemPath, err := writeBenchDataset(t.TempDir(), 200000, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	queue, err := NewJobQueue(NewTransactionReconciliationService(), JobQueueConfig{Dir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, shutdown := context.WithCancel(context.Background())
+	defer shutdown()
+	queue.Start(ctx)
+
+	job, err := queue.Submit(sourcePath, systemPath, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for deadline := time.Now().Add(10 * time.Second); ; time.Sleep(time.Millisecond) {
+		if job, _ = queue.Get(job.ID); job.Status == JobRunning || time.Now().After(deadline) {
+			break
+		}
+	}
+	if job.Status != JobRunning {
+		t.Fatalf("job is %s, want it running", job.Status)
+	}
+
+	if _, err := queue.Cancel(job.ID); err != nil {
+		t.Fatal(err)
+	}
+	shutdown()
+	queue.Wait()
+
+	if job, _ = queue.Get(job.ID); job.Status != JobCancelled && job.Status != JobSucceeded {
+		t.Errorf("job cancelled during shutdown is %s, want %s", job.Status, JobCancelled)
+	}
+
+	// The next start must not pick it up again
+	reopened, err := NewJobQueue(NewTransactionReconciliationService(), JobQueueConfig{Dir: queue.config.Dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := reopened.Get(job.ID); got.Status == JobQueued {
+		t.Errorf("job cancelled during shutdown is queued again after a restart")
+	}
+}
+
+func TestJobQueueProgressWriteDoesNotBlockQueue(t *testing.T) {
+	sourcePath, systemPath, err := writeBenchDataset(t.TempDir(), 20000, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	queue, err := NewJobQueue(NewTransactionReconciliationService(), JobQueueConfig{Dir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The first progress record written hangs until released, standing for a slow disk
+	blocked, release := make(chan struct{}), make(chan struct{})
+	var once sync.Once
+	queue.write = func(path string, data []byte) error {
+		var job ReconciliationJob
+		if err := json.Unmarshal(data, &job); err == nil && job.Progress.Phase == PhaseReadingSource {
+			once.Do(func() {
+				close(blocked)
+				<-release
+			})
+		}
+		return writeFileAtomic(path, data)
+	}
+	ctx, shutdown := context.WithCancel(context.Background())
+	defer shutdown()
+	queue.Start(ctx)
+
+	job, err := queue.Submit(sourcePath, systemPath, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-blocked:
+	case <-time.After(10 * time.Second):
+		t.Fatal("no progress record written")
+	}
+
+	listed := make(chan struct{})
+	go func() {
+		queue.List()
+		queue.Get(job.ID)
+		close(listed)
+	}()
+	select {
+	case <-listed:
+	case <-time.After(5 * time.Second):
+		t.Error("the queue is locked while a progress record is written")
+	}
+	close(release)
+
+	for deadline := time.Now().Add(10 * time.Second); !job.IsFinished() && time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		job, _ = queue.Get(job.ID)
+	}
+	if job.Status != JobSucceeded {
+		t.Fatalf("job is %s, want %s", job.Status, JobSucceeded)
+	}
+	// The late progress record did not overwrite the final one
+	reopened, err := NewJobQueue(NewTransactionReconciliationService(), JobQueueConfig{Dir: queue.config.Dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := reopened.Get(job.ID); got.Status != JobSucceeded {
+		t.Errorf("job record is %s on disk, want %s", got.Status, JobSucceeded)
+	}
+}
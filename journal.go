@@ -0,0 +1,286 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/devhindo/TransactionReconcilerService/reconcile"
+)
+
+// Journal rules, each one maps to a debit/credit account pair in the chart of accounts
+const (
+	JournalRuleMissingCharge    = "missing_charge"    // the provider charged, the internal system has no record
+	JournalRuleUnknownCharge    = "unknown_charge"    // the internal system booked a charge the provider never made
+	JournalRuleAmountDifference = "amount_difference" // both sides have the transaction with different amounts
+	JournalRuleStatusChange     = "status_change"     // the provider settled a transaction the internal system did not, or the other way round
+	JournalRuleWriteOff         = "write_off"         // the amount at stake is written off
+	JournalRuleForceMatch       = "force_match"       // the amount difference of a forced match is parked in suspense
+)
+
+// AccountMapping is the account pair a journal rule posts to, positive amounts debit Debit and credit Credit
+type AccountMapping struct {
+	Debit  string `json:"debit"`
+	Credit string `json:"credit"`
+}
+
+// ChartOfAccounts maps every journal rule to the accounts it posts to
+type ChartOfAccounts map[string]AccountMapping
+
+// DefaultChartOfAccounts is used when no chart of accounts file is configured
+func DefaultChartOfAccounts() ChartOfAccounts {
+	return ChartOfAccounts{
+		JournalRuleMissingCharge:    {Debit: "1210-provider-clearing", Credit: "4000-revenue"},
+		JournalRuleUnknownCharge:    {Debit: "4000-revenue", Credit: "1210-provider-clearing"},
+		JournalRuleAmountDifference: {Debit: "1210-provider-clearing", Credit: "1999-suspense"},
+		JournalRuleStatusChange:     {Debit: "1210-provider-clearing", Credit: "4000-revenue"},
+		JournalRuleWriteOff:         {Debit: "6900-write-offs", Credit: "1210-provider-clearing"},
+		JournalRuleForceMatch:       {Debit: "1999-suspense", Credit: "1210-provider-clearing"},
+	}
+}
+
+// LoadChartOfAccounts reads a chart of accounts JSON file, rules it leaves out keep their default accounts
+func LoadChartOfAccounts(path string) (ChartOfAccounts, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read chart of accounts: %w", err)
+	}
+
+	var overrides ChartOfAccounts
+	if err := json.Unmarshal(data, &overrides); err != nil {
+		return nil, fmt.Errorf("failed to parse chart of accounts: %w", err)
+	}
+
+	accounts := DefaultChartOfAccounts()
+	for rule, mapping := range overrides {
+		if mapping.Debit == "" || mapping.Credit == "" {
+			return nil, fmt.Errorf("chart of accounts rule %q needs both a debit and a credit account", rule)
+		}
+		accounts[rule] = mapping
+	}
+
+	return accounts, nil
+}
+
+// JournalLine is one debit or credit posting of a journal entry
+type JournalLine struct {
+	Account string  `json:"account"`
+	Debit   float64 `json:"debit"`
+	Credit  float64 `json:"credit"`
+	Memo    string  `json:"memo,omitempty"`
+}
+
+// JournalEntry is a balanced double-entry adjustment generated from a resolved case
+type JournalEntry struct {
+	EntryID       string        `json:"entryId"`
+	Date          time.Time     `json:"date"`
+	CaseID        string        `json:"caseId"`
+	TransactionID string        `json:"transactionId"`
+	Rule          string        `json:"rule"`
+	Currency      string        `json:"currency"`
+	Description   string        `json:"description"`
+	Lines         []JournalLine `json:"lines"`
+}
+
+// ManualAdjustment is a closed case the generator cannot book, left to an accountant
+type ManualAdjustment struct {
+	CaseID        string `json:"caseId"`
+	TransactionID string `json:"transactionId"`
+	Reason        string `json:"reason"`
+}
+
+// JournalGenerator turns resolved cases into adjustment journal entries
+type JournalGenerator struct {
+	accounts ChartOfAccounts
+}
+
+// NewJournalGenerator creates a generator posting to the given chart of accounts
+func NewJournalGenerator(accounts ChartOfAccounts) *JournalGenerator {
+	return &JournalGenerator{accounts: accounts}
+}
+
+// Generate builds the journal entries for the cases that were resolved in a way that needs booking.
+// Open cases and cases where the internal system was right produce no entries. Cases whose two sides
+// are in different currencies have no amount that could be booked without a rate, they are returned
+// for a manual entry instead.
+func (g *JournalGenerator) Generate(cases []Case) ([]JournalEntry, []ManualAdjustment, error) {
+	var entries []JournalEntry
+	var manual []ManualAdjustment
+
+	for _, c := range cases {
+		if !c.IsClosed() {
+			continue
+		}
+
+		rule, amount := g.ruleFor(c)
+		if rule == "" {
+			continue
+		}
+		if reason := needsManualEntry(c); reason != "" {
+			manual = append(manual, ManualAdjustment{CaseID: c.ID, TransactionID: c.TransactionID, Reason: reason})
+			continue
+		}
+		if roundCents(amount) == 0 {
+			continue
+		}
+
+		mapping, ok := g.accounts[rule]
+		if !ok {
+			return nil, nil, fmt.Errorf("no accounts configured for journal rule %q (case %s)", rule, c.ID)
+		}
+
+		// A negative amount reverses the direction of the mapping
+		debit, credit := mapping.Debit, mapping.Credit
+		if amount < 0 {
+			debit, credit = credit, debit
+			amount = -amount
+		}
+		amount = roundCents(amount)
+
+		memo := fmt.Sprintf("%s %s", c.Kind, c.TransactionID)
+		entry := JournalEntry{
+			EntryID:       "JE-" + strings.TrimPrefix(c.ID, "CASE-"),
+			Date:          resolvedAt(c),
+			CaseID:        c.ID,
+			TransactionID: c.TransactionID,
+			Rule:          rule,
+			Currency:      c.Currency,
+			Description:   fmt.Sprintf("Reconciliation adjustment for %s (%s)", c.TransactionID, c.Resolution),
+			Lines: []JournalLine{
+				{Account: debit, Debit: amount, Memo: memo},
+				{Account: credit, Credit: amount, Memo: memo},
+			},
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, manual, nil
+}
+
+// needsManualEntry tells why the case cannot be booked automatically, empty when it can. Write-offs are
+// no exception, their amount being no difference either when the two sides are in different currencies.
+func needsManualEntry(c Case) string {
+	if c.Source == nil || c.System == nil {
+		return ""
+	}
+	_, differs := c.Discrepancies["currency"]
+	if differs || !strings.EqualFold(c.Source.Currency, c.System.Currency) {
+		return fmt.Sprintf("the provider booked %s and the internal system %s, converting needs a rate", c.Source.Currency, c.System.Currency)
+	}
+	return ""
+}
+
+// ruleFor picks the journal rule for a closed case and the signed amount to post
+func (g *JournalGenerator) ruleFor(c Case) (string, float64) {
+	switch c.Resolution {
+	case ResolutionWriteOff:
+		return JournalRuleWriteOff, c.Amount
+
+	case ResolutionForceMatch:
+		if c.Source != nil && c.System != nil {
+			return JournalRuleForceMatch, c.Source.Amount - c.System.Amount
+		}
+
+	case ResolutionBookSource:
+		switch c.Kind {
+		case ExceptionMissingInInternal:
+			if c.Source != nil {
+				return JournalRuleMissingCharge, c.Source.Amount
+			}
+		case ExceptionMissingInSource:
+			if c.System != nil {
+				return JournalRuleUnknownCharge, c.System.Amount
+			}
+		case ExceptionMismatched:
+			if c.Source == nil || c.System == nil {
+				return "", 0
+			}
+			// A change into or out of the completed state moves the whole amount of the side that is
+			// settled, whatever the amount the other side has, so it takes in an amount difference too
+			if _, ok := c.Discrepancies["status"]; ok {
+				sourceSettled := reconcile.NormalizeStatus(c.Source.Status) == "COMPLETED"
+				systemSettled := reconcile.NormalizeStatus(c.System.Status) == "COMPLETED"
+				switch {
+				case sourceSettled && !systemSettled:
+					return JournalRuleStatusChange, c.Source.Amount
+				case !sourceSettled && systemSettled:
+					return JournalRuleStatusChange, -c.System.Amount
+				}
+			}
+			if _, ok := c.Discrepancies["amount"]; ok {
+				return JournalRuleAmountDifference, c.Source.Amount - c.System.Amount
+			}
+		}
+	}
+
+	return "", 0
+}
+
+// resolvedAt returns when the case was resolved, falling back to its last update
+func resolvedAt(c Case) time.Time {
+	for i := len(c.History) - 1; i >= 0; i-- {
+		if strings.HasPrefix(c.History[i].Action, "resolved:") {
+			return c.History[i].At
+		}
+	}
+	return c.UpdatedAt
+}
+
+// WriteJournalCSV writes the entries as one row per journal line, ready for ledger import
+func WriteJournalCSV(w io.Writer, entries []JournalEntry) error {
+	writer := csv.NewWriter(w)
+
+	header := []string{"entryId", "date", "caseId", "transactionId", "rule", "account", "debit", "credit", "currency", "memo", "description"}
+	if err := writer.Write(header); err != nil {
+		return fmt.Errorf("failed to write journal header: %w", err)
+	}
+
+	for _, entry := range entries {
+		for _, line := range entry.Lines {
+			record := []string{
+				entry.EntryID,
+				entry.Date.Format("2006-01-02"),
+				entry.CaseID,
+				entry.TransactionID,
+				entry.Rule,
+				line.Account,
+				strconv.FormatFloat(line.Debit, 'f', 2, 64),
+				strconv.FormatFloat(line.Credit, 'f', 2, 64),
+				entry.Currency,
+				line.Memo,
+				entry.Description,
+			}
+			if err := writer.Write(record); err != nil {
+				return fmt.Errorf("failed to write journal line: %w", err)
+			}
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// WriteJournalJSON writes the entries as an indented JSON array
+func WriteJournalJSON(w io.Writer, entries []JournalEntry) error {
+	if entries == nil {
+		entries = []JournalEntry{}
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(entries); err != nil {
+		return fmt.Errorf("failed to write journal JSON: %w", err)
+	}
+	return nil
+}
+
+// roundCents rounds an amount to two decimals
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"log"
+	"os"
+)
+
+// runJournal generates adjustment journal entries from the resolved cases
+func runJournal(args []string) {
+	fs := flag.NewFlagSet("journal", flag.ExitOnError)
+	storePath := fs.String("cases", defaultCaseStorePath, "path to the case store")
+	accountsPath := fs.String("accounts", "", "chart of accounts JSON mapping journal rules to debit/credit accounts")
+	format := fs.String("format", "csv", "output format, csv or json")
+	outPath := fs.String("out", "", "file to write the journal to, stdout when empty")
+	fs.Parse(args)
+
+	accounts := DefaultChartOfAccounts()
+	if *accountsPath != "" {
+		var err error
+		accounts, err = LoadChartOfAccounts(*accountsPath)
+		if err != nil {
+			log.Fatalf("Failed to load chart of accounts: %v", err)
+		}
+	}
+
+	store, err := NewCaseStore(*storePath)
+	if err != nil {
+		log.Fatalf("Failed to open case store: %v", err)
+	}
+
+	entries, manual, err := NewJournalGenerator(accounts).Generate(store.List(CaseFilter{}))
+	if err != nil {
+		log.Fatalf("Failed to generate journal: %v", err)
+	}
+	for _, m := range manual {
+		log.Printf("Warning: case %s (%s) needs a manual journal entry: %s", m.CaseID, m.TransactionID, m.Reason)
+	}
+
+	var out io.Writer = os.Stdout
+	if *outPath != "" {
+		file, err := os.Create(*outPath)
+		if err != nil {
+			log.Fatalf("Failed to create journal file: %v", err)
+		}
+		defer file.Close()
+		out = file
+	}
+
+	switch *format {
+	case "csv":
+		err = WriteJournalCSV(out, entries)
+	case "json":
+		err = WriteJournalJSON(out, entries)
+	default:
+		log.Fatalf("Unknown journal format %q, use csv or json", *format)
+	}
+	if err != nil {
+		log.Fatalf("Failed to write journal: %v", err)
+	}
+
+	if *outPath != "" {
+		log.Printf("Wrote %d journal entries to %s", len(entries), *outPath)
+	}
+}
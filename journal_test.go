@@ -0,0 +1,114 @@
+package main
+
+import "testing"
+
+func TestJournalGeneratorGenerate(t *testing.T) {
+	mismatch := func(resolution Resolution, source SourceTransaction, system SystemTransaction, fields ...string) Case {
+		discrepancies := make(map[string]Discrepancy)
+		for _, field := range fields {
+			discrepancies[field] = Discrepancy{}
+		}
+		return Case{
+			ID: "CASE-1", Kind: ExceptionMismatched, TransactionID: "txn-1", Status: CaseStatusResolved,
+			Resolution: resolution, Currency: source.Currency, Source: &source, System: &system, Discrepancies: discrepancies,
+		}
+	}
+
+	withAmount := func(c Case, amount float64) Case {
+		c.Amount = amount
+		return c
+	}
+
+	tests := []struct {
+		name       string
+		c          Case
+		wantRule   string
+		wantDebit  string
+		wantAmount float64
+		wantManual bool
+	}{
+		{
+			name:       "amount difference",
+			c:          mismatch(ResolutionBookSource, SourceTransaction{Amount: 120, Currency: "USD", Status: "completed"}, SystemTransaction{Amount: 100, Currency: "USD", Status: "completed"}, "amount"),
+			wantRule:   JournalRuleAmountDifference,
+			wantDebit:  "1210-provider-clearing",
+			wantAmount: 20,
+		},
+		{
+			name:       "settled by the provider with another amount",
+			c:          mismatch(ResolutionBookSource, SourceTransaction{Amount: 120, Currency: "USD", Status: "completed"}, SystemTransaction{Amount: 100, Currency: "USD", Status: "pending"}, "amount", "status"),
+			wantRule:   JournalRuleStatusChange,
+			wantDebit:  "1210-provider-clearing",
+			wantAmount: 120,
+		},
+		{
+			name:       "settled internally only, with another amount",
+			c:          mismatch(ResolutionBookSource, SourceTransaction{Amount: 120, Currency: "USD", Status: "failed"}, SystemTransaction{Amount: 100, Currency: "USD", Status: "completed"}, "amount", "status"),
+			wantRule:   JournalRuleStatusChange,
+			wantDebit:  "4000-revenue",
+			wantAmount: 100,
+		},
+		{
+			name:       "status difference not moving money, with another amount",
+			c:          mismatch(ResolutionBookSource, SourceTransaction{Amount: 120, Currency: "USD", Status: "pending"}, SystemTransaction{Amount: 100, Currency: "USD", Status: "failed"}, "amount", "status"),
+			wantRule:   JournalRuleAmountDifference,
+			wantDebit:  "1210-provider-clearing",
+			wantAmount: 20,
+		},
+		{
+			name:       "currency difference",
+			c:          mismatch(ResolutionBookSource, SourceTransaction{Amount: 92, Currency: "EUR", Status: "completed"}, SystemTransaction{Amount: 100, Currency: "USD", Status: "completed"}, "amount", "currency"),
+			wantManual: true,
+		},
+		{
+			name:       "forced match across currencies",
+			c:          mismatch(ResolutionForceMatch, SourceTransaction{Amount: 92, Currency: "EUR"}, SystemTransaction{Amount: 100, Currency: "USD"}, "currency"),
+			wantManual: true,
+		},
+		{
+			name:       "write-off",
+			c:          withAmount(mismatch(ResolutionWriteOff, SourceTransaction{Amount: 100.5, Currency: "USD"}, SystemTransaction{Amount: 100, Currency: "USD"}, "amount"), 0.5),
+			wantRule:   JournalRuleWriteOff,
+			wantDebit:  "6900-write-offs",
+			wantAmount: 0.5,
+		},
+		{
+			name:       "write-off across currencies",
+			c:          withAmount(mismatch(ResolutionWriteOff, SourceTransaction{Amount: 92, Currency: "EUR"}, SystemTransaction{Amount: 100, Currency: "USD"}, "amount", "currency"), 92),
+			wantManual: true,
+		},
+		{
+			name: "internal system kept across currencies",
+			c:    mismatch(ResolutionBookSystem, SourceTransaction{Amount: 92, Currency: "EUR"}, SystemTransaction{Amount: 100, Currency: "USD"}, "currency"),
+		},
+	}
+
+	generator := NewJournalGenerator(DefaultChartOfAccounts())
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, manual, err := generator.Generate([]Case{tt.c})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := len(manual) == 1; got != tt.wantManual {
+				t.Fatalf("manual adjustments = %v, want manual %t", manual, tt.wantManual)
+			}
+			if tt.wantRule == "" {
+				if len(entries) != 0 {
+					t.Errorf("entries = %v, want none", entries)
+				}
+				return
+			}
+			if len(entries) != 1 {
+				t.Fatalf("got %d entries, want 1", len(entries))
+			}
+			entry := entries[0]
+			if entry.Rule != tt.wantRule {
+				t.Errorf("rule = %s, want %s", entry.Rule, tt.wantRule)
+			}
+			if debit := entry.Lines[0]; debit.Account != tt.wantDebit || debit.Debit != tt.wantAmount || entry.Lines[1].Credit != tt.wantAmount {
+				t.Errorf("lines = %+v, want %.2f debited to %s", entry.Lines, tt.wantAmount, tt.wantDebit)
+			}
+		})
+	}
+}
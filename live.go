@@ -0,0 +1,174 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// LiveState is where a transaction stands in the continuous reconciliation
+type LiveState string
+
+const (
+	LiveMatched           LiveState = "matched"
+	LiveMismatched        LiveState = "mismatched"
+	LiveMissingInInternal LiveState = "missing_in_internal"
+	LivePending           LiveState = "pending" // only known from a status change, not compared until the full record arrives
+)
+
+var ErrLiveTransactionNotFound = errors.New("transaction not tracked by the live reconciliation")
+
+// SourceUpdate is the change a provider event makes to a source transaction. A status-only update
+// changes the status of a transaction already known, or tracks a partial one until the full record arrives.
+type SourceUpdate struct {
+	Transaction SourceTransaction
+	StatusOnly  bool
+}
+
+// LiveMatch is the current reconciliation state of a transaction updated by provider events
+type LiveMatch struct {
+	TransactionID string             `json:"transactionId"`
+	State         LiveState          `json:"state"`
+	Discrepancies DiscrepancyMap     `json:"discrepancies,omitempty"`
+	Source        SourceTransaction  `json:"source"`
+	System        *SystemTransaction `json:"system,omitempty"`
+	ReconciledAt  time.Time          `json:"reconciledAt"`
+}
+
+// LiveReconciler keeps the latest version of every source transaction received from provider events
+// and reconciles each one against the internal side as soon as it changes
+type LiveReconciler struct {
+	reconciler *TransactionReconciler
+	mu         sync.RWMutex
+	source     map[string]SourceTransaction
+	system     map[string]SystemTransaction
+	matches    map[string]*LiveMatch
+	partial    map[string]bool // only known from a status change so far
+	now        func() time.Time
+}
+
+// NewLiveReconciler creates a live reconciliation with an empty internal side
+func NewLiveReconciler(reconciler *TransactionReconciler) *LiveReconciler {
+	return &LiveReconciler{
+		reconciler: reconciler,
+		source:     make(map[string]SourceTransaction),
+		system:     make(map[string]SystemTransaction),
+		matches:    make(map[string]*LiveMatch),
+		partial:    make(map[string]bool),
+		now:        func() time.Time { return time.Now().UTC() },
+	}
+}
+
+// SetSystemTransactions replaces the internal side and reconciles every tracked transaction against it again
+func (lr *LiveReconciler) SetSystemTransactions(transactions []SystemTransaction) {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+
+	lr.system = make(map[string]SystemTransaction, len(transactions))
+	for _, txn := range transactions {
+		lr.system[txn.TransactionID] = txn
+	}
+	for id := range lr.source {
+		lr.reconcile(id)
+	}
+}
+
+// Apply merges an update into the tracked source transactions and returns the new state of the transaction.
+// Updates older than the version already held are ignored, so events may arrive out of order.
+func (lr *LiveReconciler) Apply(update SourceUpdate) LiveMatch {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+
+	txn := update.Transaction
+	id := txn.ProviderTransactionID
+	existing, known := lr.source[id]
+	switch {
+	case !known:
+		lr.partial[id] = update.StatusOnly
+	case update.StatusOnly:
+		if txn.UpdatedAt.Before(existing.UpdatedAt) {
+			return *lr.matches[id]
+		}
+		existing.Status = txn.Status
+		existing.UpdatedAt = txn.UpdatedAt
+		txn = existing
+	case lr.partial[id]:
+		// The full record arrived after a status change, which stays when it is the newer one
+		if existing.UpdatedAt.After(txn.UpdatedAt) {
+			txn.Status = existing.Status
+			txn.UpdatedAt = existing.UpdatedAt
+		}
+		lr.partial[id] = false
+	case txn.UpdatedAt.Before(existing.UpdatedAt):
+		return *lr.matches[id]
+	}
+
+	lr.source[id] = txn
+	return lr.reconcile(id)
+}
+
+// reconcile compares a source transaction with its internal counterpart, callers must hold the lock.
+// A partial transaction lacks the amount and currency, comparing it would report made-up discrepancies.
+func (lr *LiveReconciler) reconcile(id string) LiveMatch {
+	match := &LiveMatch{
+		TransactionID: id,
+		Source:        lr.source[id],
+		ReconciledAt:  lr.now(),
+	}
+
+	if lr.partial[id] {
+		match.State = LivePending
+	} else if system, ok := lr.system[id]; ok {
+		match.System = &system
+		match.Discrepancies = lr.reconciler.Compare(match.Source, system)
+		match.State = LiveMatched
+		if len(match.Discrepancies) > 0 {
+			match.State = LiveMismatched
+		} else {
+			match.Discrepancies = nil
+		}
+	} else {
+		match.State = LiveMissingInInternal
+	}
+
+	lr.matches[id] = match
+	return *match
+}
+
+// Get returns the current state of a tracked transaction
+func (lr *LiveReconciler) Get(id string) (LiveMatch, error) {
+	lr.mu.RLock()
+	defer lr.mu.RUnlock()
+
+	match, ok := lr.matches[id]
+	if !ok {
+		return LiveMatch{}, fmt.Errorf("%w: %s", ErrLiveTransactionNotFound, id)
+	}
+	return *match, nil
+}
+
+// List returns the tracked transactions in the given state, or all of them when state is empty,
+// most recently reconciled first
+func (lr *LiveReconciler) List(state LiveState) []LiveMatch {
+	lr.mu.RLock()
+	defer lr.mu.RUnlock()
+
+	matches := make([]LiveMatch, 0, len(lr.matches))
+	for _, match := range lr.matches {
+		if state != "" && match.State != state {
+			continue
+		}
+		matches = append(matches, *match)
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if !matches[i].ReconciledAt.Equal(matches[j].ReconciledAt) {
+			return matches[i].ReconciledAt.After(matches[j].ReconciledAt)
+		}
+		return matches[i].TransactionID < matches[j].TransactionID
+	})
+
+	return matches
+}
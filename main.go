@@ -1,25 +1,169 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"fmt"
 	"log"
 	"os"
 	"path/filepath"
-	"fmt"
+
+	"github.com/devhindo/TransactionReconcilerService/reconcile"
 )
 
 func main() {
+	// No subcommand keeps the original behaviour of reconciling the bundled CSV files
+	if len(os.Args) < 2 {
+		runReconcile(nil)
+		return
+	}
 
-	// Initialize the service
-	service := NewTransactionReconciliationService()
+	switch os.Args[1] {
+	case "reconcile":
+		runReconcile(os.Args[2:])
+	case "cases":
+		runCases(os.Args[2:])
+	case "journal":
+		runJournal(os.Args[2:])
+	case "corrections":
+		runCorrections(os.Args[2:])
+	case "serve":
+		runServe(os.Args[2:])
+	case "watch":
+		runWatch(os.Args[2:])
+	case "stream":
+		runStream(os.Args[2:])
+	case "bench":
+		runBench(os.Args[2:])
+	case "coordinate":
+		runCoordinate(os.Args[2:])
+	case "nway":
+		runNWay(os.Args[2:])
+	case "statements":
+		runStatements(os.Args[2:])
+	case "help", "-h", "--help":
+		printUsage()
+	default:
+		// Flags without a subcommand belong to reconcile
+		if len(os.Args[1]) > 0 && os.Args[1][0] == '-' {
+			runReconcile(os.Args[1:])
+			return
+		}
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", os.Args[1])
+		printUsage()
+		os.Exit(2)
+	}
+}
+
+// printUsage lists the available subcommands
+func printUsage() {
+	fmt.Fprintln(os.Stderr, "usage: TransactionReconcilerService <command> [flags]")
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(os.Stderr, "commands:")
+	fmt.Fprintln(os.Stderr, "  reconcile   reconcile the source and system CSV files (default)")
+	fmt.Fprintln(os.Stderr, "  cases       list, assign, comment on and resolve exception cases")
+	fmt.Fprintln(os.Stderr, "  journal     generate adjustment journal entries from resolved cases")
+	fmt.Fprintln(os.Stderr, "  corrections push corrections for resolved cases to the internal system, or roll them back")
+	fmt.Fprintln(os.Stderr, "  serve       run the REST API")
+	fmt.Fprintln(os.Stderr, "  watch       follow the live progress of a reconciliation running on the API server")
+	fmt.Fprintln(os.Stderr, "  stream      reconcile NDJSON transactions continuously as they arrive, in any order")
+	fmt.Fprintln(os.Stderr, "  coordinate  split a reconciliation into partitions and run them on worker API servers")
+	fmt.Fprintln(os.Stderr, "  nway        match any number of datasets, e.g. provider report, internal ledger and bank statement")
+	fmt.Fprintln(os.Stderr, "  statements  list the statements of camt, MT940 or BAI2 files with their balances")
+	fmt.Fprintln(os.Stderr, "  bench       time sequential and parallel reconciliation on a generated dataset")
+}
 
+// currentUser is the default identity recorded on case changes
+func currentUser() string {
+	if user := os.Getenv("USER"); user != "" {
+		return user
+	}
+	return "unknown"
+}
+
+// runReconcile reconciles the source and system files and opens cases for the exceptions found
+func runReconcile(args []string) {
 	workingDir, err := os.Getwd()
 	if err != nil {
 		log.Fatalf("Failed to get working directory: %v", err)
-	}// get the paths for the CSV files
-	
+	}
+
+	// get the paths for the CSV files
+	fs := flag.NewFlagSet("reconcile", flag.ExitOnError)
+	sourceFlag := fs.String("source", filepath.Join(workingDir, "assets", "data", "csvs", "source_transactions.csv"), "path to the source transactions CSV")
+	sourceFormatFlag := fs.String("source-format", "", "format of the source file: csv, json, ndjson, xlsx, stripe for a Stripe balance transactions or payout reconciliation report, paypal for a PayPal Settlement Report; by extension when empty")
+	sourceSheetFlag := fs.String("source-sheet", "", "sheet of a source .xlsx workbook, the first when empty")
+	sourceDialectFlag := fs.String("source-dialect", "", `dialect of a delimited source file, e.g. "delimiter=; quote=' comment=# lazy-quotes trailing-delimiter"; comma-delimited when empty`)
+	sourceLayoutFlag := fs.String("source-layout", "", "JSON column-position layout reading the source file as fixed-width")
+	sourceValuesFlag := fs.String("source-values", "", `timestamps and amounts of the source file, e.g. "time=2006-01-02 15:04:05|epoch-millis; timezone=Europe/Berlin; locale=de"; RFC 3339 and decimal points when empty`)
+	systemFlag := fs.String("system", filepath.Join(workingDir, "assets", "data", "csvs", "system_transactions.csv"), "path to the system transactions CSV")
+	systemFormatFlag := fs.String("system-format", "", "format of the system file: csv, json, ndjson or xlsx; by extension when empty")
+	systemSheetFlag := fs.String("system-sheet", "", "sheet of a system .xlsx workbook, the first when empty")
+	systemDialectFlag := fs.String("system-dialect", "", "dialect of a delimited system file, like -source-dialect")
+	systemLayoutFlag := fs.String("system-layout", "", "JSON column-position layout reading the system file as fixed-width")
+	systemValuesFlag := fs.String("system-values", "", "timestamps and amounts of the system file, like -source-values")
+	xlsxFlag := fs.String("xlsx", "", "also write the report as an Excel workbook to this path")
+	casesFlag := fs.String("cases", defaultCaseStorePath, "path to the case store, empty disables case tracking")
+	userFlag := fs.String("user", currentUser(), "identity recorded on newly opened cases")
+	streamingFlag := fs.Bool("streaming", false, "stream both files row by row instead of loading them, for files larger than memory")
+	latenessFlag := fs.Duration("lateness", defaultStreamingLateness, "how far out of createdAt order the files may be in streaming mode before falling back to sort-merge")
+	sortMergeFlag := fs.Bool("sort-merge", false, "sort both files on disk by transaction ID and merge-join them, for files of any size and order")
+	tempDirFlag := fs.String("temp-dir", "", "directory for the sort-merge spill files, the system temp directory when empty")
+	workersFlag := fs.Int("workers", 1, "workers parsing and matching in parallel, 0 uses every CPU")
+	orderFlag := fs.String("order", DefaultReportOrder.String(), "sort order of the report sections: severity, amount, createdAt or id, - for descending")
+	chunkRowsFlag := fs.Int("chunk-rows", defaultSortChunkRows, "rows sorted in memory per spill file in sort-merge mode")
+	fs.Parse(args)
 
-	sourceFile := filepath.Join(workingDir, "assets", "data", "csvs", "source_transactions.csv")
-	systemFile := filepath.Join(workingDir, "assets", "data", "csvs", "system_transactions.csv")
+	sourceFile := *sourceFlag
+	systemFile := *systemFlag
+
+	order, err := ParseReportOrder(*orderFlag)
+	if err != nil {
+		log.Fatalf("Invalid -order: %v", err)
+	}
+
+	sourceFormat, err := reconcile.ParseFormat(*sourceFormatFlag)
+	if err != nil {
+		log.Fatalf("Invalid -source-format: %v", err)
+	}
+	systemFormat, err := reconcile.ParseFormat(*systemFormatFlag)
+	if err == nil && (systemFormat == reconcile.FormatStripe || systemFormat == reconcile.FormatPayPal) {
+		err = fmt.Errorf("%s reports hold source transactions only", systemFormat)
+	}
+	if err != nil {
+		log.Fatalf("Invalid -system-format: %v", err)
+	}
+
+	// Initialize the service
+	service := NewTransactionReconciliationService(reconcile.WithOrder(order))
+	service.csvReader.SourceFormat, service.csvReader.SystemFormat = sourceFormat, systemFormat
+	service.csvReader.SourceSheet, service.csvReader.SystemSheet = *sourceSheetFlag, *systemSheetFlag
+	if service.csvReader.SourceDialect, err = reconcile.ParseDialect(*sourceDialectFlag); err != nil {
+		log.Fatalf("Invalid -source-dialect: %v", err)
+	}
+	if service.csvReader.SystemDialect, err = reconcile.ParseDialect(*systemDialectFlag); err != nil {
+		log.Fatalf("Invalid -system-dialect: %v", err)
+	}
+	if service.csvReader.SourceValues, err = reconcile.ParseValueFormat(*sourceValuesFlag); err != nil {
+		log.Fatalf("Invalid -source-values: %v", err)
+	}
+	if service.csvReader.SystemValues, err = reconcile.ParseValueFormat(*systemValuesFlag); err != nil {
+		log.Fatalf("Invalid -system-values: %v", err)
+	}
+	if *sourceLayoutFlag != "" {
+		layout, err := reconcile.LoadFixedWidthLayout(*sourceLayoutFlag)
+		if err != nil {
+			log.Fatalf("Invalid -source-layout: %v", err)
+		}
+		service.csvReader.SourceLayout = &layout
+	}
+	if *systemLayoutFlag != "" {
+		layout, err := reconcile.LoadFixedWidthLayout(*systemLayoutFlag)
+		if err != nil {
+			log.Fatalf("Invalid -system-layout: %v", err)
+		}
+		service.csvReader.SystemLayout = &layout
+	}
 
 	// Check if files exist
 	if _, err := os.Stat(sourceFile); os.IsNotExist(err) {
@@ -29,11 +173,29 @@ func main() {
 		log.Fatalf("System transactions file not found: %s", systemFile)
 	}
 
+	if *sortMergeFlag && *xlsxFlag != "" {
+		log.Fatalf("-xlsx needs the whole result in memory and cannot be combined with -sort-merge")
+	}
+
 	fmt.Println("🔄 Starting Transaction Reconciliation Service")
 	fmt.Println("================================================")
 
+	if *sortMergeFlag {
+		config := SortMergeConfig{TempDir: *tempDirFlag, ChunkRows: *chunkRowsFlag}
+		runSortMerge(service, sourceFile, systemFile, config, *casesFlag, *userFlag)
+		return
+	}
+
 	// Process the reconciliation
-	result, err := service.ProcessReconciliation(sourceFile, systemFile)
+	var result *ReconciliationResult
+	switch {
+	case *streamingFlag:
+		result, err = service.ProcessReconciliationStreaming(context.Background(), sourceFile, systemFile, *latenessFlag, nil)
+	case *workersFlag != 1:
+		result, err = service.ProcessReconciliationParallel(context.Background(), sourceFile, systemFile, *workersFlag, nil)
+	default:
+		result, err = service.ProcessReconciliation(sourceFile, systemFile)
+	}
 	if err != nil {
 		log.Fatalf("Reconciliation failed: %v", err)
 	}
@@ -41,7 +203,6 @@ func main() {
 	// Print summary
 	service.PrintSummary(result)
 
-
 	// Output the detailed JSON result to a file
 	fmt.Println("\n📊 DETAILED RECONCILIATION REPORT:")
 	fmt.Println("==================================")
@@ -49,6 +210,73 @@ func main() {
 	if err != nil {
 		log.Fatalf("Failed to output reconciliation result: %v", err)
 	}
+	if *xlsxFlag != "" {
+		if err := (reconcile.XLSXSink{Path: *xlsxFlag}).Write(context.Background(), result); err != nil {
+			log.Fatalf("Failed to save Excel report: %v", err)
+		}
+		log.Printf("Excel report saved to: %s", *xlsxFlag)
+	}
+
+	// Open cases for the exceptions so they can be worked on
+	if *casesFlag != "" {
+		store, err := NewCaseStore(*casesFlag)
+		if err != nil {
+			log.Fatalf("Failed to open case store: %v", err)
+		}
+		opened, err := store.Sync(result, *userFlag)
+		if err != nil {
+			log.Fatalf("Failed to update case store: %v", err)
+		}
+		log.Printf("Opened %d new exception cases in %s", opened, *casesFlag)
+	}
+
+	fmt.Println("\n✅ Reconciliation completed successfully!")
+}
+
+// runSortMerge reconciles in sort-merge mode, writing the report file as the sorted exceptions come rather
+// than printing it, and opening the cases a batch of exceptions at a time
+func runSortMerge(service *TransactionReconciliationService, sourceFile, systemFile string, config SortMergeConfig, casesPath, user string) {
+	var onExceptions func(*ReconciliationResult) error
+	opened := 0
+	if casesPath != "" {
+		store, err := NewCaseStore(casesPath)
+		if err != nil {
+			log.Fatalf("Failed to open case store: %v", err)
+		}
+		onExceptions = func(batch *ReconciliationResult) error {
+			n, err := store.Sync(batch, user)
+			opened += n
+			return err
+		}
+	}
+
+	outputFile := "reconciliation_report.json"
+	report, err := os.CreateTemp(filepath.Dir(outputFile), "."+filepath.Base(outputFile)+"-*")
+	if err != nil {
+		log.Fatalf("Failed to create report file: %v", err)
+	}
+	defer os.Remove(report.Name())
+	report.Chmod(0644)
+	summary, err := service.ProcessReconciliationSortMerge(context.Background(), sourceFile, systemFile, config, report, onExceptions, nil)
+	if closeErr := report.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		log.Fatalf("Reconciliation failed: %v", err)
+	}
+	if err := os.Rename(report.Name(), outputFile); err != nil {
+		log.Fatalf("Failed to save report to %s: %v", outputFile, err)
+	}
+
+	result := &ReconciliationResult{Summary: summary}
+	service.PrintSummary(result)
+	log.Printf("Reconciliation report saved to: %s", outputFile)
+	if err := service.OutputSummaryToFile(result); err != nil {
+		log.Printf("Warning: Could not save summary to file: %v", err)
+	}
+	if casesPath != "" {
+		log.Printf("Opened %d new exception cases in %s", opened, casesPath)
+	}
 
 	fmt.Println("\n✅ Reconciliation completed successfully!")
-}
\ No newline at end of file
+}
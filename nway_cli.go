@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+
+	"github.com/devhindo/TransactionReconcilerService/reconcile"
+)
+
+// listFlag collects the values of a flag given several times
+type listFlag []string
+
+func (l *listFlag) String() string {
+	return strings.Join(*l, " ")
+}
+
+func (l *listFlag) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// runNWay reconciles any number of named datasets, e.g. provider, ledger and bank, and writes the N-way report
+func runNWay(args []string) {
+	workingDir, err := os.Getwd()
+	if err != nil {
+		log.Fatalf("Failed to get working directory: %v", err)
+	}
+
+	var datasetFlags, keyFlags, ruleFlags listFlag
+	fs := flag.NewFlagSet("nway", flag.ExitOnError)
+	fs.Var(&datasetFlags, "dataset", "a dataset as name=format:path, format being source or system for the two transaction layouts, in CSV, JSON, NDJSON or the first sheet of an XLSX workbook by extension, stripe or paypal for the provider's own settlement report, json for an array of canonical transactions, or camt, mt940 or bai2 for the entries of a bank statement in that format; repeat for each dataset")
+	fs.Var(&keyFlags, "key", "what a dataset is matched on as name=id or name=reference, id by default; repeatable")
+	fs.Var(&ruleFlags, "rule", "datasets compared as left:right, optionally limited to some fields as left:right=amount,currency; repeatable, every pair when none")
+	output := fs.String("output", "nway_report.json", "path of the N-way report")
+	fs.Parse(args)
+
+	if len(datasetFlags) == 0 {
+		datasetFlags = listFlag{
+			"provider=source:" + filepath.Join(workingDir, "assets", "data", "csvs", "source_transactions.csv"),
+			"ledger=system:" + filepath.Join(workingDir, "assets", "data", "csvs", "system_transactions.csv"),
+		}
+	}
+
+	var datasets []reconcile.Dataset
+	for _, value := range datasetFlags {
+		dataset, err := parseDatasetFlag(value)
+		if err != nil {
+			log.Fatalf("Invalid -dataset %q: %v", value, err)
+		}
+		datasets = append(datasets, dataset)
+	}
+	if err := applyKeyFlags(datasets, keyFlags); err != nil {
+		log.Fatalf("Invalid -key: %v", err)
+	}
+	rules, err := parseRuleFlags(ruleFlags)
+	if err != nil {
+		log.Fatalf("Invalid -rule: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	fmt.Println("🔄 Starting N-way Transaction Reconciliation")
+	fmt.Println("============================================")
+
+	result, err := reconcile.New().ReconcileN(ctx, datasets, rules...)
+	if err != nil {
+		log.Fatalf("Reconciliation failed: %v", err)
+	}
+
+	printNWaySummary(result)
+
+	data, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		log.Fatalf("Failed to marshal N-way report: %v", err)
+	}
+	if err := os.WriteFile(*output, data, 0644); err != nil {
+		log.Fatalf("Failed to save N-way report: %v", err)
+	}
+	log.Printf("N-way report saved to: %s", *output)
+
+	fmt.Println("\n✅ Reconciliation completed successfully!")
+}
+
+// parseDatasetFlag reads a name=format:path dataset
+func parseDatasetFlag(value string) (reconcile.Dataset, error) {
+	name, spec, ok := strings.Cut(value, "=")
+	format, path, ok2 := strings.Cut(spec, ":")
+	if !ok || !ok2 || name == "" || path == "" {
+		return reconcile.Dataset{}, fmt.Errorf("expected name=format:path")
+	}
+
+	dataset := reconcile.Dataset{Name: name}
+	switch format {
+	case "source":
+		dataset.Source = reconcile.Canonicalize(reconcile.SourceFile(path), reconcile.FromSource)
+	case "system":
+		dataset.Source = reconcile.Canonicalize(reconcile.SystemFile(path), reconcile.FromSystem)
+	case "stripe", "paypal":
+		dataset.Source = reconcile.Canonicalize(reconcile.SourceFileAs(path, reconcile.Format(format)), reconcile.FromSource)
+	case "json":
+		dataset.Source = reconcile.JSONFile[reconcile.Transaction](path)
+	case "camt":
+		dataset.Source = reconcile.Canonicalize(reconcile.CAMTFile(path), reconcile.FromStatementEntry)
+	case "mt940":
+		dataset.Source = reconcile.Canonicalize(reconcile.MT940File(path), reconcile.FromStatementEntry)
+	case "bai2":
+		dataset.Source = reconcile.Canonicalize(reconcile.BAI2File(path), reconcile.FromStatementEntry)
+	default:
+		return reconcile.Dataset{}, fmt.Errorf("unknown format %q, expected source, system, stripe, paypal, json, camt, mt940 or bai2", format)
+	}
+	return dataset, nil
+}
+
+// applyKeyFlags sets what the datasets are matched on from name=id or name=reference values
+func applyKeyFlags(datasets []reconcile.Dataset, values []string) error {
+	for _, value := range values {
+		name, field, _ := strings.Cut(value, "=")
+		i := slices.IndexFunc(datasets, func(dataset reconcile.Dataset) bool { return dataset.Name == name })
+		if i < 0 {
+			return fmt.Errorf("%q: no dataset named %q", value, name)
+		}
+		switch field {
+		case "id":
+			datasets[i].Key = nil
+		case "reference":
+			datasets[i].Key = func(txn reconcile.Transaction) string { return txn.Reference }
+		default:
+			return fmt.Errorf("%q: expected name=id or name=reference", value)
+		}
+	}
+	return nil
+}
+
+// parseRuleFlags reads left:right rules, limited to some fields as left:right=amount,currency
+func parseRuleFlags(values []string) ([]reconcile.MatchRule, error) {
+	var rules []reconcile.MatchRule
+	for _, value := range values {
+		pair, fields, limited := strings.Cut(value, "=")
+		left, right, ok := strings.Cut(pair, ":")
+		if !ok || left == "" || right == "" {
+			return nil, fmt.Errorf("%q: expected left:right", value)
+		}
+		rule := reconcile.MatchRule{Left: left, Right: right}
+		if limited {
+			matcher := reconcile.DefaultFieldMatcher
+			matcher.Fields = strings.Split(fields, ",")
+			if slices.Contains(matcher.Fields, "") {
+				return nil, fmt.Errorf("%q: expected comma-separated field names after =", value)
+			}
+			rule.Matcher = matcher
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// printNWaySummary prints the counts of an N-way reconciliation
+func printNWaySummary(result *reconcile.NWayResult) {
+	separator := strings.Repeat("=", 60)
+	fmt.Println("\n" + separator)
+	fmt.Println("N-WAY RECONCILIATION SUMMARY")
+	fmt.Println(separator)
+	for _, name := range result.Datasets {
+		fmt.Printf("%-32s %d\n", name+" transactions:", result.Summary.TransactionsPerDataset[name])
+	}
+	fmt.Printf("%-32s %s\n", "Rules:", strings.Join(result.Rules, ", "))
+	fmt.Println(separator)
+	fmt.Printf("%-32s %d\n", "Logical transactions:", result.Summary.LogicalTransactions)
+	fmt.Printf("%-32s %d\n", "Fully matched:", result.Summary.FullyMatchedCount)
+	fmt.Printf("%-32s %d\n", "Missing from a dataset:", result.Summary.IncompleteCount)
+	fmt.Printf("%-32s %d\n", "Disagreeing:", result.Summary.DisagreeingCount)
+	fmt.Printf("%-32s %d\n", "Duplicated keys:", result.Summary.DuplicatedCount)
+	fmt.Printf("%-32s %d\n", "Without a key:", result.Summary.UnkeyedCount)
+	fmt.Println(separator)
+}
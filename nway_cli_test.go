@@ -0,0 +1,43 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/devhindo/TransactionReconcilerService/reconcile"
+)
+
+func TestApplyKeyFlags(t *testing.T) {
+	datasets := []reconcile.Dataset{{Name: "ledger"}, {Name: "bank"}}
+	if err := applyKeyFlags(datasets, []string{"bank=reference", "ledger=id"}); err != nil {
+		t.Fatal(err)
+	}
+	if datasets[0].Key != nil || datasets[1].Key == nil || datasets[1].Key(reconcile.Transaction{ID: "dep-1", Reference: "ref-1"}) != "ref-1" {
+		t.Error("ledger is not matched on the ID and bank on the reference")
+	}
+
+	for _, value := range []string{"bank=amount", "bank", "provider=id", "=reference"} {
+		if err := applyKeyFlags(datasets, []string{value}); err == nil {
+			t.Errorf("-key %s was accepted", value)
+		}
+	}
+}
+
+func TestParseRuleFlags(t *testing.T) {
+	rules, err := parseRuleFlags([]string{"provider:ledger", "ledger:bank=amount,currency"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 2 || rules[0].Left != "provider" || rules[0].Right != "ledger" || rules[0].Matcher != nil {
+		t.Fatalf("rules = %+v", rules)
+	}
+	matcher, ok := rules[1].Matcher.(reconcile.FieldMatcher)
+	if !ok || len(matcher.Fields) != 2 || matcher.Fields[0] != "amount" || matcher.Fields[1] != "currency" {
+		t.Errorf("limited rule matcher = %+v, want the amount and currency fields", rules[1].Matcher)
+	}
+
+	for _, value := range []string{"provider", "provider:", ":ledger", "provider:ledger=", "provider:ledger=amount,"} {
+		if _, err := parseRuleFlags([]string{value}); err == nil {
+			t.Errorf("-rule %s was accepted", value)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"sync"
+
+	"github.com/devhindo/TransactionReconcilerService/reconcile"
+)
+
+// parallelChunkRows is how many CSV rows are handed to a parsing worker at once
+const parallelChunkRows = 4096
+
+// parallelWorkers resolves a worker count, zero or less meaning one per CPU
+func parallelWorkers(workers int) int {
+	if workers <= 0 {
+		return runtime.GOMAXPROCS(0)
+	}
+	return workers
+}
+
+// ReadSourceTransactionsParallel reads source transactions like ReadSourceTransactionsContext, decoding
+// chunks of rows on several workers. Transactions keep their file order, and the error of the earliest
+// invalid row is returned whatever the worker that found it. Other formats, dialects and fixed-width files
+// are read on a single worker.
+func (r *CSVReader) ReadSourceTransactionsParallel(ctx context.Context, filePath string, workers int) ([]SourceTransaction, error) {
+	if r.sourceFormat(filePath) != reconcile.FormatCSV || r.SourceDialect != (reconcile.Dialect{}) || r.SourceLayout != nil {
+		return r.ReadSourceTransactionsContext(ctx, filePath, nil)
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open source transactions file: %w", err)
+	}
+	defer file.Close()
+
+	return parseParallel(ctx, file, parallelWorkers(workers), r.SourceValues.ParseSourceRecord)
+}
+
+// ReadSystemTransactionsParallel reads system transactions on several workers, like ReadSourceTransactionsParallel
+func (r *CSVReader) ReadSystemTransactionsParallel(ctx context.Context, filePath string, workers int) ([]SystemTransaction, error) {
+	if r.systemFormat(filePath) != reconcile.FormatCSV || r.SystemDialect != (reconcile.Dialect{}) || r.SystemLayout != nil {
+		return r.ReadSystemTransactionsContext(ctx, filePath, nil)
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open system transactions file: %w", err)
+	}
+	defer file.Close()
+
+	return parseParallel(ctx, file, parallelWorkers(workers), r.SystemValues.ParseSystemRecord)
+}
+
+// recordChunk is a run of consecutive CSV rows, index is its position in the file and lines the line
+// each row starts on, which quoted fields spanning lines keep from following the row count
+type recordChunk struct {
+	index   int
+	lines   []int
+	records [][]string
+}
+
+// parsedChunk is a chunk once decoded by a worker
+type parsedChunk[T any] struct {
+	index int
+	items []T
+	err   error
+}
+
+// parseParallel splits a CSV file into chunks of rows after its header and parses them on workers.
+// Splitting the fields stays on one goroutine, as quoted fields may span lines, while converting them
+// to transactions, where most of the time goes, is spread across the workers.
+func parseParallel[T any](ctx context.Context, file io.Reader, workers int, parse func(record []string, line int) (T, error)) ([]T, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	chunks := make(chan recordChunk, workers)
+	results := make(chan parsedChunk[T], workers)
+
+	var readErr error
+	go func() {
+		defer close(chunks)
+		send := func(chunk recordChunk) bool {
+			select {
+			case chunks <- chunk:
+				return true
+			case <-ctx.Done():
+				readErr = ctx.Err()
+				return false
+			}
+		}
+
+		reader := csv.NewReader(file)
+		// Skip header row
+		if _, err := reader.Read(); errors.Is(err, io.EOF) {
+			readErr = fmt.Errorf("CSV file is empty")
+			return
+		} else if err != nil {
+			readErr = fmt.Errorf("failed to read CSV records: %w", err)
+			return
+		}
+
+		var chunk recordChunk
+		for {
+			record, err := reader.Read()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				readErr = fmt.Errorf("failed to read CSV records: %w", err)
+				return
+			}
+
+			line, _ := reader.FieldPos(0)
+			chunk.lines = append(chunk.lines, line)
+			chunk.records = append(chunk.records, record)
+			if len(chunk.records) == parallelChunkRows {
+				if !send(chunk) {
+					return
+				}
+				chunk = recordChunk{index: chunk.index + 1}
+			}
+		}
+		if len(chunk.records) > 0 {
+			send(chunk)
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for chunk := range chunks {
+				parsed := parsedChunk[T]{index: chunk.index, items: make([]T, 0, len(chunk.records))}
+				for i, record := range chunk.records {
+					item, err := parse(record, chunk.lines[i])
+					if err != nil {
+						parsed.err = err
+						break
+					}
+					parsed.items = append(parsed.items, item)
+				}
+				results <- parsed
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	var parts [][]T
+	var parseErr error
+	parseErrIndex := -1
+	for parsed := range results {
+		if parsed.err != nil {
+			if parseErrIndex < 0 || parsed.index < parseErrIndex {
+				parseErr, parseErrIndex = parsed.err, parsed.index
+			}
+			cancel()
+			continue
+		}
+		for len(parts) <= parsed.index {
+			parts = append(parts, nil)
+		}
+		parts[parsed.index] = parsed.items
+	}
+
+	// results is closed only once the reader has returned, so readErr is settled
+	if parseErr != nil {
+		return nil, parseErr
+	}
+	if readErr != nil {
+		return nil, readErr
+	}
+
+	total := 0
+	for _, part := range parts {
+		total += len(part)
+	}
+	items := make([]T, 0, total)
+	for _, part := range parts {
+		items = append(items, part...)
+	}
+	return items, nil
+}
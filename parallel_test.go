@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/devhindo/TransactionReconcilerService/reconcile"
+)
+
+func TestReadSourceTransactionsParallelLines(t *testing.T) {
+	// The description of the first row spans three lines, the invalid amount of the third row is on line 6
+	rows := []string{
+		strings.Join(reconcile.SourceCSVHeader, ","),
+		`txn-1,a@example.com,usr-1,Stripe,10.00,USD,completed,payment,card,2025-03-01T00:00:00Z,2025-03-01T00:00:00Z,ref-1,low,inv-1,A,"first line`,
+		`second line`,
+		`third line"`,
+		`txn-2,b@example.com,usr-2,Stripe,20.00,USD,completed,payment,card,2025-03-01T00:00:00Z,2025-03-01T00:00:00Z,ref-2,low,inv-2,B,payment`,
+		`txn-3,c@example.com,usr-3,Stripe,lots,USD,completed,payment,card,2025-03-01T00:00:00Z,2025-03-01T00:00:00Z,ref-3,low,inv-3,C,payment`,
+	}
+	path := filepath.Join(t.TempDir(), "source.csv")
+	if err := os.WriteFile(path, []byte(strings.Join(rows, "\n")+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	reader := NewCSVReader()
+	_, want := reader.ReadSourceTransactions(path)
+	_, err := reader.ReadSourceTransactionsParallel(context.Background(), path, 2)
+	if err == nil || !strings.Contains(err.Error(), "line 6") {
+		t.Fatalf("err = %v, want the invalid amount reported on line 6", err)
+	}
+	if want == nil || err.Error() != want.Error() {
+		t.Errorf("err = %v, want the error of the sequential reader %v", err, want)
+	}
+}
@@ -0,0 +1,390 @@
+package reconcile
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BAI2Source reads BAI2 cash management files, as sent by US banks. A file holds groups of account
+// records, each account becoming a statement: its 03 record gives the opening (010) and closing (015)
+// ledger balances, its 16 records the entries with their type codes, and 88 records continue the record
+// before them.
+type BAI2Source struct {
+	name string
+	open func() (io.ReadCloser, error)
+}
+
+// BAI2File reads the statements of a BAI2 file
+func BAI2File(path string) *BAI2Source {
+	return &BAI2Source{name: "BAI2 file", open: openFile(path)}
+}
+
+// BAI2 reads BAI2 statements, which can only be iterated once
+func BAI2(r io.Reader) *BAI2Source {
+	return &BAI2Source{name: "BAI2 statement", open: nopOpen(r)}
+}
+
+// Transactions yields the entries of every account in file order
+func (s *BAI2Source) Transactions(ctx context.Context) iter.Seq2[StatementEntry, error] {
+	return entriesOf(s.Statements(ctx))
+}
+
+// Statements yields one statement per account record in file order, each once its 49 trailer has been read
+func (s *BAI2Source) Statements(ctx context.Context) iter.Seq2[Statement, error] {
+	return func(yield func(Statement, error) bool) {
+		r, err := s.open()
+		if err != nil {
+			yield(Statement{}, fmt.Errorf("failed to open %s: %w", s.name, err))
+			return
+		}
+		defer r.Close()
+
+		if err := s.each(ctx, r, yield); err != nil && !errors.Is(err, errStopIteration) {
+			yield(Statement{}, err)
+		}
+	}
+}
+
+// bai2Record is a record with its 88 continuations, line being where it starts
+type bai2Record struct {
+	code     string
+	segments []string
+	line     int
+}
+
+// each joins the lines into records and the records into statements
+func (s *BAI2Source) each(ctx context.Context, r io.Reader, yield func(Statement, error) bool) error {
+	parser := &bai2Parser{}
+	var pending *bai2Record
+	process := func() error {
+		if pending == nil {
+			return nil
+		}
+		statement, done, err := parser.record(*pending)
+		pending = nil
+		if err != nil {
+			return err
+		}
+		if done && !yield(statement, nil) {
+			return errStopIteration
+		}
+		return nil
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if line%checkInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+		}
+
+		// Lines may be padded to a fixed record length
+		text := strings.TrimRight(scanner.Text(), " \r")
+		if text == "" {
+			continue
+		}
+		code, rest, _ := strings.Cut(text, ",")
+		if code == "88" {
+			if pending == nil {
+				return fmt.Errorf("%w: line %d: continuation record with no record before it", ErrInvalidStatement, line)
+			}
+			pending.segments = append(pending.segments, rest)
+			continue
+		}
+		if err := process(); err != nil {
+			return err
+		}
+		pending = &bai2Record{code: code, segments: []string{rest}, line: line}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read %s: %w", s.name, err)
+	}
+	if err := process(); err != nil {
+		return err
+	}
+	if parser.current != nil {
+		return fmt.Errorf("%w: account record at line %d has no 49 trailer", ErrInvalidStatement, parser.accountLine)
+	}
+	if parser.statements == 0 {
+		return fmt.Errorf("%w: no account record found in %s", ErrInvalidStatement, s.name)
+	}
+	return nil
+}
+
+// bai2Parser holds what the file and group headers tell about the account records after them
+type bai2Parser struct {
+	fileID      string
+	asOfDate    time.Time
+	currency    string
+	current     *Statement
+	accountLine int
+	statements  int
+}
+
+// record reads one record, returning the statement of the account once it ends
+func (p *bai2Parser) record(record bai2Record) (Statement, bool, error) {
+	fields := &bai2Fields{segments: record.segments}
+	invalid := func(err error) error {
+		return fmt.Errorf("%w: record %s at line %d: %v", ErrInvalidStatement, record.code, record.line, err)
+	}
+
+	switch record.code {
+	case "01":
+		// sender, receiver, creation date and time, file ID
+		fields.skip(4)
+		p.fileID = fields.next()
+	case "02":
+		// receiver, originator, group status, as-of date, as-of time, currency
+		fields.skip(3)
+		date, err := time.Parse("060102", fields.next())
+		if err != nil {
+			return Statement{}, false, invalid(fmt.Errorf("invalid as-of date"))
+		}
+		fields.skip(1)
+		p.asOfDate, p.currency = date, fields.next()
+	case "03":
+		if p.current != nil {
+			return Statement{}, false, invalid(fmt.Errorf("the account record at line %d has no 49 trailer", p.accountLine))
+		}
+		statement, err := p.account(fields)
+		if err != nil {
+			return Statement{}, false, invalid(err)
+		}
+		p.current, p.accountLine = &statement, record.line
+	case "16":
+		if p.current == nil {
+			return Statement{}, false, invalid(fmt.Errorf("transaction detail outside an account"))
+		}
+		entry, ok, err := p.detail(fields)
+		if err != nil {
+			return Statement{}, false, invalid(err)
+		}
+		if ok {
+			p.current.Entries = append(p.current.Entries, entry)
+		}
+	case "49":
+		if p.current == nil {
+			return Statement{}, false, invalid(fmt.Errorf("account trailer outside an account"))
+		}
+		statement := *p.current
+		p.current = nil
+		p.statements++
+		return statement, true, nil
+	case "98", "99":
+		if p.current != nil {
+			return Statement{}, false, invalid(fmt.Errorf("the account record at line %d has no 49 trailer", p.accountLine))
+		}
+	default:
+		return Statement{}, false, invalid(fmt.Errorf("unknown record code"))
+	}
+	return Statement{}, false, nil
+}
+
+// account reads an account identifier record: the account, its currency, then summaries as type code,
+// amount, item count and funds type
+func (p *bai2Parser) account(fields *bai2Fields) (Statement, error) {
+	account := fields.next()
+	if account == "" {
+		return Statement{}, fmt.Errorf("no account number")
+	}
+	currency := fields.next()
+	if currency == "" {
+		currency = p.currency
+	}
+	statement := Statement{
+		ID:       fmt.Sprintf("%s/%s/%s", p.fileID, p.asOfDate.Format("060102"), account),
+		Account:  account,
+		Currency: currency,
+	}
+
+	for !fields.done() {
+		code, value := fields.next(), fields.next()
+		fields.skip(1)
+		if err := fields.skipFunds(); err != nil {
+			return Statement{}, err
+		}
+		if value == "" || (code != "010" && code != "015") {
+			continue
+		}
+		amount, err := parseBAI2Amount(value, currency)
+		if err != nil {
+			return Statement{}, fmt.Errorf("invalid amount %q for type code %s", value, code)
+		}
+		balance := Balance{Amount: amount, Currency: currency, Date: p.asOfDate}
+		if code == "010" {
+			statement.Opening = &balance
+		} else {
+			statement.Closing = &balance
+		}
+	}
+	return statement, nil
+}
+
+// detail reads a transaction detail record: type code, amount, funds type, bank reference, customer
+// reference and text. Records whose type code gives no direction, loan (7xx) and non-monetary (8xx)
+// details, are skipped, ok being false.
+func (p *bai2Parser) detail(fields *bai2Fields) (entry StatementEntry, ok bool, err error) {
+	code := fields.next()
+	number, err := strconv.Atoi(code)
+	if err != nil || number < 100 || number > 999 {
+		return StatementEntry{}, false, fmt.Errorf("invalid type code %q", code)
+	}
+	var creditDebit CreditDebit
+	switch {
+	case number < 400, number >= 900 && number < 960:
+		// 900 to 959 are credits defined by the bank and its customer
+		creditDebit = Credit
+	case number < 700, number >= 960:
+		creditDebit = Debit
+	default:
+		return StatementEntry{}, false, nil
+	}
+
+	value := fields.next()
+	amount, err := parseBAI2Amount(value, p.current.Currency)
+	if err != nil || amount < 0 {
+		return StatementEntry{}, false, fmt.Errorf("invalid amount %q", value)
+	}
+
+	entry = StatementEntry{
+		StatementID:     p.current.ID,
+		Account:         p.current.Account,
+		Amount:          amount,
+		Currency:        p.current.Currency,
+		CreditDebit:     creditDebit,
+		Status:          "BOOK",
+		BookingDate:     p.asOfDate,
+		ValueDate:       p.asOfDate,
+		TransactionCode: code,
+	}
+	// A value dated entry gives its value date after the funds type
+	if fields.peek() == "V" {
+		fields.skip(1)
+		date := fields.next()
+		fields.skip(1)
+		if entry.ValueDate, err = time.Parse("060102", date); err != nil {
+			return StatementEntry{}, false, fmt.Errorf("invalid value date %q", date)
+		}
+	} else if err := fields.skipFunds(); err != nil {
+		return StatementEntry{}, false, err
+	}
+
+	bankRef, customerRef := fields.next(), fields.next()
+	entry.Reference = bankRef
+	if entry.Reference == "" {
+		entry.Reference = customerRef
+	}
+	if entry.Reference == "" {
+		entry.Reference = fmt.Sprintf("%s/%d", p.current.ID, len(p.current.Entries)+1)
+	}
+	entry.EndToEndID = customerRef
+	entry.RemittanceInfo = fields.rest()
+	return entry, true, nil
+}
+
+// bai2Fields hands out the comma-separated fields of a record and its continuations in turn, each
+// segment ending at a slash
+type bai2Fields struct {
+	segments []string
+}
+
+// current is the unread part of the segment being read, moving to the next segment once it is used up.
+// A slash left unread still holds an empty field, the one ending the record.
+func (f *bai2Fields) current() *string {
+	for len(f.segments) > 1 && f.segments[0] == "" {
+		f.segments = f.segments[1:]
+	}
+	if len(f.segments) == 0 {
+		return nil
+	}
+	return &f.segments[0]
+}
+
+// next returns the next field, empty once the record is used up
+func (f *bai2Fields) next() string {
+	segment := f.current()
+	if segment == nil {
+		return ""
+	}
+	field, rest, found := strings.Cut(*segment, ",")
+	if !found {
+		field, rest = strings.TrimSuffix(field, "/"), ""
+	}
+	*segment = rest
+	return strings.TrimSpace(field)
+}
+
+// peek returns the next field without reading it
+func (f *bai2Fields) peek() string {
+	segment := f.current()
+	if segment == nil {
+		return ""
+	}
+	field, _, _ := strings.Cut(*segment, ",")
+	return strings.TrimSpace(strings.TrimSuffix(field, "/"))
+}
+
+// skip reads n fields
+func (f *bai2Fields) skip(n int) {
+	for range n {
+		f.next()
+	}
+}
+
+// done tells whether every field has been read, an empty one before the ending slash aside
+func (f *bai2Fields) done() bool {
+	segment := f.current()
+	return segment == nil || *segment == "" || *segment == "/"
+}
+
+// rest returns the text left in the record, its continuations joined with spaces. Text is the last field
+// and may hold commas and slashes, only a slash ending the record being dropped.
+func (f *bai2Fields) rest() string {
+	var parts []string
+	for _, segment := range f.segments {
+		if segment = strings.TrimSpace(strings.TrimSuffix(segment, "/")); segment != "" {
+			parts = append(parts, segment)
+		}
+	}
+	f.segments = nil
+	return strings.Join(parts, " ")
+}
+
+// skipFunds reads a funds type and the availability it gives: a value date and time for V, three
+// amounts for S, and a count of day and amount pairs for D
+func (f *bai2Fields) skipFunds() error {
+	switch fundsType := f.next(); fundsType {
+	case "", "0", "1", "2", "Z":
+	case "V":
+		f.skip(2)
+	case "S":
+		f.skip(3)
+	case "D":
+		count, err := strconv.Atoi(f.next())
+		if err != nil || count < 0 {
+			return fmt.Errorf("invalid distributed availability count")
+		}
+		f.skip(2 * count)
+	default:
+		return fmt.Errorf("unknown funds type %q", fundsType)
+	}
+	return nil
+}
+
+// parseBAI2Amount parses an amount in the minor unit of the currency, with an optional sign
+func parseBAI2Amount(value, currency string) (float64, error) {
+	cents, err := strconv.ParseInt(strings.TrimPrefix(value, "+"), 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return float64(cents) / minorUnitScale(currency), nil
+}
@@ -0,0 +1,415 @@
+package reconcile
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"strings"
+)
+
+// CAMTSource reads the entries of ISO 20022 bank-to-customer messages: camt.053 statements, camt.054
+// debit/credit notifications and camt.052 account reports, in any version. The XML is decoded one entry
+// at a time, so memory does not grow with the file. An entry booking several transactions with their
+// own amounts, such as a batch, yields one statement entry per transaction.
+type CAMTSource struct {
+	name string
+	open func() (io.ReadCloser, error)
+}
+
+// CAMTFile reads the entries of a camt.052, camt.053 or camt.054 XML file
+func CAMTFile(path string) *CAMTSource {
+	return &CAMTSource{name: "bank statement file", open: openFile(path)}
+}
+
+// CAMT reads the entries of a camt message, which can only be iterated once
+func CAMT(r io.Reader) *CAMTSource {
+	return &CAMTSource{name: "bank statement", open: nopOpen(r)}
+}
+
+// camtContainers hold the account and the entries: Stmt in camt.053, Ntfctn in camt.054, Rpt in camt.052
+var camtContainers = []string{"Stmt", "Ntfctn", "Rpt"}
+
+// Transactions yields the entries of every statement, notification or report in the file, in file order
+func (s *CAMTSource) Transactions(ctx context.Context) iter.Seq2[StatementEntry, error] {
+	return func(yield func(StatementEntry, error) bool) {
+		err := s.walk(ctx, camtVisitor{
+			entry: func(entry StatementEntry) error {
+				if !yield(entry, nil) {
+					return errStopIteration
+				}
+				return nil
+			},
+		})
+		if err != nil && !errors.Is(err, errStopIteration) {
+			yield(StatementEntry{}, err)
+		}
+	}
+}
+
+// Statements yields every statement, notification or report with its entries, the opening balance being
+// the opening booked balance (OPBD), else the previous closing one (PRCD), and the closing balance CLBD
+func (s *CAMTSource) Statements(ctx context.Context) iter.Seq2[Statement, error] {
+	return func(yield func(Statement, error) bool) {
+		var current Statement
+		err := s.walk(ctx, camtVisitor{
+			entry: func(entry StatementEntry) error {
+				current.Entries = append(current.Entries, entry)
+				return nil
+			},
+			balance: func(code string, balance Balance) {
+				switch {
+				case code == "OPBD", code == "PRCD" && current.Opening == nil:
+					current.Opening = &balance
+				case code == "CLBD":
+					current.Closing = &balance
+				}
+			},
+			end: func(header Statement) error {
+				header.Opening, header.Closing, header.Entries = current.Opening, current.Closing, current.Entries
+				// An account given without its currency holds the currency of its entries
+				if header.Currency == "" && len(header.Entries) > 0 {
+					header.Currency = header.Entries[0].Currency
+				}
+				current = Statement{}
+				if !yield(header, nil) {
+					return errStopIteration
+				}
+				return nil
+			},
+		})
+		if err != nil && !errors.Is(err, errStopIteration) {
+			yield(Statement{}, err)
+		}
+	}
+}
+
+// camtVisitor receives what walk finds, any of its functions may be nil
+type camtVisitor struct {
+	entry   func(entry StatementEntry) error
+	balance func(code string, balance Balance)
+	end     func(header Statement) error // at the end of each container, with its ID, account and currency
+}
+
+// walk opens the file and walks it
+func (s *CAMTSource) walk(ctx context.Context, visitor camtVisitor) error {
+	r, err := s.open()
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", s.name, err)
+	}
+	defer r.Close()
+
+	return s.each(ctx, r, visitor)
+}
+
+// each walks the XML tree, decoding the identification, account and balances of each container and its entries
+func (s *CAMTSource) each(ctx context.Context, r io.Reader, visitor camtVisitor) error {
+	decoder := xml.NewDecoder(r)
+	isContainer := func(name string) bool {
+		for _, container := range camtContainers {
+			if name == container {
+				return true
+			}
+		}
+		return false
+	}
+
+	var stack []string
+	var header Statement
+	containers, entries := 0, 0
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			line, _ := decoder.InputPos()
+			return fmt.Errorf("%w: line %d: %v", ErrInvalidStatement, line, err)
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			inContainer := len(stack) > 0 && isContainer(stack[len(stack)-1])
+			switch {
+			case inContainer && t.Name.Local == "Id":
+				if err := decoder.DecodeElement(&header.ID, &t); err != nil {
+					return fmt.Errorf("%w: %v", ErrInvalidStatement, err)
+				}
+				header.ID = strings.TrimSpace(header.ID)
+				continue
+			case inContainer && t.Name.Local == "Acct":
+				var acct camtAccount
+				if err := decoder.DecodeElement(&acct, &t); err != nil {
+					return fmt.Errorf("%w: %v", ErrInvalidStatement, err)
+				}
+				header.Account, header.Currency = acct.id(), strings.TrimSpace(acct.Currency)
+				continue
+			case inContainer && t.Name.Local == "Bal":
+				line, _ := decoder.InputPos()
+				var bal camtBalance
+				if err := decoder.DecodeElement(&bal, &t); err != nil {
+					return fmt.Errorf("%w: balance at line %d: %v", ErrInvalidStatement, line, err)
+				}
+				balance, err := bal.balance()
+				if err != nil {
+					return fmt.Errorf("%w: balance at line %d: %v", ErrInvalidStatement, line, err)
+				}
+				if visitor.balance != nil {
+					visitor.balance(strings.TrimSpace(bal.Code), balance)
+				}
+				continue
+			case inContainer && t.Name.Local == "Ntry":
+				if entries%checkInterval == 0 {
+					if err := ctx.Err(); err != nil {
+						return err
+					}
+				}
+				entries++
+
+				line, _ := decoder.InputPos()
+				var entry camtEntry
+				if err := decoder.DecodeElement(&entry, &t); err != nil {
+					return fmt.Errorf("%w: entry at line %d: %v", ErrInvalidStatement, line, err)
+				}
+				lines, err := entry.statementEntries(header.ID, header.Account)
+				if err != nil {
+					return fmt.Errorf("%w: entry at line %d: %v", ErrInvalidStatement, line, err)
+				}
+				for _, line := range lines {
+					if visitor.entry != nil {
+						if err := visitor.entry(line); err != nil {
+							return err
+						}
+					}
+				}
+				continue
+			case isContainer(t.Name.Local):
+				header = Statement{}
+				containers++
+			}
+			stack = append(stack, t.Name.Local)
+		case xml.EndElement:
+			if len(stack) == 0 {
+				continue
+			}
+			if isContainer(stack[len(stack)-1]) && visitor.end != nil {
+				if err := visitor.end(header); err != nil {
+					return err
+				}
+			}
+			stack = stack[:len(stack)-1]
+		}
+	}
+
+	if containers == 0 {
+		return fmt.Errorf("%w: no statement, notification or report found in %s", ErrInvalidStatement, s.name)
+	}
+	return nil
+}
+
+// camtBalance is a Bal element
+type camtBalance struct {
+	Code        string     `xml:"Tp>CdOrPrtry>Cd"`
+	Amount      camtAmount `xml:"Amt"`
+	CreditDebit string     `xml:"CdtDbtInd"`
+	Date        camtDate   `xml:"Dt"`
+}
+
+// balance reads the amount, negative when debit, and the date
+func (b camtBalance) balance() (Balance, error) {
+	amount, err := parseStatementAmount(b.Amount.Value)
+	if err != nil {
+		return Balance{}, fmt.Errorf("invalid amount %q", b.Amount.Value)
+	}
+	if CreditDebit(strings.TrimSpace(b.CreditDebit)) == Debit {
+		amount = -amount
+	}
+	balance := Balance{Amount: amount, Currency: b.Amount.Currency}
+	if value := b.Date.value(); value != "" {
+		if balance.Date, err = parseStatementDate(value); err != nil {
+			return Balance{}, fmt.Errorf("invalid date %q", value)
+		}
+	}
+	return balance, nil
+}
+
+// camtAccount is the Acct of a statement, identified by IBAN or another scheme
+type camtAccount struct {
+	IBAN     string `xml:"Id>IBAN"`
+	Other    string `xml:"Id>Othr>Id"`
+	Currency string `xml:"Ccy"`
+}
+
+func (a camtAccount) id() string {
+	if a.IBAN != "" {
+		return strings.TrimSpace(a.IBAN)
+	}
+	return strings.TrimSpace(a.Other)
+}
+
+// camtAmount is an amount with its currency attribute
+type camtAmount struct {
+	Value    string `xml:",chardata"`
+	Currency string `xml:"Ccy,attr"`
+}
+
+// camtDate is a date or a date-time
+type camtDate struct {
+	Date     string `xml:"Dt"`
+	DateTime string `xml:"DtTm"`
+}
+
+func (d camtDate) value() string {
+	if d.DateTime != "" {
+		return d.DateTime
+	}
+	return d.Date
+}
+
+// camtEntry is an Ntry element
+type camtEntry struct {
+	EntryRef    string     `xml:"NtryRef"`
+	Amount      camtAmount `xml:"Amt"`
+	CreditDebit string     `xml:"CdtDbtInd"`
+	Status      struct {
+		Text string `xml:",chardata"` // up to version 7
+		Code string `xml:"Cd"`        // from version 8
+	} `xml:"Sts"`
+	BookingDate camtDate `xml:"BookgDt"`
+	ValueDate   camtDate `xml:"ValDt"`
+	ServicerRef string   `xml:"AcctSvcrRef"`
+	BankCode    struct {
+		Domain      string `xml:"Domn>Cd"`
+		Family      string `xml:"Domn>Fmly>Cd"`
+		SubFamily   string `xml:"Domn>Fmly>SubFmlyCd"`
+		Proprietary string `xml:"Prtry>Cd"`
+	} `xml:"BkTxCd"`
+	Details []camtTransaction `xml:"NtryDtls>TxDtls"`
+}
+
+// camtTransaction is a TxDtls element, one transaction booked by an entry
+type camtTransaction struct {
+	ServicerRef   string      `xml:"Refs>AcctSvcrRef"`
+	TransactionID string      `xml:"Refs>TxId"`
+	EndToEndID    string      `xml:"Refs>EndToEndId"`
+	Amount        *camtAmount `xml:"Amt"`
+	AmountDetails *camtAmount `xml:"AmtDtls>TxAmt>Amt"`
+	CreditDebit   string      `xml:"CdtDbtInd"`
+	Unstructured  []string    `xml:"RmtInf>Ustrd"`
+	CreditorRefs  []string    `xml:"RmtInf>Strd>CdtrRefInf>Ref"`
+}
+
+// amount is the amount of the transaction when the entry gives one
+func (t camtTransaction) amount() *camtAmount {
+	if t.Amount != nil {
+		return t.Amount
+	}
+	return t.AmountDetails
+}
+
+// statementEntries turns an entry into one statement entry, or one per transaction for a batch
+func (e camtEntry) statementEntries(statementID, account string) ([]StatementEntry, error) {
+	base := StatementEntry{
+		StatementID: statementID,
+		Account:     account,
+		Reference:   strings.TrimSpace(e.ServicerRef),
+		Currency:    e.Amount.Currency,
+		CreditDebit: CreditDebit(strings.TrimSpace(e.CreditDebit)),
+		Status:      strings.TrimSpace(e.Status.Code),
+	}
+	if base.Reference == "" {
+		base.Reference = strings.TrimSpace(e.EntryRef)
+	}
+	if base.Status == "" {
+		base.Status = strings.TrimSpace(e.Status.Text)
+	}
+	if base.CreditDebit != Credit && base.CreditDebit != Debit {
+		return nil, fmt.Errorf("credit/debit indicator %q is neither CRDT nor DBIT", e.CreditDebit)
+	}
+	switch {
+	case e.BankCode.Domain != "":
+		base.TransactionCode = strings.Join([]string{e.BankCode.Domain, e.BankCode.Family, e.BankCode.SubFamily}, "/")
+	default:
+		base.TransactionCode = strings.TrimSpace(e.BankCode.Proprietary)
+	}
+
+	var err error
+	if base.Amount, err = parseStatementAmount(e.Amount.Value); err != nil {
+		return nil, fmt.Errorf("invalid amount %q", e.Amount.Value)
+	}
+	if value := e.BookingDate.value(); value != "" {
+		if base.BookingDate, err = parseStatementDate(value); err != nil {
+			return nil, fmt.Errorf("invalid booking date %q", value)
+		}
+	}
+	if value := e.ValueDate.value(); value != "" {
+		if base.ValueDate, err = parseStatementDate(value); err != nil {
+			return nil, fmt.Errorf("invalid value date %q", value)
+		}
+	}
+
+	// A single transaction, or several without amounts of their own, describe the entry as a whole
+	batch := len(e.Details) > 1
+	for _, detail := range e.Details {
+		batch = batch && detail.amount() != nil
+	}
+	if !batch {
+		entry := base
+		if len(e.Details) > 0 {
+			e.Details[0].describe(&entry)
+		}
+		return []StatementEntry{entry}, nil
+	}
+
+	entries := make([]StatementEntry, len(e.Details))
+	for i, detail := range e.Details {
+		entry := base
+		entry.Reference = fmt.Sprintf("%s/%d", base.Reference, i+1)
+		detail.describe(&entry)
+		if detail.ServicerRef != "" {
+			entry.Reference = strings.TrimSpace(detail.ServicerRef)
+		}
+
+		amount := detail.amount()
+		if entry.Amount, err = parseStatementAmount(amount.Value); err != nil {
+			return nil, fmt.Errorf("invalid amount %q of transaction %d", amount.Value, i+1)
+		}
+		if amount.Currency != "" {
+			entry.Currency = amount.Currency
+		}
+		if indicator := CreditDebit(strings.TrimSpace(detail.CreditDebit)); indicator == Credit || indicator == Debit {
+			entry.CreditDebit = indicator
+		}
+		entries[i] = entry
+	}
+	return entries, nil
+}
+
+// describe copies the references and remittance information of a transaction onto an entry
+func (t camtTransaction) describe(entry *StatementEntry) {
+	entry.EndToEndID = strings.TrimSpace(t.EndToEndID)
+	if entry.EndToEndID == "NOTPROVIDED" {
+		entry.EndToEndID = ""
+	}
+
+	var lines []string
+	for _, ref := range t.CreditorRefs {
+		if ref = strings.TrimSpace(ref); ref != "" {
+			lines = append(lines, ref)
+			if entry.RemittanceRef == "" {
+				entry.RemittanceRef = ref
+			}
+		}
+	}
+	for _, line := range t.Unstructured {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+			if entry.RemittanceRef == "" {
+				entry.RemittanceRef = line
+			}
+		}
+	}
+	entry.RemittanceInfo = strings.Join(lines, " ")
+}
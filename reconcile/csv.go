@@ -0,0 +1,286 @@
+package reconcile
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Header rows of the source and system transactions files
+var (
+	SourceCSVHeader = []string{"providerTransactionId", "email", "userId", "provider", "amount", "currency", "status", "transactionType", "paymentMethod", "createdAt", "updatedAt", "providerReference", "fraudRisk", "details_invoiceId", "details_customerName", "details_description"}
+	SystemCSVHeader = []string{"transactionId", "userId", "amount", "currency", "status", "paymentMethod", "createdAt", "updatedAt", "referenceId", "metadata_orderId", "metadata_description"}
+)
+
+// CSVSource reads one side from a CSV file with a header row, one transaction per row. The record
+// buffer is reused between rows, so memory does not grow with the file.
+type CSVSource[T any] struct {
+	side    string
+	open    func() (io.ReadCloser, error)
+	dialect Dialect
+	values  ValueFormat
+	parse   func(values ValueFormat, record []string, line int) (T, error)
+}
+
+// SourceCSVFile reads source transactions from a CSV file laid out like source_transactions.csv
+func SourceCSVFile(path string) *CSVSource[SourceTransaction] {
+	return SourceDelimitedFile(path, Dialect{})
+}
+
+// SystemCSVFile reads system transactions from a CSV file laid out like system_transactions.csv
+func SystemCSVFile(path string) *CSVSource[SystemTransaction] {
+	return SystemDelimitedFile(path, Dialect{})
+}
+
+// SourceDelimitedFile reads source transactions from a file with the columns of source_transactions.csv
+// in another dialect, e.g. semicolon-delimited
+func SourceDelimitedFile(path string, dialect Dialect) *CSVSource[SourceTransaction] {
+	return &CSVSource[SourceTransaction]{side: "source", open: openFile(path), dialect: dialect, parse: ValueFormat.ParseSourceRecord}
+}
+
+// SystemDelimitedFile reads system transactions from a file with the columns of system_transactions.csv
+// in another dialect
+func SystemDelimitedFile(path string, dialect Dialect) *CSVSource[SystemTransaction] {
+	return &CSVSource[SystemTransaction]{side: "system", open: openFile(path), dialect: dialect, parse: ValueFormat.ParseSystemRecord}
+}
+
+// SourceCSV reads source transactions from CSV data, which can only be iterated once
+func SourceCSV(r io.Reader) *CSVSource[SourceTransaction] {
+	return &CSVSource[SourceTransaction]{side: "source", open: nopOpen(r), parse: ValueFormat.ParseSourceRecord}
+}
+
+// SystemCSV reads system transactions from CSV data, which can only be iterated once
+func SystemCSV(r io.Reader) *CSVSource[SystemTransaction] {
+	return &CSVSource[SystemTransaction]{side: "system", open: nopOpen(r), parse: ValueFormat.ParseSystemRecord}
+}
+
+// WithValues reads timestamps and amounts as values tells, returning s
+func (s *CSVSource[T]) WithValues(values ValueFormat) *CSVSource[T] {
+	s.values = values
+	return s
+}
+
+// openFile opens path on every iteration
+func openFile(path string) func() (io.ReadCloser, error) {
+	return func() (io.ReadCloser, error) { return os.Open(path) }
+}
+
+// nopOpen hands out a reader that is not closed after iterating
+func nopOpen(r io.Reader) func() (io.ReadCloser, error) {
+	return func() (io.ReadCloser, error) { return io.NopCloser(r), nil }
+}
+
+// Transactions yields one transaction per row after the header
+func (s *CSVSource[T]) Transactions(ctx context.Context) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var zero T
+		file, err := s.open()
+		if err != nil {
+			yield(zero, fmt.Errorf("failed to open %s transactions file: %w", s.side, err))
+			return
+		}
+		defer file.Close()
+
+		err = EachDelimitedRecord(ctx, file, s.dialect, func(record []string, line int) error {
+			txn, err := s.parse(s.values, record, line)
+			if err != nil {
+				return err
+			}
+			if !yield(txn, nil) {
+				return errStopIteration
+			}
+			return nil
+		})
+		if err != nil && !errors.Is(err, errStopIteration) {
+			yield(zero, err)
+		}
+	}
+}
+
+// ParseSourceRecord parses one row of a source transactions file, line is used in errors
+func ParseSourceRecord(record []string, line int) (SourceTransaction, error) {
+	return ValueFormat{}.ParseSourceRecord(record, line)
+}
+
+// ParseSourceRecord parses one row of a source transactions file with the timestamps and amounts of the format
+func (f ValueFormat) ParseSourceRecord(record []string, line int) (SourceTransaction, error) {
+	if len(record) < 16 {
+		return SourceTransaction{}, fmt.Errorf("invalid record at line %d: expected 16 fields, got %d", line, len(record))
+	}
+
+	amount, err := f.ParseAmountIn(record[4], record[5])
+	if err != nil {
+		return SourceTransaction{}, fmt.Errorf("invalid amount at line %d: %w", line, err)
+	}
+
+	createdAt, err := f.ParseTime("createdAt", record[9])
+	if err != nil {
+		return SourceTransaction{}, fmt.Errorf("invalid createdAt at line %d: %w", line, err)
+	}
+
+	updatedAt, err := f.ParseTime("updatedAt", record[10])
+	if err != nil {
+		return SourceTransaction{}, fmt.Errorf("invalid updatedAt at line %d: %w", line, err)
+	}
+
+	return SourceTransaction{
+		ProviderTransactionID: record[0],
+		Email:                 record[1],
+		UserID:                record[2],
+		Provider:              record[3],
+		Amount:                amount,
+		Currency:              record[5],
+		Status:                record[6],
+		TransactionType:       record[7],
+		PaymentMethod:         record[8],
+		CreatedAt:             createdAt,
+		UpdatedAt:             updatedAt,
+		ProviderReference:     record[11],
+		FraudRisk:             record[12],
+		DetailsInvoiceID:      record[13],
+		DetailsCustomerName:   record[14],
+		DetailsDescription:    record[15],
+	}, nil
+}
+
+// ParseSystemRecord parses one row of a system transactions file, line is used in errors
+func ParseSystemRecord(record []string, line int) (SystemTransaction, error) {
+	return ValueFormat{}.ParseSystemRecord(record, line)
+}
+
+// ParseSystemRecord parses one row of a system transactions file with the timestamps and amounts of the format
+func (f ValueFormat) ParseSystemRecord(record []string, line int) (SystemTransaction, error) {
+	if len(record) < 11 {
+		return SystemTransaction{}, fmt.Errorf("invalid record at line %d: expected 11 fields, got %d", line, len(record))
+	}
+
+	amount, err := f.ParseAmountIn(record[2], record[3])
+	if err != nil {
+		return SystemTransaction{}, fmt.Errorf("invalid amount at line %d: %w", line, err)
+	}
+
+	createdAt, err := f.ParseTime("createdAt", record[6])
+	if err != nil {
+		return SystemTransaction{}, fmt.Errorf("invalid createdAt at line %d: %w", line, err)
+	}
+
+	updatedAt, err := f.ParseTime("updatedAt", record[7])
+	if err != nil {
+		return SystemTransaction{}, fmt.Errorf("invalid updatedAt at line %d: %w", line, err)
+	}
+
+	return SystemTransaction{
+		TransactionID:       record[0],
+		UserID:              record[1],
+		Amount:              amount,
+		Currency:            record[3],
+		Status:              record[4],
+		PaymentMethod:       record[5],
+		CreatedAt:           createdAt,
+		UpdatedAt:           updatedAt,
+		ReferenceID:         record[8],
+		MetadataOrderID:     record[9],
+		MetadataDescription: record[10],
+	}, nil
+}
+
+// FormatSourceRecord writes a source transaction as a row of a source transactions file
+func FormatSourceRecord(txn SourceTransaction) []string {
+	return []string{
+		txn.ProviderTransactionID,
+		txn.Email,
+		txn.UserID,
+		txn.Provider,
+		strconv.FormatFloat(txn.Amount, 'f', -1, 64),
+		txn.Currency,
+		txn.Status,
+		txn.TransactionType,
+		txn.PaymentMethod,
+		txn.CreatedAt.Format(time.RFC3339Nano),
+		txn.UpdatedAt.Format(time.RFC3339Nano),
+		txn.ProviderReference,
+		txn.FraudRisk,
+		txn.DetailsInvoiceID,
+		txn.DetailsCustomerName,
+		txn.DetailsDescription,
+	}
+}
+
+// FormatSystemRecord writes a system transaction as a row of a system transactions file
+func FormatSystemRecord(txn SystemTransaction) []string {
+	return []string{
+		txn.TransactionID,
+		txn.UserID,
+		strconv.FormatFloat(txn.Amount, 'f', -1, 64),
+		txn.Currency,
+		txn.Status,
+		txn.PaymentMethod,
+		txn.CreatedAt.Format(time.RFC3339Nano),
+		txn.UpdatedAt.Format(time.RFC3339Nano),
+		txn.ReferenceID,
+		txn.MetadataOrderID,
+		txn.MetadataDescription,
+	}
+}
+
+// errStopIteration ends EachCSVRecord early when the consumer of an iterator stops
+var errStopIteration = errors.New("iteration stopped")
+
+// EachCSVRecord calls fn with every data row of a CSV file after its header and its line number, stopping
+// at the first error. The record slice is reused for the next row, and ctx is checked every checkInterval rows.
+func EachCSVRecord(ctx context.Context, file io.Reader, fn func(record []string, line int) error) error {
+	return EachDelimitedRecord(ctx, file, Dialect{}, fn)
+}
+
+// EachDelimitedRecord calls fn with every data row of a file in the dialect, like EachCSVRecord. Every
+// row must have as many fields as the header once a trailing delimiter is dropped.
+func EachDelimitedRecord(ctx context.Context, file io.Reader, dialect Dialect, fn func(record []string, line int) error) error {
+	reader, err := dialect.newRecordReader(file)
+	if err != nil {
+		return err
+	}
+
+	// Skip header row
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return fmt.Errorf("CSV file is empty")
+	} else if err != nil {
+		return fmt.Errorf("failed to read CSV records: %w", err)
+	}
+	columns := len(header)
+	if dialect.TrailingDelimiter && columns > 1 && header[columns-1] == "" {
+		columns--
+	}
+
+	for row := 0; ; row++ {
+		if row%checkInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+		}
+
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read CSV records: %w", err)
+		}
+		if dialect.TrailingDelimiter && len(record) == columns+1 && record[columns] == "" {
+			record = record[:columns]
+		}
+		// The line the row starts at, comments, blank lines and fields over several lines included
+		line, _ := reader.FieldPos(0)
+		if len(record) != columns {
+			return fmt.Errorf("failed to read CSV records: record on line %d: expected %d fields, got %d", line, columns, len(record))
+		}
+		if err := fn(record, line); err != nil {
+			return err
+		}
+	}
+}
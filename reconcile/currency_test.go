@@ -0,0 +1,31 @@
+package reconcile
+
+import "testing"
+
+func TestMinorUnitScale(t *testing.T) {
+	tests := []struct {
+		currency string
+		want     float64
+	}{
+		{"USD", 100},
+		{"eur", 100},
+		{"JPY", 1},
+		{"CLP", 1},
+		{"ISK", 1},
+		{"VND", 1},
+		{"XOF", 1},
+		{"KWD", 1000},
+		{"IQD", 1000},
+		{"LYD", 1000},
+		{"CLF", 10000},
+		{"XYZ", 100},
+	}
+	for _, tt := range tests {
+		if got := minorUnitScale(tt.currency); got != tt.want {
+			t.Errorf("minorUnitScale(%s) = %v, want %v", tt.currency, got, tt.want)
+		}
+	}
+	if _, ok := CurrencyExponent("XYZ"); ok {
+		t.Error("XYZ is known as a currency")
+	}
+}
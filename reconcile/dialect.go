@@ -0,0 +1,250 @@
+package reconcile
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// NoQuote is the Dialect.Quote of files whose fields are never quoted
+const NoQuote rune = -1
+
+// Dialect is the flavour of a delimited file, such as the semicolon or pipe separated exports of core
+// banking systems. The zero Dialect is comma-delimited RFC 4180.
+type Dialect struct {
+	Delimiter         rune // separates fields, ',' when zero
+	Quote             rune // encloses fields holding delimiters or line breaks, doubled inside them; '"' when zero
+	Comment           rune // starts lines that are skipped, none when zero
+	LazyQuotes        bool // quotes inside unquoted fields and stray quotes inside quoted ones are kept as text
+	TrailingDelimiter bool // rows may end with a delimiter, the empty field after it being dropped
+}
+
+// ParseDialect reads a dialect from space-separated settings, e.g. "delimiter=; quote=' comment=#
+// lazy-quotes trailing-delimiter". Characters may be given by name: tab, space, pipe, semicolon, comma,
+// and none for the quote.
+func ParseDialect(spec string) (Dialect, error) {
+	var d Dialect
+	for _, setting := range strings.Fields(spec) {
+		key, value, _ := strings.Cut(setting, "=")
+		var err error
+		switch key {
+		case "delimiter":
+			d.Delimiter, err = parseDialectChar(value)
+		case "quote":
+			if value == "none" {
+				d.Quote = NoQuote
+			} else {
+				d.Quote, err = parseDialectChar(value)
+			}
+		case "comment":
+			d.Comment, err = parseDialectChar(value)
+		case "lazy-quotes":
+			d.LazyQuotes = true
+		case "trailing-delimiter":
+			d.TrailingDelimiter = true
+		default:
+			return Dialect{}, fmt.Errorf("unknown dialect setting %q, expected delimiter, quote, comment, lazy-quotes or trailing-delimiter", key)
+		}
+		if err != nil {
+			return Dialect{}, fmt.Errorf("invalid %s: %w", key, err)
+		}
+	}
+	return d, d.validate()
+}
+
+// dialectChars are the characters that are awkward to give on a command line
+var dialectChars = map[string]rune{"tab": '\t', "space": ' ', "pipe": '|', "semicolon": ';', "comma": ','}
+
+// parseDialectChar reads a single character or its name
+func parseDialectChar(value string) (rune, error) {
+	if c, ok := dialectChars[value]; ok {
+		return c, nil
+	}
+	if value == `\t` {
+		return '\t', nil
+	}
+	if utf8.RuneCountInString(value) != 1 {
+		return 0, fmt.Errorf("%q is not a single character", value)
+	}
+	c, _ := utf8.DecodeRuneInString(value)
+	return c, nil
+}
+
+// delimiter is the dialect's delimiter, the comma by default
+func (d Dialect) delimiter() rune {
+	if d.Delimiter == 0 {
+		return ','
+	}
+	return d.Delimiter
+}
+
+// quote is the dialect's quote, NoQuote included, the double quote by default
+func (d Dialect) quote() rune {
+	if d.Quote == 0 {
+		return '"'
+	}
+	return d.Quote
+}
+
+// validate rejects dialects whose characters cannot be told apart
+func (d Dialect) validate() error {
+	delimiter, quote := d.delimiter(), d.quote()
+	switch {
+	case delimiter == '\r' || delimiter == '\n' || delimiter == utf8.RuneError:
+		return fmt.Errorf("invalid dialect: %q cannot be the delimiter", delimiter)
+	case delimiter == quote:
+		return fmt.Errorf("invalid dialect: the delimiter and the quote are both %q", delimiter)
+	case d.Comment != 0 && (d.Comment == delimiter || d.Comment == quote):
+		return fmt.Errorf("invalid dialect: the comment character %q is also the delimiter or the quote", d.Comment)
+	}
+	return nil
+}
+
+// recordReader reads the rows of a delimited file
+type recordReader interface {
+	Read() ([]string, error)
+	FieldPos(field int) (line, column int) // where a field of the last row starts, like csv.Reader.FieldPos
+}
+
+// newRecordReader reads rows in the dialect, with encoding/csv when its quote is the double quote it
+// supports, record slices being reused between rows
+func (d Dialect) newRecordReader(r io.Reader) (recordReader, error) {
+	if err := d.validate(); err != nil {
+		return nil, err
+	}
+	if d.quote() == '"' {
+		reader := csv.NewReader(r)
+		reader.ReuseRecord = true
+		reader.Comma = d.delimiter()
+		reader.Comment = d.Comment
+		reader.LazyQuotes = d.LazyQuotes
+		if d.TrailingDelimiter {
+			reader.FieldsPerRecord = -1
+		}
+		return reader, nil
+	}
+	return &quotedReader{r: bufio.NewReader(r), dialect: d, delimiter: d.delimiter(), quote: d.quote()}, nil
+}
+
+// quotedReader splits rows quoted with another character than the double quote, or not quoted at all,
+// following the rules of encoding/csv otherwise: empty lines are skipped and quoted fields may span lines
+type quotedReader struct {
+	r                *bufio.Reader
+	dialect          Dialect
+	delimiter, quote rune
+	fields           []string
+	positions        [][2]int // line and column each field of the last row starts at
+	field            strings.Builder
+	line             int // lines read so far, for errors
+}
+
+// Read returns the next row, the slice being reused by the next call
+func (q *quotedReader) Read() ([]string, error) {
+	line, err := q.nextLine()
+	if err != nil {
+		return nil, err
+	}
+
+	start := q.line
+	q.fields = q.fields[:0]
+	q.positions = append(q.positions[:0], [2]int{start, 1})
+	q.field.Reset()
+	quoted, fieldStart := false, true
+	for {
+		for i := 0; i < len(line); {
+			c, size := utf8.DecodeRuneInString(line[i:])
+			i += size
+			switch {
+			case quoted && c == q.quote:
+				next, nextSize := utf8.DecodeRuneInString(line[i:])
+				switch {
+				case nextSize > 0 && next == q.quote:
+					q.field.WriteRune(c)
+					i += nextSize
+				case nextSize == 0 || next == q.delimiter:
+					quoted = false
+				case q.dialect.LazyQuotes:
+					q.field.WriteRune(c)
+				default:
+					return nil, fmt.Errorf("record on line %d: extraneous %q after a quoted field", q.line, c)
+				}
+			case quoted:
+				q.field.WriteRune(c)
+			case c == q.delimiter:
+				q.fields = append(q.fields, q.field.String())
+				q.positions = append(q.positions, [2]int{q.line, i + 1})
+				q.field.Reset()
+				fieldStart = true
+				continue
+			case c == q.quote && fieldStart:
+				quoted = true
+			case c == q.quote && !q.dialect.LazyQuotes:
+				return nil, fmt.Errorf("record on line %d: bare %q in an unquoted field", q.line, c)
+			default:
+				q.field.WriteRune(c)
+			}
+			fieldStart = false
+		}
+		if !quoted {
+			break
+		}
+
+		// The quoted field goes on with the next line
+		more, err := q.readLine()
+		if errors.Is(err, io.EOF) {
+			if q.dialect.LazyQuotes {
+				break
+			}
+			return nil, fmt.Errorf("record on line %d: quoted field is not closed", start)
+		}
+		if err != nil {
+			return nil, err
+		}
+		q.field.WriteByte('\n')
+		line = more
+	}
+	q.fields = append(q.fields, q.field.String())
+	return q.fields, nil
+}
+
+// FieldPos returns the line and the 1-based byte column a field of the last row starts at
+func (q *quotedReader) FieldPos(field int) (line, column int) {
+	if field < 0 || field >= len(q.positions) {
+		panic("out of range index passed to FieldPos")
+	}
+	return q.positions[field][0], q.positions[field][1]
+}
+
+// nextLine returns the next line that is neither empty nor a comment
+func (q *quotedReader) nextLine() (string, error) {
+	for {
+		line, err := q.readLine()
+		if err != nil {
+			return "", err
+		}
+		if line == "" {
+			continue
+		}
+		if q.dialect.Comment != 0 && strings.HasPrefix(line, string(q.dialect.Comment)) {
+			continue
+		}
+		return line, nil
+	}
+}
+
+// readLine returns the next line without its line ending, io.EOF once the file is read
+func (q *quotedReader) readLine() (string, error) {
+	line, err := q.r.ReadString('\n')
+	if errors.Is(err, io.EOF) && line == "" {
+		return "", io.EOF
+	}
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	q.line++
+	return strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r"), nil
+}
@@ -0,0 +1,136 @@
+package reconcile
+
+import (
+	"context"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseDialect(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    Dialect
+		wantErr bool
+	}{
+		{spec: "", want: Dialect{}},
+		{spec: "delimiter=; quote=' comment=# lazy-quotes trailing-delimiter", want: Dialect{Delimiter: ';', Quote: '\'', Comment: '#', LazyQuotes: true, TrailingDelimiter: true}},
+		{spec: "delimiter=tab quote=none", want: Dialect{Delimiter: '\t', Quote: NoQuote}},
+		{spec: `delimiter=\t`, want: Dialect{Delimiter: '\t'}},
+		{spec: "delimiter=pipe", want: Dialect{Delimiter: '|'}},
+		{spec: "delimiter=;;", wantErr: true},
+		{spec: "delimiter=\" ", wantErr: true},
+		{spec: "delimiter=; comment=;", wantErr: true},
+		{spec: "separator=;", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseDialect(tt.spec)
+		if (err != nil) != tt.wantErr || !tt.wantErr && got != tt.want {
+			t.Errorf("ParseDialect(%q) = %+v, %v, want %+v, error %t", tt.spec, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestEachDelimitedRecord(t *testing.T) {
+	tests := []struct {
+		name      string
+		dialect   Dialect
+		input     string
+		want      [][]string
+		wantLines []int
+		wantErr   string
+	}{
+		{
+			name:      "comma",
+			input:     "id,amount\na,1\nb,2\n",
+			want:      [][]string{{"a", "1"}, {"b", "2"}},
+			wantLines: []int{2, 3},
+		},
+		{
+			name:      "comments, blank lines and a field over two lines",
+			dialect:   Dialect{Comment: '#'},
+			input:     "# export\nid,amount,text\n\na,1,\"two\nlines\"\n# note\nb,2,x\n",
+			want:      [][]string{{"a", "1", "two\nlines"}, {"b", "2", "x"}},
+			wantLines: []int{4, 7},
+		},
+		{
+			name:      "single quotes, comments, blank lines and a field over two lines",
+			dialect:   Dialect{Delimiter: ';', Quote: '\'', Comment: '#'},
+			input:     "# export\nid;amount;text\n\na;1;'two\nlines'\n# note\nb;2;'it''s'\n",
+			want:      [][]string{{"a", "1", "two\nlines"}, {"b", "2", "it's"}},
+			wantLines: []int{4, 7},
+		},
+		{
+			name:      "trailing delimiter",
+			dialect:   Dialect{Delimiter: '|', Quote: NoQuote, TrailingDelimiter: true},
+			input:     "id|amount|\r\na|1|\r\nb|\"2\"|\r\n",
+			want:      [][]string{{"a", "1"}, {"b", `"2"`}},
+			wantLines: []int{2, 3},
+		},
+		{
+			name:    "missing field after a field over two lines",
+			input:   "id,amount,text\na,1,\"two\nlines\"\n\nb,2\n",
+			wantErr: "record on line 5",
+		},
+		{
+			name:    "missing field with single quotes",
+			dialect: Dialect{Quote: '\''},
+			input:   "id,amount,text\na,1,'two\nlines'\n\nb,2\n",
+			wantErr: "record on line 5: expected 3 fields, got 2",
+		},
+		{
+			name:    "unclosed quote",
+			dialect: Dialect{Quote: '\''},
+			input:   "id,text\na,'open\n",
+			wantErr: "record on line 2: quoted field is not closed",
+		},
+		{
+			name:    "bare quote",
+			dialect: Dialect{Quote: '\''},
+			input:   "id,text\na,it's\n",
+			wantErr: "record on line 2: bare",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got [][]string
+			var lines []int
+			err := EachDelimitedRecord(context.Background(), strings.NewReader(tt.input), tt.dialect, func(record []string, line int) error {
+				got = append(got, slices.Clone(record))
+				lines = append(lines, line)
+				return nil
+			})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) || !slices.Equal(lines, tt.wantLines) {
+				t.Errorf("records = %q on lines %v, want %q on lines %v", got, lines, tt.want, tt.wantLines)
+			}
+		})
+	}
+}
+
+func TestDelimitedSample(t *testing.T) {
+	dialect, err := ParseDialect("delimiter=; quote=' comment=# trailing-delimiter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := Collect(context.Background(), SourceDelimitedFile("../assets/data/legacy/source_transactions_semicolon.csv", dialect))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := Collect(context.Background(), SourceCSVFile("../assets/data/csvs/source_transactions.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("the semicolon-delimited sample reads as %d transactions differing from the CSV sample's %d", len(got), len(want))
+	}
+}
@@ -0,0 +1,13 @@
+// Package reconcile matches the transactions of an external provider against those of an internal system
+// by transaction ID, and reports the transactions missing on either side and the pairs whose fields differ.
+//
+// A Reconciler reads both sides from a TransactionSource, such as a CSV file, JSON, an API endpoint or a
+// database query, compares each pair with a Matcher and writes the result to any number of ResultSinks:
+//
+//	reconciler := reconcile.New(reconcile.WithWorkers(4))
+//	result, err := reconciler.Run(ctx,
+//		reconcile.SourceCSVFile("source_transactions.csv"),
+//		reconcile.SystemCSVFile("system_transactions.csv"),
+//		reconcile.FileSink{Path: "reconciliation_report.json"},
+//	)
+package reconcile
@@ -0,0 +1,187 @@
+package reconcile
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"os"
+	"slices"
+	"strings"
+)
+
+// FixedWidthLayout is the column-position spec of a fixed-width file, as in the record layouts of core
+// banking exports
+type FixedWidthLayout struct {
+	Skip         int                `json:"skip,omitempty"`         // lines before the records, such as a header
+	RecordPrefix string             `json:"recordPrefix,omitempty"` // when set, lines not starting with it, such as trailers, are skipped
+	Columns      []FixedWidthColumn `json:"columns"`
+}
+
+// FixedWidthColumn is where a field is on a line of a fixed-width file
+type FixedWidthColumn struct {
+	Name     string `json:"name"`               // the field's name in the CSV header, e.g. transactionId
+	Start    int    `json:"start"`              // position of the first character, from 1
+	Width    int    `json:"width"`              // characters, padding included
+	Decimals int    `json:"decimals,omitempty"` // implied decimal places of an amount without a decimal point
+}
+
+// LoadFixedWidthLayout reads a layout from a JSON file
+func LoadFixedWidthLayout(path string) (FixedWidthLayout, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return FixedWidthLayout{}, fmt.Errorf("failed to read fixed-width layout: %w", err)
+	}
+	var layout FixedWidthLayout
+	if err := json.Unmarshal(data, &layout); err != nil {
+		return FixedWidthLayout{}, fmt.Errorf("failed to parse fixed-width layout %s: %w", path, err)
+	}
+	return layout, nil
+}
+
+// FixedWidthSource reads one side from a fixed-width file, one transaction per line. Each column of the
+// layout is cut out of the line and trimmed of its padding, then the fields are parsed like a row of the
+// CSV file, so columns the layout leaves out are empty.
+type FixedWidthSource[T any] struct {
+	side   string
+	open   func() (io.ReadCloser, error)
+	layout FixedWidthLayout
+	header []string
+	values ValueFormat
+	parse  func(values ValueFormat, record []string, line int) (T, error)
+}
+
+// SourceFixedWidthFile reads source transactions from a fixed-width file, the layout naming the columns
+// after SourceCSVHeader
+func SourceFixedWidthFile(path string, layout FixedWidthLayout) *FixedWidthSource[SourceTransaction] {
+	return &FixedWidthSource[SourceTransaction]{side: "source", open: openFile(path), layout: layout, header: SourceCSVHeader, parse: ValueFormat.ParseSourceRecord}
+}
+
+// SystemFixedWidthFile reads system transactions from a fixed-width file, the layout naming the columns
+// after SystemCSVHeader
+func SystemFixedWidthFile(path string, layout FixedWidthLayout) *FixedWidthSource[SystemTransaction] {
+	return &FixedWidthSource[SystemTransaction]{side: "system", open: openFile(path), layout: layout, header: SystemCSVHeader, parse: ValueFormat.ParseSystemRecord}
+}
+
+// WithValues reads timestamps and amounts as values tells, returning s
+func (s *FixedWidthSource[T]) WithValues(values ValueFormat) *FixedWidthSource[T] {
+	s.values = values
+	return s
+}
+
+// Transactions yields one transaction per record line
+func (s *FixedWidthSource[T]) Transactions(ctx context.Context) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var zero T
+		fields, err := s.fields()
+		if err != nil {
+			yield(zero, fmt.Errorf("invalid %s fixed-width layout: %w", s.side, err))
+			return
+		}
+		file, err := s.open()
+		if err != nil {
+			yield(zero, fmt.Errorf("failed to open %s transactions file: %w", s.side, err))
+			return
+		}
+		defer file.Close()
+
+		if err := s.each(ctx, file, fields, yield); err != nil && !errors.Is(err, errStopIteration) {
+			yield(zero, err)
+		}
+	}
+}
+
+// fields checks the layout, returning the field of each of its columns
+func (s *FixedWidthSource[T]) fields() ([]int, error) {
+	if len(s.layout.Columns) == 0 {
+		return nil, errors.New("no columns")
+	}
+	fields := make([]int, len(s.layout.Columns))
+	for i, column := range s.layout.Columns {
+		fields[i] = slices.Index(s.header, column.Name)
+		switch {
+		case fields[i] < 0:
+			return nil, fmt.Errorf("unknown column %q, expected one of %s", column.Name, strings.Join(s.header, ", "))
+		case column.Start < 1 || column.Width < 1:
+			return nil, fmt.Errorf("column %q needs a start from 1 and a positive width", column.Name)
+		case column.Decimals < 0:
+			return nil, fmt.Errorf("column %q has negative decimals", column.Name)
+		}
+	}
+	if !slices.Contains(fields, 0) {
+		return nil, fmt.Errorf("no %s column", s.header[0])
+	}
+	return fields, nil
+}
+
+// each cuts every record line into its fields and parses them
+func (s *FixedWidthSource[T]) each(ctx context.Context, r io.Reader, fields []int, yield func(T, error) bool) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	record := make([]string, len(s.header))
+	for line := 1; scanner.Scan(); line++ {
+		if line%checkInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+		}
+
+		text := strings.TrimSuffix(scanner.Text(), "\r")
+		if line <= s.layout.Skip || strings.TrimSpace(text) == "" {
+			continue
+		}
+		if s.layout.RecordPrefix != "" && !strings.HasPrefix(text, s.layout.RecordPrefix) {
+			continue
+		}
+
+		runes := []rune(text)
+		clear(record)
+		for i, column := range s.layout.Columns {
+			start := min(column.Start-1, len(runes))
+			end := min(start+column.Width, len(runes))
+			value := strings.TrimSpace(string(runes[start:end]))
+			if column.Decimals > 0 {
+				value = impliedDecimals(value, column.Decimals, s.values.decimal())
+			}
+			record[fields[i]] = value
+		}
+
+		txn, err := s.parse(s.values, record, line)
+		if err != nil {
+			return err
+		}
+		if !yield(txn, nil) {
+			return errStopIteration
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read %s transactions file: %w", s.side, err)
+	}
+	return nil
+}
+
+// impliedDecimals puts the decimal separator into an amount written without it, 0000083730 with two
+// decimals being 837.30. A sign may lead or trail the digits, as in COBOL's 0000083730-.
+func impliedDecimals(value string, decimals int, separator rune) string {
+	if value == "" || strings.ContainsRune(value, separator) {
+		return value
+	}
+	sign := ""
+	switch {
+	case strings.HasPrefix(value, "-"), strings.HasPrefix(value, "+"):
+		sign, value = value[:1], value[1:]
+	case strings.HasSuffix(value, "-"), strings.HasSuffix(value, "+"):
+		sign, value = value[len(value)-1:], value[:len(value)-1]
+	}
+	if sign == "+" {
+		sign = ""
+	}
+	if pad := decimals + 1 - len(value); pad > 0 {
+		value = strings.Repeat("0", pad) + value
+	}
+	return sign + value[:len(value)-decimals] + string(separator) + value[len(value)-decimals:]
+}
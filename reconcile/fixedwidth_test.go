@@ -0,0 +1,136 @@
+package reconcile
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFixedWidthSample(t *testing.T) {
+	layout, err := LoadFixedWidthLayout("../assets/data/legacy/system_layout.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := Collect(context.Background(), SystemFixedWidthFile("../assets/data/legacy/system_transactions.dat", layout))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := Collect(context.Background(), SystemCSVFile("../assets/data/csvs/system_transactions.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("the fixed-width sample reads as %d transactions differing from the CSV sample's %d", len(got), len(want))
+	}
+}
+
+func TestFixedWidthSource(t *testing.T) {
+	layout := FixedWidthLayout{
+		Skip:         1,
+		RecordPrefix: "D",
+		Columns: []FixedWidthColumn{
+			{Name: "transactionId", Start: 2, Width: 4},
+			{Name: "amount", Start: 6, Width: 8, Decimals: 2},
+			{Name: "currency", Start: 14, Width: 3},
+			{Name: "createdAt", Start: 17, Width: 20},
+			{Name: "updatedAt", Start: 37, Width: 20},
+			{Name: "metadata_description", Start: 57, Width: 40},
+		},
+	}
+	const at = "2024-01-01T00:00:00Z2024-01-02T00:00:00Z"
+	tests := []struct {
+		name    string
+		layout  FixedWidthLayout
+		lines   []string
+		want    []float64
+		wantErr string
+	}{
+		{
+			name:   "header, trailer and blank lines skipped",
+			layout: layout,
+			lines:  []string{"H EXPORT", "Dt1  00083730EUR" + at + "card settlement", "", "Dt2  0000125-USD" + at, "T 2"},
+			want:   []float64{837.30, -1.25},
+		},
+		{
+			name:   "line ending before the last column",
+			layout: layout,
+			lines:  []string{"H EXPORT", "Dt1  00000050EUR" + at},
+			want:   []float64{0.50},
+		},
+		{
+			name:    "line of the failing record",
+			layout:  layout,
+			lines:   []string{"H EXPORT", "Dt1  00000050EUR" + at, "T 1", "Dt2  0000ten0EUR" + at},
+			wantErr: "line 4",
+		},
+		{
+			name:    "unknown column",
+			layout:  FixedWidthLayout{Columns: []FixedWidthColumn{{Name: "transactionId", Start: 1, Width: 4}, {Name: "iban", Start: 5, Width: 22}}},
+			wantErr: `unknown column "iban"`,
+		},
+		{
+			name:    "column without a width",
+			layout:  FixedWidthLayout{Columns: []FixedWidthColumn{{Name: "transactionId", Start: 1}}},
+			wantErr: "needs a start from 1 and a positive width",
+		},
+		{
+			name:    "no ID column",
+			layout:  FixedWidthLayout{Columns: []FixedWidthColumn{{Name: "amount", Start: 1, Width: 8}}},
+			wantErr: "no transactionId column",
+		},
+		{
+			name:    "no columns",
+			wantErr: "no columns",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "system.dat")
+			if err := os.WriteFile(path, []byte(strings.Join(tt.lines, "\n")), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			transactions, err := Collect(context.Background(), SystemFixedWidthFile(path, tt.layout))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var amounts []float64
+			for _, txn := range transactions {
+				amounts = append(amounts, txn.Amount)
+			}
+			if !reflect.DeepEqual(amounts, tt.want) {
+				t.Errorf("amounts = %v, want %v", amounts, tt.want)
+			}
+		})
+	}
+}
+
+func TestImpliedDecimals(t *testing.T) {
+	tests := []struct {
+		value     string
+		decimals  int
+		separator rune
+		want      string
+	}{
+		{"0000083730", 2, '.', "00000837.30"},
+		{"0000083730-", 2, '.', "-00000837.30"},
+		{"+5", 2, '.', "0.05"},
+		{"-5", 3, ',', "-0,005"},
+		{"837.30", 2, '.', "837.30"},
+		{"837,30", 2, ',', "837,30"},
+		{"", 2, '.', ""},
+	}
+	for _, tt := range tests {
+		if got := impliedDecimals(tt.value, tt.decimals, tt.separator); got != tt.want {
+			t.Errorf("impliedDecimals(%q, %d, %q) = %q, want %q", tt.value, tt.decimals, tt.separator, got, tt.want)
+		}
+	}
+}
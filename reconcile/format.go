@@ -0,0 +1,84 @@
+package reconcile
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Format is the layout of a transactions file
+type Format string
+
+const (
+	FormatCSV    Format = "csv"
+	FormatJSON   Format = "json"   // a JSON array of transactions
+	FormatNDJSON Format = "ndjson" // one JSON transaction per line
+	FormatXLSX   Format = "xlsx"   // the first sheet of an Excel workbook
+	FormatStripe Format = "stripe" // a Stripe balance transactions export or payout reconciliation report, source side only
+	FormatPayPal Format = "paypal" // a PayPal Settlement Report, source side only
+)
+
+// ParseFormat reads a format name, empty meaning the format of the extension
+func ParseFormat(name string) (Format, error) {
+	switch format := Format(strings.ToLower(name)); format {
+	case "", FormatCSV, FormatJSON, FormatNDJSON, FormatXLSX, FormatStripe, FormatPayPal:
+		return format, nil
+	}
+	return "", fmt.Errorf("unknown format %q, expected csv, json, ndjson, xlsx, stripe or paypal", name)
+}
+
+// FormatOf tells the format of a file from its extension: .json, .ndjson or .jsonl, .xlsx, and CSV for
+// anything else
+func FormatOf(path string) Format {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return FormatJSON
+	case ".ndjson", ".jsonl":
+		return FormatNDJSON
+	case ".xlsx":
+		return FormatXLSX
+	}
+	return FormatCSV
+}
+
+// SourceFile reads source transactions from a file in the format of its extension
+func SourceFile(path string) TransactionSource[SourceTransaction] {
+	return SourceFileAs(path, FormatOf(path))
+}
+
+// SourceFileAs reads source transactions from a file in the given format, for the providers' own reports
+// that cannot be told apart by their extension
+func SourceFileAs(path string, format Format) TransactionSource[SourceTransaction] {
+	switch format {
+	case FormatJSON:
+		return SourceJSONFile(path)
+	case FormatNDJSON:
+		return SourceNDJSONFile(path)
+	case FormatXLSX:
+		return SourceXLSXFile(path, "", nil)
+	case FormatStripe:
+		return StripeFile(path)
+	case FormatPayPal:
+		return PayPalSettlementFile(path)
+	}
+	return SourceCSVFile(path)
+}
+
+// SystemFile reads system transactions from a file in the format of its extension
+func SystemFile(path string) TransactionSource[SystemTransaction] {
+	return SystemFileAs(path, FormatOf(path))
+}
+
+// SystemFileAs reads system transactions from a file in the given format, whatever its extension. The
+// providers' report formats hold source transactions only and are read as CSV.
+func SystemFileAs(path string, format Format) TransactionSource[SystemTransaction] {
+	switch format {
+	case FormatJSON:
+		return SystemJSONFile(path)
+	case FormatNDJSON:
+		return SystemNDJSONFile(path)
+	case FormatXLSX:
+		return SystemXLSXFile(path, "", nil)
+	}
+	return SystemCSVFile(path)
+}
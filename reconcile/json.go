@@ -0,0 +1,286 @@
+package reconcile
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"net/http"
+)
+
+// JSONSource reads one side from a JSON array of transactions, or from newline-delimited JSON with one
+// transaction per line. Transactions are decoded one at a time, so memory does not grow with the input,
+// and errors give the line the transaction starts at.
+type JSONSource[T any] struct {
+	name   string // what is read, for errors
+	ndjson bool
+	open   func(ctx context.Context) (io.ReadCloser, error)
+	decode func(data []byte) (T, error)
+}
+
+// SourceJSONFile reads source transactions from a file holding a JSON array. Nested objects are flattened
+// into the CSV header names, {"details": {"invoiceId": ...}} filling details_invoiceId.
+func SourceJSONFile(path string) *JSONSource[SourceTransaction] {
+	return &JSONSource[SourceTransaction]{name: "source transactions file", open: openFileContext(path), decode: decodeFlattened[SourceTransaction]}
+}
+
+// SystemJSONFile reads system transactions from a file holding a JSON array, {"metadata": {"orderId": ...}}
+// filling metadata_orderId
+func SystemJSONFile(path string) *JSONSource[SystemTransaction] {
+	return &JSONSource[SystemTransaction]{name: "system transactions file", open: openFileContext(path), decode: decodeFlattened[SystemTransaction]}
+}
+
+// SourceNDJSONFile reads source transactions from a newline-delimited JSON file, nested objects flattened
+func SourceNDJSONFile(path string) *JSONSource[SourceTransaction] {
+	return &JSONSource[SourceTransaction]{name: "source transactions file", ndjson: true, open: openFileContext(path), decode: decodeFlattened[SourceTransaction]}
+}
+
+// SystemNDJSONFile reads system transactions from a newline-delimited JSON file, nested objects flattened
+func SystemNDJSONFile(path string) *JSONSource[SystemTransaction] {
+	return &JSONSource[SystemTransaction]{name: "system transactions file", ndjson: true, open: openFileContext(path), decode: decodeFlattened[SystemTransaction]}
+}
+
+// SourceJSON reads source transactions from a JSON array, which can only be iterated once
+func SourceJSON(r io.Reader) *JSONSource[SourceTransaction] {
+	return &JSONSource[SourceTransaction]{name: "source transactions", open: nopOpenContext(r), decode: decodeFlattened[SourceTransaction]}
+}
+
+// SystemJSON reads system transactions from a JSON array, which can only be iterated once
+func SystemJSON(r io.Reader) *JSONSource[SystemTransaction] {
+	return &JSONSource[SystemTransaction]{name: "system transactions", open: nopOpenContext(r), decode: decodeFlattened[SystemTransaction]}
+}
+
+// SourceNDJSON reads source transactions from newline-delimited JSON, which can only be iterated once
+func SourceNDJSON(r io.Reader) *JSONSource[SourceTransaction] {
+	return &JSONSource[SourceTransaction]{name: "source transactions", ndjson: true, open: nopOpenContext(r), decode: decodeFlattened[SourceTransaction]}
+}
+
+// SystemNDJSON reads system transactions from newline-delimited JSON, which can only be iterated once
+func SystemNDJSON(r io.Reader) *JSONSource[SystemTransaction] {
+	return &JSONSource[SystemTransaction]{name: "system transactions", ndjson: true, open: nopOpenContext(r), decode: decodeFlattened[SystemTransaction]}
+}
+
+// JSONFile reads transactions of any type, such as canonical transactions, from a file holding a JSON array
+func JSONFile[T any](path string) *JSONSource[T] {
+	return &JSONSource[T]{name: "transactions file", open: openFileContext(path), decode: decodePlain[T]}
+}
+
+// JSON reads transactions of any type from a JSON array, which can only be iterated once
+func JSON[T any](r io.Reader) *JSONSource[T] {
+	return &JSONSource[T]{name: "transactions", open: nopOpenContext(r), decode: decodePlain[T]}
+}
+
+// NDJSONFile reads transactions of any type from a newline-delimited JSON file
+func NDJSONFile[T any](path string) *JSONSource[T] {
+	return &JSONSource[T]{name: "transactions file", ndjson: true, open: openFileContext(path), decode: decodePlain[T]}
+}
+
+// HTTPSource fetches transactions from an API endpoint answering a GET with a JSON array. Client is
+// http.DefaultClient when nil, Header is added to the request, e.g. for authentication.
+func HTTPSource[T any](url string, header http.Header, client *http.Client) *JSONSource[T] {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &JSONSource[T]{name: "transactions", decode: decodePlain[T], open: func(ctx context.Context) (io.ReadCloser, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, err
+		}
+		for key, values := range header {
+			req.Header[key] = values
+		}
+		req.Header.Set("Accept", "application/json")
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			resp.Body.Close()
+			return nil, fmt.Errorf("%s answered %s", url, resp.Status)
+		}
+		return resp.Body, nil
+	}}
+}
+
+// openFileContext opens path on every iteration
+func openFileContext(path string) func(context.Context) (io.ReadCloser, error) {
+	open := openFile(path)
+	return func(context.Context) (io.ReadCloser, error) { return open() }
+}
+
+// nopOpenContext hands out a reader that is not closed after iterating
+func nopOpenContext(r io.Reader) func(context.Context) (io.ReadCloser, error) {
+	open := nopOpen(r)
+	return func(context.Context) (io.ReadCloser, error) { return open() }
+}
+
+// Transactions yields the transactions in input order
+func (s *JSONSource[T]) Transactions(ctx context.Context) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var zero T
+		r, err := s.open(ctx)
+		if err != nil {
+			yield(zero, fmt.Errorf("failed to open %s: %w", s.name, err))
+			return
+		}
+		defer r.Close()
+
+		if s.ndjson {
+			err = s.eachLine(ctx, r, yield)
+		} else {
+			err = s.eachElement(ctx, r, yield)
+		}
+		if err != nil && !errors.Is(err, errStopIteration) {
+			yield(zero, err)
+		}
+	}
+}
+
+// eachElement decodes the elements of a JSON array
+func (s *JSONSource[T]) eachElement(ctx context.Context, r io.Reader, yield func(T, error) bool) error {
+	counter := &lineCounter{r: r}
+	decoder := json.NewDecoder(counter)
+
+	// line is the line the decoder has reached, the newlines it has read ahead not counted
+	line := func() int {
+		buffered, _ := io.ReadAll(decoder.Buffered())
+		return counter.newlines - bytes.Count(buffered, []byte{'\n'}) + 1
+	}
+	// next is the line the next element starts at, past the separators the decoder has not consumed yet
+	next := func() int {
+		buffered, _ := io.ReadAll(decoder.Buffered())
+		separators := len(buffered) - len(bytes.TrimLeft(buffered, " \t\r\n,"))
+		return line() + bytes.Count(buffered[:separators], []byte{'\n'})
+	}
+
+	if token, err := decoder.Token(); errors.Is(err, io.EOF) {
+		return fmt.Errorf("JSON input is empty")
+	} else if err != nil {
+		return fmt.Errorf("invalid JSON at line %d: %w", line(), err)
+	} else if token != json.Delim('[') {
+		return fmt.Errorf("invalid JSON at line %d: expected an array of transactions", line())
+	}
+
+	for i := 0; decoder.More(); i++ {
+		if i%checkInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+		}
+
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); err != nil {
+			return fmt.Errorf("invalid JSON at line %d: %w", next(), err)
+		}
+		txn, err := s.decode(raw)
+		if err != nil {
+			return fmt.Errorf("invalid transaction at line %d: %w", line()-bytes.Count(raw, []byte{'\n'}), err)
+		}
+		if !yield(txn, nil) {
+			return errStopIteration
+		}
+	}
+	if _, err := decoder.Token(); err != nil {
+		return fmt.Errorf("invalid JSON at line %d: %w", line(), err)
+	}
+	return nil
+}
+
+// eachLine decodes one transaction per line, skipping blank lines
+func (s *JSONSource[T]) eachLine(ctx context.Context, r io.Reader, yield func(T, error) bool) error {
+	reader := bufio.NewReader(r)
+	for line := 1; ; line++ {
+		if line%checkInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+		}
+
+		data, err := reader.ReadBytes('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("failed to read %s: %w", s.name, err)
+		}
+		if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 {
+			txn, decodeErr := s.decode(trimmed)
+			if decodeErr != nil {
+				return fmt.Errorf("invalid transaction at line %d: %w", line, decodeErr)
+			}
+			if !yield(txn, nil) {
+				return errStopIteration
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+	}
+}
+
+// lineCounter counts the newlines read through it
+type lineCounter struct {
+	r        io.Reader
+	newlines int
+}
+
+func (c *lineCounter) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.newlines += bytes.Count(p[:n], []byte{'\n'})
+	return n, err
+}
+
+// decodePlain decodes a transaction as is
+func decodePlain[T any](data []byte) (T, error) {
+	var txn T
+	err := json.Unmarshal(data, &txn)
+	return txn, err
+}
+
+// decodeFlattened decodes a transaction after flattening its nested objects
+func decodeFlattened[T any](data []byte) (T, error) {
+	var txn T
+	flat, err := FlattenJSON(data)
+	if err != nil {
+		return txn, err
+	}
+	err = json.Unmarshal(flat, &txn)
+	return txn, err
+}
+
+// FlattenJSON turns the nested objects of a JSON object into keys joined with an underscore,
+// {"details": {"invoiceId": "x"}} becoming {"details_invoiceId": "x"}, the naming of the CSV headers
+func FlattenJSON(data []byte) ([]byte, error) {
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(data, &object); err != nil {
+		return nil, err
+	}
+	flat := make(map[string]json.RawMessage, len(object))
+	if err := flattenInto(flat, "", object); err != nil {
+		return nil, err
+	}
+	return json.Marshal(flat)
+}
+
+// flattenInto copies the fields of object into flat, prefixing their names
+func flattenInto(flat map[string]json.RawMessage, prefix string, object map[string]json.RawMessage) error {
+	for key, value := range object {
+		if prefix != "" {
+			key = prefix + "_" + key
+		}
+		if trimmed := bytes.TrimSpace(value); len(trimmed) > 0 && trimmed[0] == '{' {
+			var nested map[string]json.RawMessage
+			if err := json.Unmarshal(trimmed, &nested); err != nil {
+				return err
+			}
+			if err := flattenInto(flat, key, nested); err != nil {
+				return err
+			}
+			continue
+		}
+		flat[key] = value
+	}
+	return nil
+}
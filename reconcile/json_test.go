@@ -0,0 +1,109 @@
+package reconcile
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestJSONSourceErrorLines(t *testing.T) {
+	tests := []struct {
+		name     string
+		source   *JSONSource[SystemTransaction]
+		wantLine string
+	}{
+		{
+			name:     "array, one element per line",
+			source:   SystemJSON(strings.NewReader("[\n{\"transactionId\": \"a\"},\n{\"transactionId\": \"b\", \"amount\": \"ten\"}\n]")),
+			wantLine: "invalid transaction at line 3",
+		},
+		{
+			name:     "array, elements over several lines",
+			source:   SystemJSON(strings.NewReader("[\n  {\n    \"transactionId\": \"a\"\n  },\n\n  {\n    \"transactionId\": \"b\",\n    \"amount\": \"ten\"\n  }\n]")),
+			wantLine: "invalid transaction at line 6",
+		},
+		{
+			name:     "array, syntax error",
+			source:   SystemJSON(strings.NewReader("[\n{\"transactionId\": \"a\"},\n{\"transactionId\": }\n]")),
+			wantLine: "invalid JSON at line 3",
+		},
+		{
+			name:     "not an array",
+			source:   SystemJSON(strings.NewReader("\n\n{\"transactionId\": \"a\"}")),
+			wantLine: "invalid JSON at line 3",
+		},
+		{
+			name:     "NDJSON with blank lines",
+			source:   SystemNDJSON(strings.NewReader("{\"transactionId\": \"a\"}\n\n\n{\"transactionId\": \"b\", \"amount\": \"ten\"}\n")),
+			wantLine: "invalid transaction at line 4",
+		},
+		{
+			name:     "NDJSON, last line without a newline",
+			source:   SystemNDJSON(strings.NewReader("{\"transactionId\": \"a\"}\r\n{\"transactionId\": ")),
+			wantLine: "invalid transaction at line 2",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Collect(context.Background(), tt.source)
+			if err == nil || !strings.Contains(err.Error(), tt.wantLine) {
+				t.Errorf("error = %v, want it at %q", err, tt.wantLine)
+			}
+		})
+	}
+}
+
+func TestJSONSourceFlattensNestedObjects(t *testing.T) {
+	tests := []struct {
+		name   string
+		source *JSONSource[SourceTransaction]
+	}{
+		{"array", SourceJSON(strings.NewReader(`[{"providerTransactionId": "a", "amount": 12.5, "details": {"invoiceId": "inv-1"}}]`))},
+		{"NDJSON", SourceNDJSON(strings.NewReader(`{"providerTransactionId": "a", "amount": 12.5, "details": {"invoiceId": "inv-1"}}` + "\n"))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Collect(context.Background(), tt.source)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != 1 || got[0].ProviderTransactionID != "a" || got[0].Amount != 12.5 || got[0].DetailsInvoiceID != "inv-1" {
+				t.Errorf("transactions = %+v, want a of 12.5 with invoice inv-1", got)
+			}
+		})
+	}
+}
+
+func TestHTTPSource(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.Header.Get("Accept") != "application/json" || r.Header.Get("Authorization") != "Bearer token" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`[{"transactionId": "a", "amount": 10}, {"transactionId": "b", "amount": 20}]`))
+	}))
+	defer server.Close()
+	header := http.Header{"Authorization": {"Bearer token"}}
+
+	// Every iteration fetches the transactions again, with http.DefaultClient when no client is given
+	source := HTTPSource[SystemTransaction](server.URL+"/transactions", header, nil)
+	for range 2 {
+		got, err := Collect(context.Background(), source)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != 2 || got[0].TransactionID != "a" || got[1].Amount != 20 {
+			t.Errorf("transactions = %+v, want a and b", got)
+		}
+	}
+
+	if _, err := Collect(context.Background(), HTTPSource[SystemTransaction](server.URL+"/missing", header, server.Client())); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("error = %v, want the 404 status", err)
+	}
+}
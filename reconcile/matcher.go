@@ -0,0 +1,135 @@
+package reconcile
+
+import (
+	"math"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Matcher compares the two sides of a pair sharing a transaction ID, an empty result meaning they match.
+// Both sides are canonical transactions, whichever feeds they come from.
+type Matcher interface {
+	Compare(source, system Transaction) DiscrepancyMap
+}
+
+// MatcherFunc adapts a function to the Matcher interface
+type MatcherFunc func(source, system Transaction) DiscrepancyMap
+
+// Compare calls f
+func (f MatcherFunc) Compare(source, system Transaction) DiscrepancyMap {
+	return f(source, system)
+}
+
+// FieldMatcher compares the user, amount, currency, status, payment method, timestamps and reference
+// of a pair, the default matcher of a Reconciler
+type FieldMatcher struct {
+	AmountTolerance float64       // amounts closer than this are equal, for floating point precision
+	TimeTolerance   time.Duration // timestamps this close are equal, for processing delays
+	Fields          []string      // canonical fields compared, by their discrepancy name, all of them when empty
+	Attributes      []string      // extra attributes compared when both sides have them, reported under their name
+}
+
+// DefaultFieldMatcher allows one cent on amounts and five seconds on timestamps
+var DefaultFieldMatcher = FieldMatcher{AmountTolerance: 0.01, TimeTolerance: 5 * time.Second}
+
+// Compare returns the discrepancies between the canonical fields of two transactions
+func (m FieldMatcher) Compare(source, system Transaction) DiscrepancyMap {
+	discrepancies := make(DiscrepancyMap)
+
+	// Compare User ID
+	if m.compares("userId") && source.UserID != system.UserID {
+		discrepancies["userId"] = Discrepancy{
+			Source: source.UserID,
+			System: system.UserID,
+		}
+	}
+
+	// Compare amounts with tolerance for floating point precision
+	if m.compares("amount") && math.Abs(source.Amount-system.Amount) >= m.AmountTolerance {
+		discrepancies["amount"] = Discrepancy{
+			Source: source.Amount,
+			System: system.Amount,
+		}
+	}
+
+	// Compare currency
+	if m.compares("currency") && source.Currency != system.Currency {
+		discrepancies["currency"] = Discrepancy{
+			Source: source.Currency,
+			System: system.Currency,
+		}
+	}
+
+	// Compare statuses (normalize before comparison)
+	if m.compares("status") && NormalizeStatus(source.Status) != NormalizeStatus(system.Status) {
+		discrepancies["status"] = Discrepancy{
+			Source: source.Status,
+			System: system.Status,
+		}
+	}
+
+	// Compare payment method
+	if m.compares("paymentMethod") && source.PaymentMethod != system.PaymentMethod {
+		discrepancies["paymentMethod"] = Discrepancy{
+			Source: source.PaymentMethod,
+			System: system.PaymentMethod,
+		}
+	}
+
+	// Compare created timestamps (allow small tolerance for time differences)
+	if m.compares("createdAt") && source.CreatedAt.Sub(system.CreatedAt).Abs() > m.TimeTolerance {
+		discrepancies["createdAt"] = Discrepancy{
+			Source: source.CreatedAt.Format(time.RFC3339),
+			System: system.CreatedAt.Format(time.RFC3339),
+		}
+	}
+
+	// Compare updated timestamps (allow small tolerance for time differences)
+	if m.compares("updatedAt") && source.UpdatedAt.Sub(system.UpdatedAt).Abs() > m.TimeTolerance {
+		discrepancies["updatedAt"] = Discrepancy{
+			Source: source.UpdatedAt.Format(time.RFC3339),
+			System: system.UpdatedAt.Format(time.RFC3339),
+		}
+	}
+
+	// Compare references, the provider reference against the system reference ID for the built-in feeds
+	if m.compares("referenceId") && source.Reference != system.Reference {
+		discrepancies["referenceId"] = Discrepancy{
+			Source: source.Reference,
+			System: system.Reference,
+		}
+	}
+
+	// Compare the extra attributes both sides carry
+	for _, name := range m.Attributes {
+		sourceValue, sourceOK := source.Attribute(name)
+		systemValue, systemOK := system.Attribute(name)
+		if sourceOK && systemOK && sourceValue != systemValue {
+			discrepancies[name] = Discrepancy{
+				Source: sourceValue,
+				System: systemValue,
+			}
+		}
+	}
+
+	return discrepancies
+}
+
+// compares tells whether a canonical field is compared
+func (m FieldMatcher) compares(field string) bool {
+	return len(m.Fields) == 0 || slices.Contains(m.Fields, field)
+}
+
+// NormalizeStatus standardizes status values from different systems to a common format
+func NormalizeStatus(status string) string {
+	// Convert to uppercase for case-insensitive comparison
+	normalizedStatus := strings.ToUpper(strings.TrimSpace(status))
+
+	// Handle SUCCEEDED and COMPLETED as the same thing
+	if normalizedStatus == "SUCCEEDED" || normalizedStatus == "COMPLETED" {
+		return "COMPLETED"
+	}
+
+	return normalizedStatus
+}
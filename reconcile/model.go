@@ -0,0 +1,200 @@
+package reconcile
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SourceTransaction represents a transaction from the external provider like Stripe, to be parsed from source_transactions.csv
+type SourceTransaction struct {
+	ProviderTransactionID string    `csv:"providerTransactionId" json:"providerTransactionId"`
+	Email                 string    `csv:"email" json:"email"`
+	UserID                string    `csv:"userId" json:"userId"`
+	Provider              string    `csv:"provider" json:"provider"`
+	Amount                float64   `csv:"amount" json:"amount"`
+	Currency              string    `csv:"currency" json:"currency"`
+	Status                string    `csv:"status" json:"status"`
+	TransactionType       string    `csv:"transactionType" json:"transactionType"`
+	PaymentMethod         string    `csv:"paymentMethod" json:"paymentMethod"`
+	CreatedAt             time.Time `csv:"createdAt" json:"createdAt"`
+	UpdatedAt             time.Time `csv:"updatedAt" json:"updatedAt"`
+	ProviderReference     string    `csv:"providerReference" json:"providerReference"`
+	FraudRisk             string    `csv:"fraudRisk" json:"fraudRisk"`
+	DetailsInvoiceID      string    `csv:"details_invoiceId" json:"details_invoiceId"`
+	DetailsCustomerName   string    `csv:"details_customerName" json:"details_customerName"`
+	DetailsDescription    string    `csv:"details_description" json:"details_description"`
+
+	// Settlement details, set when read from a provider's own settlement report
+	Fee               float64 `csv:"fee" json:"fee,omitempty"`                             // what the provider kept, negative when it was given back
+	Net               float64 `csv:"net" json:"net,omitempty"`                             // the change to the balance after the fee, negative for refunds
+	PayoutID          string  `csv:"payoutId" json:"payoutId,omitempty"`                   // the payout the transaction was paid out in
+	ReportingCategory string  `csv:"reportingCategory" json:"reportingCategory,omitempty"` // e.g. charge, refund, dispute or payout
+}
+
+// SystemTransaction represents an internal system transaction, to be parsed from system_transactions.csv
+type SystemTransaction struct {
+	TransactionID       string    `csv:"transactionId" json:"transactionId"`
+	UserID              string    `csv:"userId" json:"userId"`
+	Amount              float64   `csv:"amount" json:"amount"`
+	Currency            string    `csv:"currency" json:"currency"`
+	Status              string    `csv:"status" json:"status"`
+	PaymentMethod       string    `csv:"paymentMethod" json:"paymentMethod"`
+	CreatedAt           time.Time `csv:"createdAt" json:"createdAt"`
+	UpdatedAt           time.Time `csv:"updatedAt" json:"updatedAt"`
+	ReferenceID         string    `csv:"referenceId" json:"referenceId"`
+	MetadataOrderID     string    `csv:"metadata_orderId" json:"metadata_orderId"`
+	MetadataDescription string    `csv:"metadata_description" json:"metadata_description"`
+}
+
+// Discrepancy represents a field mismatch between source and system
+type Discrepancy struct {
+	Source interface{} `json:"source"`
+	System interface{} `json:"system"`
+}
+
+// MismatchedTransaction represents transactions with the same ID but different amounts/statuses
+type MismatchedTransaction struct {
+	TransactionID string             `json:"transactionId"`
+	Discrepancies DiscrepancyMap     `json:"discrepancies"`
+	Source        *SourceTransaction `json:"-"` // the source side, kept for case management
+	System        *SystemTransaction `json:"-"` // the system side, kept for case management
+}
+
+// Result represents the complete reconciliation report
+type Result struct {
+	MissingInInternal      []SourceTransaction     `json:"missing_in_internal"`
+	MissingInSource        []SystemTransaction     `json:"missing_in_source"`
+	MismatchedTransactions []MismatchedTransaction `json:"mismatched_transactions"`
+	Summary                Summary                 `json:"summary"`
+}
+
+// Report is the simplified report written to reconciliation_report.json, the struct keeps the JSON field order
+type Report struct {
+	MissingInInternal      []map[string]interface{} `json:"missing_in_internal"`
+	MissingInSource        []map[string]interface{} `json:"missing_in_source"`
+	MismatchedTransactions []MismatchedTransaction  `json:"mismatched_transactions"`
+}
+
+// Summary provides statistics about the reconciliation
+type Summary struct {
+	TotalSourceTransactions     int `json:"total_source_transactions"`
+	TotalSystemTransactions     int `json:"total_system_transactions"`
+	MissingInInternalCount      int `json:"missing_in_internal_count"`
+	MissingInSourceCount        int `json:"missing_in_source_count"`
+	MismatchedTransactionsCount int `json:"mismatched_transactions_count"`
+	SuccessfullyMatchedCount    int `json:"successfully_matched_count"`
+}
+
+// MatchRate is the percentage of the transactions of the smaller side that matched, false when a side is empty
+func (s Summary) MatchRate() (float64, bool) {
+	possible := min(s.TotalSourceTransactions, s.TotalSystemTransactions)
+	if possible <= 0 {
+		return 0, false
+	}
+	return float64(s.SuccessfullyMatchedCount) / float64(possible) * 100, true
+}
+
+// AmountAtStake is the difference when the amounts differ, otherwise the whole transaction, in the
+// source currency. Amounts in different currencies are not subtracted, the whole source transaction
+// being at stake.
+func (mismatch MismatchedTransaction) AmountAtStake() float64 {
+	if mismatch.Source == nil || mismatch.System == nil {
+		return 0
+	}
+	if _, ok := mismatch.Discrepancies["currency"]; ok || !strings.EqualFold(mismatch.Source.Currency, mismatch.System.Currency) {
+		return mismatch.Source.Amount
+	}
+	if _, ok := mismatch.Discrepancies["amount"]; ok {
+		return math.Abs(mismatch.Source.Amount - mismatch.System.Amount)
+	}
+	return mismatch.Source.Amount
+}
+
+// ExceptionKind tells which section of the Result an exception belongs to
+type ExceptionKind string
+
+const (
+	ExceptionMissingInInternal ExceptionKind = "missing_in_internal"
+	ExceptionMissingInSource   ExceptionKind = "missing_in_source"
+	ExceptionMismatched        ExceptionKind = "mismatched"
+)
+
+// Exception is a single exception found while reconciling, Source, System or Mismatch is set depending on Kind
+type Exception struct {
+	Kind     ExceptionKind
+	Source   *SourceTransaction
+	System   *SystemTransaction
+	Mismatch *MismatchedTransaction
+}
+
+// MatchProgress is reported while matching, the exception counts are the ones found so far
+type MatchProgress struct {
+	PairsCompared     int
+	PairsTotal        int
+	MissingInInternal int
+	MissingInSource   int
+	Mismatched        int
+}
+
+// discrepancyFieldOrder is the order FieldMatcher compares the fields in, and the order they are written in
+var discrepancyFieldOrder = []string{"userId", "amount", "currency", "status", "paymentMethod", "createdAt", "updatedAt", "referenceId"}
+
+// DiscrepancyMap holds the discrepancies of a pair by field. It is written to JSON in the order the fields
+// are compared, unknown fields last in alphabetical order, rather than in the alphabetical order of a plain map.
+type DiscrepancyMap map[string]Discrepancy
+
+// Fields returns the fields that differ in the order they are compared, unknown fields last in
+// alphabetical order
+func (d DiscrepancyMap) Fields() []string {
+	rank := func(field string) int {
+		for i, known := range discrepancyFieldOrder {
+			if field == known {
+				return i
+			}
+		}
+		return len(discrepancyFieldOrder)
+	}
+	fields := make([]string, 0, len(d))
+	for field := range d {
+		fields = append(fields, field)
+	}
+	sort.Slice(fields, func(i, j int) bool {
+		if ri, rj := rank(fields[i]), rank(fields[j]); ri != rj {
+			return ri < rj
+		}
+		return fields[i] < fields[j]
+	})
+	return fields
+}
+
+// MarshalJSON writes the discrepancies in a stable field order
+func (d DiscrepancyMap) MarshalJSON() ([]byte, error) {
+	if d == nil {
+		return []byte("null"), nil
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, field := range d.Fields() {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(field)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(d[field])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
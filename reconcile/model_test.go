@@ -0,0 +1,24 @@
+package reconcile
+
+import "testing"
+
+func TestAmountAtStake(t *testing.T) {
+	tests := []struct {
+		name          string
+		source        SourceTransaction
+		system        SystemTransaction
+		discrepancies DiscrepancyMap
+		want          float64
+	}{
+		{"amount difference", SourceTransaction{Amount: 100, Currency: "USD"}, SystemTransaction{Amount: 120, Currency: "USD"}, DiscrepancyMap{"amount": {}}, 20},
+		{"status difference", SourceTransaction{Amount: 100, Currency: "USD"}, SystemTransaction{Amount: 100, Currency: "USD"}, DiscrepancyMap{"status": {}}, 100},
+		{"currency difference", SourceTransaction{Amount: 92, Currency: "EUR"}, SystemTransaction{Amount: 100, Currency: "USD"}, DiscrepancyMap{"amount": {}, "currency": {}}, 92},
+		{"currency difference under a matcher ignoring it", SourceTransaction{Amount: 92, Currency: "EUR"}, SystemTransaction{Amount: 100, Currency: "USD"}, DiscrepancyMap{"amount": {}}, 92},
+	}
+	for _, tt := range tests {
+		mismatch := MismatchedTransaction{Source: &tt.source, System: &tt.system, Discrepancies: tt.discrepancies}
+		if got := mismatch.AmountAtStake(); got != tt.want {
+			t.Errorf("%s: AmountAtStake() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
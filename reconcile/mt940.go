@@ -0,0 +1,304 @@
+package reconcile
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MT940Source reads SWIFT MT940 customer statements. A file may hold several statements, with or without
+// the SWIFT blocks around them. Each :61: statement line becomes an entry, described by the :86: field
+// following it, and the :60F:/:60M: and :62F:/:62M: fields give the opening and closing balances.
+type MT940Source struct {
+	name string
+	open func() (io.ReadCloser, error)
+}
+
+// MT940File reads the statements of an MT940 file
+func MT940File(path string) *MT940Source {
+	return &MT940Source{name: "MT940 file", open: openFile(path)}
+}
+
+// MT940 reads MT940 statements, which can only be iterated once
+func MT940(r io.Reader) *MT940Source {
+	return &MT940Source{name: "MT940 statement", open: nopOpen(r)}
+}
+
+// Transactions yields the entries of every statement in file order
+func (s *MT940Source) Transactions(ctx context.Context) iter.Seq2[StatementEntry, error] {
+	return entriesOf(s.Statements(ctx))
+}
+
+// mt940Field is a field of a statement with its continuation lines, line being where it starts
+type mt940Field struct {
+	tag   string
+	value []string
+	line  int
+}
+
+// Statements yields the statements in file order, each once its closing balance has been read
+func (s *MT940Source) Statements(ctx context.Context) iter.Seq2[Statement, error] {
+	return func(yield func(Statement, error) bool) {
+		r, err := s.open()
+		if err != nil {
+			yield(Statement{}, fmt.Errorf("failed to open %s: %w", s.name, err))
+			return
+		}
+		defer r.Close()
+
+		if err := s.each(ctx, r, yield); err != nil && !errors.Is(err, errStopIteration) {
+			yield(Statement{}, err)
+		}
+	}
+}
+
+// each splits the file into fields and groups them into statements, a statement starting at its :20: field
+func (s *MT940Source) each(ctx context.Context, r io.Reader, yield func(Statement, error) bool) error {
+	var fields []mt940Field
+	statements := 0
+	flush := func() error {
+		if len(fields) == 0 {
+			return nil
+		}
+		statement, err := parseMT940Statement(fields)
+		fields = nil
+		if err != nil {
+			return err
+		}
+		statements++
+		if !yield(statement, nil) {
+			return errStopIteration
+		}
+		return nil
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if line%checkInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+		}
+
+		text := strings.TrimRight(scanner.Text(), "\r")
+		// The text block {4: opens the fields, -} closes it, the header blocks before it are ignored
+		if strings.HasPrefix(text, "{") {
+			if i := strings.Index(text, "{4:"); i >= 0 {
+				text = text[i+len("{4:"):]
+			} else {
+				continue
+			}
+		}
+		if text == "-}" || text == "-" || strings.HasPrefix(text, "-}") {
+			if err := flush(); err != nil {
+				return err
+			}
+			continue
+		}
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+
+		if tag, value, ok := cutMT940Tag(text); ok {
+			if tag == "20" {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+			fields = append(fields, mt940Field{tag: tag, value: []string{value}, line: line})
+			continue
+		}
+		if len(fields) == 0 {
+			return fmt.Errorf("%w: line %d: expected a field such as :20:, got %q", ErrInvalidStatement, line, text)
+		}
+		// A line without a tag continues the previous field
+		last := &fields[len(fields)-1]
+		last.value = append(last.value, text)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read %s: %w", s.name, err)
+	}
+	if err := flush(); err != nil {
+		return err
+	}
+	if statements == 0 {
+		return fmt.Errorf("%w: no statement found in %s", ErrInvalidStatement, s.name)
+	}
+	return nil
+}
+
+// cutMT940Tag splits a line starting with :tag: into the tag and the rest
+func cutMT940Tag(text string) (string, string, bool) {
+	if len(text) < 4 || text[0] != ':' {
+		return "", "", false
+	}
+	end := strings.IndexByte(text[1:], ':')
+	if end < 2 || end > 3 {
+		return "", "", false
+	}
+	return text[1 : end+1], text[end+2:], true
+}
+
+// parseMT940Statement builds a statement from its fields
+func parseMT940Statement(fields []mt940Field) (Statement, error) {
+	var statement Statement
+	var entries []StatementEntry
+	lines := 0
+
+	for i, field := range fields {
+		invalid := func(err error) error {
+			return fmt.Errorf("%w: field :%s: at line %d: %v", ErrInvalidStatement, field.tag, field.line, err)
+		}
+
+		switch field.tag {
+		case "20":
+			statement.ID = strings.TrimSpace(field.value[0])
+		case "25":
+			statement.Account = strings.TrimSpace(field.value[0])
+		case "60F", "60M":
+			balance, err := parseMT940Balance(field.value[0])
+			if err != nil {
+				return Statement{}, invalid(err)
+			}
+			statement.Opening, statement.Currency = &balance, balance.Currency
+		case "62F", "62M":
+			balance, err := parseMT940Balance(field.value[0])
+			if err != nil {
+				return Statement{}, invalid(err)
+			}
+			statement.Closing = &balance
+		case "61":
+			lines++
+			line, err := parseMT940Line(field.value, statement.Currency)
+			if err != nil {
+				return Statement{}, invalid(err)
+			}
+			line.StatementID, line.Account = statement.ID, statement.Account
+			if line.Reference == "" {
+				line.Reference = fmt.Sprintf("%s/%d", statement.ID, lines)
+			}
+			entries = append(entries, line)
+		case "86":
+			// Information to the account owner describes the statement line right before it, or else the
+			// statement as a whole
+			if i == 0 || fields[i-1].tag != "61" {
+				continue
+			}
+			entry := &entries[len(entries)-1]
+			var info []string
+			for _, value := range field.value {
+				if value = strings.TrimSpace(value); value != "" {
+					info = append(info, value)
+				}
+			}
+			if len(info) > 0 {
+				entry.RemittanceRef = info[0]
+			}
+			// after the supplementary details of the :61: field, if any
+			entry.RemittanceInfo = strings.TrimSpace(entry.RemittanceInfo + " " + strings.Join(info, " "))
+		}
+	}
+
+	if statement.ID == "" {
+		return Statement{}, fmt.Errorf("%w: statement at line %d has no :20: reference", ErrInvalidStatement, fields[0].line)
+	}
+	statement.Entries = entries
+	return statement, nil
+}
+
+// parseMT940Balance parses a balance such as C240630USD10000,00
+func parseMT940Balance(value string) (Balance, error) {
+	value = strings.TrimSpace(value)
+	if len(value) < 11 {
+		return Balance{}, fmt.Errorf("balance %q is too short", value)
+	}
+	date, err := time.Parse("060102", value[1:7])
+	if err != nil {
+		return Balance{}, fmt.Errorf("invalid date in balance %q", value)
+	}
+	amount, err := parseMT940Amount(value[10:])
+	if err != nil {
+		return Balance{}, fmt.Errorf("invalid amount in balance %q", value)
+	}
+	switch value[0] {
+	case 'C':
+	case 'D':
+		amount = -amount
+	default:
+		return Balance{}, fmt.Errorf("balance %q is neither C nor D", value)
+	}
+	return Balance{Amount: amount, Currency: value[7:10], Date: date}, nil
+}
+
+// mt940Line is the layout of a :61: field: value date, optional entry date, debit/credit mark (R for a
+// reversal), optional funds code, amount, transaction type, reference for the account owner, and the
+// reference of the bank after //
+var mt940Line = regexp.MustCompile(`^(\d{6})(\d{4})?(RC|RD|C|D)([A-Z])?(\d[\d,]*)([A-Z][A-Z0-9]{3})([^/]*(?:/[^/][^/]*)*)(?://(.*))?$`)
+
+// parseMT940Line parses a statement line, its second line holding supplementary details
+func parseMT940Line(value []string, currency string) (StatementEntry, error) {
+	match := mt940Line.FindStringSubmatch(strings.TrimSpace(value[0]))
+	if match == nil {
+		return StatementEntry{}, fmt.Errorf("statement line %q does not match the :61: layout", value[0])
+	}
+
+	valueDate, err := time.Parse("060102", match[1])
+	if err != nil {
+		return StatementEntry{}, fmt.Errorf("invalid value date %q", match[1])
+	}
+	bookingDate := valueDate
+	if match[2] != "" {
+		// The entry date has no year, it is the one closest to the value date
+		bookingDate, err = time.Parse("20060102", strconv.Itoa(valueDate.Year())+match[2])
+		if err != nil {
+			return StatementEntry{}, fmt.Errorf("invalid entry date %q", match[2])
+		}
+		if bookingDate.Sub(valueDate) > 180*24*time.Hour {
+			bookingDate = bookingDate.AddDate(-1, 0, 0)
+		} else if valueDate.Sub(bookingDate) > 180*24*time.Hour {
+			bookingDate = bookingDate.AddDate(1, 0, 0)
+		}
+	}
+
+	amount, err := parseMT940Amount(match[5])
+	if err != nil {
+		return StatementEntry{}, fmt.Errorf("invalid amount %q", match[5])
+	}
+
+	// A reversal of a credit debits the account and the other way round
+	creditDebit := Credit
+	if match[3] == "D" || match[3] == "RC" {
+		creditDebit = Debit
+	}
+
+	entry := StatementEntry{
+		Reference:       strings.TrimSpace(match[8]),
+		Amount:          amount,
+		Currency:        currency,
+		CreditDebit:     creditDebit,
+		Status:          "BOOK",
+		BookingDate:     bookingDate,
+		ValueDate:       valueDate,
+		TransactionCode: match[6],
+	}
+	if reference := strings.TrimSpace(match[7]); reference != "NONREF" {
+		entry.EndToEndID = reference
+	}
+	if len(value) > 1 {
+		entry.RemittanceInfo = strings.TrimSpace(strings.Join(value[1:], " "))
+	}
+	return entry, nil
+}
+
+// parseMT940Amount parses an amount with a decimal comma, such as 1234,5
+func parseMT940Amount(value string) (float64, error) {
+	return parseStatementAmount(strings.Replace(strings.TrimSpace(value), ",", ".", 1))
+}
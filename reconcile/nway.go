@@ -0,0 +1,221 @@
+package reconcile
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+var ErrInvalidDatasets = errors.New("invalid datasets")
+
+// Dataset is one named side of an N-way reconciliation, e.g. the provider report, the internal ledger or
+// the bank statement. Key gives the value its transactions are matched on across datasets, the ID when nil,
+// e.g. the reference for bank deposits that carry the payment reference rather than the transaction ID.
+type Dataset struct {
+	Name   string
+	Source TransactionSource[Transaction]
+	Key    func(txn Transaction) string
+}
+
+// MatchRule compares the transactions two datasets hold for a key, with Matcher or, when nil, the matcher
+// of the reconciler. The transaction of Left is reported as the source side of a discrepancy, the one of
+// Right as the system side.
+type MatchRule struct {
+	Left    string
+	Right   string
+	Matcher Matcher
+}
+
+// PairDiscrepancy is where two datasets disagree about a transaction
+type PairDiscrepancy struct {
+	Left          string         `json:"left"`
+	Right         string         `json:"right"`
+	Discrepancies DiscrepancyMap `json:"discrepancies"`
+}
+
+// LogicalTransaction is one transaction across every dataset: which datasets hold it, which do not, and
+// where the ones compared by a rule disagree. Records holds the transaction of each dataset holding it,
+// the first one read when a dataset holds the key more than once, and Duplicates the further ones.
+type LogicalTransaction struct {
+	Key           string                   `json:"key"`
+	Present       []string                 `json:"present"`
+	Missing       []string                 `json:"missing,omitempty"`
+	Disagreements []PairDiscrepancy        `json:"disagreements,omitempty"`
+	Records       map[string]Transaction   `json:"records"`
+	Duplicates    map[string][]Transaction `json:"duplicates,omitempty"`
+}
+
+// Matched tells whether every dataset holds the transaction once and they all agree
+func (t LogicalTransaction) Matched() bool {
+	return len(t.Missing) == 0 && len(t.Disagreements) == 0 && len(t.Duplicates) == 0
+}
+
+// UnkeyedTransaction is a transaction without a value for the key of its dataset, e.g. a deposit without a
+// reference, which cannot be matched
+type UnkeyedTransaction struct {
+	Dataset string      `json:"dataset"`
+	Record  Transaction `json:"record"`
+}
+
+// NWaySummary provides statistics about an N-way reconciliation. TransactionsPerDataset counts every
+// transaction read, duplicated and unkeyed ones included.
+type NWaySummary struct {
+	TransactionsPerDataset map[string]int `json:"transactions_per_dataset"`
+	LogicalTransactions    int            `json:"logical_transactions"`
+	FullyMatchedCount      int            `json:"fully_matched_count"`
+	IncompleteCount        int            `json:"incomplete_count"`  // missing from at least one dataset
+	DisagreeingCount       int            `json:"disagreeing_count"` // at least one rule found discrepancies
+	DuplicatedCount        int            `json:"duplicated_count"`  // held more than once by a dataset
+	UnkeyedCount           int            `json:"unkeyed_count"`     // transactions without a key
+}
+
+// NWayResult is the outcome of an N-way reconciliation. The transactions needing attention come first,
+// then the matched ones, each group in key order. Unkeyed holds the transactions left out of the matching,
+// in the order they were read.
+type NWayResult struct {
+	Datasets     []string             `json:"datasets"`
+	Rules        []string             `json:"rules"`
+	Transactions []LogicalTransaction `json:"transactions"`
+	Unkeyed      []UnkeyedTransaction `json:"unkeyed,omitempty"`
+	Summary      NWaySummary          `json:"summary"`
+}
+
+// ReconcileN matches any number of named datasets on their keys. Every rule compares the transactions of
+// its two datasets wherever both hold a key; without rules, every pair of datasets is compared. When a key
+// appears more than once in a dataset, the first transaction is compared and the others are reported as
+// duplicates. Transactions with an empty key are reported as unkeyed rather than matched with each other.
+func (r *Reconciler) ReconcileN(ctx context.Context, datasets []Dataset, rules ...MatchRule) (*NWayResult, error) {
+	if len(datasets) < 2 {
+		return nil, fmt.Errorf("%w: at least two datasets are needed, got %d", ErrInvalidDatasets, len(datasets))
+	}
+	names := make([]string, len(datasets))
+	for i, dataset := range datasets {
+		if dataset.Name == "" || slices.Contains(names[:i], dataset.Name) {
+			return nil, fmt.Errorf("%w: dataset names must be unique and not empty, got %q", ErrInvalidDatasets, dataset.Name)
+		}
+		names[i] = dataset.Name
+	}
+
+	if len(rules) == 0 {
+		for i := range names {
+			for j := i + 1; j < len(names); j++ {
+				rules = append(rules, MatchRule{Left: names[i], Right: names[j]})
+			}
+		}
+	}
+	ruleNames := make([]string, len(rules))
+	for i, rule := range rules {
+		if !slices.Contains(names, rule.Left) || !slices.Contains(names, rule.Right) || rule.Left == rule.Right {
+			return nil, fmt.Errorf("%w: rule %s:%s must compare two different datasets among %s", ErrInvalidDatasets, rule.Left, rule.Right, strings.Join(names, ", "))
+		}
+		ruleNames[i] = rule.Left + ":" + rule.Right
+	}
+
+	// Index every dataset by key
+	indexes := make([]map[string]Transaction, len(datasets))
+	duplicates := make([]map[string][]Transaction, len(datasets))
+	var unkeyed []UnkeyedTransaction
+	summary := NWaySummary{TransactionsPerDataset: make(map[string]int, len(datasets))}
+	keys := make(map[string]struct{})
+	for i, dataset := range datasets {
+		key := dataset.Key
+		if key == nil {
+			key = func(txn Transaction) string { return txn.ID }
+		}
+
+		index := make(map[string]Transaction)
+		duplicates[i] = make(map[string][]Transaction)
+		for txn, err := range dataset.Source.Transactions(ctx) {
+			if err != nil {
+				return nil, fmt.Errorf("failed to read %s transactions: %w", dataset.Name, err)
+			}
+			summary.TransactionsPerDataset[dataset.Name]++
+			k := key(txn)
+			switch _, seen := index[k]; {
+			case k == "":
+				unkeyed = append(unkeyed, UnkeyedTransaction{Dataset: dataset.Name, Record: txn})
+			case seen:
+				duplicates[i][k] = append(duplicates[i][k], txn)
+			default:
+				index[k] = txn
+				keys[k] = struct{}{}
+			}
+		}
+		indexes[i] = index
+	}
+
+	position := make(map[string]int, len(names))
+	for i, name := range names {
+		position[name] = i
+	}
+
+	transactions := make([]LogicalTransaction, 0, len(keys))
+	for key := range keys {
+		if len(transactions)%checkInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+
+		logical := LogicalTransaction{Key: key, Records: make(map[string]Transaction)}
+		for i, name := range names {
+			if txn, ok := indexes[i][key]; ok {
+				logical.Present = append(logical.Present, name)
+				logical.Records[name] = txn
+			} else {
+				logical.Missing = append(logical.Missing, name)
+			}
+			if extra := duplicates[i][key]; len(extra) > 0 {
+				if logical.Duplicates == nil {
+					logical.Duplicates = make(map[string][]Transaction)
+				}
+				logical.Duplicates[name] = extra
+			}
+		}
+
+		for _, rule := range rules {
+			left, leftOK := indexes[position[rule.Left]][key]
+			right, rightOK := indexes[position[rule.Right]][key]
+			if !leftOK || !rightOK {
+				continue
+			}
+			matcher := rule.Matcher
+			if matcher == nil {
+				matcher = r.matcher
+			}
+			if discrepancies := matcher.Compare(left, right); len(discrepancies) > 0 {
+				logical.Disagreements = append(logical.Disagreements, PairDiscrepancy{Left: rule.Left, Right: rule.Right, Discrepancies: discrepancies})
+			}
+		}
+
+		if len(logical.Missing) > 0 {
+			summary.IncompleteCount++
+		}
+		if len(logical.Disagreements) > 0 {
+			summary.DisagreeingCount++
+		}
+		if len(logical.Duplicates) > 0 {
+			summary.DuplicatedCount++
+		}
+		if logical.Matched() {
+			summary.FullyMatchedCount++
+		}
+		transactions = append(transactions, logical)
+	}
+	summary.LogicalTransactions = len(transactions)
+	summary.UnkeyedCount = len(unkeyed)
+
+	slices.SortFunc(transactions, func(a, b LogicalTransaction) int {
+		if a.Matched() != b.Matched() {
+			if b.Matched() {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(a.Key, b.Key)
+	})
+
+	return &NWayResult{Datasets: names, Rules: ruleNames, Transactions: transactions, Unkeyed: unkeyed, Summary: summary}, nil
+}
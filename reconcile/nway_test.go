@@ -0,0 +1,97 @@
+package reconcile
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestReconcileN(t *testing.T) {
+	ledger := SliceSource[Transaction]{
+		{ID: "txn-1", Amount: 10, Currency: "USD", Reference: "ref-1"},
+		{ID: "txn-2", Amount: 20, Currency: "USD", Reference: "ref-2"},
+		{ID: "txn-3", Amount: 30, Currency: "USD", Reference: "ref-3"},
+		{ID: "txn-4", Amount: 40, Currency: "USD"},
+	}
+	provider := SliceSource[Transaction]{
+		{ID: "txn-1", Amount: 10, Currency: "USD", Reference: "ref-1"},
+		{ID: "txn-2", Amount: 25, Currency: "USD", Reference: "ref-2"},
+		{ID: "txn-3", Amount: 30, Currency: "USD", Reference: "ref-3"},
+		{ID: "txn-3", Amount: 30, Currency: "USD", Reference: "ref-3"},
+	}
+	bank := SliceSource[Transaction]{
+		{ID: "dep-1", Amount: 10, Currency: "USD", Reference: "ref-1"},
+		{ID: "dep-2", Amount: 20, Currency: "USD", Reference: "ref-2"},
+		{ID: "dep-8", Amount: 5, Currency: "USD"},
+		{ID: "dep-9", Amount: 7, Currency: "USD"},
+	}
+	byReference := func(txn Transaction) string { return txn.Reference }
+
+	result, err := New().ReconcileN(context.Background(), []Dataset{
+		{Name: "ledger", Source: ledger},
+		{Name: "provider", Source: provider},
+	}, MatchRule{Left: "provider", Right: "ledger"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logical := make(map[string]LogicalTransaction)
+	for _, txn := range result.Transactions {
+		logical[txn.Key] = txn
+	}
+	if got := logical["txn-1"]; !got.Matched() || len(got.Present) != 2 {
+		t.Errorf("txn-1 = %+v, want matched in both datasets", got)
+	}
+	if got := logical["txn-2"]; len(got.Disagreements) != 1 || got.Disagreements[0].Left != "provider" || got.Disagreements[0].Discrepancies["amount"].Source != 25.0 {
+		t.Errorf("txn-2 disagreements = %+v, want the provider amount of 25 against the ledger", got.Disagreements)
+	}
+	if got := logical["txn-3"]; got.Matched() || len(got.Duplicates["provider"]) != 1 || len(got.Disagreements) != 0 {
+		t.Errorf("txn-3 = %+v, want the second provider record reported as a duplicate", got)
+	}
+	if got := logical["txn-4"]; len(got.Missing) != 1 || got.Missing[0] != "provider" {
+		t.Errorf("txn-4 missing from %v, want provider", got.Missing)
+	}
+	if result.Summary.TransactionsPerDataset["provider"] != 4 || result.Summary.DuplicatedCount != 1 || result.Summary.IncompleteCount != 1 ||
+		result.Summary.DisagreeingCount != 1 || result.Summary.FullyMatchedCount != 1 || result.Summary.LogicalTransactions != 4 {
+		t.Errorf("summary = %+v", result.Summary)
+	}
+	if result.Transactions[len(result.Transactions)-1].Key != "txn-1" {
+		t.Errorf("the matched transaction does not come last: %v", result.Transactions)
+	}
+
+	// Entries without a reference are reported apart instead of being matched with each other
+	result, err = New().ReconcileN(context.Background(), []Dataset{
+		{Name: "ledger", Source: ledger, Key: byReference},
+		{Name: "bank", Source: bank, Key: byReference},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Summary.LogicalTransactions != 3 || result.Summary.FullyMatchedCount != 2 || result.Summary.UnkeyedCount != 3 || len(result.Unkeyed) != 3 {
+		t.Errorf("summary = %+v, unkeyed %v, want ref-1 to ref-3 matched on and txn-4, dep-8 and dep-9 unkeyed", result.Summary, result.Unkeyed)
+	}
+	if result.Unkeyed[0].Dataset != "ledger" || result.Unkeyed[0].Record.ID != "txn-4" || result.Unkeyed[2].Record.ID != "dep-9" {
+		t.Errorf("unkeyed = %+v", result.Unkeyed)
+	}
+}
+
+func TestReconcileNValidation(t *testing.T) {
+	one := SliceSource[Transaction]{{ID: "txn-1"}}
+	tests := []struct {
+		name     string
+		datasets []Dataset
+		rules    []MatchRule
+	}{
+		{"a single dataset", []Dataset{{Name: "ledger", Source: one}}, nil},
+		{"duplicate names", []Dataset{{Name: "ledger", Source: one}, {Name: "ledger", Source: one}}, nil},
+		{"empty name", []Dataset{{Name: "ledger", Source: one}, {Source: one}}, nil},
+		{"rule on an unknown dataset", []Dataset{{Name: "ledger", Source: one}, {Name: "bank", Source: one}}, []MatchRule{{Left: "ledger", Right: "provider"}}},
+		{"rule comparing a dataset with itself", []Dataset{{Name: "ledger", Source: one}, {Name: "bank", Source: one}}, []MatchRule{{Left: "bank", Right: "bank"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New().ReconcileN(context.Background(), tt.datasets, tt.rules...); !errors.Is(err, ErrInvalidDatasets) {
+				t.Errorf("ReconcileN() = %v, want %v", err, ErrInvalidDatasets)
+			}
+		})
+	}
+}
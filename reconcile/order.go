@@ -0,0 +1,211 @@
+package reconcile
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ReportSortField is a field the sections of a reconciliation result can be sorted by
+type ReportSortField string
+
+const (
+	SortBySeverity  ReportSortField = "severity"
+	SortByAmount    ReportSortField = "amount"
+	SortByCreatedAt ReportSortField = "createdAt"
+	SortByID        ReportSortField = "id"
+)
+
+var ErrInvalidReportOrder = errors.New("invalid report order")
+
+// ReportSortKey is one level of a report order, descending reverses the natural ascending order of the field
+type ReportSortKey struct {
+	Field      ReportSortField
+	Descending bool
+}
+
+// ReportOrder sorts every section of a reconciliation result, each key breaking the ties of the previous one.
+// The transaction ID always breaks the remaining ties, so the order of a result never depends on map iteration.
+type ReportOrder []ReportSortKey
+
+// DefaultReportOrder puts the most severe exceptions first, then the largest amounts, then the oldest
+var DefaultReportOrder = ReportOrder{
+	{Field: SortBySeverity, Descending: true},
+	{Field: SortByAmount, Descending: true},
+	{Field: SortByCreatedAt},
+	{Field: SortByID},
+}
+
+// ParseReportOrder parses a comma separated list of fields, each prefixed with - to sort it descending,
+// e.g. "-severity,-amount,createdAt,id"
+func ParseReportOrder(s string) (ReportOrder, error) {
+	var order ReportOrder
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		key := ReportSortKey{Field: ReportSortField(strings.TrimPrefix(part, "-")), Descending: strings.HasPrefix(part, "-")}
+		switch key.Field {
+		case SortBySeverity, SortByAmount, SortByCreatedAt, SortByID:
+		default:
+			return nil, fmt.Errorf("%w: unknown field %q, expected severity, amount, createdAt or id", ErrInvalidReportOrder, key.Field)
+		}
+		order = append(order, key)
+	}
+	if len(order) == 0 {
+		return nil, fmt.Errorf("%w: no field given", ErrInvalidReportOrder)
+	}
+	return order, nil
+}
+
+// String formats the order the way ParseReportOrder reads it
+func (order ReportOrder) String() string {
+	parts := make([]string, len(order))
+	for i, key := range order {
+		parts[i] = string(key.Field)
+		if key.Descending {
+			parts[i] = "-" + parts[i]
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// Severity ranks how much an exception needs attention
+type Severity int
+
+const (
+	SeverityLow Severity = iota + 1
+	SeverityMedium
+	SeverityHigh
+)
+
+// sortable is what a report order compares of an exception
+type sortable struct {
+	id        string
+	severity  Severity
+	amount    float64
+	createdAt time.Time
+}
+
+// Sort orders the three sections of a result in place, a nil order meaning DefaultReportOrder
+func (order ReportOrder) Sort(result *Result) {
+	if order == nil {
+		order = DefaultReportOrder
+	}
+
+	missingInInternal, missingInSource := result.MissingInInternal, result.MissingInSource
+	sort.SliceStable(missingInInternal, func(i, j int) bool {
+		return order.less(sourceSortable(missingInInternal[i]), sourceSortable(missingInInternal[j]))
+	})
+	sort.SliceStable(missingInSource, func(i, j int) bool {
+		return order.less(systemSortable(missingInSource[i]), systemSortable(missingInSource[j]))
+	})
+	sort.SliceStable(result.MismatchedTransactions, func(i, j int) bool {
+		return order.less(mismatchSortable(result.MismatchedTransactions[i]), mismatchSortable(result.MismatchedTransactions[j]))
+	})
+}
+
+// CompareMissingInInternal compares two source transactions missing in the internal system the way Sort
+// orders them, for sorting a section that is not held in a Result
+func (order ReportOrder) CompareMissingInInternal(a, b SourceTransaction) int {
+	return order.compare(sourceSortable(a), sourceSortable(b))
+}
+
+// CompareMissingInSource compares two system transactions missing in the source the way Sort orders them
+func (order ReportOrder) CompareMissingInSource(a, b SystemTransaction) int {
+	return order.compare(systemSortable(a), systemSortable(b))
+}
+
+// CompareMismatched compares two mismatched transactions the way Sort orders them
+func (order ReportOrder) CompareMismatched(a, b MismatchedTransaction) int {
+	return order.compare(mismatchSortable(a), mismatchSortable(b))
+}
+
+// less compares two exceptions key by key, falling back to the transaction ID
+func (order ReportOrder) less(a, b sortable) bool {
+	return order.compare(a, b) < 0
+}
+
+// compare returns -1, 0 or 1 as a sorts before, with or after b, a nil order meaning DefaultReportOrder
+func (order ReportOrder) compare(a, b sortable) int {
+	if order == nil {
+		order = DefaultReportOrder
+	}
+	for _, key := range order {
+		var cmp int
+		switch key.Field {
+		case SortBySeverity:
+			cmp = compareOrdered(a.severity, b.severity)
+		case SortByAmount:
+			cmp = compareOrdered(a.amount, b.amount)
+		case SortByCreatedAt:
+			cmp = a.createdAt.Compare(b.createdAt)
+		case SortByID:
+			cmp = strings.Compare(a.id, b.id)
+		}
+		if key.Descending {
+			cmp = -cmp
+		}
+		if cmp != 0 {
+			return cmp
+		}
+	}
+	return strings.Compare(a.id, b.id)
+}
+
+// compareOrdered returns -1, 0 or 1 as a is less than, equal to or greater than b
+func compareOrdered[T ~int | ~float64](a, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// sourceSortable describes a source transaction missing in the internal system
+func sourceSortable(txn SourceTransaction) sortable {
+	return missingSortable(txn.ProviderTransactionID, txn.Status, txn.Amount, txn.CreatedAt)
+}
+
+// systemSortable describes a system transaction missing in the source
+func systemSortable(txn SystemTransaction) sortable {
+	return missingSortable(txn.TransactionID, txn.Status, txn.Amount, txn.CreatedAt)
+}
+
+// missingSortable describes a transaction missing on the other side. A completed transaction is money
+// moved on one side only, so it ranks above a pending one, which ranks above any other status.
+func missingSortable(id, status string, amount float64, createdAt time.Time) sortable {
+	severity := SeverityLow
+	switch strings.ToUpper(strings.TrimSpace(status)) {
+	case "COMPLETED", "SUCCEEDED":
+		severity = SeverityHigh
+	case "PENDING":
+		severity = SeverityMedium
+	}
+	return sortable{id: id, severity: severity, amount: amount, createdAt: createdAt}
+}
+
+// mismatchSortable describes a mismatch by its worst discrepancy: money fields first, then the status,
+// then anything else. Its amount is the amount at stake, as for a case.
+func mismatchSortable(mismatch MismatchedTransaction) sortable {
+	s := sortable{id: mismatch.TransactionID, severity: SeverityLow, amount: mismatch.AmountAtStake()}
+	for field := range mismatch.Discrepancies {
+		switch field {
+		case "amount", "currency":
+			s.severity = SeverityHigh
+		case "status":
+			s.severity = max(s.severity, SeverityMedium)
+		}
+	}
+	if mismatch.Source != nil {
+		s.createdAt = mismatch.Source.CreatedAt
+	} else if mismatch.System != nil {
+		s.createdAt = mismatch.System.CreatedAt
+	}
+	return s
+}
@@ -0,0 +1,151 @@
+package reconcile
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseReportOrder(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    ReportOrder
+		wantErr bool
+	}{
+		{in: "-severity,-amount,createdAt,id", want: DefaultReportOrder},
+		{in: " amount , -id ", want: ReportOrder{{Field: SortByAmount}, {Field: SortByID, Descending: true}}},
+		{in: "createdAt,", want: ReportOrder{{Field: SortByCreatedAt}}},
+		{in: "", wantErr: true},
+		{in: "-date", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseReportOrder(tt.in)
+		if tt.wantErr {
+			if !errors.Is(err, ErrInvalidReportOrder) {
+				t.Errorf("ParseReportOrder(%q) = %v, %v, want %v", tt.in, got, err, ErrInvalidReportOrder)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseReportOrder(%q) = %v, %v, want %v", tt.in, got, err, tt.want)
+		}
+		if again, err := ParseReportOrder(got.String()); err != nil || !reflect.DeepEqual(again, got) {
+			t.Errorf("%v does not round-trip through String: %v, %v", got, again, err)
+		}
+	}
+}
+
+// orderDataset builds both sides with many ties on status, amount and createdAt, about a tenth of each side
+// without a counterpart and a tenth of the pairs differing in amount or status
+func orderDataset(n int) ([]SourceTransaction, []SystemTransaction) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	day := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	statuses := []string{"completed", "pending", "failed"}
+	var source []SourceTransaction
+	var system []SystemTransaction
+	for i, key := range rng.Perm(n) {
+		id := fmt.Sprintf("txn-%04d", key)
+		status := statuses[rng.IntN(len(statuses))]
+		amount := float64(rng.IntN(5) * 10)
+		createdAt := day.Add(time.Duration(rng.IntN(3)) * time.Hour)
+		src := SourceTransaction{ProviderTransactionID: id, Amount: amount, Currency: "USD", Status: status, CreatedAt: createdAt, UpdatedAt: createdAt}
+		sys := SystemTransaction{TransactionID: id, Amount: amount, Currency: "USD", Status: status, CreatedAt: createdAt, UpdatedAt: createdAt}
+		switch i % 10 {
+		case 0:
+			source = append(source, src)
+			continue
+		case 1:
+			system = append(system, sys)
+			continue
+		case 2:
+			sys.Amount += 5
+		case 3:
+			sys.Status = "failed"
+		}
+		source = append(source, src)
+		system = append(system, sys)
+	}
+	return source, system
+}
+
+func TestReportOrderIsDeterministic(t *testing.T) {
+	source, system := orderDataset(2000)
+	orders := []ReportOrder{DefaultReportOrder, {{Field: SortByCreatedAt, Descending: true}}, {{Field: SortByAmount}}}
+
+	for _, order := range orders {
+		t.Run(order.String(), func(t *testing.T) {
+			reconciler := New(WithOrder(order))
+			want, err := reconciler.Reconcile(context.Background(), source, system)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkSorted(t, order, want)
+
+			rng := rand.New(rand.NewPCG(3, 4))
+			for run := range 5 {
+				shuffledSource, shuffledSystem := append([]SourceTransaction(nil), source...), append([]SystemTransaction(nil), system...)
+				rng.Shuffle(len(shuffledSource), func(i, j int) { shuffledSource[i], shuffledSource[j] = shuffledSource[j], shuffledSource[i] })
+				rng.Shuffle(len(shuffledSystem), func(i, j int) { shuffledSystem[i], shuffledSystem[j] = shuffledSystem[j], shuffledSystem[i] })
+
+				var got *Result
+				if run%2 == 0 {
+					got, err = reconciler.Reconcile(context.Background(), shuffledSource, shuffledSystem)
+				} else {
+					got, err = reconciler.ReconcileParallel(context.Background(), shuffledSource, shuffledSystem, run+1)
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(got, want) {
+					t.Fatalf("run %d on shuffled input differs from the first run", run)
+				}
+			}
+		})
+	}
+}
+
+// checkSorted fails when two neighbouring exceptions of a section are out of order or tie completely
+func checkSorted(t *testing.T, order ReportOrder, result *Result) {
+	t.Helper()
+	if len(result.MissingInInternal) < 2 || len(result.MissingInSource) < 2 || len(result.MismatchedTransactions) < 2 {
+		t.Fatalf("summary %+v leaves a section with too few exceptions to check the order", result.Summary)
+	}
+	for i := 1; i < len(result.MissingInInternal); i++ {
+		if !order.less(sourceSortable(result.MissingInInternal[i-1]), sourceSortable(result.MissingInInternal[i])) {
+			t.Fatalf("missing in internal %d and %d out of order", i-1, i)
+		}
+	}
+	for i := 1; i < len(result.MissingInSource); i++ {
+		if !order.less(systemSortable(result.MissingInSource[i-1]), systemSortable(result.MissingInSource[i])) {
+			t.Fatalf("missing in source %d and %d out of order", i-1, i)
+		}
+	}
+	for i := 1; i < len(result.MismatchedTransactions); i++ {
+		if !order.less(mismatchSortable(result.MismatchedTransactions[i-1]), mismatchSortable(result.MismatchedTransactions[i])) {
+			t.Fatalf("mismatches %d and %d out of order", i-1, i)
+		}
+	}
+}
+
+func TestReportOrderSeverity(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b sortable
+	}{
+		{"completed before pending", missingSortable("b", "completed", 1, time.Time{}), missingSortable("a", "pending", 100, time.Time{})},
+		{"pending before failed", missingSortable("b", " Pending ", 1, time.Time{}), missingSortable("a", "failed", 100, time.Time{})},
+		{"larger amount first", missingSortable("b", "failed", 100, time.Time{}), missingSortable("a", "failed", 1, time.Time{})},
+		{"ID breaks the ties", missingSortable("a", "failed", 1, time.Time{}), missingSortable("b", "failed", 1, time.Time{})},
+		{"amount mismatch before status", mismatchSortable(MismatchedTransaction{TransactionID: "b", Discrepancies: DiscrepancyMap{"amount": {}}}),
+			mismatchSortable(MismatchedTransaction{TransactionID: "a", Discrepancies: DiscrepancyMap{"status": {}}})},
+	}
+	for _, tt := range tests {
+		if !DefaultReportOrder.less(tt.a, tt.b) || DefaultReportOrder.less(tt.b, tt.a) {
+			t.Errorf("%s: %+v does not sort before %+v", tt.name, tt.a, tt.b)
+		}
+	}
+}
@@ -0,0 +1,243 @@
+package reconcile
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// PayPalSource reads a PayPal Settlement Report (SRF) as source transactions. The report is a CSV whose
+// first column tells the row type: CH rows name the columns of the SB body rows after them, the header,
+// footer and count rows around them are skipped. Amounts are in minor units with a separate debit or
+// credit mark: the amount is unsigned like in the source transactions file, the net amount negative for
+// debits such as refunds, and a fee PayPal kept positive. The event code is the transaction type, and its
+// group gives the reporting category.
+type PayPalSource struct {
+	name string
+	open func() (io.ReadCloser, error)
+}
+
+// PayPalSettlementFile reads a PayPal Settlement Report file
+func PayPalSettlementFile(path string) *PayPalSource {
+	return &PayPalSource{name: "PayPal settlement report file", open: openFile(path)}
+}
+
+// PayPalSettlement reads a PayPal Settlement Report, which can only be iterated once
+func PayPalSettlement(r io.Reader) *PayPalSource {
+	return &PayPalSource{name: "PayPal settlement report", open: nopOpen(r)}
+}
+
+// Transactions yields one transaction per body row in file order
+func (s *PayPalSource) Transactions(ctx context.Context) iter.Seq2[SourceTransaction, error] {
+	return func(yield func(SourceTransaction, error) bool) {
+		r, err := s.open()
+		if err != nil {
+			yield(SourceTransaction{}, fmt.Errorf("failed to open %s: %w", s.name, err))
+			return
+		}
+		defer r.Close()
+
+		if err := s.each(ctx, r, yield); err != nil && !errors.Is(err, errStopIteration) {
+			yield(SourceTransaction{}, err)
+		}
+	}
+}
+
+// each parses the body rows with the column header before them
+func (s *PayPalSource) each(ctx context.Context, r io.Reader, yield func(SourceTransaction, error) bool) error {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	var columns csvColumns
+	for line := 1; ; line++ {
+		if line%checkInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+		}
+
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", s.name, err)
+		}
+
+		switch strings.TrimPrefix(strings.TrimSpace(record[0]), "\ufeff") {
+		case "CH":
+			columns = newCSVColumns(record, normalizePayPalColumn)
+			if !columns.has("transaction id") || !columns.has("gross transaction amount") {
+				return fmt.Errorf("invalid column header at line %d: expected Transaction ID and Gross Transaction Amount columns", line)
+			}
+		case "SB":
+			if columns == nil {
+				return fmt.Errorf("invalid record at line %d: body row before any column header", line)
+			}
+			txn, err := parsePayPalRecord(columns, record, line)
+			if err != nil {
+				return err
+			}
+			if !yield(txn, nil) {
+				return errStopIteration
+			}
+		}
+	}
+	if columns == nil {
+		return fmt.Errorf("%s has no column header, it is not a settlement report", s.name)
+	}
+	return nil
+}
+
+// normalizePayPalColumn makes column names comparable, the version of the report changing their case
+func normalizePayPalColumn(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// parsePayPalRecord parses one body row, line is used in errors
+func parsePayPalRecord(columns csvColumns, record []string, line int) (SourceTransaction, error) {
+	get := func(name string) string { return columns.get(record, name) }
+
+	currency := strings.ToUpper(get("gross transaction currency"))
+	amount, err := parsePayPalAmount(get("gross transaction amount"), get("transaction debit or credit"), currency)
+	if err != nil {
+		return SourceTransaction{}, fmt.Errorf("invalid amount at line %d: %w", line, err)
+	}
+	// A fee debited from the account is what PayPal kept, a credited one is a fee given back
+	fee, err := parsePayPalAmount(get("fee amount"), get("fee debit or credit"), currency)
+	if err != nil {
+		return SourceTransaction{}, fmt.Errorf("invalid fee at line %d: %w", line, err)
+	}
+	if fee != 0 {
+		fee = -fee
+	}
+
+	createdAt, err := parseSettlementTime(get("transaction initiation date"))
+	if err != nil {
+		return SourceTransaction{}, fmt.Errorf("invalid createdAt at line %d: %w", line, err)
+	}
+	updatedAt := createdAt
+	if completed := get("transaction completion date"); completed != "" {
+		if updatedAt, err = parseSettlementTime(completed); err != nil {
+			return SourceTransaction{}, fmt.Errorf("invalid updatedAt at line %d: %w", line, err)
+		}
+	}
+
+	id := get("transaction id")
+	if id == "" {
+		return SourceTransaction{}, fmt.Errorf("invalid record at line %d: no transaction ID", line)
+	}
+	code := get("transaction event code")
+	category := payPalCategory(code)
+
+	txn := SourceTransaction{
+		ProviderTransactionID: id,
+		Provider:              "PayPal",
+		Amount:                math.Abs(amount),
+		Currency:              currency,
+		Status:                payPalStatus(code, category),
+		TransactionType:       code,
+		CreatedAt:             createdAt,
+		UpdatedAt:             updatedAt,
+		ProviderReference:     get("paypal reference id"),
+		DetailsInvoiceID:      get("invoice id"),
+		DetailsDescription:    get("custom field"),
+		Fee:                   fee,
+		Net:                   amount - fee,
+		ReportingCategory:     category,
+	}
+	// The consumer is given by email or by PayPal account ID
+	if consumer := get("consumer id"); strings.Contains(consumer, "@") {
+		txn.Email = consumer
+	} else {
+		txn.UserID = consumer
+	}
+	// The report does not tell the withdrawal a payment was paid out in, a withdrawal is its own payout
+	if category == "withdrawal" {
+		txn.PayoutID = id
+	}
+	return txn, nil
+}
+
+// parsePayPalAmount parses an amount in minor units, negative when mark is DR
+func parsePayPalAmount(value, mark, currency string) (float64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	cents, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	amount := float64(cents) / minorUnitScale(currency)
+	switch strings.ToUpper(mark) {
+	case "CR", "":
+		return amount, nil
+	case "DR":
+		return -amount, nil
+	}
+	return 0, fmt.Errorf("debit or credit mark %q is neither DR nor CR", mark)
+}
+
+// payPalCategories name the groups of transaction event codes, T0006 being in group T00
+var payPalCategories = map[string]string{
+	"T00": "payment",
+	"T01": "fee",
+	"T02": "currency_conversion",
+	"T03": "deposit",
+	"T04": "withdrawal",
+	"T05": "debit_card",
+	"T06": "credit_card_withdrawal",
+	"T07": "credit_card_deposit",
+	"T08": "bonus",
+	"T09": "incentive",
+	"T10": "bill_pay",
+	"T11": "reversal",
+	"T12": "adjustment",
+	"T13": "authorization",
+	"T14": "dividend",
+	"T15": "hold",
+	"T16": "buyer_credit_deposit",
+	"T17": "non_bank_withdrawal",
+	"T18": "buyer_credit_withdrawal",
+	"T19": "account_correction",
+	"T20": "transfer",
+	"T21": "reserve",
+	"T22": "transfer",
+	"T30": "generic_instrument",
+	"T50": "collection",
+	"T97": "payable",
+	"T98": "display_only",
+	"T99": "other",
+}
+
+// payPalCategory is the reporting category of an event code, other when the group is unknown
+func payPalCategory(code string) string {
+	if len(code) >= 3 {
+		if category, ok := payPalCategories[strings.ToUpper(code[:3])]; ok {
+			return category
+		}
+	}
+	return "other"
+}
+
+// payPalStatus tells the status of a settled transaction from its event code: chargebacks and dispute
+// holds are disputed, other reversals refunded, and funds on hold pending
+func payPalStatus(code, category string) string {
+	switch strings.ToUpper(code) {
+	case "T1106", "T1110", "T1201":
+		return "disputed"
+	}
+	switch category {
+	case "reversal":
+		return "refunded"
+	case "hold":
+		return "pending"
+	}
+	return "completed"
+}
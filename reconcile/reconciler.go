@@ -0,0 +1,295 @@
+package reconcile
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sync"
+)
+
+// checkInterval is how many pairs are compared between progress callbacks and cancellation checks
+const checkInterval = 1000
+
+// Reconciler matches source transactions against system transactions by transaction ID
+type Reconciler struct {
+	matcher Matcher
+	order   ReportOrder
+	workers int
+}
+
+// Option configures a Reconciler
+type Option func(*Reconciler)
+
+// WithMatcher replaces the comparison of the two sides of a pair, DefaultFieldMatcher by default
+func WithMatcher(matcher Matcher) Option {
+	return func(r *Reconciler) { r.matcher = matcher }
+}
+
+// WithOrder sets the order of the exceptions in every result, DefaultReportOrder by default
+func WithOrder(order ReportOrder) Option {
+	return func(r *Reconciler) { r.order = order }
+}
+
+// WithWorkers makes Run match on several workers with ReconcileParallel, zero meaning one per CPU.
+// One, the default, matches on the calling goroutine.
+func WithWorkers(workers int) Option {
+	return func(r *Reconciler) { r.workers = workers }
+}
+
+// New creates a reconciler
+func New(opts ...Option) *Reconciler {
+	r := &Reconciler{matcher: DefaultFieldMatcher, order: DefaultReportOrder, workers: 1}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Order returns the order the exceptions of every result are sorted in
+func (r *Reconciler) Order() ReportOrder {
+	return r.order
+}
+
+// Compare maps the two sides of a pair into canonical transactions and returns their discrepancies
+// according to the matcher. A FieldMatcher comparing no attributes reads only the common fields, so the
+// attributes and the raw records are not built for it, saving their allocations on every pair.
+func (r *Reconciler) Compare(source SourceTransaction, system SystemTransaction) DiscrepancyMap {
+	switch m := r.matcher.(type) {
+	case FieldMatcher:
+		if len(m.Attributes) == 0 {
+			return m.Compare(sourceFields(source), systemFields(system))
+		}
+	case *FieldMatcher:
+		if len(m.Attributes) == 0 {
+			return m.Compare(sourceFields(source), systemFields(system))
+		}
+	}
+	return r.matcher.Compare(FromSource(source), FromSystem(system))
+}
+
+// Run reads both sides from their sources, reconciles them and writes the result to every sink in turn
+func (r *Reconciler) Run(ctx context.Context, source TransactionSource[SourceTransaction], system TransactionSource[SystemTransaction], sinks ...ResultSink) (*Result, error) {
+	sourceTransactions, err := Collect(ctx, source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read source transactions: %w", err)
+	}
+	systemTransactions, err := Collect(ctx, system)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read system transactions: %w", err)
+	}
+
+	var result *Result
+	if r.workers == 1 {
+		result, err = r.Reconcile(ctx, sourceTransactions, systemTransactions)
+	} else {
+		result, err = r.ReconcileParallel(ctx, sourceTransactions, systemTransactions, r.workers)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, sink := range sinks {
+		if err := sink.Write(ctx, result); err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+// Reconcile performs the reconciliation between source and system transactions, stopping when ctx is done.
+// When a transaction ID appears more than once on one side, the last one wins.
+func (r *Reconciler) Reconcile(ctx context.Context, sourceTransactions []SourceTransaction, systemTransactions []SystemTransaction) (*Result, error) {
+	return r.reconcile(ctx, sourceTransactions, systemTransactions, nil, nil)
+}
+
+// ReconcileWithProgress performs the reconciliation like Reconcile, calling onProgress, when not nil,
+// periodically while the source transactions are matched
+func (r *Reconciler) ReconcileWithProgress(ctx context.Context, sourceTransactions []SourceTransaction, systemTransactions []SystemTransaction, onProgress func(MatchProgress)) (*Result, error) {
+	return r.reconcile(ctx, sourceTransactions, systemTransactions, onProgress, nil)
+}
+
+// ReconcileStream performs the reconciliation like Reconcile, calling onException as soon as an exception
+// is found. An error returned by onException stops the reconciliation and is returned as is.
+func (r *Reconciler) ReconcileStream(ctx context.Context, sourceTransactions []SourceTransaction, systemTransactions []SystemTransaction, onException func(Exception) error) (*Result, error) {
+	return r.reconcile(ctx, sourceTransactions, systemTransactions, nil, onException)
+}
+
+// reconcile is the matching loop behind Reconcile, ReconcileWithProgress and ReconcileStream
+func (r *Reconciler) reconcile(ctx context.Context, sourceTransactions []SourceTransaction, systemTransactions []SystemTransaction, onProgress func(MatchProgress), onException func(Exception) error) (*Result, error) {
+	// Create maps for efficient lookup
+	sourceMap := make(map[string]SourceTransaction)
+	systemMap := make(map[string]SystemTransaction)
+
+	// Index source transactions by their ID
+	for _, txn := range sourceTransactions {
+		sourceMap[txn.ProviderTransactionID] = txn
+	}
+
+	// Index system transactions by their ID
+	for _, txn := range systemTransactions {
+		systemMap[txn.TransactionID] = txn
+	}
+
+	var missingInInternal []SourceTransaction
+	var missingInSource []SystemTransaction
+	var mismatchedTransactions []MismatchedTransaction
+	matchedCount := 0
+	compared := 0
+
+	emit := func(exception Exception) error {
+		if onException == nil {
+			return nil
+		}
+		return onException(exception)
+	}
+
+	report := func() {
+		if onProgress != nil {
+			onProgress(MatchProgress{
+				PairsCompared:     compared,
+				PairsTotal:        len(sourceMap),
+				MissingInInternal: len(missingInInternal),
+				MissingInSource:   len(missingInSource),
+				Mismatched:        len(mismatchedTransactions),
+			})
+		}
+	}
+
+	// Find transactions missing in internal system and mismatched transactions
+	for id, sourceTxn := range sourceMap {
+		if compared%checkInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			report()
+		}
+		compared++
+
+		if systemTxn, exists := systemMap[id]; exists {
+			// Transaction exists in both systems, check for discrepancies
+			discrepancies := r.Compare(sourceTxn, systemTxn)
+			if len(discrepancies) > 0 {
+				sourceCopy, systemCopy := sourceTxn, systemTxn
+				mismatch := MismatchedTransaction{
+					TransactionID: id,
+					Discrepancies: discrepancies,
+					Source:        &sourceCopy,
+					System:        &systemCopy,
+				}
+				mismatchedTransactions = append(mismatchedTransactions, mismatch)
+				if err := emit(Exception{Kind: ExceptionMismatched, Mismatch: &mismatch}); err != nil {
+					return nil, err
+				}
+			} else {
+				matchedCount++
+			}
+		} else {
+			// Transaction exists in source but not in internal system
+			missingInInternal = append(missingInInternal, sourceTxn)
+			if err := emit(Exception{Kind: ExceptionMissingInInternal, Source: &sourceTxn}); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// Find transactions missing in source
+	for id, systemTxn := range systemMap {
+		if _, exists := sourceMap[id]; !exists {
+			// Transaction exists in system but not in source
+			missingInSource = append(missingInSource, systemTxn)
+			if err := emit(Exception{Kind: ExceptionMissingInSource, System: &systemTxn}); err != nil {
+				return nil, err
+			}
+		}
+	}
+	report()
+
+	// Create summary
+	summary := Summary{
+		TotalSourceTransactions:     len(sourceTransactions),
+		TotalSystemTransactions:     len(systemTransactions),
+		MissingInInternalCount:      len(missingInInternal),
+		MissingInSourceCount:        len(missingInSource),
+		MismatchedTransactionsCount: len(mismatchedTransactions),
+		SuccessfullyMatchedCount:    matchedCount,
+	}
+
+	result := &Result{
+		MissingInInternal:      missingInInternal,
+		MissingInSource:        missingInSource,
+		MismatchedTransactions: mismatchedTransactions,
+		Summary:                summary,
+	}
+	r.order.Sort(result)
+	return result, nil
+}
+
+// ReconcileParallel performs the reconciliation like Reconcile on several workers. Transactions are
+// hash-partitioned by ID into one shard per worker, so both sides of a pair land in the same shard, and the
+// shard results are merged and sorted in the report order, which keeps the output identical from one run
+// to the next whatever the number of workers.
+func (r *Reconciler) ReconcileParallel(ctx context.Context, sourceTransactions []SourceTransaction, systemTransactions []SystemTransaction, workers int) (*Result, error) {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	sourceShards := make([][]SourceTransaction, workers)
+	for _, txn := range sourceTransactions {
+		shard := Shard(txn.ProviderTransactionID, workers)
+		sourceShards[shard] = append(sourceShards[shard], txn)
+	}
+	systemShards := make([][]SystemTransaction, workers)
+	for _, txn := range systemTransactions {
+		shard := Shard(txn.TransactionID, workers)
+		systemShards[shard] = append(systemShards[shard], txn)
+	}
+
+	shardResults := make([]*Result, workers)
+	shardErrs := make([]error, workers)
+	var wg sync.WaitGroup
+	for shard := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			shardResults[shard], shardErrs[shard] = r.Reconcile(ctx, sourceShards[shard], systemShards[shard])
+		}()
+	}
+	wg.Wait()
+
+	for _, err := range shardErrs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return MergeResults(shardResults, r.order), nil
+}
+
+// MergeResults combines the results of disjoint sets of transactions into one, with the exceptions
+// sorted in the given order
+func MergeResults(results []*Result, order ReportOrder) *Result {
+	merged := &Result{}
+	for _, result := range results {
+		merged.MissingInInternal = append(merged.MissingInInternal, result.MissingInInternal...)
+		merged.MissingInSource = append(merged.MissingInSource, result.MissingInSource...)
+		merged.MismatchedTransactions = append(merged.MismatchedTransactions, result.MismatchedTransactions...)
+		merged.Summary.TotalSourceTransactions += result.Summary.TotalSourceTransactions
+		merged.Summary.TotalSystemTransactions += result.Summary.TotalSystemTransactions
+		merged.Summary.MissingInInternalCount += result.Summary.MissingInInternalCount
+		merged.Summary.MissingInSourceCount += result.Summary.MissingInSourceCount
+		merged.Summary.MismatchedTransactionsCount += result.Summary.MismatchedTransactionsCount
+		merged.Summary.SuccessfullyMatchedCount += result.Summary.SuccessfullyMatchedCount
+	}
+
+	order.Sort(merged)
+	return merged
+}
+
+// Shard maps a transaction ID to one of n shards with FNV-1a, the same ID always landing in the same shard
+func Shard(id string, n int) int {
+	hash := uint32(2166136261)
+	for i := 0; i < len(id); i++ {
+		hash ^= uint32(id[i])
+		hash *= 16777619
+	}
+	return int(hash % uint32(n))
+}
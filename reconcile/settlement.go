@@ -0,0 +1,71 @@
+package reconcile
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// csvColumns finds the columns of a provider report by name, whatever their order, so the readers
+// survive columns being added, removed or moved between report versions
+type csvColumns map[string]int
+
+// newCSVColumns indexes a header row, its names made comparable with normalize
+func newCSVColumns(header []string, normalize func(string) string) csvColumns {
+	columns := make(csvColumns, len(header))
+	for i, name := range header {
+		name = normalize(strings.TrimPrefix(name, "\ufeff"))
+		if _, seen := columns[name]; !seen {
+			columns[name] = i
+		}
+	}
+	return columns
+}
+
+// has tells whether any of the columns is in the header
+func (c csvColumns) has(names ...string) bool {
+	for _, name := range names {
+		if _, ok := c[name]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// get returns the value of the first of the columns the header has, empty when it has none of them
+func (c csvColumns) get(record []string, names ...string) string {
+	for _, name := range names {
+		if i, ok := c[name]; ok {
+			if i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+	}
+	return ""
+}
+
+// settlementTimeLayouts are the layouts of the dates in provider reports, those without a zone in UTC
+var settlementTimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006/01/02 15:04:05 -0700",
+	"2006/01/02 15:04:05 MST",
+	time.DateOnly,
+}
+
+// parseSettlementTime parses a date of a provider report, or seconds since the epoch
+func parseSettlementTime(value string) (time.Time, error) {
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(seconds, 0).UTC(), nil
+	}
+	var err error
+	for _, layout := range settlementTimeLayouts {
+		var t time.Time
+		if t, err = time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, err
+}
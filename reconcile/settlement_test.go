@@ -0,0 +1,145 @@
+package reconcile
+
+import (
+	"context"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStripeSource(t *testing.T) {
+	transactions, err := Collect(context.Background(), StripeFile("../assets/data/providers/stripe_payout_reconciliation.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(transactions) != 28 {
+		t.Fatalf("got %d transactions, want 28", len(transactions))
+	}
+	charge, refund := transactions[0], transactions[1]
+	if charge.ProviderTransactionID != "1fc4ea5d-e9aa-403c-8609-59919fdcb127" || charge.ProviderReference != "txn_3PQ0000Kx2mAbC1fc4ea" ||
+		charge.Amount != 104.89 || charge.Fee != 3.34 || charge.Net != 101.55 || charge.Currency != "USD" || charge.Status != "succeeded" ||
+		charge.PayoutID != "po_1PQx8LKx2mAbC0001" || charge.ReportingCategory != "charge" || charge.Email != "fleming_morin@outlook.com" ||
+		!charge.CreatedAt.Equal(time.Date(2024, 1, 8, 4, 53, 49, 0, time.UTC)) || !charge.UpdatedAt.Equal(time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("charge = %+v", charge)
+	}
+	if refund.Amount != 823.58 || refund.Net != -823.58 || refund.Status != "refunded" || refund.ReportingCategory != "refund" {
+		t.Errorf("refund = %+v, want 823.58 refunded with a net of -823.58", refund)
+	}
+
+	tests := []struct {
+		name    string
+		csv     string
+		want    SourceTransaction
+		wantErr string
+	}{
+		{
+			name: "API export with the balance transaction only",
+			csv:  "id,amount,fee,currency,created,type,status\ntxn_1,\"1,250.00\",36.55,eur,1704067200,charge,pending\n",
+			want: SourceTransaction{ProviderTransactionID: "txn_1", ProviderReference: "txn_1", Amount: 1250, Fee: 36.55, Net: 1213.45, Currency: "EUR", Status: "pending", TransactionType: "charge", ReportingCategory: "charge"},
+		},
+		{
+			name: "dispute",
+			csv:  "Balance Transaction ID,Source ID,Gross,Currency,Created (UTC),Reporting Category\ntxn_2,ch_2,-40.00,usd,2024-01-01 00:00:00,dispute\n",
+			want: SourceTransaction{ProviderTransactionID: "ch_2", ProviderReference: "txn_2", Amount: 40, Net: -40, Currency: "USD", Status: "disputed", TransactionType: "dispute", ReportingCategory: "dispute"},
+		},
+		{
+			name:    "invalid amount",
+			csv:     "id,amount,currency,created\ntxn_1,10.00,usd,1704067200\ntxn_2,ten,usd,1704067200\n",
+			wantErr: "invalid amount at line 3",
+		},
+		{
+			name:    "not a Stripe report",
+			csv:     "transactionId,amount,currency\n",
+			wantErr: "has no created_utc column",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transactions, err := Collect(context.Background(), Stripe(strings.NewReader(tt.csv)))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := transactions[0]
+			got.Provider, got.CreatedAt, got.UpdatedAt = "", time.Time{}, time.Time{}
+			if got.Net = math.Round(got.Net*100) / 100; got != tt.want {
+				t.Errorf("transaction = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPayPalSettlementSource(t *testing.T) {
+	transactions, err := Collect(context.Background(), PayPalSettlementFile("../assets/data/providers/paypal_settlement_report.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(transactions) != 23 {
+		t.Fatalf("got %d transactions, want 23", len(transactions))
+	}
+	reversal := transactions[0]
+	if reversal.ProviderTransactionID != "58511bef-b9d4-43ce-8127-a565ce5a99d5" || reversal.ProviderReference != "88f1abe5-61a1-471c-876a-932d603d3ab9" ||
+		reversal.Amount != 605.95 || reversal.Fee != -21.15 || math.Abs(reversal.Net+584.8) > 1e-9 || reversal.Status != "refunded" ||
+		reversal.ReportingCategory != "reversal" || reversal.Email != "tasha_west@outlook.com" ||
+		!reversal.CreatedAt.Equal(time.Date(2024, 1, 11, 19, 3, 8, 0, time.UTC)) {
+		t.Errorf("first transaction = %+v", reversal)
+	}
+
+	header := `"CH","Transaction ID","Transaction Event Code","Transaction Initiation Date","Transaction Debit or Credit","Gross Transaction Amount","Gross Transaction Currency","Fee Debit or Credit","Fee Amount","Consumer ID"` + "\n"
+	tests := []struct {
+		name    string
+		row     string
+		want    SourceTransaction
+		wantErr string
+	}{
+		{
+			name: "payment in yen",
+			row:  `"SB","p1","T0006","2024/01/01 00:00:00 -0000","CR","1500","JPY","DR","60","BUYER123"`,
+			want: SourceTransaction{ProviderTransactionID: "p1", Amount: 1500, Fee: 60, Net: 1440, Currency: "JPY", Status: "completed", TransactionType: "T0006", ReportingCategory: "payment", UserID: "BUYER123"},
+		},
+		{
+			name: "chargeback",
+			row:  `"SB","c1","T1106","2024/01/01 00:00:00 -0000","DR","2500","USD","","",""`,
+			want: SourceTransaction{ProviderTransactionID: "c1", Amount: 25, Net: -25, Currency: "USD", Status: "disputed", TransactionType: "T1106", ReportingCategory: "reversal"},
+		},
+		{
+			name: "withdrawal",
+			row:  `"SB","w1","T0400","2024/01/01 00:00:00 -0000","DR","10000","USD","","",""`,
+			want: SourceTransaction{ProviderTransactionID: "w1", Amount: 100, Net: -100, Currency: "USD", Status: "completed", TransactionType: "T0400", ReportingCategory: "withdrawal", PayoutID: "w1"},
+		},
+		{
+			name:    "unknown debit or credit mark",
+			row:     `"SB","p1","T0006","2024/01/01 00:00:00 -0000","XX","1500","USD","","",""`,
+			wantErr: "invalid amount at line 2",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transactions, err := Collect(context.Background(), PayPalSettlement(strings.NewReader(header+tt.row+"\n")))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := transactions[0]
+			got.Provider, got.CreatedAt, got.UpdatedAt = "", time.Time{}, time.Time{}
+			if got != tt.want {
+				t.Errorf("transaction = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := Collect(context.Background(), PayPalSettlement(strings.NewReader(`"SB","p1"`+"\n"))); err == nil {
+		t.Error("body row without a column header accepted")
+	}
+}
@@ -0,0 +1,258 @@
+package reconcile
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"iter"
+	"net/http"
+	"os"
+)
+
+// ResultSink receives the result of a reconciliation
+type ResultSink interface {
+	Write(ctx context.Context, result *Result) error
+}
+
+// SinkFunc adapts a function to the ResultSink interface
+type SinkFunc func(ctx context.Context, result *Result) error
+
+// Write calls f
+func (f SinkFunc) Write(ctx context.Context, result *Result) error {
+	return f(ctx, result)
+}
+
+// NewReport transforms a result into the simplified report format of reconciliation_report.json
+func NewReport(result *Result) Report {
+	// Transform missing_in_internal to simplified format
+	missingInInternal := make([]map[string]interface{}, len(result.MissingInInternal))
+	for i, txn := range result.MissingInInternal {
+		missingInInternal[i] = missingInInternalEntry(txn)
+	}
+
+	// Transform missing_in_source to simplified format
+	missingInSource := make([]map[string]interface{}, len(result.MissingInSource))
+	for i, txn := range result.MissingInSource {
+		missingInSource[i] = missingInSourceEntry(txn)
+	}
+
+	return Report{
+		MissingInInternal:      missingInInternal,
+		MissingInSource:        missingInSource,
+		MismatchedTransactions: result.MismatchedTransactions,
+	}
+}
+
+// missingInInternalEntry is a source transaction missing in the internal system as the report lists it
+func missingInInternalEntry(txn SourceTransaction) map[string]interface{} {
+	return map[string]interface{}{
+		"providerTransactionId": txn.ProviderTransactionID,
+		"amount":                txn.Amount,
+		"currency":              txn.Currency,
+		"status":                txn.Status,
+	}
+}
+
+// missingInSourceEntry is a system transaction missing in the source as the report lists it
+func missingInSourceEntry(txn SystemTransaction) map[string]interface{} {
+	return map[string]interface{}{
+		"transactionId": txn.TransactionID,
+		"amount":        txn.Amount,
+		"currency":      txn.Currency,
+		"status":        txn.Status,
+	}
+}
+
+// StreamReport writes the report of a result whose sections are read one exception at a time, already in
+// report order, so a result larger than memory can be reported. The output is the one of MarshalReport.
+func StreamReport(w io.Writer, missingInInternal iter.Seq2[SourceTransaction, error], missingInSource iter.Seq2[SystemTransaction, error], mismatched iter.Seq2[MismatchedTransaction, error]) error {
+	out := bufio.NewWriter(w)
+	out.WriteString("{\n")
+	if err := streamSection(out, "missing_in_internal", "[]", missingInInternal, missingInInternalEntry); err != nil {
+		return err
+	}
+	out.WriteString(",\n")
+	if err := streamSection(out, "missing_in_source", "[]", missingInSource, missingInSourceEntry); err != nil {
+		return err
+	}
+	out.WriteString(",\n")
+	// An empty mismatch section is null, as for a Result that found no mismatch
+	if err := streamSection(out, "mismatched_transactions", "null", mismatched, func(m MismatchedTransaction) MismatchedTransaction { return m }); err != nil {
+		return err
+	}
+	out.WriteString("\n}")
+	return out.Flush()
+}
+
+// streamSection writes one section of a report indented like MarshalReport, or empty when it holds nothing
+func streamSection[T, E any](out *bufio.Writer, name, empty string, records iter.Seq2[T, error], entry func(T) E) error {
+	fmt.Fprintf(out, "  %q: ", name)
+	count := 0
+	for record, err := range records {
+		if err != nil {
+			return err
+		}
+		data, err := json.MarshalIndent(entry(record), "    ", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal result to JSON: %w", err)
+		}
+		if count == 0 {
+			out.WriteString("[\n    ")
+		} else {
+			out.WriteString(",\n    ")
+		}
+		out.Write(data)
+		count++
+	}
+	if count == 0 {
+		out.WriteString(empty)
+		return nil
+	}
+	_, err := out.WriteString("\n  ]")
+	return err
+}
+
+// MarshalReport returns the report of a result as indented JSON
+func MarshalReport(result *Result) ([]byte, error) {
+	data, err := json.MarshalIndent(NewReport(result), "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal result to JSON: %w", err)
+	}
+	return data, nil
+}
+
+// FileSink writes the report to a file, replacing it
+type FileSink struct {
+	Path string
+}
+
+// Write saves the report
+func (s FileSink) Write(ctx context.Context, result *Result) error {
+	data, err := MarshalReport(result)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(s.Path, data, 0644); err != nil {
+		return fmt.Errorf("failed to save report to %s: %w", s.Path, err)
+	}
+	return nil
+}
+
+// WriterSink writes the report to a writer such as os.Stdout
+type WriterSink struct {
+	W io.Writer
+}
+
+// Write prints the report followed by a newline
+func (s WriterSink) Write(ctx context.Context, result *Result) error {
+	data, err := MarshalReport(result)
+	if err != nil {
+		return err
+	}
+	_, err = s.W.Write(append(data, '\n'))
+	return err
+}
+
+// HTTPSink posts the full result, summary included, as JSON to a URL
+type HTTPSink struct {
+	URL    string
+	Header http.Header  // added to the request, e.g. for authentication
+	Client *http.Client // http.DefaultClient when nil
+}
+
+// Write posts the result and fails unless the server answers with a 2xx status
+func (s HTTPSink) Write(ctx context.Context, result *Result) error {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("failed to marshal result to JSON: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	for key, values := range s.Header {
+		req.Header[key] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post result: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("failed to post result: %s answered %s", s.URL, resp.Status)
+	}
+	return nil
+}
+
+// SQLSink inserts one row per exception within a single database transaction. Insert is a statement taking
+// the kind, transaction ID, amount, currency, status and discrepancies as JSON (NULL unless mismatched)
+// as its six arguments, in the placeholder syntax of the driver, e.g.
+// "INSERT INTO exceptions (kind, transaction_id, amount, currency, status, discrepancies) VALUES ($1, $2, $3, $4, $5, $6)".
+type SQLSink struct {
+	DB     *sql.DB
+	Insert string
+}
+
+// Write inserts the exceptions, none of them when one fails
+func (s SQLSink) Write(ctx context.Context, result *Result) error {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, s.Insert)
+	if err != nil {
+		return fmt.Errorf("failed to prepare insert: %w", err)
+	}
+	defer stmt.Close()
+
+	insert := func(kind ExceptionKind, id string, amount float64, currency, status string, discrepancies any) error {
+		if _, err := stmt.ExecContext(ctx, string(kind), id, amount, currency, status, discrepancies); err != nil {
+			return fmt.Errorf("failed to insert %s exception %s: %w", kind, id, err)
+		}
+		return nil
+	}
+
+	for _, txn := range result.MissingInInternal {
+		if err := insert(ExceptionMissingInInternal, txn.ProviderTransactionID, txn.Amount, txn.Currency, txn.Status, nil); err != nil {
+			return err
+		}
+	}
+	for _, txn := range result.MissingInSource {
+		if err := insert(ExceptionMissingInSource, txn.TransactionID, txn.Amount, txn.Currency, txn.Status, nil); err != nil {
+			return err
+		}
+	}
+	for _, mismatch := range result.MismatchedTransactions {
+		discrepancies, err := json.Marshal(mismatch.Discrepancies)
+		if err != nil {
+			return fmt.Errorf("failed to marshal discrepancies of %s: %w", mismatch.TransactionID, err)
+		}
+		var currency, status string
+		if mismatch.Source != nil {
+			currency, status = mismatch.Source.Currency, mismatch.Source.Status
+		}
+		if err := insert(ExceptionMismatched, mismatch.TransactionID, mismatch.AmountAtStake(), currency, status, string(discrepancies)); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit exceptions: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package reconcile
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// sinkResult returns a result with one exception of every kind
+func sinkResult() *Result {
+	source := SourceTransaction{ProviderTransactionID: "c", Amount: 30, Currency: "EUR", Status: "completed"}
+	system := SystemTransaction{TransactionID: "c", Amount: 25, Currency: "EUR", Status: "completed"}
+	return &Result{
+		MissingInInternal: []SourceTransaction{{ProviderTransactionID: "a", Amount: 10, Currency: "USD", Status: "completed"}},
+		MissingInSource:   []SystemTransaction{{TransactionID: "b", Amount: 20, Currency: "GBP", Status: "pending"}},
+		MismatchedTransactions: []MismatchedTransaction{{
+			TransactionID: "c",
+			Discrepancies: DiscrepancyMap{"amount": {Source: 30.0, System: 25.0}},
+			Source:        &source,
+			System:        &system,
+		}},
+		Summary: Summary{TotalSourceTransactions: 2, TotalSystemTransactions: 2, MissingInInternalCount: 1, MissingInSourceCount: 1, MismatchedTransactionsCount: 1},
+	}
+}
+
+func TestHTTPSink(t *testing.T) {
+	var posted Result
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" || r.Header.Get("Authorization") != "Bearer token" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		if r.URL.Path == "/full" {
+			http.Error(w, "no space left", http.StatusInsufficientStorage)
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(&posted); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+	header := http.Header{"Authorization": {"Bearer token"}}
+
+	result := sinkResult()
+	if err := (HTTPSink{URL: server.URL + "/results", Header: header}).Write(context.Background(), result); err != nil {
+		t.Fatal(err)
+	}
+	if posted.Summary != result.Summary || len(posted.MissingInInternal) != 1 || len(posted.MissingInSource) != 1 ||
+		len(posted.MismatchedTransactions) != 1 || posted.MismatchedTransactions[0].TransactionID != "c" {
+		t.Errorf("posted %+v, want the whole result", posted)
+	}
+
+	err := (HTTPSink{URL: server.URL + "/full", Header: header, Client: server.Client()}).Write(context.Background(), result)
+	if err == nil || !strings.Contains(err.Error(), "507") {
+		t.Errorf("error = %v, want the 507 status", err)
+	}
+}
+
+func TestSQLSink(t *testing.T) {
+	db := &fakeDB{}
+	sink := SQLSink{DB: db.open(), Insert: "INSERT INTO exceptions VALUES ($1, $2, $3, $4, $5, $6)"}
+	if err := sink.Write(context.Background(), sinkResult()); err != nil {
+		t.Fatal(err)
+	}
+
+	want := [][]driver.Value{
+		{"missing_in_internal", "a", 10.0, "USD", "completed", nil},
+		{"missing_in_source", "b", 20.0, "GBP", "pending", nil},
+		{"mismatched", "c", 5.0, "EUR", "completed", `{"amount":{"source":30,"system":25}}`},
+	}
+	if !reflect.DeepEqual(db.inserted, want) {
+		t.Errorf("inserted %v, want %v", db.inserted, want)
+	}
+
+	// A failing insert rolls back the exceptions inserted before it
+	db = &fakeDB{failExec: 2}
+	sink.DB = db.open()
+	if err := sink.Write(context.Background(), sinkResult()); err == nil || !strings.Contains(err.Error(), "missing_in_source exception b") {
+		t.Errorf("error = %v, want the failed insert of b", err)
+	}
+	if len(db.inserted) != 0 {
+		t.Errorf("inserted %v, want nothing once an insert failed", db.inserted)
+	}
+}
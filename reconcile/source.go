@@ -0,0 +1,91 @@
+package reconcile
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"iter"
+)
+
+// TransactionSource provides the transactions of one side of a reconciliation, T being SourceTransaction
+// or SystemTransaction. Iteration stops at the first error, which is yielded with a zero transaction.
+type TransactionSource[T any] interface {
+	Transactions(ctx context.Context) iter.Seq2[T, error]
+}
+
+// SourceFunc adapts a function to the TransactionSource interface, for instance to page through an API
+type SourceFunc[T any] func(ctx context.Context) iter.Seq2[T, error]
+
+// Transactions calls f
+func (f SourceFunc[T]) Transactions(ctx context.Context) iter.Seq2[T, error] {
+	return f(ctx)
+}
+
+// SliceSource provides transactions already in memory
+type SliceSource[T any] []T
+
+// Transactions yields the transactions of the slice in order
+func (s SliceSource[T]) Transactions(ctx context.Context) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		for i, txn := range s {
+			if i%checkInterval == 0 {
+				if err := ctx.Err(); err != nil {
+					var zero T
+					yield(zero, err)
+					return
+				}
+			}
+			if !yield(txn, nil) {
+				return
+			}
+		}
+	}
+}
+
+// Collect reads every transaction of a source
+func Collect[T any](ctx context.Context, source TransactionSource[T]) ([]T, error) {
+	var transactions []T
+	for txn, err := range source.Transactions(ctx) {
+		if err != nil {
+			return nil, err
+		}
+		transactions = append(transactions, txn)
+	}
+	return transactions, nil
+}
+
+// SQLSource reads transactions from a database with any database/sql driver. Scan turns the current row
+// into a transaction, so the query can select whichever columns the schema has.
+type SQLSource[T any] struct {
+	DB    *sql.DB
+	Query string
+	Args  []any
+	Scan  func(rows *sql.Rows) (T, error)
+}
+
+// Transactions runs the query and yields one transaction per row
+func (s SQLSource[T]) Transactions(ctx context.Context) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var zero T
+		rows, err := s.DB.QueryContext(ctx, s.Query, s.Args...)
+		if err != nil {
+			yield(zero, fmt.Errorf("failed to query transactions: %w", err))
+			return
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			txn, err := s.Scan(rows)
+			if err != nil {
+				yield(zero, fmt.Errorf("failed to scan transaction: %w", err))
+				return
+			}
+			if !yield(txn, nil) {
+				return
+			}
+		}
+		if err := rows.Err(); err != nil {
+			yield(zero, fmt.Errorf("failed to read transactions: %w", err))
+		}
+	}
+}
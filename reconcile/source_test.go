@@ -0,0 +1,55 @@
+package reconcile
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSQLSource(t *testing.T) {
+	db := &fakeDB{
+		columns: []string{"transaction_id", "amount"},
+		rows:    [][]driver.Value{{"a", 10.0}, {"b", 20.5}},
+	}
+	source := SQLSource[SystemTransaction]{
+		DB:    db.open(),
+		Query: "SELECT transaction_id, amount FROM transactions WHERE day = $1",
+		Args:  []any{"2025-03-01"},
+		Scan: func(rows *sql.Rows) (SystemTransaction, error) {
+			var txn SystemTransaction
+			err := rows.Scan(&txn.TransactionID, &txn.Amount)
+			return txn, err
+		},
+	}
+
+	got, err := Collect(context.Background(), source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].TransactionID != "a" || got[1].Amount != 20.5 {
+		t.Errorf("transactions = %+v, want a and b", got)
+	}
+
+	// The iteration can stop after any transaction
+	for txn, err := range source.Transactions(context.Background()) {
+		if err != nil || txn.TransactionID != "a" {
+			t.Errorf("first transaction = %+v, %v, want a", txn, err)
+		}
+		break
+	}
+
+	errScan := errors.New("unexpected column")
+	failing := source
+	failing.Scan = func(*sql.Rows) (SystemTransaction, error) { return SystemTransaction{}, errScan }
+	if _, err := Collect(context.Background(), failing); !errors.Is(err, errScan) || !strings.Contains(err.Error(), "failed to scan") {
+		t.Errorf("scan error = %v, want %v", err, errScan)
+	}
+
+	db.queryErr = errors.New("no such table")
+	if _, err := Collect(context.Background(), source); !errors.Is(err, db.queryErr) || !strings.Contains(err.Error(), "failed to query") {
+		t.Errorf("query error = %v, want %v", err, db.queryErr)
+	}
+}
@@ -0,0 +1,104 @@
+package reconcile
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"sync"
+)
+
+// fakeDB is a database/sql driver keeping the rows inserted by committed transactions in memory and
+// answering every query with the same rows, so the SQL sink and source are tested without a database
+type fakeDB struct {
+	mu       sync.Mutex
+	columns  []string
+	rows     [][]driver.Value // answered to every query
+	queryErr error            // returned by every query when set
+	failExec int              // the exec failing, counting from 1, none when zero
+	execs    int
+	inserted [][]driver.Value // the arguments of the execs of committed transactions
+}
+
+// open returns a database using the fake driver
+func (f *fakeDB) open() *sql.DB {
+	return sql.OpenDB(f)
+}
+
+func (f *fakeDB) Connect(context.Context) (driver.Conn, error) { return &fakeConn{db: f}, nil }
+func (f *fakeDB) Driver() driver.Driver                        { return nil }
+
+// fakeConn is a connection to a fakeDB, holding the execs of its open transaction
+type fakeConn struct {
+	db      *fakeDB
+	pending [][]driver.Value
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) { return &fakeStmt{conn: c}, nil }
+func (c *fakeConn) Close() error                              { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error)                 { return c, nil }
+
+// Commit keeps the rows inserted by the transaction
+func (c *fakeConn) Commit() error {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	c.db.inserted = append(c.db.inserted, c.pending...)
+	c.pending = nil
+	return nil
+}
+
+// Rollback drops the rows inserted by the transaction
+func (c *fakeConn) Rollback() error {
+	c.pending = nil
+	return nil
+}
+
+// fakeStmt is a prepared statement of a fakeConn, whatever its query
+type fakeStmt struct {
+	conn *fakeConn
+}
+
+func (s *fakeStmt) Close() error  { return nil }
+func (s *fakeStmt) NumInput() int { return -1 }
+
+// Exec records the arguments in the open transaction
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	db := s.conn.db
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if db.execs++; db.execs == db.failExec {
+		return nil, errors.New("constraint violated")
+	}
+	s.conn.pending = append(s.conn.pending, args)
+	return driver.RowsAffected(1), nil
+}
+
+// Query answers the rows of the database
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	db := s.conn.db
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if db.queryErr != nil {
+		return nil, db.queryErr
+	}
+	return &fakeRows{columns: db.columns, rows: db.rows}, nil
+}
+
+// fakeRows iterates over the rows answered to a query
+type fakeRows struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
@@ -0,0 +1,210 @@
+package reconcile
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidStatement = errors.New("invalid bank statement")
+
+// CreditDebit tells whether a statement entry credits or debits the account
+type CreditDebit string
+
+const (
+	Credit CreditDebit = "CRDT"
+	Debit  CreditDebit = "DBIT"
+)
+
+// StatementEntry is one line of a bank statement or notification, whatever the format it came in
+type StatementEntry struct {
+	StatementID     string      `json:"statementId"`
+	Account         string      `json:"account"`   // IBAN or other account identification
+	Reference       string      `json:"reference"` // the reference of the bank, unique within the account
+	Amount          float64     `json:"amount"`    // always positive, CreditDebit gives the direction
+	Currency        string      `json:"currency"`
+	CreditDebit     CreditDebit `json:"creditDebit"`
+	Status          string      `json:"status"` // BOOK, PDNG or INFO
+	BookingDate     time.Time   `json:"bookingDate"`
+	ValueDate       time.Time   `json:"valueDate"`
+	EndToEndID      string      `json:"endToEndId"`      // set by the initiating party, e.g. a payout ID
+	RemittanceRef   string      `json:"remittanceRef"`   // the structured creditor reference, else the first unstructured line
+	RemittanceInfo  string      `json:"remittanceInfo"`  // every remittance line, joined with spaces
+	TransactionCode string      `json:"transactionCode"` // the bank transaction code, e.g. PMNT/RCDT/ESCT or an MT940 or BAI2 type code
+}
+
+// Balance is the balance of an account on a date, negative when the account is overdrawn
+type Balance struct {
+	Amount   float64   `json:"amount"`
+	Currency string    `json:"currency"`
+	Date     time.Time `json:"date"`
+}
+
+// Statement is one statement of an account with its balances and lines
+type Statement struct {
+	ID       string           `json:"id"`
+	Account  string           `json:"account"`
+	Currency string           `json:"currency"`
+	Opening  *Balance         `json:"opening,omitempty"`
+	Closing  *Balance         `json:"closing,omitempty"`
+	Entries  []StatementEntry `json:"entries"`
+}
+
+// Movement is the credits minus the debits of the booked entries
+func (s Statement) Movement() float64 {
+	movement := 0.0
+	for _, entry := range s.Entries {
+		if entry.Status != "" && entry.Status != "BOOK" {
+			continue
+		}
+		if entry.CreditDebit == Debit {
+			movement -= entry.Amount
+		} else {
+			movement += entry.Amount
+		}
+	}
+	return movement
+}
+
+// Balanced tells whether the opening balance plus the movement gives the closing balance, true when
+// the statement lacks either balance
+func (s Statement) Balanced() bool {
+	if s.Opening == nil || s.Closing == nil {
+		return true
+	}
+	return math.Abs(s.Opening.Amount+s.Movement()-s.Closing.Amount) < 0.005
+}
+
+// StatementSource reads bank statements, one entry at a time as a reconciliation side or one statement
+// at a time with its balances
+type StatementSource interface {
+	TransactionSource[StatementEntry]
+	Statements(ctx context.Context) iter.Seq2[Statement, error]
+}
+
+// StatementFile reads a bank statement file in the format of its extension: camt XML (.xml), MT940
+// (.sta, .mt940, .940) or BAI2 (.bai, .bai2)
+func StatementFile(path string) (StatementSource, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".xml":
+		return CAMTFile(path), nil
+	case ".sta", ".mt940", ".940":
+		return MT940File(path), nil
+	case ".bai", ".bai2":
+		return BAI2File(path), nil
+	}
+	return nil, fmt.Errorf("%w: cannot tell the format of %s from its extension", ErrInvalidStatement, path)
+}
+
+// entriesOf yields the entries of every statement in turn
+func entriesOf(statements iter.Seq2[Statement, error]) iter.Seq2[StatementEntry, error] {
+	return func(yield func(StatementEntry, error) bool) {
+		for statement, err := range statements {
+			if err != nil {
+				yield(StatementEntry{}, err)
+				return
+			}
+			for _, entry := range statement.Entries {
+				if !yield(entry, nil) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// FromStatementEntry maps a statement entry into a canonical transaction. The remittance reference
+// becomes the reference, the usual key to match entries against payouts or ledger transactions with,
+// falling back to the end-to-end ID. The booking status maps onto the statuses of the other feeds, and
+// the amount is signed, negative for debits, so a payment out never matches a deposit of the same amount.
+func FromStatementEntry(entry StatementEntry) Transaction {
+	id := entry.Reference
+	if id == "" {
+		id = entry.EndToEndID
+	}
+	reference := entry.RemittanceRef
+	if reference == "" {
+		reference = entry.EndToEndID
+	}
+	createdAt := entry.BookingDate
+	if createdAt.IsZero() {
+		createdAt = entry.ValueDate
+	}
+	amount := entry.Amount
+	if entry.CreditDebit == Debit {
+		amount = -amount
+	}
+
+	return Transaction{
+		ID:        id,
+		Amount:    amount,
+		Currency:  entry.Currency,
+		Status:    entryStatus(entry.Status),
+		CreatedAt: createdAt,
+		UpdatedAt: createdAt,
+		Reference: reference,
+		Attributes: map[string]string{
+			"statementId":     entry.StatementID,
+			"account":         entry.Account,
+			"creditDebit":     string(entry.CreditDebit),
+			"valueDate":       formatDate(entry.ValueDate),
+			"endToEndId":      entry.EndToEndID,
+			"remittanceInfo":  entry.RemittanceInfo,
+			"transactionCode": entry.TransactionCode,
+		},
+		Raw: entry,
+	}
+}
+
+// entryStatus maps a booking status onto the statuses the other feeds use
+func entryStatus(status string) string {
+	switch status {
+	case "BOOK":
+		return "COMPLETED"
+	case "PDNG":
+		return "PENDING"
+	}
+	return status
+}
+
+// formatDate writes a date, empty when it is not set
+func formatDate(date time.Time) string {
+	if date.IsZero() {
+		return ""
+	}
+	return date.Format(time.DateOnly)
+}
+
+// statementDateLayouts are the layouts of ISO dates and date-times in statements, those without a zone in UTC
+var statementDateLayouts = []string{time.RFC3339Nano, "2006-01-02T15:04:05.999999999", time.DateOnly}
+
+// parseStatementDate parses an ISO date or date-time
+func parseStatementDate(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	var err error
+	for _, layout := range statementDateLayouts {
+		var date time.Time
+		if date, err = time.Parse(layout, value); err == nil {
+			return date, nil
+		}
+	}
+	return time.Time{}, err
+}
+
+// parseStatementAmount parses an unsigned decimal amount
+func parseStatementAmount(value string) (float64, error) {
+	amount, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil {
+		return 0, err
+	}
+	if amount < 0 {
+		return 0, strconv.ErrSyntax
+	}
+	return amount, nil
+}
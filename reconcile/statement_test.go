@@ -0,0 +1,239 @@
+package reconcile
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// statementCase is what a sample statement file must parse into
+type statementCase struct {
+	file       string
+	statements []wantStatement
+	entries    []wantEntry
+}
+
+// wantStatement is the header and balances of one statement, noBalance standing for a missing balance
+type wantStatement struct {
+	id, account      string
+	opening, closing float64
+	entries          int
+}
+
+// noBalance marks a statement without an opening or closing balance
+const noBalance = -1
+
+// wantEntry is one entry of a statement, remittanceInfo being a substring of the entry's
+type wantEntry struct {
+	statement, index int
+	reference        string
+	amount           float64
+	creditDebit      CreditDebit
+	status           string
+	transactionCode  string
+	endToEndID       string
+	remittanceInfo   string
+}
+
+func testStatementFiles(t *testing.T, tests []statementCase) {
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			source, err := StatementFile("../assets/data/statements/" + tt.file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var statements []Statement
+			for statement, err := range source.Statements(context.Background()) {
+				if err != nil {
+					t.Fatal(err)
+				}
+				statements = append(statements, statement)
+			}
+			if len(statements) != len(tt.statements) {
+				t.Fatalf("got %d statements, want %d", len(statements), len(tt.statements))
+			}
+
+			for i, want := range tt.statements {
+				got := statements[i]
+				if got.ID != want.id || got.Account != want.account || len(got.Entries) != want.entries {
+					t.Errorf("statement %d = %s of %s with %d entries, want %s of %s with %d", i, got.ID, got.Account, len(got.Entries), want.id, want.account, want.entries)
+				}
+				if balance := balanceAmount(got.Opening); balance != want.opening {
+					t.Errorf("statement %d opening balance = %v, want %v", i, balance, want.opening)
+				}
+				if balance := balanceAmount(got.Closing); balance != want.closing {
+					t.Errorf("statement %d closing balance = %v, want %v", i, balance, want.closing)
+				}
+				if !got.Balanced() {
+					t.Errorf("statement %d is not balanced: opening %v, movement %v, closing %v", i, want.opening, got.Movement(), want.closing)
+				}
+			}
+
+			for _, want := range tt.entries {
+				got := statements[want.statement].Entries[want.index]
+				if got.Reference != want.reference || got.Amount != want.amount || got.CreditDebit != want.creditDebit || got.Status != want.status ||
+					got.TransactionCode != want.transactionCode || got.EndToEndID != want.endToEndID || !strings.Contains(got.RemittanceInfo, want.remittanceInfo) {
+					t.Errorf("entry %d of statement %d = %+v, want %+v", want.index, want.statement, got, want)
+				}
+			}
+
+			// Transactions yields the entries of every statement in turn
+			entries, err := Collect(context.Background(), TransactionSource[StatementEntry](source))
+			if err != nil {
+				t.Fatal(err)
+			}
+			total := 0
+			for _, statement := range statements {
+				total += len(statement.Entries)
+			}
+			if len(entries) != total {
+				t.Errorf("Transactions yields %d entries, want %d", len(entries), total)
+			}
+		})
+	}
+}
+
+// balanceAmount is the amount of a balance, noBalance when there is none
+func balanceAmount(balance *Balance) float64 {
+	if balance == nil {
+		return noBalance
+	}
+	return balance.Amount
+}
+
+func TestCAMTStatements(t *testing.T) {
+	testStatementFiles(t, []statementCase{
+		{
+			file: "camt053_sample.xml",
+			statements: []wantStatement{
+				{id: "STMT-20240701-1", account: "DE89370400440532013000", opening: 10000, closing: 14742, entries: 8},
+				{id: "STMT-20240701-2", account: "DE89370400440532013000", opening: 12500, closing: 15136.71, entries: 3},
+			},
+			entries: []wantEntry{
+				{0, 0, "BK-20240407-0001", 678.34, Credit, "BOOK", "PMNT/RCDT/ESCT", "PO-592DBB3C", "592dbb3c-5a8f-49b5-a210-7e79e1417a85 nulla quis"},
+				// A batch entry is split into one entry per transaction, each with its own amount
+				{0, 5, "BK-20240612-0006-1", 420.84, Credit, "BOOK", "PMNT/RCDT/BOOK", "PO-4B29B889", "4b29b889-19dc-49fd-8a8b-71ac27807506"},
+				{0, 7, "BK-20240612-0006-3", 619.27, Credit, "BOOK", "PMNT/RCDT/BOOK", "PO-14F87C7A", "14f87c7a-80bd-4fcd-989e-1b5c302645c4"},
+				{1, 2, "BK-20240901-0003", 1377.59, Credit, "BOOK", "PMNT/RCDT/ESCT", "PO-6873377F", "officia veniam"},
+			},
+		},
+		{
+			file: "camt054_sample.xml",
+			statements: []wantStatement{
+				{id: "NTFCTN-20240701-1", account: "0532013000", opening: noBalance, closing: noBalance, entries: 3},
+			},
+			entries: []wantEntry{
+				{0, 0, "BK-20241129-0001", 926.13, Credit, "BOOK", "PMNT/RCDT/ESCT", "PO-B2D5CDC9", "b2d5cdc9-5e10-47de-a8e1-ecfb18f372e7"},
+				{0, 2, "BK-20241014-0003", 571.04, Credit, "PDNG", "PMNT/RCDT/ESCT", "PO-2CD5E409", "2cd5e409-6d24-40b6-8f3d-a68133360497"},
+			},
+		},
+	})
+}
+
+func TestMT940Statements(t *testing.T) {
+	testStatementFiles(t, []statementCase{
+		{
+			file: "mt940_sample.sta",
+			statements: []wantStatement{
+				{id: "STMT0001", account: "NL91ABNA0417164300", opening: 12500, closing: 15817.73, entries: 6},
+				{id: "STMT0002", account: "NL91ABNA0417164300", opening: 15817.73, closing: 18496.12, entries: 6},
+			},
+			entries: []wantEntry{
+				// :86: continues over the next lines
+				{0, 0, "BK2402180001", 398.68, Credit, "BOOK", "NTRF", "PO-8375E0DC", "8375e0dc-371a-458f-b051-9cb54f3ef890 ORDER fa6ad370-017b-4483-8296-56d366f5dd8a qui dolore velit Lorem ad et commodo quis"},
+				// The supplementary details line of :61: comes first
+				{0, 1, "BK2404070002", 678.34, Credit, "BOOK", "NTRF", "PO-592DBB3C", "CARD SETTLEMENT CREDIT_CARD 592dbb3c-5a8f-49b5-a210-7e79e1417a85 ORDER"},
+				{0, 5, "FEE0001", 2.5, Debit, "BOOK", "NCHG", "", "ACCOUNT MAINTENANCE FEE"},
+				{1, 4, "BK2411100005", 1142.44, Credit, "BOOK", "NTRF", "PO-1455486E", "1455486e-614a-4c2f-be73-9661f4fbdb6d ORDER 3358c1de"},
+			},
+		},
+	})
+}
+
+func TestBAI2Statements(t *testing.T) {
+	testStatementFiles(t, []statementCase{
+		{
+			file: "bai2_sample.bai",
+			statements: []wantStatement{
+				{id: "BAI0001/240629/4001239876", account: "4001239876", opening: 50000, closing: 53484.97, entries: 6},
+				{id: "BAI0001/240630/4001239877", account: "4001239877", opening: 60000, closing: 62647.18, entries: 6},
+			},
+			entries: []wantEntry{
+				{0, 0, "FW2406290001", 1377.59, Credit, "BOOK", "195", "6873377f-0e02-4267-82d7-dd186b578d69", "INCOMING WIRE ORDER 88bf0ea2-ad4b-47a0-bfc1-45240ca33134"},
+				// An 88 record continues the text
+				{0, 2, "AC2406290003", 520.32, Credit, "BOOK", "165", "c929d4a2-5134-4f3d-b8cc-4f36f5ff5582", "PREAUTHORIZED ACH CREDIT ORDER 6effda96-d229-475d-9af9-a17e172b82b3, dolor deserunt"},
+				// An 88 record continues the fields, from the customer reference on
+				{0, 3, "AC2406290004", 571.04, Credit, "BOOK", "165", "2cd5e409-6d24-40b6-8f3d-a68133360497", "PREAUTHORIZED ACH CREDIT"},
+				// Without a bank or customer reference, the entry is numbered within its statement
+				{0, 5, "BAI0001/240629/4001239876/6", 15, Debit, "BOOK", "698", "", "ACCOUNT ANALYSIS FEE"},
+				{1, 2, "AC2406300003", 246.92, Credit, "BOOK", "165", "01471efd-dd6c-43d3-a444-6084858a2d33", "ORDER 61110edb-2bc9-4356-a345-8e50256bd603, consectetur"},
+			},
+		},
+	})
+}
+
+func TestBAI2TypeCodes(t *testing.T) {
+	tests := []struct {
+		code      string
+		want      CreditDebit // empty when the detail is skipped
+		wantError bool
+	}{
+		{code: "165", want: Credit},
+		{code: "475", want: Debit},
+		{code: "699", want: Debit},
+		{code: "720"},
+		{code: "890"},
+		{code: "901", want: Credit},
+		{code: "959", want: Credit},
+		{code: "960", want: Debit},
+		{code: "999", want: Debit},
+		{code: "050", wantError: true},
+		{code: "x", wantError: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.code, func(t *testing.T) {
+			input := strings.Join([]string{
+				"01,121000248,ACMECORP,240630,0600,BAI0001,,,2/",
+				"02,ACMECORP,121000248,1,240630,0600,USD,2/",
+				"03,4001239876,USD,010,100000,,/",
+				"16," + tt.code + ",2500,0,REF1,,DETAIL/",
+				"49,102500,3/",
+				"98,102500,1,5/",
+				"99,102500,1,7/",
+			}, "\n")
+			entries, err := Collect(context.Background(), TransactionSource[StatementEntry](BAI2(strings.NewReader(input))))
+			if tt.wantError {
+				if err == nil {
+					t.Fatalf("entries = %+v, want an error", entries)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.want == "" {
+				if len(entries) != 0 {
+					t.Errorf("entries = %+v, want the detail skipped", entries)
+				}
+				return
+			}
+			if len(entries) != 1 || entries[0].CreditDebit != tt.want || entries[0].Amount != 25 {
+				t.Errorf("entries = %+v, want one %s of 25", entries, tt.want)
+			}
+		})
+	}
+}
+
+func TestFromStatementEntrySignsDebits(t *testing.T) {
+	credit := StatementEntry{Reference: "e-1", Amount: 25, Currency: "EUR", CreditDebit: Credit}
+	debit := StatementEntry{Reference: "e-2", Amount: 25, Currency: "EUR", CreditDebit: Debit}
+	if got := FromStatementEntry(credit).Amount; got != 25 {
+		t.Errorf("credit amount = %v, want 25", got)
+	}
+	if got := FromStatementEntry(debit).Amount; got != -25 {
+		t.Errorf("debit amount = %v, want -25", got)
+	}
+	if discrepancies := DefaultFieldMatcher.Compare(FromStatementEntry(credit), FromStatementEntry(debit)); discrepancies["amount"] == (Discrepancy{}) {
+		t.Errorf("a credit and a debit of the same amount agree: %v", discrepancies)
+	}
+}
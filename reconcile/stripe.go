@@ -0,0 +1,220 @@
+package reconcile
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// StripeSource reads a Stripe balance transactions export, or the itemized balance change and payout
+// reconciliation reports, as source transactions. Each row is a balance transaction: its gross amount
+// becomes the amount, unsigned like in the source transactions file, and the fee, the signed net amount,
+// the automatic payout and the reporting category are kept. The ID
+// is that of the charge, refund or dispute the row is for, the one a ledger records, falling back to the
+// balance transaction, whose ID becomes the provider reference.
+type StripeSource struct {
+	name string
+	open func() (io.ReadCloser, error)
+}
+
+// StripeFile reads a Stripe report CSV file
+func StripeFile(path string) *StripeSource {
+	return &StripeSource{name: "Stripe report file", open: openFile(path)}
+}
+
+// Stripe reads a Stripe report CSV, which can only be iterated once
+func Stripe(r io.Reader) *StripeSource {
+	return &StripeSource{name: "Stripe report", open: nopOpen(r)}
+}
+
+// stripeColumns are the names a field has in the API export and in the reports, e.g. "Created (UTC)"
+// and created_utc, after normalizeStripeColumn
+var stripeColumns = struct {
+	id, source, gross, fee, net, currency, created, available, status, kind, category, payout,
+	description, customer, email, name, invoice, paymentMethod []string
+}{
+	id:            []string{"balance_transaction_id", "id"},
+	source:        []string{"source_id", "source"},
+	gross:         []string{"gross", "amount"},
+	fee:           []string{"fee"},
+	net:           []string{"net"},
+	currency:      []string{"currency"},
+	created:       []string{"created_utc", "created"},
+	available:     []string{"available_on_utc", "available_on"},
+	status:        []string{"status"},
+	kind:          []string{"type"},
+	category:      []string{"reporting_category"},
+	payout:        []string{"automatic_payout_id", "payout_id", "transfer"},
+	description:   []string{"description"},
+	customer:      []string{"customer_id", "customer"},
+	email:         []string{"customer_email"},
+	name:          []string{"customer_name"},
+	invoice:       []string{"invoice_id", "invoice"},
+	paymentMethod: []string{"payment_method_type", "card_brand"},
+}
+
+// normalizeStripeColumn turns "Available On (UTC)" into available_on_utc
+func normalizeStripeColumn(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	name = strings.NewReplacer(" (utc)", "_utc", " ", "_").Replace(name)
+	return name
+}
+
+// Transactions yields one transaction per row after the header
+func (s *StripeSource) Transactions(ctx context.Context) iter.Seq2[SourceTransaction, error] {
+	return func(yield func(SourceTransaction, error) bool) {
+		r, err := s.open()
+		if err != nil {
+			yield(SourceTransaction{}, fmt.Errorf("failed to open %s: %w", s.name, err))
+			return
+		}
+		defer r.Close()
+
+		if err := s.each(ctx, r, yield); err != nil && !errors.Is(err, errStopIteration) {
+			yield(SourceTransaction{}, err)
+		}
+	}
+}
+
+// each reads the header, then parses every row with it
+func (s *StripeSource) each(ctx context.Context, r io.Reader, yield func(SourceTransaction, error) bool) error {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return fmt.Errorf("%s is empty", s.name)
+	} else if err != nil {
+		return fmt.Errorf("failed to read %s: %w", s.name, err)
+	}
+	columns := newCSVColumns(header, normalizeStripeColumn)
+	for _, required := range [][]string{stripeColumns.gross, stripeColumns.currency, stripeColumns.created} {
+		if !columns.has(required...) {
+			return fmt.Errorf("%s has no %s column", s.name, required[0])
+		}
+	}
+	if !columns.has(stripeColumns.id...) && !columns.has(stripeColumns.source...) {
+		return fmt.Errorf("%s has no balance_transaction_id or source_id column", s.name)
+	}
+
+	for line := 2; ; line++ {
+		if line%checkInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+		}
+
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", s.name, err)
+		}
+		txn, err := parseStripeRecord(columns, record, line)
+		if err != nil {
+			return err
+		}
+		if !yield(txn, nil) {
+			return errStopIteration
+		}
+	}
+}
+
+// parseStripeRecord parses one row of a Stripe report, line is used in errors
+func parseStripeRecord(columns csvColumns, record []string, line int) (SourceTransaction, error) {
+	get := func(names []string) string { return columns.get(record, names...) }
+
+	amounts := make(map[string]float64, 3)
+	for _, column := range []struct {
+		field string
+		names []string
+	}{{"amount", stripeColumns.gross}, {"fee", stripeColumns.fee}, {"net", stripeColumns.net}} {
+		value := strings.ReplaceAll(get(column.names), ",", "")
+		if value == "" {
+			continue
+		}
+		amount, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return SourceTransaction{}, fmt.Errorf("invalid %s at line %d: %w", column.field, line, err)
+		}
+		amounts[column.field] = amount
+	}
+	// Reports leave the net out when asked for fewer columns, it is what is left after the fee
+	if _, ok := amounts["net"]; !ok {
+		amounts["net"] = amounts["amount"] - amounts["fee"]
+	}
+
+	createdAt, err := parseSettlementTime(get(stripeColumns.created))
+	if err != nil {
+		return SourceTransaction{}, fmt.Errorf("invalid createdAt at line %d: %w", line, err)
+	}
+	updatedAt := createdAt
+	if available := get(stripeColumns.available); available != "" {
+		if updatedAt, err = parseSettlementTime(available); err != nil {
+			return SourceTransaction{}, fmt.Errorf("invalid available_on at line %d: %w", line, err)
+		}
+	}
+
+	balanceTransaction := get(stripeColumns.id)
+	id := get(stripeColumns.source)
+	if id == "" {
+		id = balanceTransaction
+	}
+	if id == "" {
+		return SourceTransaction{}, fmt.Errorf("invalid record at line %d: no balance transaction or source ID", line)
+	}
+
+	kind, category := get(stripeColumns.kind), get(stripeColumns.category)
+	if kind == "" {
+		kind = category
+	}
+	if category == "" {
+		category = kind
+	}
+
+	return SourceTransaction{
+		ProviderTransactionID: id,
+		Email:                 get(stripeColumns.email),
+		UserID:                get(stripeColumns.customer),
+		Provider:              "Stripe",
+		Amount:                math.Abs(amounts["amount"]),
+		Currency:              strings.ToUpper(get(stripeColumns.currency)),
+		Status:                stripeStatus(get(stripeColumns.status), category),
+		TransactionType:       kind,
+		PaymentMethod:         get(stripeColumns.paymentMethod),
+		CreatedAt:             createdAt,
+		UpdatedAt:             updatedAt,
+		ProviderReference:     balanceTransaction,
+		DetailsInvoiceID:      get(stripeColumns.invoice),
+		DetailsCustomerName:   get(stripeColumns.name),
+		DetailsDescription:    get(stripeColumns.description),
+		Fee:                   amounts["fee"],
+		Net:                   amounts["net"],
+		PayoutID:              get(stripeColumns.payout),
+		ReportingCategory:     category,
+	}, nil
+}
+
+// stripeStatus tells the status of a balance transaction from its reporting category, funds not yet
+// available being pending
+func stripeStatus(status, category string) string {
+	if strings.EqualFold(status, "pending") {
+		return "pending"
+	}
+	switch category {
+	case "refund", "partial_capture_reversal":
+		return "refunded"
+	case "dispute":
+		return "disputed"
+	case "charge_failure", "refund_failure", "payout_failure":
+		return "failed"
+	}
+	return "succeeded"
+}
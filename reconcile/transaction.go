@@ -0,0 +1,115 @@
+package reconcile
+
+import (
+	"context"
+	"iter"
+	"strconv"
+	"time"
+)
+
+// Transaction is the canonical form every feed is mapped into before comparison, so the comparison is
+// defined once whatever the feeds. Fields a feed has no column for are left empty, anything beyond the
+// common fields goes into Attributes, and Raw keeps the original record for reporting.
+type Transaction struct {
+	ID            string            `json:"transactionId"`
+	UserID        string            `json:"userId"`
+	Amount        float64           `json:"amount"`
+	Currency      string            `json:"currency"`
+	Status        string            `json:"status"`
+	PaymentMethod string            `json:"paymentMethod"`
+	CreatedAt     time.Time         `json:"createdAt"`
+	UpdatedAt     time.Time         `json:"updatedAt"`
+	Reference     string            `json:"referenceId"`
+	Attributes    map[string]string `json:"attributes,omitempty"`
+	Raw           any               `json:"-"` // the record the transaction was mapped from
+}
+
+// Attribute returns an extra attribute of the transaction and whether it is set
+func (t Transaction) Attribute(name string) (string, bool) {
+	value, ok := t.Attributes[name]
+	return value, ok
+}
+
+// Adapter maps a record of one feed into a canonical transaction
+type Adapter[T any] func(record T) Transaction
+
+// FromSource maps a provider transaction, its provider reference becoming the reference
+func FromSource(txn SourceTransaction) Transaction {
+	canonical := sourceFields(txn)
+	canonical.Attributes = map[string]string{
+		"email":           txn.Email,
+		"provider":        txn.Provider,
+		"transactionType": txn.TransactionType,
+		"fraudRisk":       txn.FraudRisk,
+		"invoiceId":       txn.DetailsInvoiceID,
+		"customerName":    txn.DetailsCustomerName,
+		"description":     txn.DetailsDescription,
+	}
+	// Settlement details only come with the providers' own reports
+	if txn.Fee != 0 || txn.Net != 0 || txn.PayoutID != "" || txn.ReportingCategory != "" {
+		canonical.Attributes["fee"] = strconv.FormatFloat(txn.Fee, 'f', -1, 64)
+		canonical.Attributes["net"] = strconv.FormatFloat(txn.Net, 'f', -1, 64)
+		canonical.Attributes["payoutId"] = txn.PayoutID
+		canonical.Attributes["reportingCategory"] = txn.ReportingCategory
+	}
+	canonical.Raw = txn
+	return canonical
+}
+
+// FromSystem maps an internal system transaction
+func FromSystem(txn SystemTransaction) Transaction {
+	canonical := systemFields(txn)
+	canonical.Attributes = map[string]string{
+		"orderId":     txn.MetadataOrderID,
+		"description": txn.MetadataDescription,
+	}
+	canonical.Raw = txn
+	return canonical
+}
+
+// sourceFields maps the common fields of a provider transaction, without Attributes and Raw
+func sourceFields(txn SourceTransaction) Transaction {
+	return Transaction{
+		ID:            txn.ProviderTransactionID,
+		UserID:        txn.UserID,
+		Amount:        txn.Amount,
+		Currency:      txn.Currency,
+		Status:        txn.Status,
+		PaymentMethod: txn.PaymentMethod,
+		CreatedAt:     txn.CreatedAt,
+		UpdatedAt:     txn.UpdatedAt,
+		Reference:     txn.ProviderReference,
+	}
+}
+
+// systemFields maps the common fields of a system transaction, without Attributes and Raw
+func systemFields(txn SystemTransaction) Transaction {
+	return Transaction{
+		ID:            txn.TransactionID,
+		UserID:        txn.UserID,
+		Amount:        txn.Amount,
+		Currency:      txn.Currency,
+		Status:        txn.Status,
+		PaymentMethod: txn.PaymentMethod,
+		CreatedAt:     txn.CreatedAt,
+		UpdatedAt:     txn.UpdatedAt,
+		Reference:     txn.ReferenceID,
+	}
+}
+
+// Canonicalize maps every record of a feed into a canonical transaction with its adapter
+func Canonicalize[T any](source TransactionSource[T], adapt Adapter[T]) TransactionSource[Transaction] {
+	return SourceFunc[Transaction](func(ctx context.Context) iter.Seq2[Transaction, error] {
+		return func(yield func(Transaction, error) bool) {
+			for record, err := range source.Transactions(ctx) {
+				if err != nil {
+					yield(Transaction{}, err)
+					return
+				}
+				if !yield(adapt(record), nil) {
+					return
+				}
+			}
+		}
+	})
+}
@@ -0,0 +1,141 @@
+package reconcile
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFromSource(t *testing.T) {
+	at := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	txn := SourceTransaction{
+		ProviderTransactionID: "txn-1", Email: "a@example.com", UserID: "usr-1", Provider: "Stripe", Amount: 10,
+		Currency: "USD", Status: "completed", TransactionType: "payment", PaymentMethod: "card", CreatedAt: at,
+		UpdatedAt: at.Add(time.Minute), ProviderReference: "ref-1", FraudRisk: "low", DetailsInvoiceID: "inv-1",
+		DetailsCustomerName: "A", DetailsDescription: "order",
+	}
+
+	got := FromSource(txn)
+	want := Transaction{
+		ID: "txn-1", UserID: "usr-1", Amount: 10, Currency: "USD", Status: "completed", PaymentMethod: "card",
+		CreatedAt: at, UpdatedAt: at.Add(time.Minute), Reference: "ref-1",
+		Attributes: map[string]string{
+			"email": "a@example.com", "provider": "Stripe", "transactionType": "payment", "fraudRisk": "low",
+			"invoiceId": "inv-1", "customerName": "A", "description": "order",
+		},
+		Raw: txn,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("FromSource = %+v, want %+v", got, want)
+	}
+	if _, ok := got.Attribute("fee"); ok {
+		t.Error("fee set for a transaction without settlement details")
+	}
+
+	// Settlement details come as attributes, a zero fee included once any of them is set
+	txn.Net, txn.PayoutID, txn.ReportingCategory = 9.5, "po_1", "charge"
+	got = FromSource(txn)
+	for name, value := range map[string]string{"fee": "0", "net": "9.5", "payoutId": "po_1", "reportingCategory": "charge"} {
+		if v, ok := got.Attribute(name); !ok || v != value {
+			t.Errorf("Attribute(%q) = %q, %v, want %q", name, v, ok, value)
+		}
+	}
+}
+
+func TestFromSystem(t *testing.T) {
+	at := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	txn := SystemTransaction{
+		TransactionID: "txn-1", UserID: "usr-1", Amount: 10, Currency: "USD", Status: "completed", PaymentMethod: "card",
+		CreatedAt: at, UpdatedAt: at, ReferenceID: "ref-1", MetadataOrderID: "ord-1", MetadataDescription: "order",
+	}
+
+	got := FromSystem(txn)
+	want := Transaction{
+		ID: "txn-1", UserID: "usr-1", Amount: 10, Currency: "USD", Status: "completed", PaymentMethod: "card",
+		CreatedAt: at, UpdatedAt: at, Reference: "ref-1",
+		Attributes: map[string]string{"orderId": "ord-1", "description": "order"},
+		Raw:        txn,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("FromSystem = %+v, want %+v", got, want)
+	}
+	if v, ok := got.Attribute("description"); !ok || v != "order" {
+		t.Errorf(`Attribute("description") = %q, %v, want "order"`, v, ok)
+	}
+	if v, ok := got.Attribute("email"); ok || v != "" {
+		t.Errorf(`Attribute("email") = %q, %v, want it unset`, v, ok)
+	}
+	if v, ok := (Transaction{}).Attribute("orderId"); ok || v != "" {
+		t.Errorf(`Attribute("orderId") without attributes = %q, %v, want it unset`, v, ok)
+	}
+}
+
+func TestCanonicalize(t *testing.T) {
+	ctx := context.Background()
+	got, err := Collect(ctx, Canonicalize(SliceSource[SystemTransaction]{{TransactionID: "a"}, {TransactionID: "b"}}, FromSystem))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].ID != "a" || got[1].ID != "b" {
+		t.Errorf("Canonicalize = %+v, want a then b", got)
+	}
+
+	// A read error is passed on and ends the feed
+	errRead := errors.New("read failed")
+	failing := SourceFunc[SystemTransaction](func(ctx context.Context) iter.Seq2[SystemTransaction, error] {
+		return func(yield func(SystemTransaction, error) bool) {
+			if yield(SystemTransaction{TransactionID: "a"}, nil) {
+				yield(SystemTransaction{}, errRead)
+			}
+		}
+	})
+	if got, err := Collect(ctx, Canonicalize(failing, FromSystem)); !errors.Is(err, errRead) {
+		t.Errorf("Canonicalize of a failing feed = %+v, %v, want %v", got, err, errRead)
+	}
+}
+
+func TestReconcilerCompareAttributes(t *testing.T) {
+	source := SourceTransaction{ProviderTransactionID: "txn-1", Amount: 10, DetailsDescription: "order 1"}
+	system := SystemTransaction{TransactionID: "txn-1", Amount: 10, MetadataDescription: "order 2"}
+
+	r := New()
+	if got := r.Compare(source, system); len(got) != 0 {
+		t.Errorf("default matcher: discrepancies = %v, want none", got)
+	}
+	// Without attributes to compare, only the discrepancy map is allocated
+	if allocs := testing.AllocsPerRun(100, func() { r.Compare(source, system) }); allocs > 1 {
+		t.Errorf("default matcher: %v allocations per pair, want only the discrepancy map", allocs)
+	}
+	withAttributes := DefaultFieldMatcher
+	withAttributes.Attributes = []string{"description"}
+	for _, matcher := range []Matcher{withAttributes, &withAttributes} {
+		got := New(WithMatcher(matcher)).Compare(source, system)
+		if d, ok := got["description"]; !ok || d.Source != "order 1" || d.System != "order 2" || len(got) != 1 {
+			t.Errorf("%T comparing descriptions: discrepancies = %v, want the description", matcher, got)
+		}
+	}
+
+	// Other matchers get the whole canonical transactions
+	var raw any
+	New(WithMatcher(MatcherFunc(func(source, system Transaction) DiscrepancyMap {
+		raw = source.Raw
+		return nil
+	}))).Compare(source, system)
+	if raw != any(source) {
+		t.Errorf("Raw = %v, want the source transaction", raw)
+	}
+}
+
+func BenchmarkReconcilerCompare(b *testing.B) {
+	at := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	source := SourceTransaction{ProviderTransactionID: "txn-1", UserID: "usr-1", Amount: 10, Currency: "USD", CreatedAt: at, UpdatedAt: at}
+	system := SystemTransaction{TransactionID: "txn-1", UserID: "usr-1", Amount: 10, Currency: "USD", CreatedAt: at, UpdatedAt: at}
+	r := New()
+	b.ReportAllocs()
+	for b.Loop() {
+		r.Compare(source, system)
+	}
+}
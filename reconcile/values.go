@@ -0,0 +1,333 @@
+package reconcile
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// timeColumns are the timestamp columns of both transactions files
+var timeColumns = []string{"createdAt", "updatedAt"}
+
+// Special time layouts for timestamps given as a number since the Unix epoch
+const (
+	LayoutEpoch       = "epoch"        // seconds, possibly with a fraction down to milliseconds
+	LayoutEpochMillis = "epoch-millis" // milliseconds
+)
+
+// ValueFormat tells how the timestamps and amounts of a file are written. The zero ValueFormat reads
+// RFC 3339 timestamps and amounts with a decimal point, as in the sample files.
+type ValueFormat struct {
+	// TimeLayouts lists per timestamp column, createdAt or updatedAt, the Go time layouts tried in order,
+	// LayoutEpoch and LayoutEpochMillis included, RFC 3339 being tried after them. The "*" entry is for
+	// the columns without their own list.
+	TimeLayouts map[string][]string
+	Location    *time.Location // zone of timestamps without one, UTC when nil
+	Decimal     rune           // decimal separator, '.' when zero
+	Thousands   rune           // thousands separator, none when zero
+}
+
+// numberLocales are the decimal and thousands separators of locales, by language or language and region
+var numberLocales = map[string][2]rune{
+	"en": {'.', ','}, "en-in": {'.', ','}, "ja": {'.', ','}, "zh": {'.', ','}, "ko": {'.', ','},
+	"de": {',', '.'}, "es": {',', '.'}, "it": {',', '.'}, "nl": {',', '.'}, "pt": {',', '.'},
+	"id": {',', '.'}, "tr": {',', '.'}, "da": {',', '.'}, "el": {',', '.'},
+	"fr": {',', ' '}, "ru": {',', ' '}, "pl": {',', ' '}, "cs": {',', ' '}, "sv": {',', ' '},
+	"nb": {',', ' '}, "fi": {',', ' '}, "uk": {',', ' '}, "pt-pt": {',', ' '},
+	"de-ch": {'.', '\''}, "fr-ch": {'.', '\''}, "it-ch": {'.', '\''},
+}
+
+// NumberLocale returns the decimal and thousands separators of a locale such as de, fr-CH or pt_BR,
+// falling back from the region to the language
+func NumberLocale(name string) (decimal, thousands rune, ok bool) {
+	name = strings.ToLower(strings.ReplaceAll(name, "_", "-"))
+	separators, ok := numberLocales[name]
+	if !ok {
+		language, _, _ := strings.Cut(name, "-")
+		separators, ok = numberLocales[language]
+	}
+	return separators[0], separators[1], ok
+}
+
+// ParseValueFormat reads a value format from settings separated by semicolons, e.g.
+// "time=2006-01-02 15:04:05|epoch-millis; timezone=Europe/Berlin; locale=de". Settings are time for
+// the layouts of every timestamp column, createdAt or updatedAt for one column, timezone, locale, and
+// decimal and thousands for separators no locale has.
+func ParseValueFormat(spec string) (ValueFormat, error) {
+	var f ValueFormat
+	for setting := range strings.SplitSeq(spec, ";") {
+		setting = strings.TrimSpace(setting)
+		if setting == "" {
+			continue
+		}
+		key, value, _ := strings.Cut(setting, "=")
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		var err error
+		switch {
+		case key == "time" || slices.Contains(timeColumns, key):
+			if key == "time" {
+				key = "*"
+			}
+			if f.TimeLayouts == nil {
+				f.TimeLayouts = make(map[string][]string)
+			}
+			for layout := range strings.SplitSeq(value, "|") {
+				if layout = strings.TrimSpace(layout); layout != "" {
+					f.TimeLayouts[key] = append(f.TimeLayouts[key], layout)
+				}
+			}
+		case key == "timezone":
+			f.Location, err = time.LoadLocation(value)
+		case key == "locale":
+			var ok bool
+			if f.Decimal, f.Thousands, ok = NumberLocale(value); !ok {
+				err = fmt.Errorf("unknown locale %q", value)
+			}
+		case key == "decimal":
+			f.Decimal, err = parseDialectChar(value)
+		case key == "thousands":
+			f.Thousands, err = parseDialectChar(value)
+		default:
+			return ValueFormat{}, fmt.Errorf("unknown value setting %q, expected time, createdAt, updatedAt, timezone, locale, decimal or thousands", key)
+		}
+		if err != nil {
+			return ValueFormat{}, fmt.Errorf("invalid %s: %w", key, err)
+		}
+	}
+	if f.decimal() == f.Thousands {
+		return ValueFormat{}, fmt.Errorf("the decimal and thousands separators are both %q", f.Thousands)
+	}
+	return f, nil
+}
+
+// decimal is the decimal separator, the point by default
+func (f ValueFormat) decimal() rune {
+	if f.Decimal == 0 {
+		return '.'
+	}
+	return f.Decimal
+}
+
+// location is the zone of timestamps without one
+func (f ValueFormat) location() *time.Location {
+	if f.Location == nil {
+		return time.UTC
+	}
+	return f.Location
+}
+
+// ParseTime parses a timestamp of a column with the layouts of the column
+func (f ValueFormat) ParseTime(column, value string) (time.Time, error) {
+	layouts := f.layouts(column)
+	if len(layouts) == 0 {
+		return time.Parse(time.RFC3339, value)
+	}
+
+	for _, layout := range layouts {
+		switch layout {
+		case LayoutEpoch:
+			// NaN, the infinities and seconds beyond the range of time.UnixMilli are no timestamps
+			if seconds, err := strconv.ParseFloat(value, 64); err == nil && math.Abs(seconds) < math.MaxInt64/1000 {
+				return time.UnixMilli(int64(math.Round(seconds * 1000))).UTC(), nil
+			}
+		case LayoutEpochMillis:
+			if millis, err := strconv.ParseInt(value, 10, 64); err == nil {
+				return time.UnixMilli(millis).UTC(), nil
+			}
+		default:
+			if t, err := time.ParseInLocation(layout, value, f.location()); err == nil {
+				return t, nil
+			}
+		}
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("%q matches none of the layouts %q", value, layouts)
+}
+
+// layouts are the time layouts of a column, those for every column when it has none of its own
+func (f ValueFormat) layouts(column string) []string {
+	if layouts, ok := f.TimeLayouts[column]; ok {
+		return layouts
+	}
+	return f.TimeLayouts["*"]
+}
+
+// epoch tells whether the timestamps of a column may be numbers since the Unix epoch
+func (f ValueFormat) epoch(column string) bool {
+	return slices.ContainsFunc(f.layouts(column), func(layout string) bool {
+		return layout == LayoutEpoch || layout == LayoutEpochMillis
+	})
+}
+
+// inLocation reads the clock time of t, which has no zone of its own such as an Excel date, in the zone
+// of timestamps without one
+func (f ValueFormat) inLocation(t time.Time) time.Time {
+	if f.Location == nil {
+		return t
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), f.Location)
+}
+
+// ParseAmount parses an amount with the separators of the format, such as 1.234,56. A currency symbol
+// or three-letter code around it is dropped, as in $1,234.56, R$ 1.234,56 or 1 234,56 EUR, and a minus
+// sign before or after it, or accounting parentheses, make it negative. Thousands separators must split
+// the digits before the decimal separator into groups of three, or of two before the last three as in
+// Indian numbering, so that 12.50 is not read as 1250 when the separators are those of another locale.
+func (f ValueFormat) ParseAmount(value string) (float64, error) {
+	return f.ParseAmountIn(value, "")
+}
+
+// ParseAmountIn parses an amount like ParseAmount, a currency code next to it having to be the currency
+// of the row, such as EUR for 12,50 EUR. An empty currency accepts any code.
+func (f ValueFormat) ParseAmountIn(value, currency string) (float64, error) {
+	if f.decimal() == '.' {
+		if amount, err := strconv.ParseFloat(value, 64); err == nil && !math.IsNaN(amount) && !math.IsInf(amount, 0) {
+			return amount, nil
+		}
+	}
+
+	number, negative := strings.TrimSpace(value), false
+	if strings.HasPrefix(number, "(") && strings.HasSuffix(number, ")") {
+		number, negative = number[1:len(number)-1], true
+	}
+	number, err := trimCurrency(value, number, currency)
+	if err != nil {
+		return 0, err
+	}
+	if rest, ok := strings.CutPrefix(number, "-"); ok {
+		number, negative = rest, !negative
+	} else if rest, ok := strings.CutSuffix(number, "-"); ok {
+		number, negative = rest, !negative
+	} else {
+		number = strings.TrimPrefix(number, "+")
+	}
+	// The sign may come before the symbol, as in -$12.50
+	if number, err = trimCurrency(value, number, currency); err != nil {
+		return 0, err
+	}
+
+	if f.Thousands != 0 {
+		integer, fraction, _ := strings.Cut(number, string(f.decimal()))
+		if strings.ContainsFunc(fraction, f.isThousands) || !groupedDigits(integer, f.isThousands) {
+			return 0, fmt.Errorf("%q has thousands separators %q out of place", value, f.Thousands)
+		}
+		number = strings.Map(func(r rune) rune {
+			if f.isThousands(r) {
+				return -1
+			}
+			return r
+		}, number)
+	}
+	if decimal := f.decimal(); decimal != '.' {
+		if strings.Contains(number, ".") {
+			return 0, fmt.Errorf("%q has a point but the decimal separator is %q", value, decimal)
+		}
+		number = strings.ReplaceAll(number, string(decimal), ".")
+	}
+	if number == "" || strings.ContainsFunc(number, func(r rune) bool { return r != '.' && !unicode.IsDigit(r) }) {
+		return 0, fmt.Errorf("%q is not an amount", value)
+	}
+
+	amount, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return 0, err
+	}
+	if negative {
+		amount = -amount
+	}
+	return amount, nil
+}
+
+// isThousands tells whether r is the thousands separator, any space when that is a space
+func (f ValueFormat) isThousands(r rune) bool {
+	return r == f.Thousands || unicode.IsSpace(f.Thousands) && unicode.IsSpace(r)
+}
+
+// groupedDigits tells whether the separators split the integer part of an amount into groups of three
+// digits, or into groups of two followed by one of three
+func groupedDigits(integer string, isSeparator func(rune) bool) bool {
+	var groups []string
+	start := 0
+	for i, r := range integer {
+		if isSeparator(r) {
+			groups = append(groups, integer[start:i])
+			start = i + utf8.RuneLen(r)
+		}
+	}
+	groups = append(groups, integer[start:])
+	if len(groups) == 1 {
+		return true
+	}
+	if len(groups[len(groups)-1]) != 3 {
+		return false
+	}
+	width := 3
+	if len(groups) > 2 && len(groups[1]) == 2 {
+		width = 2
+	}
+	for _, group := range groups[1 : len(groups)-1] {
+		if len(group) != width {
+			return false
+		}
+	}
+	return len(groups[0]) >= 1 && len(groups[0]) <= width
+}
+
+// trimCurrency drops the currency symbol or code, and the spaces around it, at the start and at the end
+// of the number of an amount value
+func trimCurrency(value, number, currency string) (string, error) {
+	number = strings.TrimSpace(number)
+	start := strings.IndexFunc(number, func(r rune) bool { return !isCurrencyMark(r) })
+	if start < 0 {
+		// Nothing but letters and symbols, not an amount
+		return number, nil
+	}
+	end := strings.LastIndexFunc(number, func(r rune) bool { return !isCurrencyMark(r) })
+	_, size := utf8.DecodeRuneInString(number[end:])
+	end += size
+	for _, mark := range []string{number[:start], number[end:]} {
+		if err := checkCurrencyMark(value, mark, currency); err != nil {
+			return "", err
+		}
+	}
+	return strings.TrimSpace(number[start:end]), nil
+}
+
+// checkCurrencyMark checks what is next to the number of an amount: nothing, a symbol such as $, € or R$,
+// or a three-letter code, which must be the currency when one is given
+func checkCurrencyMark(value, mark, currency string) error {
+	letters := 0
+	for _, r := range mark {
+		if unicode.IsLetter(r) {
+			letters++
+		}
+	}
+	switch {
+	case mark == "":
+		return nil
+	case strings.ContainsFunc(mark, func(r rune) bool { return unicode.Is(unicode.Sc, r) }):
+		if letters > 3 {
+			return fmt.Errorf("%q has %q next to it, not a currency symbol", value, mark)
+		}
+		return nil
+	case letters == 3 && len(mark) == 3:
+		if currency != "" && !strings.EqualFold(mark, currency) {
+			return fmt.Errorf("%q is in %s, not in the %s of the transaction", value, mark, currency)
+		}
+		return nil
+	default:
+		return fmt.Errorf("%q has %q next to it, not a currency code", value, mark)
+	}
+}
+
+// isCurrencyMark tells whether r may be part of a currency symbol or code
+func isCurrencyMark(r rune) bool {
+	return unicode.Is(unicode.Sc, r) || unicode.IsLetter(r)
+}
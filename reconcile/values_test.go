@@ -0,0 +1,184 @@
+package reconcile
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseAmount(t *testing.T) {
+	tests := []struct {
+		locale  string
+		value   string
+		want    float64
+		wantErr bool
+	}{
+		{locale: "en", value: "1234.56", want: 1234.56},
+		{locale: "en", value: "$1,234.56", want: 1234.56},
+		{locale: "en", value: "-$1,234,567.5", want: -1234567.5},
+		{locale: "en", value: "(1,234.56)", want: -1234.56},
+		{locale: "en", value: "1,234", want: 1234},
+		{locale: "en-IN", value: "₹1,23,45,678.90", want: 12345678.90},
+		{locale: "de", value: "1.234,56", want: 1234.56},
+		{locale: "de", value: "1.234.567,89 EUR", want: 1234567.89},
+		{locale: "de", value: "12,50-", want: -12.5},
+		{locale: "de", value: "1234,56", want: 1234.56},
+		{locale: "fr", value: "1 234,56 €", want: 1234.56},
+		{locale: "fr", value: "1 234,56", want: 1234.56},
+		{locale: "de-CH", value: "CHF 1'234.50", want: 1234.5},
+		{locale: "pt-BR", value: "R$ 1.234,56", want: 1234.56},
+
+		// Separators of another locale
+		{locale: "de", value: "12.50", wantErr: true},
+		{locale: "de", value: "$1,234.56", wantErr: true},
+		{locale: "de", value: "1.23,4", wantErr: true},
+		{locale: "en", value: "1.234,56", wantErr: true},
+		{locale: "en", value: "12,50", wantErr: true},
+		{locale: "en", value: "1,2345.6", wantErr: true},
+		{locale: "en", value: ",123", wantErr: true},
+		{locale: "en", value: "1,,234", wantErr: true},
+		{locale: "fr", value: "1 234.56", wantErr: true},
+		{locale: "en", value: "ten", wantErr: true},
+		{locale: "en", value: "", wantErr: true},
+
+		// Only a symbol or a three-letter code may come with the number
+		{locale: "en", value: "12.50 EUR", want: 12.5},
+		{locale: "en", value: "12.50 dollars", wantErr: true},
+		{locale: "en", value: "about 12.50", wantErr: true},
+		{locale: "en", value: "US 12.50", wantErr: true},
+		{locale: "en", value: "NaN", wantErr: true},
+		{locale: "en", value: "-Inf", wantErr: true},
+		{locale: "de", value: "Inf", wantErr: true},
+	}
+	for _, tt := range tests {
+		decimal, thousands, ok := NumberLocale(tt.locale)
+		if !ok {
+			t.Fatalf("unknown locale %s", tt.locale)
+		}
+		got, err := ValueFormat{Decimal: decimal, Thousands: thousands}.ParseAmount(tt.value)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseAmount(%q) in %s = %v, %v, want %v, error %t", tt.value, tt.locale, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestParseAmountIn(t *testing.T) {
+	tests := []struct {
+		value, currency string
+		want            float64
+		wantErr         bool
+	}{
+		{value: "12,50 EUR", currency: "EUR", want: 12.5},
+		{value: "eur 12,50", currency: "EUR", want: 12.5},
+		{value: "-12,50 EUR", currency: "EUR", want: -12.5},
+		{value: "12,50 EUR", currency: "", want: 12.5},
+		{value: "12,50 €", currency: "USD", want: 12.5}, // symbols are shared by currencies and not checked
+		{value: "12,50 EUR", currency: "USD", wantErr: true},
+		{value: "USD 12,50", currency: "EUR", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ValueFormat{Decimal: ',', Thousands: '.'}.ParseAmountIn(tt.value, tt.currency)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseAmountIn(%q, %q) = %v, %v, want %v, error %t", tt.value, tt.currency, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestParseValueFormat(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		spec    string
+		want    ValueFormat
+		wantErr bool
+	}{
+		{spec: "", want: ValueFormat{}},
+		{
+			spec: "time=2006-01-02 15:04:05|epoch-millis; createdAt=epoch; timezone=Europe/Berlin; locale=de",
+			want: ValueFormat{
+				TimeLayouts: map[string][]string{"*": {"2006-01-02 15:04:05", LayoutEpochMillis}, "createdAt": {LayoutEpoch}},
+				Location:    berlin, Decimal: ',', Thousands: '.',
+			},
+		},
+		{spec: "decimal=,; thousands=space", want: ValueFormat{Decimal: ',', Thousands: ' '}},
+		{spec: "locale=xx", wantErr: true},
+		{spec: "thousands=.", wantErr: true},
+		{spec: "timezone=Mars/Olympus", wantErr: true},
+		{spec: "currency=EUR", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseValueFormat(tt.spec)
+		if (err != nil) != tt.wantErr || !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseValueFormat(%q) = %+v, %v, want %+v, error %t", tt.spec, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestParseTime(t *testing.T) {
+	values, err := ParseValueFormat("time=02.01.2006 15:04|epoch-millis; updatedAt=epoch; timezone=Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		column, value string
+		want          time.Time
+		wantErr       bool
+	}{
+		{column: "createdAt", value: "01.07.2024 12:30", want: time.Date(2024, 7, 1, 10, 30, 0, 0, time.UTC)},
+		{column: "createdAt", value: "1704067200000", want: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{column: "createdAt", value: "2024-01-01T00:00:00Z", want: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{column: "updatedAt", value: "1704067200.25", want: time.Date(2024, 1, 1, 0, 0, 0, 250e6, time.UTC)},
+		{column: "updatedAt", value: "01.07.2024 12:30", wantErr: true},
+		{column: "updatedAt", value: "NaN", wantErr: true},
+		{column: "updatedAt", value: "+Inf", wantErr: true},
+		{column: "updatedAt", value: "1e300", wantErr: true},
+		{column: "createdAt", value: "2024-07-01", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := values.ParseTime(tt.column, tt.value)
+		if (err != nil) != tt.wantErr || !got.Equal(tt.want) {
+			t.Errorf("ParseTime(%s, %q) = %v, %v, want %v, error %t", tt.column, tt.value, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestLocaleSamples(t *testing.T) {
+	sourceValues, err := ParseValueFormat("createdAt=02.01.2006 15:04:05,000; updatedAt=epoch-millis; timezone=Europe/Berlin; locale=de")
+	if err != nil {
+		t.Fatal(err)
+	}
+	systemValues, err := ParseValueFormat("time=2006-01-02 15:04:05.000|epoch; locale=en")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	source, err := Collect(context.Background(), SourceDelimitedFile("../assets/data/locale/source_transactions_de.csv", Dialect{Delimiter: ';'}).WithValues(sourceValues))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantSource, err := Collect(context.Background(), SourceCSVFile("../assets/data/csvs/source_transactions.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range source {
+		source[i].CreatedAt, source[i].UpdatedAt = source[i].CreatedAt.UTC(), source[i].UpdatedAt.UTC()
+	}
+	if !reflect.DeepEqual(source, wantSource) {
+		t.Errorf("the de source sample differs from the CSV sample")
+	}
+
+	system, err := Collect(context.Background(), SystemCSVFile("../assets/data/locale/system_transactions_us.csv").WithValues(systemValues))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantSystem, err := Collect(context.Background(), SystemCSVFile("../assets/data/csvs/system_transactions.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(system, wantSystem) {
+		t.Errorf("the en system sample differs from the CSV sample")
+	}
+}
@@ -0,0 +1,428 @@
+package reconcile
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// XLSXSource reads one side from a sheet of an Excel workbook. The first non-empty row is the header,
+// its columns found by the names of the CSV header whatever their order and case, or renamed through
+// columns. Rows are read one at a time, dates may be Excel dates or text, RFC 3339 unless WithValues
+// tells otherwise, and blank rows are skipped.
+type XLSXSource[T any] struct {
+	path    string
+	sheet   string
+	columns map[string]string // sheet column name to field name
+	side    string
+	header  []string
+	values  ValueFormat
+	parse   func(values ValueFormat, record []string, line int) (T, error)
+}
+
+// SourceXLSXFile reads source transactions from a sheet of a workbook, the first sheet when sheet is
+// empty. columns renames sheet columns into the names of SourceCSVHeader, e.g. {"Txn Ref":
+// "providerTransactionId"}; it may be nil.
+func SourceXLSXFile(path, sheet string, columns map[string]string) *XLSXSource[SourceTransaction] {
+	return &XLSXSource[SourceTransaction]{path: path, sheet: sheet, columns: columns, side: "source", header: SourceCSVHeader, parse: ValueFormat.ParseSourceRecord}
+}
+
+// SystemXLSXFile reads system transactions from a sheet of a workbook, like SourceXLSXFile with the
+// names of SystemCSVHeader
+func SystemXLSXFile(path, sheet string, columns map[string]string) *XLSXSource[SystemTransaction] {
+	return &XLSXSource[SystemTransaction]{path: path, sheet: sheet, columns: columns, side: "system", header: SystemCSVHeader, parse: ValueFormat.ParseSystemRecord}
+}
+
+// WithValues reads timestamps and amounts given as text as values tells, Excel dates being in its zone,
+// returning s
+func (s *XLSXSource[T]) WithValues(values ValueFormat) *XLSXSource[T] {
+	s.values = values
+	return s
+}
+
+// Transactions yields one transaction per row after the header
+func (s *XLSXSource[T]) Transactions(ctx context.Context) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var zero T
+		workbook, err := excelize.OpenFile(s.path)
+		if err != nil {
+			yield(zero, fmt.Errorf("failed to open %s transactions file: %w", s.side, err))
+			return
+		}
+		defer workbook.Close()
+
+		if err := s.each(ctx, workbook, yield); err != nil && !errors.Is(err, errStopIteration) {
+			yield(zero, err)
+		}
+	}
+}
+
+// each maps the header onto the fields, then parses every row with it
+func (s *XLSXSource[T]) each(ctx context.Context, workbook *excelize.File, yield func(T, error) bool) error {
+	sheet := s.sheet
+	if sheet == "" {
+		sheet = workbook.GetSheetName(0)
+	}
+	rows, err := workbook.Rows(sheet)
+	if err != nil {
+		return fmt.Errorf("failed to read sheet %q of %s transactions file: %w", sheet, s.side, err)
+	}
+	defer rows.Close()
+
+	date1904 := false
+	if props, err := workbook.GetWorkbookProps(); err == nil && props.Date1904 != nil {
+		date1904 = *props.Date1904
+	}
+
+	var fields []int // the field of each sheet column, -1 when unused
+	for line := 1; rows.Next(); line++ {
+		if line%checkInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+		}
+
+		cells, err := rows.Columns(excelize.Options{RawCellValue: true})
+		if err != nil {
+			return fmt.Errorf("failed to read row %d of sheet %q: %w", line, sheet, err)
+		}
+		if !slices.ContainsFunc(cells, func(cell string) bool { return strings.TrimSpace(cell) != "" }) {
+			continue
+		}
+		if fields == nil {
+			if fields, err = s.mapHeader(cells); err != nil {
+				return fmt.Errorf("invalid header in row %d of sheet %q: %w", line, sheet, err)
+			}
+			continue
+		}
+
+		record := make([]string, len(s.header))
+		for i, cell := range cells {
+			if i < len(fields) && fields[i] >= 0 {
+				record[fields[i]] = strings.TrimSpace(cell)
+			}
+		}
+		for _, name := range timeColumns {
+			i := slices.Index(s.header, name)
+			if i < 0 {
+				continue
+			}
+			// A number is a date cell unless the column may hold epoch timestamps
+			if s.values.epoch(name) {
+				continue
+			}
+			if serial, err := strconv.ParseFloat(record[i], 64); err == nil {
+				t, err := excelize.ExcelDateToTime(serial, date1904)
+				if err != nil {
+					return fmt.Errorf("invalid %s at line %d: %w", name, line, err)
+				}
+				record[i] = s.values.inLocation(t.UTC()).Format(time.RFC3339Nano)
+			}
+		}
+		// Amounts in number cells have a point whatever the separators of those given as text
+		if i := slices.Index(s.header, "amount"); i >= 0 && s.values.decimal() != '.' {
+			if _, err := strconv.ParseFloat(record[i], 64); err == nil {
+				record[i] = strings.Replace(record[i], ".", string(s.values.decimal()), 1)
+			}
+		}
+
+		txn, err := s.parse(s.values, record, line)
+		if err != nil {
+			return err
+		}
+		if !yield(txn, nil) {
+			return errStopIteration
+		}
+	}
+	if err := rows.Error(); err != nil {
+		return fmt.Errorf("failed to read sheet %q: %w", sheet, err)
+	}
+	if fields == nil {
+		return fmt.Errorf("sheet %q of %s transactions file is empty", sheet, s.side)
+	}
+	return nil
+}
+
+// mapHeader finds the field of each column of the header row, the first field, the transaction ID, being required
+func (s *XLSXSource[T]) mapHeader(cells []string) ([]int, error) {
+	fields := make([]int, len(cells))
+	found := make([]bool, len(s.header))
+	for i, cell := range cells {
+		name := strings.TrimSpace(cell)
+		if renamed, ok := s.columns[name]; ok {
+			name = renamed
+		}
+		fields[i] = slices.IndexFunc(s.header, func(field string) bool { return strings.EqualFold(field, name) })
+		if fields[i] >= 0 {
+			found[fields[i]] = true
+		}
+	}
+	if !found[0] {
+		return nil, fmt.Errorf("no %s column", s.header[0])
+	}
+	return fields, nil
+}
+
+// XLSXSink writes the report as an Excel workbook: a summary sheet mirroring summary.txt, then one sheet
+// per section of the result with frozen headers and formatted amounts and dates. Rows are streamed to
+// the file, so memory does not grow with the report.
+type XLSXSink struct {
+	Path string
+}
+
+// xlsxStyles are the cell styles of the report
+type xlsxStyles struct {
+	header, title, amount, date, percent int
+}
+
+// Write saves the workbook, replacing the file
+func (s XLSXSink) Write(ctx context.Context, result *Result) error {
+	workbook := excelize.NewFile()
+	defer workbook.Close()
+
+	styles, err := newXLSXStyles(workbook)
+	if err != nil {
+		return fmt.Errorf("failed to create report styles: %w", err)
+	}
+
+	sheets := []struct {
+		name  string
+		write func(*excelize.StreamWriter) error
+	}{
+		{"Summary", func(w *excelize.StreamWriter) error { return writeXLSXSummary(w, styles, result.Summary) }},
+		{"Missing in internal", func(w *excelize.StreamWriter) error {
+			return writeXLSXMissingInInternal(w, styles, result.MissingInInternal)
+		}},
+		{"Missing in source", func(w *excelize.StreamWriter) error {
+			return writeXLSXMissingInSource(w, styles, result.MissingInSource)
+		}},
+		{"Mismatched", func(w *excelize.StreamWriter) error {
+			return writeXLSXMismatched(w, styles, result.MismatchedTransactions)
+		}},
+	}
+	for i, sheet := range sheets {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		// A new workbook comes with one sheet, renamed into the first
+		if i == 0 {
+			err = workbook.SetSheetName(workbook.GetSheetName(0), sheet.name)
+		} else {
+			_, err = workbook.NewSheet(sheet.name)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to add sheet %q: %w", sheet.name, err)
+		}
+		writer, err := workbook.NewStreamWriter(sheet.name)
+		if err != nil {
+			return fmt.Errorf("failed to write sheet %q: %w", sheet.name, err)
+		}
+		if err := sheet.write(writer); err != nil {
+			return fmt.Errorf("failed to write sheet %q: %w", sheet.name, err)
+		}
+		if err := writer.Flush(); err != nil {
+			return fmt.Errorf("failed to write sheet %q: %w", sheet.name, err)
+		}
+	}
+
+	if err := workbook.SaveAs(s.Path); err != nil {
+		return fmt.Errorf("failed to save report to %s: %w", s.Path, err)
+	}
+	return nil
+}
+
+// newXLSXStyles registers the styles of the report in the workbook
+func newXLSXStyles(workbook *excelize.File) (xlsxStyles, error) {
+	var styles xlsxStyles
+	dateFormat, percentFormat := "yyyy-mm-dd hh:mm:ss", "0.00\"%\""
+	for _, style := range []struct {
+		id    *int
+		style *excelize.Style
+	}{
+		{&styles.header, &excelize.Style{
+			Font: &excelize.Font{Bold: true},
+			Fill: excelize.Fill{Type: "pattern", Pattern: 1, Color: []string{"D9E1F2"}},
+		}},
+		{&styles.title, &excelize.Style{Font: &excelize.Font{Bold: true, Size: 13}}},
+		{&styles.amount, &excelize.Style{NumFmt: 4}}, // #,##0.00
+		{&styles.date, &excelize.Style{CustomNumFmt: &dateFormat}},
+		{&styles.percent, &excelize.Style{CustomNumFmt: &percentFormat}},
+	} {
+		id, err := workbook.NewStyle(style.style)
+		if err != nil {
+			return xlsxStyles{}, err
+		}
+		*style.id = id
+	}
+	return styles, nil
+}
+
+// writeXLSXHeader sets the column widths, freezes the header row and writes it
+func writeXLSXHeader(w *excelize.StreamWriter, styles xlsxStyles, header []string, widths []float64) error {
+	for i, width := range widths {
+		if err := w.SetColWidth(i+1, i+1, width); err != nil {
+			return err
+		}
+	}
+	if err := w.SetPanes(&excelize.Panes{Freeze: true, YSplit: 1, TopLeftCell: "A2", ActivePane: "bottomLeft"}); err != nil {
+		return err
+	}
+	row := make([]any, len(header))
+	for i, name := range header {
+		row[i] = excelize.Cell{StyleID: styles.header, Value: name}
+	}
+	return w.SetRow("A1", row)
+}
+
+// writeXLSXRows writes the rows after the header
+func writeXLSXRows(w *excelize.StreamWriter, rows iter.Seq[[]any]) error {
+	line := 2
+	for row := range rows {
+		cell, err := excelize.CoordinatesToCellName(1, line)
+		if err != nil {
+			return err
+		}
+		if err := w.SetRow(cell, row); err != nil {
+			return err
+		}
+		line++
+	}
+	return nil
+}
+
+// xlsxDate writes a date as an Excel date, empty when it is not set
+func xlsxDate(styles xlsxStyles, date time.Time) any {
+	if date.IsZero() {
+		return nil
+	}
+	return excelize.Cell{StyleID: styles.date, Value: date.UTC()}
+}
+
+// xlsxAmount writes an amount with two decimals and thousands separators
+func xlsxAmount(styles xlsxStyles, amount float64) any {
+	return excelize.Cell{StyleID: styles.amount, Value: amount}
+}
+
+// writeXLSXSummary writes the lines of summary.txt as label and value
+func writeXLSXSummary(w *excelize.StreamWriter, styles xlsxStyles, summary Summary) error {
+	if err := w.SetColWidth(1, 1, 32); err != nil {
+		return err
+	}
+	if err := w.SetColWidth(2, 2, 14); err != nil {
+		return err
+	}
+	rows := [][]any{
+		{excelize.Cell{StyleID: styles.title, Value: "TRANSACTION RECONCILIATION SUMMARY"}},
+		{"Total Source Transactions", summary.TotalSourceTransactions},
+		{"Total System Transactions", summary.TotalSystemTransactions},
+		{"Successfully Matched", summary.SuccessfullyMatchedCount},
+		{"Missing in Internal System", summary.MissingInInternalCount},
+		{"Missing in Source", summary.MissingInSourceCount},
+		{"Mismatched Transactions", summary.MismatchedTransactionsCount},
+	}
+	if rate, ok := summary.MatchRate(); ok {
+		rows = append(rows, []any{"Reconciliation Rate", excelize.Cell{StyleID: styles.percent, Value: rate}})
+	}
+	for i, row := range rows {
+		cell, err := excelize.CoordinatesToCellName(1, i+1)
+		if err != nil {
+			return err
+		}
+		if err := w.SetRow(cell, row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeXLSXMissingInInternal writes the source transactions missing from the internal system, with the
+// settlement columns when a provider report was read
+func writeXLSXMissingInInternal(w *excelize.StreamWriter, styles xlsxStyles, transactions []SourceTransaction) error {
+	settlement := slices.ContainsFunc(transactions, func(txn SourceTransaction) bool {
+		return txn.Fee != 0 || txn.Net != 0 || txn.PayoutID != "" || txn.ReportingCategory != ""
+	})
+	header := slices.Clone(SourceCSVHeader)
+	widths := []float64{38, 28, 26, 10, 12, 9, 11, 15, 15, 20, 20, 38, 10, 38, 22, 40}
+	if settlement {
+		header = append(header, "fee", "net", "payoutId", "reportingCategory")
+		widths = append(widths, 12, 12, 24, 18)
+	}
+	if err := writeXLSXHeader(w, styles, header, widths); err != nil {
+		return err
+	}
+	return writeXLSXRows(w, func(yield func([]any) bool) {
+		for _, txn := range transactions {
+			row := []any{
+				txn.ProviderTransactionID, txn.Email, txn.UserID, txn.Provider, xlsxAmount(styles, txn.Amount),
+				txn.Currency, txn.Status, txn.TransactionType, txn.PaymentMethod, xlsxDate(styles, txn.CreatedAt),
+				xlsxDate(styles, txn.UpdatedAt), txn.ProviderReference, txn.FraudRisk, txn.DetailsInvoiceID,
+				txn.DetailsCustomerName, txn.DetailsDescription,
+			}
+			if settlement {
+				row = append(row, xlsxAmount(styles, txn.Fee), xlsxAmount(styles, txn.Net), txn.PayoutID, txn.ReportingCategory)
+			}
+			if !yield(row) {
+				return
+			}
+		}
+	})
+}
+
+// writeXLSXMissingInSource writes the internal transactions missing from the source
+func writeXLSXMissingInSource(w *excelize.StreamWriter, styles xlsxStyles, transactions []SystemTransaction) error {
+	widths := []float64{38, 26, 12, 9, 11, 15, 20, 20, 38, 38, 40}
+	if err := writeXLSXHeader(w, styles, SystemCSVHeader, widths); err != nil {
+		return err
+	}
+	return writeXLSXRows(w, func(yield func([]any) bool) {
+		for _, txn := range transactions {
+			row := []any{
+				txn.TransactionID, txn.UserID, xlsxAmount(styles, txn.Amount), txn.Currency, txn.Status,
+				txn.PaymentMethod, xlsxDate(styles, txn.CreatedAt), xlsxDate(styles, txn.UpdatedAt), txn.ReferenceID,
+				txn.MetadataOrderID, txn.MetadataDescription,
+			}
+			if !yield(row) {
+				return
+			}
+		}
+	})
+}
+
+// writeXLSXMismatched writes one row per discrepancy, the amount at stake and its currency, that of the
+// source side, on the first row of each transaction only
+func writeXLSXMismatched(w *excelize.StreamWriter, styles xlsxStyles, mismatches []MismatchedTransaction) error {
+	header := []string{"transactionId", "field", "source", "system", "amountAtStake", "currency"}
+	if err := writeXLSXHeader(w, styles, header, []float64{38, 15, 38, 38, 15, 10}); err != nil {
+		return err
+	}
+	value := func(field string, v any) any {
+		if amount, ok := v.(float64); ok && field == "amount" {
+			return xlsxAmount(styles, amount)
+		}
+		if t, ok := v.(time.Time); ok {
+			return xlsxDate(styles, t)
+		}
+		return v
+	}
+	return writeXLSXRows(w, func(yield func([]any) bool) {
+		for _, mismatch := range mismatches {
+			for i, field := range mismatch.Discrepancies.Fields() {
+				discrepancy := mismatch.Discrepancies[field]
+				row := []any{mismatch.TransactionID, field, value(field, discrepancy.Source), value(field, discrepancy.System)}
+				if i == 0 && mismatch.Source != nil {
+					row = append(row, xlsxAmount(styles, mismatch.AmountAtStake()), mismatch.Source.Currency)
+				}
+				if !yield(row) {
+					return
+				}
+			}
+		}
+	})
+}
@@ -0,0 +1,187 @@
+package reconcile
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+)
+
+func TestXLSXSourceTimestamps(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "system.xlsx")
+	workbook := excelize.NewFile()
+	rows := [][]any{
+		{"transactionId", "amount", "currency", "createdAt", "updatedAt"},
+		// 45292 is 2024-01-01 as an Excel date, 1704153600 2024-01-02 in seconds since the Unix epoch
+		{"t1", 1234.5, "EUR", 45292.5, 1704153600},
+		{"t2", "1.234,50", "EUR", "2024-01-01T12:00:00Z", "1704153600"},
+	}
+	for i, row := range rows {
+		cell, err := excelize.CoordinatesToCellName(1, i+1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := workbook.SetSheetRow("Sheet1", cell, &row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := workbook.SaveAs(path); err != nil {
+		t.Fatal(err)
+	}
+
+	values, err := ParseValueFormat("updatedAt=epoch; timezone=Europe/Berlin; locale=de")
+	if err != nil {
+		t.Fatal(err)
+	}
+	transactions, err := Collect(context.Background(), SystemXLSXFile(path, "", nil).WithValues(values))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(transactions) != 2 {
+		t.Fatalf("got %d transactions, want 2", len(transactions))
+	}
+	createdAt, updatedAt := time.Date(2024, 1, 1, 11, 0, 0, 0, time.UTC), time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	for _, txn := range transactions {
+		if txn.Amount != 1234.5 || !txn.UpdatedAt.Equal(updatedAt) {
+			t.Errorf("%s = %v updated %v, want 1234.5 updated %v", txn.TransactionID, txn.Amount, txn.UpdatedAt, updatedAt)
+		}
+	}
+	// The Excel date is read in the zone of timestamps without one
+	if got := transactions[0].CreatedAt; !got.Equal(createdAt) {
+		t.Errorf("t1 created %v, want %v", got, createdAt)
+	}
+}
+
+// writeXLSX saves a workbook with one sheet per entry of sheets, the first one renamed from Sheet1
+func writeXLSX(t *testing.T, sheets map[string][][]any) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "transactions.xlsx")
+	workbook := excelize.NewFile()
+	first := true
+	for name, rows := range sheets {
+		if first {
+			workbook.SetSheetName("Sheet1", name)
+			first = false
+		} else if _, err := workbook.NewSheet(name); err != nil {
+			t.Fatal(err)
+		}
+		for i, row := range rows {
+			cell, err := excelize.CoordinatesToCellName(1, i+1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := workbook.SetSheetRow(name, cell, &row); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := workbook.SaveAs(path); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestXLSXSourceSample(t *testing.T) {
+	// The sheets hold the CSV samples with their columns reordered and in another case, and Excel dates
+	// rounded to the second
+	path := "../assets/data/xlsx/transactions.xlsx"
+	source, err := Collect(context.Background(), SourceXLSXFile(path, "Provider", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantSource, err := Collect(context.Background(), SourceCSVFile("../assets/data/csvs/source_transactions.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range wantSource {
+		wantSource[i].CreatedAt, wantSource[i].UpdatedAt = wantSource[i].CreatedAt.Round(time.Second), wantSource[i].UpdatedAt.Round(time.Second)
+	}
+	if !reflect.DeepEqual(source, wantSource) {
+		t.Errorf("the Provider sheet differs from the source CSV sample")
+	}
+
+	system, err := Collect(context.Background(), SystemXLSXFile(path, "Ledger", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantSystem, err := Collect(context.Background(), SystemCSVFile("../assets/data/csvs/system_transactions.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range wantSystem {
+		wantSystem[i].CreatedAt, wantSystem[i].UpdatedAt = wantSystem[i].CreatedAt.Round(time.Second), wantSystem[i].UpdatedAt.Round(time.Second)
+	}
+	if !reflect.DeepEqual(system, wantSystem) {
+		t.Errorf("the Ledger sheet differs from the system CSV sample")
+	}
+}
+
+func TestXLSXSourceHeader(t *testing.T) {
+	path := writeXLSX(t, map[string][][]any{
+		"Ledger": {
+			{},
+			{"Ref", "Amount", "Currency", "Created", "UPDATEDAT", "Notes"},
+			{"t1", 12.5, "USD", "2024-01-01T00:00:00Z", "2024-01-01T00:00:00Z", "first"},
+			{},
+			{"t2", 20, "EUR", "2024-01-02T00:00:00Z", "2024-01-02T00:00:00Z"},
+		},
+		"Empty":    {},
+		"Unmapped": {{"id", "amount"}, {"t1", 12.5}},
+	})
+
+	// Blank rows are skipped, renamed and unknown columns found whatever their case
+	got, err := Collect(context.Background(), SystemXLSXFile(path, "Ledger", map[string]string{"Ref": "transactionId", "Created": "createdAt"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []SystemTransaction{
+		{TransactionID: "t1", Amount: 12.5, Currency: "USD", CreatedAt: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), UpdatedAt: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{TransactionID: "t2", Amount: 20, Currency: "EUR", CreatedAt: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), UpdatedAt: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("transactions = %+v, want %+v", got, want)
+	}
+
+	for sheet, wantErr := range map[string]string{
+		"Ledger":   "no transactionId column", // without the renaming of Ref
+		"Empty":    "is empty",
+		"Unmapped": "no transactionId column",
+		"Missing":  "failed to read sheet",
+	} {
+		if _, err := Collect(context.Background(), SystemXLSXFile(path, sheet, nil)); err == nil || !strings.Contains(err.Error(), wantErr) {
+			t.Errorf("sheet %s: error = %v, want %q", sheet, err, wantErr)
+		}
+	}
+}
+
+func TestXLSXSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report.xlsx")
+	if err := (XLSXSink{Path: path}).Write(context.Background(), sinkResult()); err != nil {
+		t.Fatal(err)
+	}
+
+	workbook, err := excelize.OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer workbook.Close()
+	sheets := workbook.GetSheetList()
+	if len(sheets) != 4 || sheets[0] != "Summary" {
+		t.Fatalf("sheets = %v, want the summary and a sheet per section", sheets)
+	}
+	rows, err := workbook.GetRows(sheets[3], excelize.Options{RawCellValue: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		{"transactionId", "field", "source", "system", "amountAtStake", "currency"},
+		{"c", "amount", "30", "25", "5", "EUR"},
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("%s rows = %v, want %v", sheets[3], rows, want)
+	}
+}
@@ -0,0 +1,1141 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.9
+// 	protoc        v5.29.3
+// source: reconciler/v1/reconciler.proto
+
+package reconcilerpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	structpb "google.golang.org/protobuf/types/known/structpb"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// SourceTransaction mirrors a row of source_transactions.csv
+type SourceTransaction struct {
+	state                 protoimpl.MessageState `protogen:"open.v1"`
+	ProviderTransactionId string                 `protobuf:"bytes,1,opt,name=provider_transaction_id,json=providerTransactionId,proto3" json:"provider_transaction_id,omitempty"`
+	Email                 string                 `protobuf:"bytes,2,opt,name=email,proto3" json:"email,omitempty"`
+	UserId                string                 `protobuf:"bytes,3,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	Provider              string                 `protobuf:"bytes,4,opt,name=provider,proto3" json:"provider,omitempty"`
+	Amount                float64                `protobuf:"fixed64,5,opt,name=amount,proto3" json:"amount,omitempty"`
+	Currency              string                 `protobuf:"bytes,6,opt,name=currency,proto3" json:"currency,omitempty"`
+	Status                string                 `protobuf:"bytes,7,opt,name=status,proto3" json:"status,omitempty"`
+	TransactionType       string                 `protobuf:"bytes,8,opt,name=transaction_type,json=transactionType,proto3" json:"transaction_type,omitempty"`
+	PaymentMethod         string                 `protobuf:"bytes,9,opt,name=payment_method,json=paymentMethod,proto3" json:"payment_method,omitempty"`
+	CreatedAt             *timestamppb.Timestamp `protobuf:"bytes,10,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	UpdatedAt             *timestamppb.Timestamp `protobuf:"bytes,11,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"`
+	ProviderReference     string                 `protobuf:"bytes,12,opt,name=provider_reference,json=providerReference,proto3" json:"provider_reference,omitempty"`
+	FraudRisk             string                 `protobuf:"bytes,13,opt,name=fraud_risk,json=fraudRisk,proto3" json:"fraud_risk,omitempty"`
+	DetailsInvoiceId      string                 `protobuf:"bytes,14,opt,name=details_invoice_id,json=detailsInvoiceId,proto3" json:"details_invoice_id,omitempty"`
+	DetailsCustomerName   string                 `protobuf:"bytes,15,opt,name=details_customer_name,json=detailsCustomerName,proto3" json:"details_customer_name,omitempty"`
+	DetailsDescription    string                 `protobuf:"bytes,16,opt,name=details_description,json=detailsDescription,proto3" json:"details_description,omitempty"`
+	Fee                   float64                `protobuf:"fixed64,17,opt,name=fee,proto3" json:"fee,omitempty"`
+	Net                   float64                `protobuf:"fixed64,18,opt,name=net,proto3" json:"net,omitempty"`
+	PayoutId              string                 `protobuf:"bytes,19,opt,name=payout_id,json=payoutId,proto3" json:"payout_id,omitempty"`
+	ReportingCategory     string                 `protobuf:"bytes,20,opt,name=reporting_category,json=reportingCategory,proto3" json:"reporting_category,omitempty"`
+	unknownFields         protoimpl.UnknownFields
+	sizeCache             protoimpl.SizeCache
+}
+
+func (x *SourceTransaction) Reset() {
+	*x = SourceTransaction{}
+	mi := &file_reconciler_v1_reconciler_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SourceTransaction) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SourceTransaction) ProtoMessage() {}
+
+func (x *SourceTransaction) ProtoReflect() protoreflect.Message {
+	mi := &file_reconciler_v1_reconciler_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SourceTransaction.ProtoReflect.Descriptor instead.
+func (*SourceTransaction) Descriptor() ([]byte, []int) {
+	return file_reconciler_v1_reconciler_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *SourceTransaction) GetProviderTransactionId() string {
+	if x != nil {
+		return x.ProviderTransactionId
+	}
+	return ""
+}
+
+func (x *SourceTransaction) GetEmail() string {
+	if x != nil {
+		return x.Email
+	}
+	return ""
+}
+
+func (x *SourceTransaction) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+func (x *SourceTransaction) GetProvider() string {
+	if x != nil {
+		return x.Provider
+	}
+	return ""
+}
+
+func (x *SourceTransaction) GetAmount() float64 {
+	if x != nil {
+		return x.Amount
+	}
+	return 0
+}
+
+func (x *SourceTransaction) GetCurrency() string {
+	if x != nil {
+		return x.Currency
+	}
+	return ""
+}
+
+func (x *SourceTransaction) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *SourceTransaction) GetTransactionType() string {
+	if x != nil {
+		return x.TransactionType
+	}
+	return ""
+}
+
+func (x *SourceTransaction) GetPaymentMethod() string {
+	if x != nil {
+		return x.PaymentMethod
+	}
+	return ""
+}
+
+func (x *SourceTransaction) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+func (x *SourceTransaction) GetUpdatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.UpdatedAt
+	}
+	return nil
+}
+
+func (x *SourceTransaction) GetProviderReference() string {
+	if x != nil {
+		return x.ProviderReference
+	}
+	return ""
+}
+
+func (x *SourceTransaction) GetFraudRisk() string {
+	if x != nil {
+		return x.FraudRisk
+	}
+	return ""
+}
+
+func (x *SourceTransaction) GetDetailsInvoiceId() string {
+	if x != nil {
+		return x.DetailsInvoiceId
+	}
+	return ""
+}
+
+func (x *SourceTransaction) GetDetailsCustomerName() string {
+	if x != nil {
+		return x.DetailsCustomerName
+	}
+	return ""
+}
+
+func (x *SourceTransaction) GetDetailsDescription() string {
+	if x != nil {
+		return x.DetailsDescription
+	}
+	return ""
+}
+
+func (x *SourceTransaction) GetFee() float64 {
+	if x != nil {
+		return x.Fee
+	}
+	return 0
+}
+
+func (x *SourceTransaction) GetNet() float64 {
+	if x != nil {
+		return x.Net
+	}
+	return 0
+}
+
+func (x *SourceTransaction) GetPayoutId() string {
+	if x != nil {
+		return x.PayoutId
+	}
+	return ""
+}
+
+func (x *SourceTransaction) GetReportingCategory() string {
+	if x != nil {
+		return x.ReportingCategory
+	}
+	return ""
+}
+
+// SystemTransaction mirrors a row of system_transactions.csv
+type SystemTransaction struct {
+	state               protoimpl.MessageState `protogen:"open.v1"`
+	TransactionId       string                 `protobuf:"bytes,1,opt,name=transaction_id,json=transactionId,proto3" json:"transaction_id,omitempty"`
+	UserId              string                 `protobuf:"bytes,2,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	Amount              float64                `protobuf:"fixed64,3,opt,name=amount,proto3" json:"amount,omitempty"`
+	Currency            string                 `protobuf:"bytes,4,opt,name=currency,proto3" json:"currency,omitempty"`
+	Status              string                 `protobuf:"bytes,5,opt,name=status,proto3" json:"status,omitempty"`
+	PaymentMethod       string                 `protobuf:"bytes,6,opt,name=payment_method,json=paymentMethod,proto3" json:"payment_method,omitempty"`
+	CreatedAt           *timestamppb.Timestamp `protobuf:"bytes,7,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	UpdatedAt           *timestamppb.Timestamp `protobuf:"bytes,8,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"`
+	ReferenceId         string                 `protobuf:"bytes,9,opt,name=reference_id,json=referenceId,proto3" json:"reference_id,omitempty"`
+	MetadataOrderId     string                 `protobuf:"bytes,10,opt,name=metadata_order_id,json=metadataOrderId,proto3" json:"metadata_order_id,omitempty"`
+	MetadataDescription string                 `protobuf:"bytes,11,opt,name=metadata_description,json=metadataDescription,proto3" json:"metadata_description,omitempty"`
+	unknownFields       protoimpl.UnknownFields
+	sizeCache           protoimpl.SizeCache
+}
+
+func (x *SystemTransaction) Reset() {
+	*x = SystemTransaction{}
+	mi := &file_reconciler_v1_reconciler_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SystemTransaction) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SystemTransaction) ProtoMessage() {}
+
+func (x *SystemTransaction) ProtoReflect() protoreflect.Message {
+	mi := &file_reconciler_v1_reconciler_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SystemTransaction.ProtoReflect.Descriptor instead.
+func (*SystemTransaction) Descriptor() ([]byte, []int) {
+	return file_reconciler_v1_reconciler_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *SystemTransaction) GetTransactionId() string {
+	if x != nil {
+		return x.TransactionId
+	}
+	return ""
+}
+
+func (x *SystemTransaction) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+func (x *SystemTransaction) GetAmount() float64 {
+	if x != nil {
+		return x.Amount
+	}
+	return 0
+}
+
+func (x *SystemTransaction) GetCurrency() string {
+	if x != nil {
+		return x.Currency
+	}
+	return ""
+}
+
+func (x *SystemTransaction) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *SystemTransaction) GetPaymentMethod() string {
+	if x != nil {
+		return x.PaymentMethod
+	}
+	return ""
+}
+
+func (x *SystemTransaction) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+func (x *SystemTransaction) GetUpdatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.UpdatedAt
+	}
+	return nil
+}
+
+func (x *SystemTransaction) GetReferenceId() string {
+	if x != nil {
+		return x.ReferenceId
+	}
+	return ""
+}
+
+func (x *SystemTransaction) GetMetadataOrderId() string {
+	if x != nil {
+		return x.MetadataOrderId
+	}
+	return ""
+}
+
+func (x *SystemTransaction) GetMetadataDescription() string {
+	if x != nil {
+		return x.MetadataDescription
+	}
+	return ""
+}
+
+// Discrepancy holds the differing values of a field, numbers for amounts and strings otherwise
+type Discrepancy struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Source        *structpb.Value        `protobuf:"bytes,1,opt,name=source,proto3" json:"source,omitempty"`
+	System        *structpb.Value        `protobuf:"bytes,2,opt,name=system,proto3" json:"system,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Discrepancy) Reset() {
+	*x = Discrepancy{}
+	mi := &file_reconciler_v1_reconciler_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Discrepancy) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Discrepancy) ProtoMessage() {}
+
+func (x *Discrepancy) ProtoReflect() protoreflect.Message {
+	mi := &file_reconciler_v1_reconciler_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Discrepancy.ProtoReflect.Descriptor instead.
+func (*Discrepancy) Descriptor() ([]byte, []int) {
+	return file_reconciler_v1_reconciler_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Discrepancy) GetSource() *structpb.Value {
+	if x != nil {
+		return x.Source
+	}
+	return nil
+}
+
+func (x *Discrepancy) GetSystem() *structpb.Value {
+	if x != nil {
+		return x.System
+	}
+	return nil
+}
+
+// MismatchedTransaction is a transaction found on both sides with differing fields
+type MismatchedTransaction struct {
+	state         protoimpl.MessageState  `protogen:"open.v1"`
+	TransactionId string                  `protobuf:"bytes,1,opt,name=transaction_id,json=transactionId,proto3" json:"transaction_id,omitempty"`
+	Discrepancies map[string]*Discrepancy `protobuf:"bytes,2,rep,name=discrepancies,proto3" json:"discrepancies,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Source        *SourceTransaction      `protobuf:"bytes,3,opt,name=source,proto3" json:"source,omitempty"`
+	System        *SystemTransaction      `protobuf:"bytes,4,opt,name=system,proto3" json:"system,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MismatchedTransaction) Reset() {
+	*x = MismatchedTransaction{}
+	mi := &file_reconciler_v1_reconciler_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MismatchedTransaction) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MismatchedTransaction) ProtoMessage() {}
+
+func (x *MismatchedTransaction) ProtoReflect() protoreflect.Message {
+	mi := &file_reconciler_v1_reconciler_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MismatchedTransaction.ProtoReflect.Descriptor instead.
+func (*MismatchedTransaction) Descriptor() ([]byte, []int) {
+	return file_reconciler_v1_reconciler_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *MismatchedTransaction) GetTransactionId() string {
+	if x != nil {
+		return x.TransactionId
+	}
+	return ""
+}
+
+func (x *MismatchedTransaction) GetDiscrepancies() map[string]*Discrepancy {
+	if x != nil {
+		return x.Discrepancies
+	}
+	return nil
+}
+
+func (x *MismatchedTransaction) GetSource() *SourceTransaction {
+	if x != nil {
+		return x.Source
+	}
+	return nil
+}
+
+func (x *MismatchedTransaction) GetSystem() *SystemTransaction {
+	if x != nil {
+		return x.System
+	}
+	return nil
+}
+
+// ReconciliationSummary provides statistics about the reconciliation
+type ReconciliationSummary struct {
+	state                       protoimpl.MessageState `protogen:"open.v1"`
+	TotalSourceTransactions     int64                  `protobuf:"varint,1,opt,name=total_source_transactions,json=totalSourceTransactions,proto3" json:"total_source_transactions,omitempty"`
+	TotalSystemTransactions     int64                  `protobuf:"varint,2,opt,name=total_system_transactions,json=totalSystemTransactions,proto3" json:"total_system_transactions,omitempty"`
+	MissingInInternalCount      int64                  `protobuf:"varint,3,opt,name=missing_in_internal_count,json=missingInInternalCount,proto3" json:"missing_in_internal_count,omitempty"`
+	MissingInSourceCount        int64                  `protobuf:"varint,4,opt,name=missing_in_source_count,json=missingInSourceCount,proto3" json:"missing_in_source_count,omitempty"`
+	MismatchedTransactionsCount int64                  `protobuf:"varint,5,opt,name=mismatched_transactions_count,json=mismatchedTransactionsCount,proto3" json:"mismatched_transactions_count,omitempty"`
+	SuccessfullyMatchedCount    int64                  `protobuf:"varint,6,opt,name=successfully_matched_count,json=successfullyMatchedCount,proto3" json:"successfully_matched_count,omitempty"`
+	unknownFields               protoimpl.UnknownFields
+	sizeCache                   protoimpl.SizeCache
+}
+
+func (x *ReconciliationSummary) Reset() {
+	*x = ReconciliationSummary{}
+	mi := &file_reconciler_v1_reconciler_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ReconciliationSummary) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReconciliationSummary) ProtoMessage() {}
+
+func (x *ReconciliationSummary) ProtoReflect() protoreflect.Message {
+	mi := &file_reconciler_v1_reconciler_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReconciliationSummary.ProtoReflect.Descriptor instead.
+func (*ReconciliationSummary) Descriptor() ([]byte, []int) {
+	return file_reconciler_v1_reconciler_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *ReconciliationSummary) GetTotalSourceTransactions() int64 {
+	if x != nil {
+		return x.TotalSourceTransactions
+	}
+	return 0
+}
+
+func (x *ReconciliationSummary) GetTotalSystemTransactions() int64 {
+	if x != nil {
+		return x.TotalSystemTransactions
+	}
+	return 0
+}
+
+func (x *ReconciliationSummary) GetMissingInInternalCount() int64 {
+	if x != nil {
+		return x.MissingInInternalCount
+	}
+	return 0
+}
+
+func (x *ReconciliationSummary) GetMissingInSourceCount() int64 {
+	if x != nil {
+		return x.MissingInSourceCount
+	}
+	return 0
+}
+
+func (x *ReconciliationSummary) GetMismatchedTransactionsCount() int64 {
+	if x != nil {
+		return x.MismatchedTransactionsCount
+	}
+	return 0
+}
+
+func (x *ReconciliationSummary) GetSuccessfullyMatchedCount() int64 {
+	if x != nil {
+		return x.SuccessfullyMatchedCount
+	}
+	return 0
+}
+
+// ReconciliationResult is the complete result of reconciling a session
+type ReconciliationResult struct {
+	state                  protoimpl.MessageState   `protogen:"open.v1"`
+	MissingInInternal      []*SourceTransaction     `protobuf:"bytes,1,rep,name=missing_in_internal,json=missingInInternal,proto3" json:"missing_in_internal,omitempty"`
+	MissingInSource        []*SystemTransaction     `protobuf:"bytes,2,rep,name=missing_in_source,json=missingInSource,proto3" json:"missing_in_source,omitempty"`
+	MismatchedTransactions []*MismatchedTransaction `protobuf:"bytes,3,rep,name=mismatched_transactions,json=mismatchedTransactions,proto3" json:"mismatched_transactions,omitempty"`
+	Summary                *ReconciliationSummary   `protobuf:"bytes,4,opt,name=summary,proto3" json:"summary,omitempty"`
+	unknownFields          protoimpl.UnknownFields
+	sizeCache              protoimpl.SizeCache
+}
+
+func (x *ReconciliationResult) Reset() {
+	*x = ReconciliationResult{}
+	mi := &file_reconciler_v1_reconciler_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ReconciliationResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReconciliationResult) ProtoMessage() {}
+
+func (x *ReconciliationResult) ProtoReflect() protoreflect.Message {
+	mi := &file_reconciler_v1_reconciler_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReconciliationResult.ProtoReflect.Descriptor instead.
+func (*ReconciliationResult) Descriptor() ([]byte, []int) {
+	return file_reconciler_v1_reconciler_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *ReconciliationResult) GetMissingInInternal() []*SourceTransaction {
+	if x != nil {
+		return x.MissingInInternal
+	}
+	return nil
+}
+
+func (x *ReconciliationResult) GetMissingInSource() []*SystemTransaction {
+	if x != nil {
+		return x.MissingInSource
+	}
+	return nil
+}
+
+func (x *ReconciliationResult) GetMismatchedTransactions() []*MismatchedTransaction {
+	if x != nil {
+		return x.MismatchedTransactions
+	}
+	return nil
+}
+
+func (x *ReconciliationResult) GetSummary() *ReconciliationSummary {
+	if x != nil {
+		return x.Summary
+	}
+	return nil
+}
+
+type UploadSourceTransactionsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	SessionId     string                 `protobuf:"bytes,1,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
+	Transaction   *SourceTransaction     `protobuf:"bytes,2,opt,name=transaction,proto3" json:"transaction,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UploadSourceTransactionsRequest) Reset() {
+	*x = UploadSourceTransactionsRequest{}
+	mi := &file_reconciler_v1_reconciler_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UploadSourceTransactionsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UploadSourceTransactionsRequest) ProtoMessage() {}
+
+func (x *UploadSourceTransactionsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_reconciler_v1_reconciler_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UploadSourceTransactionsRequest.ProtoReflect.Descriptor instead.
+func (*UploadSourceTransactionsRequest) Descriptor() ([]byte, []int) {
+	return file_reconciler_v1_reconciler_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *UploadSourceTransactionsRequest) GetSessionId() string {
+	if x != nil {
+		return x.SessionId
+	}
+	return ""
+}
+
+func (x *UploadSourceTransactionsRequest) GetTransaction() *SourceTransaction {
+	if x != nil {
+		return x.Transaction
+	}
+	return nil
+}
+
+type UploadSystemTransactionsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	SessionId     string                 `protobuf:"bytes,1,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
+	Transaction   *SystemTransaction     `protobuf:"bytes,2,opt,name=transaction,proto3" json:"transaction,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UploadSystemTransactionsRequest) Reset() {
+	*x = UploadSystemTransactionsRequest{}
+	mi := &file_reconciler_v1_reconciler_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UploadSystemTransactionsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UploadSystemTransactionsRequest) ProtoMessage() {}
+
+func (x *UploadSystemTransactionsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_reconciler_v1_reconciler_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UploadSystemTransactionsRequest.ProtoReflect.Descriptor instead.
+func (*UploadSystemTransactionsRequest) Descriptor() ([]byte, []int) {
+	return file_reconciler_v1_reconciler_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *UploadSystemTransactionsRequest) GetSessionId() string {
+	if x != nil {
+		return x.SessionId
+	}
+	return ""
+}
+
+func (x *UploadSystemTransactionsRequest) GetTransaction() *SystemTransaction {
+	if x != nil {
+		return x.Transaction
+	}
+	return nil
+}
+
+type UploadResponse struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	SessionId string                 `protobuf:"bytes,1,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
+	// received is the number of transactions sent on this stream, total the number held by the session for that side
+	Received      int64 `protobuf:"varint,2,opt,name=received,proto3" json:"received,omitempty"`
+	Total         int64 `protobuf:"varint,3,opt,name=total,proto3" json:"total,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UploadResponse) Reset() {
+	*x = UploadResponse{}
+	mi := &file_reconciler_v1_reconciler_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UploadResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UploadResponse) ProtoMessage() {}
+
+func (x *UploadResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_reconciler_v1_reconciler_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UploadResponse.ProtoReflect.Descriptor instead.
+func (*UploadResponse) Descriptor() ([]byte, []int) {
+	return file_reconciler_v1_reconciler_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *UploadResponse) GetSessionId() string {
+	if x != nil {
+		return x.SessionId
+	}
+	return ""
+}
+
+func (x *UploadResponse) GetReceived() int64 {
+	if x != nil {
+		return x.Received
+	}
+	return 0
+}
+
+func (x *UploadResponse) GetTotal() int64 {
+	if x != nil {
+		return x.Total
+	}
+	return 0
+}
+
+type ReconcileRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	SessionId     string                 `protobuf:"bytes,1,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ReconcileRequest) Reset() {
+	*x = ReconcileRequest{}
+	mi := &file_reconciler_v1_reconciler_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ReconcileRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReconcileRequest) ProtoMessage() {}
+
+func (x *ReconcileRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_reconciler_v1_reconciler_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReconcileRequest.ProtoReflect.Descriptor instead.
+func (*ReconcileRequest) Descriptor() ([]byte, []int) {
+	return file_reconciler_v1_reconciler_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *ReconcileRequest) GetSessionId() string {
+	if x != nil {
+		return x.SessionId
+	}
+	return ""
+}
+
+// ReconciliationException is one message of StreamExceptions
+type ReconciliationException struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Exception:
+	//
+	//	*ReconciliationException_MissingInInternal
+	//	*ReconciliationException_MissingInSource
+	//	*ReconciliationException_Mismatched
+	//	*ReconciliationException_Summary
+	Exception     isReconciliationException_Exception `protobuf_oneof:"exception"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ReconciliationException) Reset() {
+	*x = ReconciliationException{}
+	mi := &file_reconciler_v1_reconciler_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ReconciliationException) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReconciliationException) ProtoMessage() {}
+
+func (x *ReconciliationException) ProtoReflect() protoreflect.Message {
+	mi := &file_reconciler_v1_reconciler_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReconciliationException.ProtoReflect.Descriptor instead.
+func (*ReconciliationException) Descriptor() ([]byte, []int) {
+	return file_reconciler_v1_reconciler_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *ReconciliationException) GetException() isReconciliationException_Exception {
+	if x != nil {
+		return x.Exception
+	}
+	return nil
+}
+
+func (x *ReconciliationException) GetMissingInInternal() *SourceTransaction {
+	if x != nil {
+		if x, ok := x.Exception.(*ReconciliationException_MissingInInternal); ok {
+			return x.MissingInInternal
+		}
+	}
+	return nil
+}
+
+func (x *ReconciliationException) GetMissingInSource() *SystemTransaction {
+	if x != nil {
+		if x, ok := x.Exception.(*ReconciliationException_MissingInSource); ok {
+			return x.MissingInSource
+		}
+	}
+	return nil
+}
+
+func (x *ReconciliationException) GetMismatched() *MismatchedTransaction {
+	if x != nil {
+		if x, ok := x.Exception.(*ReconciliationException_Mismatched); ok {
+			return x.Mismatched
+		}
+	}
+	return nil
+}
+
+func (x *ReconciliationException) GetSummary() *ReconciliationSummary {
+	if x != nil {
+		if x, ok := x.Exception.(*ReconciliationException_Summary); ok {
+			return x.Summary
+		}
+	}
+	return nil
+}
+
+type isReconciliationException_Exception interface {
+	isReconciliationException_Exception()
+}
+
+type ReconciliationException_MissingInInternal struct {
+	MissingInInternal *SourceTransaction `protobuf:"bytes,1,opt,name=missing_in_internal,json=missingInInternal,proto3,oneof"`
+}
+
+type ReconciliationException_MissingInSource struct {
+	MissingInSource *SystemTransaction `protobuf:"bytes,2,opt,name=missing_in_source,json=missingInSource,proto3,oneof"`
+}
+
+type ReconciliationException_Mismatched struct {
+	Mismatched *MismatchedTransaction `protobuf:"bytes,3,opt,name=mismatched,proto3,oneof"`
+}
+
+type ReconciliationException_Summary struct {
+	// summary is sent last, once the reconciliation has finished
+	Summary *ReconciliationSummary `protobuf:"bytes,4,opt,name=summary,proto3,oneof"`
+}
+
+func (*ReconciliationException_MissingInInternal) isReconciliationException_Exception() {}
+
+func (*ReconciliationException_MissingInSource) isReconciliationException_Exception() {}
+
+func (*ReconciliationException_Mismatched) isReconciliationException_Exception() {}
+
+func (*ReconciliationException_Summary) isReconciliationException_Exception() {}
+
+var File_reconciler_v1_reconciler_proto protoreflect.FileDescriptor
+
+const file_reconciler_v1_reconciler_proto_rawDesc = "" +
+	"\n" +
+	"\x1ereconciler/v1/reconciler.proto\x12\rreconciler.v1\x1a\x1cgoogle/protobuf/struct.proto\x1a\x1fgoogle/protobuf/timestamp.proto\"\xfb\x05\n" +
+	"\x11SourceTransaction\x126\n" +
+	"\x17provider_transaction_id\x18\x01 \x01(\tR\x15providerTransactionId\x12\x14\n" +
+	"\x05email\x18\x02 \x01(\tR\x05email\x12\x17\n" +
+	"\auser_id\x18\x03 \x01(\tR\x06userId\x12\x1a\n" +
+	"\bprovider\x18\x04 \x01(\tR\bprovider\x12\x16\n" +
+	"\x06amount\x18\x05 \x01(\x01R\x06amount\x12\x1a\n" +
+	"\bcurrency\x18\x06 \x01(\tR\bcurrency\x12\x16\n" +
+	"\x06status\x18\a \x01(\tR\x06status\x12)\n" +
+	"\x10transaction_type\x18\b \x01(\tR\x0ftransactionType\x12%\n" +
+	"\x0epayment_method\x18\t \x01(\tR\rpaymentMethod\x129\n" +
+	"\n" +
+	"created_at\x18\n" +
+	" \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt\x129\n" +
+	"\n" +
+	"updated_at\x18\v \x01(\v2\x1a.google.protobuf.TimestampR\tupdatedAt\x12-\n" +
+	"\x12provider_reference\x18\f \x01(\tR\x11providerReference\x12\x1d\n" +
+	"\n" +
+	"fraud_risk\x18\r \x01(\tR\tfraudRisk\x12,\n" +
+	"\x12details_invoice_id\x18\x0e \x01(\tR\x10detailsInvoiceId\x122\n" +
+	"\x15details_customer_name\x18\x0f \x01(\tR\x13detailsCustomerName\x12/\n" +
+	"\x13details_description\x18\x10 \x01(\tR\x12detailsDescription\x12\x10\n" +
+	"\x03fee\x18\x11 \x01(\x01R\x03fee\x12\x10\n" +
+	"\x03net\x18\x12 \x01(\x01R\x03net\x12\x1b\n" +
+	"\tpayout_id\x18\x13 \x01(\tR\bpayoutId\x12-\n" +
+	"\x12reporting_category\x18\x14 \x01(\tR\x11reportingCategory\"\xbe\x03\n" +
+	"\x11SystemTransaction\x12%\n" +
+	"\x0etransaction_id\x18\x01 \x01(\tR\rtransactionId\x12\x17\n" +
+	"\auser_id\x18\x02 \x01(\tR\x06userId\x12\x16\n" +
+	"\x06amount\x18\x03 \x01(\x01R\x06amount\x12\x1a\n" +
+	"\bcurrency\x18\x04 \x01(\tR\bcurrency\x12\x16\n" +
+	"\x06status\x18\x05 \x01(\tR\x06status\x12%\n" +
+	"\x0epayment_method\x18\x06 \x01(\tR\rpaymentMethod\x129\n" +
+	"\n" +
+	"created_at\x18\a \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt\x129\n" +
+	"\n" +
+	"updated_at\x18\b \x01(\v2\x1a.google.protobuf.TimestampR\tupdatedAt\x12!\n" +
+	"\freference_id\x18\t \x01(\tR\vreferenceId\x12*\n" +
+	"\x11metadata_order_id\x18\n" +
+	" \x01(\tR\x0fmetadataOrderId\x121\n" +
+	"\x14metadata_description\x18\v \x01(\tR\x13metadataDescription\"m\n" +
+	"\vDiscrepancy\x12.\n" +
+	"\x06source\x18\x01 \x01(\v2\x16.google.protobuf.ValueR\x06source\x12.\n" +
+	"\x06system\x18\x02 \x01(\v2\x16.google.protobuf.ValueR\x06system\"\xef\x02\n" +
+	"\x15MismatchedTransaction\x12%\n" +
+	"\x0etransaction_id\x18\x01 \x01(\tR\rtransactionId\x12]\n" +
+	"\rdiscrepancies\x18\x02 \x03(\v27.reconciler.v1.MismatchedTransaction.DiscrepanciesEntryR\rdiscrepancies\x128\n" +
+	"\x06source\x18\x03 \x01(\v2 .reconciler.v1.SourceTransactionR\x06source\x128\n" +
+	"\x06system\x18\x04 \x01(\v2 .reconciler.v1.SystemTransactionR\x06system\x1a\\\n" +
+	"\x12DiscrepanciesEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x120\n" +
+	"\x05value\x18\x02 \x01(\v2\x1a.reconciler.v1.DiscrepancyR\x05value:\x028\x01\"\x83\x03\n" +
+	"\x15ReconciliationSummary\x12:\n" +
+	"\x19total_source_transactions\x18\x01 \x01(\x03R\x17totalSourceTransactions\x12:\n" +
+	"\x19total_system_transactions\x18\x02 \x01(\x03R\x17totalSystemTransactions\x129\n" +
+	"\x19missing_in_internal_count\x18\x03 \x01(\x03R\x16missingInInternalCount\x125\n" +
+	"\x17missing_in_source_count\x18\x04 \x01(\x03R\x14missingInSourceCount\x12B\n" +
+	"\x1dmismatched_transactions_count\x18\x05 \x01(\x03R\x1bmismatchedTransactionsCount\x12<\n" +
+	"\x1asuccessfully_matched_count\x18\x06 \x01(\x03R\x18successfullyMatchedCount\"\xd5\x02\n" +
+	"\x14ReconciliationResult\x12P\n" +
+	"\x13missing_in_internal\x18\x01 \x03(\v2 .reconciler.v1.SourceTransactionR\x11missingInInternal\x12L\n" +
+	"\x11missing_in_source\x18\x02 \x03(\v2 .reconciler.v1.SystemTransactionR\x0fmissingInSource\x12]\n" +
+	"\x17mismatched_transactions\x18\x03 \x03(\v2$.reconciler.v1.MismatchedTransactionR\x16mismatchedTransactions\x12>\n" +
+	"\asummary\x18\x04 \x01(\v2$.reconciler.v1.ReconciliationSummaryR\asummary\"\x84\x01\n" +
+	"\x1fUploadSourceTransactionsRequest\x12\x1d\n" +
+	"\n" +
+	"session_id\x18\x01 \x01(\tR\tsessionId\x12B\n" +
+	"\vtransaction\x18\x02 \x01(\v2 .reconciler.v1.SourceTransactionR\vtransaction\"\x84\x01\n" +
+	"\x1fUploadSystemTransactionsRequest\x12\x1d\n" +
+	"\n" +
+	"session_id\x18\x01 \x01(\tR\tsessionId\x12B\n" +
+	"\vtransaction\x18\x02 \x01(\v2 .reconciler.v1.SystemTransactionR\vtransaction\"a\n" +
+	"\x0eUploadResponse\x12\x1d\n" +
+	"\n" +
+	"session_id\x18\x01 \x01(\tR\tsessionId\x12\x1a\n" +
+	"\breceived\x18\x02 \x01(\x03R\breceived\x12\x14\n" +
+	"\x05total\x18\x03 \x01(\x03R\x05total\"1\n" +
+	"\x10ReconcileRequest\x12\x1d\n" +
+	"\n" +
+	"session_id\x18\x01 \x01(\tR\tsessionId\"\xd4\x02\n" +
+	"\x17ReconciliationException\x12R\n" +
+	"\x13missing_in_internal\x18\x01 \x01(\v2 .reconciler.v1.SourceTransactionH\x00R\x11missingInInternal\x12N\n" +
+	"\x11missing_in_source\x18\x02 \x01(\v2 .reconciler.v1.SystemTransactionH\x00R\x0fmissingInSource\x12F\n" +
+	"\n" +
+	"mismatched\x18\x03 \x01(\v2$.reconciler.v1.MismatchedTransactionH\x00R\n" +
+	"mismatched\x12@\n" +
+	"\asummary\x18\x04 \x01(\v2$.reconciler.v1.ReconciliationSummaryH\x00R\asummaryB\v\n" +
+	"\texception2\x98\x03\n" +
+	"\n" +
+	"Reconciler\x12k\n" +
+	"\x18UploadSourceTransactions\x12..reconciler.v1.UploadSourceTransactionsRequest\x1a\x1d.reconciler.v1.UploadResponse(\x01\x12k\n" +
+	"\x18UploadSystemTransactions\x12..reconciler.v1.UploadSystemTransactionsRequest\x1a\x1d.reconciler.v1.UploadResponse(\x01\x12Q\n" +
+	"\tReconcile\x12\x1f.reconciler.v1.ReconcileRequest\x1a#.reconciler.v1.ReconciliationResult\x12]\n" +
+	"\x10StreamExceptions\x12\x1f.reconciler.v1.ReconcileRequest\x1a&.reconciler.v1.ReconciliationException0\x01B?Z=github.com/devhindo/TransactionReconcilerService/reconcilerpbb\x06proto3"
+
+var (
+	file_reconciler_v1_reconciler_proto_rawDescOnce sync.Once
+	file_reconciler_v1_reconciler_proto_rawDescData []byte
+)
+
+func file_reconciler_v1_reconciler_proto_rawDescGZIP() []byte {
+	file_reconciler_v1_reconciler_proto_rawDescOnce.Do(func() {
+		file_reconciler_v1_reconciler_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_reconciler_v1_reconciler_proto_rawDesc), len(file_reconciler_v1_reconciler_proto_rawDesc)))
+	})
+	return file_reconciler_v1_reconciler_proto_rawDescData
+}
+
+var file_reconciler_v1_reconciler_proto_msgTypes = make([]protoimpl.MessageInfo, 12)
+var file_reconciler_v1_reconciler_proto_goTypes = []any{
+	(*SourceTransaction)(nil),               // 0: reconciler.v1.SourceTransaction
+	(*SystemTransaction)(nil),               // 1: reconciler.v1.SystemTransaction
+	(*Discrepancy)(nil),                     // 2: reconciler.v1.Discrepancy
+	(*MismatchedTransaction)(nil),           // 3: reconciler.v1.MismatchedTransaction
+	(*ReconciliationSummary)(nil),           // 4: reconciler.v1.ReconciliationSummary
+	(*ReconciliationResult)(nil),            // 5: reconciler.v1.ReconciliationResult
+	(*UploadSourceTransactionsRequest)(nil), // 6: reconciler.v1.UploadSourceTransactionsRequest
+	(*UploadSystemTransactionsRequest)(nil), // 7: reconciler.v1.UploadSystemTransactionsRequest
+	(*UploadResponse)(nil),                  // 8: reconciler.v1.UploadResponse
+	(*ReconcileRequest)(nil),                // 9: reconciler.v1.ReconcileRequest
+	(*ReconciliationException)(nil),         // 10: reconciler.v1.ReconciliationException
+	nil,                                     // 11: reconciler.v1.MismatchedTransaction.DiscrepanciesEntry
+	(*timestamppb.Timestamp)(nil),           // 12: google.protobuf.Timestamp
+	(*structpb.Value)(nil),                  // 13: google.protobuf.Value
+}
+var file_reconciler_v1_reconciler_proto_depIdxs = []int32{
+	12, // 0: reconciler.v1.SourceTransaction.created_at:type_name -> google.protobuf.Timestamp
+	12, // 1: reconciler.v1.SourceTransaction.updated_at:type_name -> google.protobuf.Timestamp
+	12, // 2: reconciler.v1.SystemTransaction.created_at:type_name -> google.protobuf.Timestamp
+	12, // 3: reconciler.v1.SystemTransaction.updated_at:type_name -> google.protobuf.Timestamp
+	13, // 4: reconciler.v1.Discrepancy.source:type_name -> google.protobuf.Value
+	13, // 5: reconciler.v1.Discrepancy.system:type_name -> google.protobuf.Value
+	11, // 6: reconciler.v1.MismatchedTransaction.discrepancies:type_name -> reconciler.v1.MismatchedTransaction.DiscrepanciesEntry
+	0,  // 7: reconciler.v1.MismatchedTransaction.source:type_name -> reconciler.v1.SourceTransaction
+	1,  // 8: reconciler.v1.MismatchedTransaction.system:type_name -> reconciler.v1.SystemTransaction
+	0,  // 9: reconciler.v1.ReconciliationResult.missing_in_internal:type_name -> reconciler.v1.SourceTransaction
+	1,  // 10: reconciler.v1.ReconciliationResult.missing_in_source:type_name -> reconciler.v1.SystemTransaction
+	3,  // 11: reconciler.v1.ReconciliationResult.mismatched_transactions:type_name -> reconciler.v1.MismatchedTransaction
+	4,  // 12: reconciler.v1.ReconciliationResult.summary:type_name -> reconciler.v1.ReconciliationSummary
+	0,  // 13: reconciler.v1.UploadSourceTransactionsRequest.transaction:type_name -> reconciler.v1.SourceTransaction
+	1,  // 14: reconciler.v1.UploadSystemTransactionsRequest.transaction:type_name -> reconciler.v1.SystemTransaction
+	0,  // 15: reconciler.v1.ReconciliationException.missing_in_internal:type_name -> reconciler.v1.SourceTransaction
+	1,  // 16: reconciler.v1.ReconciliationException.missing_in_source:type_name -> reconciler.v1.SystemTransaction
+	3,  // 17: reconciler.v1.ReconciliationException.mismatched:type_name -> reconciler.v1.MismatchedTransaction
+	4,  // 18: reconciler.v1.ReconciliationException.summary:type_name -> reconciler.v1.ReconciliationSummary
+	2,  // 19: reconciler.v1.MismatchedTransaction.DiscrepanciesEntry.value:type_name -> reconciler.v1.Discrepancy
+	6,  // 20: reconciler.v1.Reconciler.UploadSourceTransactions:input_type -> reconciler.v1.UploadSourceTransactionsRequest
+	7,  // 21: reconciler.v1.Reconciler.UploadSystemTransactions:input_type -> reconciler.v1.UploadSystemTransactionsRequest
+	9,  // 22: reconciler.v1.Reconciler.Reconcile:input_type -> reconciler.v1.ReconcileRequest
+	9,  // 23: reconciler.v1.Reconciler.StreamExceptions:input_type -> reconciler.v1.ReconcileRequest
+	8,  // 24: reconciler.v1.Reconciler.UploadSourceTransactions:output_type -> reconciler.v1.UploadResponse
+	8,  // 25: reconciler.v1.Reconciler.UploadSystemTransactions:output_type -> reconciler.v1.UploadResponse
+	5,  // 26: reconciler.v1.Reconciler.Reconcile:output_type -> reconciler.v1.ReconciliationResult
+	10, // 27: reconciler.v1.Reconciler.StreamExceptions:output_type -> reconciler.v1.ReconciliationException
+	24, // [24:28] is the sub-list for method output_type
+	20, // [20:24] is the sub-list for method input_type
+	20, // [20:20] is the sub-list for extension type_name
+	20, // [20:20] is the sub-list for extension extendee
+	0,  // [0:20] is the sub-list for field type_name
+}
+
+func init() { file_reconciler_v1_reconciler_proto_init() }
+func file_reconciler_v1_reconciler_proto_init() {
+	if File_reconciler_v1_reconciler_proto != nil {
+		return
+	}
+	file_reconciler_v1_reconciler_proto_msgTypes[10].OneofWrappers = []any{
+		(*ReconciliationException_MissingInInternal)(nil),
+		(*ReconciliationException_MissingInSource)(nil),
+		(*ReconciliationException_Mismatched)(nil),
+		(*ReconciliationException_Summary)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_reconciler_v1_reconciler_proto_rawDesc), len(file_reconciler_v1_reconciler_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   12,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_reconciler_v1_reconciler_proto_goTypes,
+		DependencyIndexes: file_reconciler_v1_reconciler_proto_depIdxs,
+		MessageInfos:      file_reconciler_v1_reconciler_proto_msgTypes,
+	}.Build()
+	File_reconciler_v1_reconciler_proto = out.File
+	file_reconciler_v1_reconciler_proto_goTypes = nil
+	file_reconciler_v1_reconciler_proto_depIdxs = nil
+}
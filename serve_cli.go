@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/devhindo/TransactionReconcilerService/reconcile"
+	"google.golang.org/grpc"
+)
+
+// runServe runs the REST API until interrupted
+func runServe(args []string) {
+	workingDir, err := os.Getwd()
+	if err != nil {
+		log.Fatalf("Failed to get working directory: %v", err)
+	}
+
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := fs.String("addr", ":8080", "address to listen on")
+	dataDir := fs.String("data-dir", workingDir, "directory files referenced by path must live in")
+	uploadDir := fs.String("upload-dir", "uploads", "directory uploaded files are stored in")
+	casesPath := fs.String("cases", defaultCaseStorePath, "path to the case store, empty disables the case endpoints")
+	approvalsPath := fs.String("approvals", defaultApprovalLogPath, "path to the append-only approval log")
+	usersPath := fs.String("users", "", "JSON file of API users and the SHA-256 digests of their tokens, empty leaves the cases read-only")
+	jobsDir := fs.String("jobs-dir", "jobs", "directory the job records and results are persisted in")
+	workers := fs.Int("workers", 2, "number of reconciliations running at the same time")
+	maxAttempts := fs.Int("max-attempts", 3, "attempts per job when reading the input fails transiently")
+	retryBackoff := fs.Duration("retry-backoff", 5*time.Second, "wait before the first retry, doubled on every further attempt")
+	webhookLog := fs.String("webhook-log", defaultWebhookLogPath, "path to the append-only log of received provider events")
+	liveSystem := fs.String("live-system", "", "system transactions CSV provider events are reconciled against, empty disables webhooks")
+	liveRefresh := fs.Duration("live-refresh", 5*time.Minute, "how often the -live-system file is read again")
+	grpcAddr := fs.String("grpc-addr", "", "address the gRPC API listens on, empty disables it")
+	sessionTTL := fs.Duration("grpc-session-ttl", defaultSessionTTL, "how long an unused gRPC upload session is kept")
+	maxSession := fs.Int("grpc-max-session", defaultMaxSession, "transactions a gRPC upload session holds per side")
+	maxSessions := fs.Int("grpc-max-sessions", defaultMaxSessions, "gRPC upload sessions open at the same time")
+	maxStored := fs.Int("grpc-max-stored", defaultMaxStored, "transactions held across all gRPC upload sessions")
+	orderFlag := fs.String("order", DefaultReportOrder.String(), "sort order of the report sections: severity, amount, createdAt or id, - for descending")
+	fs.Parse(args)
+
+	order, err := ParseReportOrder(*orderFlag)
+	if err != nil {
+		log.Fatalf("Invalid -order: %v", err)
+	}
+	service := NewTransactionReconciliationService(reconcile.WithOrder(order))
+	jobs, err := NewJobQueue(service, JobQueueConfig{
+		Dir:          *jobsDir,
+		Workers:      *workers,
+		MaxAttempts:  *maxAttempts,
+		RetryBackoff: *retryBackoff,
+	})
+	if err != nil {
+		log.Fatalf("Failed to open job queue: %v", err)
+	}
+
+	config := ServerConfig{
+		Jobs:      jobs,
+		DataDir:   *dataDir,
+		UploadDir: *uploadDir,
+	}
+	if *casesPath != "" {
+		store, err := NewCaseStore(*casesPath)
+		if err != nil {
+			log.Fatalf("Failed to open case store: %v", err)
+		}
+		policy, err := LoadApprovalPolicy(*casesPath)
+		if err != nil {
+			log.Fatalf("Failed to load approval policy: %v", err)
+		}
+		config.Cases = store
+		config.Approvals = NewApprovalWorkflow(store, NewApprovalLog(*approvalsPath), policy)
+		if *usersPath != "" {
+			if config.Users, err = LoadAPIUsers(*usersPath); err != nil {
+				log.Fatalf("Failed to load API users: %v", err)
+			}
+		} else {
+			log.Println("Warning: no -users file, cases can be listed but not changed over the API")
+		}
+
+		jobs.OnSuccess = openJobCases(store)
+	}
+
+	if *liveSystem != "" {
+		live := NewLiveReconciler(service.reconciler)
+		if err := loadLiveSystem(service, live, *liveSystem); err != nil {
+			log.Fatalf("Failed to load the internal side for webhooks: %v", err)
+		}
+		receiver := NewWebhookReceiver(live, NewWebhookEventLog(*webhookLog), map[string]string{
+			"Stripe": os.Getenv("STRIPE_WEBHOOK_SECRET"),
+			"PayPal": os.Getenv("PAYPAL_WEBHOOK_SECRET"),
+		})
+		replayed, err := receiver.Replay()
+		if err != nil {
+			log.Fatalf("Failed to replay the webhook event log: %v", err)
+		}
+		log.Printf("Accepting webhooks from %v, replayed %d logged events", receiver.Providers(), replayed)
+		config.Webhooks = receiver
+		config.Live = live
+
+		go func() {
+			ticker := time.NewTicker(*liveRefresh)
+			defer ticker.Stop()
+			for range ticker.C {
+				if err := loadLiveSystem(service, live, *liveSystem); err != nil {
+					log.Printf("Warning: could not refresh the internal side for webhooks: %v", err)
+				}
+			}
+		}()
+	}
+
+	server := NewServer(service, config)
+	httpServer := &http.Server{
+		Addr:              *addr,
+		Handler:           server.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	jobs.Start(ctx)
+
+	var grpcServer *grpc.Server
+	if *grpcAddr != "" {
+		listener, err := net.Listen("tcp", *grpcAddr)
+		if err != nil {
+			log.Fatalf("Failed to listen for gRPC on %s: %v", *grpcAddr, err)
+		}
+		grpcServer = grpc.NewServer(grpcServerOptions()...)
+		NewGRPCServer(service, GRPCLimits{SessionTTL: *sessionTTL, MaxSession: *maxSession, MaxSessions: *maxSessions, MaxStored: *maxStored}).Register(grpcServer)
+		go func() {
+			log.Printf("gRPC API listening on %s", *grpcAddr)
+			if err := grpcServer.Serve(listener); err != nil {
+				log.Printf("Warning: gRPC API stopped: %v", err)
+			}
+		}()
+	}
+
+	go func() {
+		<-ctx.Done()
+		log.Println("Shutting down the API server...")
+		server.Drain()
+		if grpcServer != nil {
+			grpcServer.GracefulStop()
+		}
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := httpServer.Shutdown(shutdownCtx); err != nil {
+			log.Printf("Warning: shutdown did not complete: %v", err)
+		}
+	}()
+
+	log.Printf("API server listening on %s", *addr)
+	if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Fatalf("API server failed: %v", err)
+	}
+
+	// Running jobs are interrupted and resume on the next start
+	jobs.Wait()
+}
+
+// loadLiveSystem reads the internal side the provider events are reconciled against
+func loadLiveSystem(service *TransactionReconciliationService, live *LiveReconciler, path string) error {
+	transactions, err := service.csvReader.ReadSystemTransactions(path)
+	if err != nil {
+		return err
+	}
+	live.SetSystemTransactions(transactions)
+	return nil
+}
+
+// openJobCases opens cases for the exceptions of every job submitted by an authenticated user, recorded
+// as opened by them. Anonymous jobs, only possible without a users file, open none, so that no one
+// can make up cases by uploading files.
+func openJobCases(store *CaseStore) func(job ReconciliationJob, result *ReconciliationResult) {
+	return func(job ReconciliationJob, result *ReconciliationResult) {
+		if job.SubmittedBy == "" {
+			return
+		}
+		if _, err := store.Sync(result, job.SubmittedBy); err != nil {
+			log.Printf("Warning: could not open cases for job %s: %v", job.ID, err)
+		}
+	}
+}
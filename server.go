@@ -0,0 +1,656 @@
+package main
+
+import (
+	"crypto/rand"
+	_ "embed"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+//go:embed openapi.yaml
+var openAPIDocument []byte
+
+// ServerConfig configures the HTTP API
+type ServerConfig struct {
+	Jobs      *JobQueue         // runs the submitted reconciliations
+	DataDir   string            // files referenced by path must live under this directory
+	UploadDir string            // uploaded files are stored here, one directory per job
+	MaxUpload int64             // maximum size of a multipart upload in bytes
+	Cases     *CaseStore        // cases opened for finished jobs, nil disables the case endpoints
+	Approvals *ApprovalWorkflow // routes resolutions through maker-checker, nil resolves directly
+	Users     *APIUsers         // authenticates the callers starting jobs and changing cases, nil leaves the cases read-only
+	Webhooks  *WebhookReceiver  // receives provider events, nil disables the webhook and live endpoints
+	Live      *LiveReconciler   // reconciles the transactions updated by provider events
+}
+
+// Server exposes the reconciliation service as a REST API
+type Server struct {
+	service *TransactionReconciliationService
+	config  ServerConfig
+	mux     *http.ServeMux
+
+	draining atomic.Bool
+}
+
+// NewServer creates the API server around a reconciliation service
+func NewServer(service *TransactionReconciliationService, config ServerConfig) *Server {
+	if config.MaxUpload == 0 {
+		config.MaxUpload = 256 << 20
+	}
+
+	s := &Server{
+		service: service,
+		config:  config,
+		mux:     http.NewServeMux(),
+	}
+	s.routes()
+	return s
+}
+
+// Handler returns the HTTP handler serving the API
+func (s *Server) Handler() http.Handler {
+	return s.mux
+}
+
+// Drain marks the server as shutting down so readiness checks fail
+func (s *Server) Drain() {
+	s.draining.Store(true)
+}
+
+// routes registers every endpoint, the same routes are documented in openapi.yaml
+func (s *Server) routes() {
+	s.mux.HandleFunc("GET /healthz", s.handleHealth)
+	s.mux.HandleFunc("GET /readyz", s.handleReady)
+	s.mux.HandleFunc("GET /openapi.yaml", s.handleOpenAPI)
+
+	// Jobs feed the case store and partitions run on the caller's data, so with users configured they
+	// need a token too
+	s.mux.HandleFunc("POST /v1/reconciliations", s.handleCreateReconciliation)
+	s.mux.HandleFunc("GET /v1/reconciliations", s.handleListReconciliations)
+	s.mux.HandleFunc("GET /v1/reconciliations/{id}", s.handleGetReconciliation)
+	s.mux.HandleFunc("GET /v1/reconciliations/{id}/report", s.handleGetReport)
+	s.mux.HandleFunc("POST /v1/reconciliations/{id}/cancel", s.handleCancelReconciliation)
+	s.mux.HandleFunc("GET /v1/reconciliations/{id}/events", s.handleReconciliationEvents)
+	s.mux.HandleFunc("POST /v1/partitions", s.handleReconcilePartition)
+
+	if s.config.Cases != nil {
+		s.mux.HandleFunc("GET /v1/cases", s.handleListCases)
+		s.mux.HandleFunc("GET /v1/cases/{id}", s.handleGetCase)
+	}
+	if s.config.Approvals != nil {
+		s.mux.HandleFunc("GET /v1/proposals", s.handleListProposals)
+	}
+	// Changes record who made them and maker-checker relies on it, so they need an authenticated caller
+	if s.config.Cases != nil && s.config.Users != nil {
+		s.mux.HandleFunc("POST /v1/cases/{id}/assign", s.handleAssignCase)
+		s.mux.HandleFunc("POST /v1/cases/{id}/status", s.handleCaseStatus)
+		s.mux.HandleFunc("POST /v1/cases/{id}/comments", s.handleCommentCase)
+		s.mux.HandleFunc("POST /v1/cases/{id}/resolve", s.handleResolveCase)
+	}
+	if s.config.Approvals != nil && s.config.Users != nil {
+		s.mux.HandleFunc("POST /v1/proposals/{id}/approve", s.handleDecideProposal)
+		s.mux.HandleFunc("POST /v1/proposals/{id}/reject", s.handleDecideProposal)
+	}
+	if s.config.Webhooks != nil {
+		s.mux.HandleFunc("POST /v1/webhooks/{provider}", s.handleWebhook)
+		s.mux.HandleFunc("GET /v1/live", s.handleListLive)
+		s.mux.HandleFunc("GET /v1/live/{id}", s.handleGetLive)
+	}
+}
+
+// handleHealth reports that the process is alive
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// handleReady reports whether the server can take new reconciliations
+func (s *Server) handleReady(w http.ResponseWriter, r *http.Request) {
+	if s.draining.Load() {
+		writeError(w, http.StatusServiceUnavailable, "server is shutting down")
+		return
+	}
+	if err := os.MkdirAll(s.config.UploadDir, 0755); err != nil {
+		writeError(w, http.StatusServiceUnavailable, "upload directory is not writable")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ready"})
+}
+
+// handleOpenAPI serves the OpenAPI document describing the API
+func (s *Server) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/yaml")
+	w.Write(openAPIDocument)
+}
+
+// reconciliationRequest references input files already on the server
+type reconciliationRequest struct {
+	SourcePath string `json:"sourcePath"`
+	SystemPath string `json:"systemPath"`
+}
+
+// handleCreateReconciliation queues a job for uploaded files or for paths under the data directory
+func (s *Server) handleCreateReconciliation(w http.ResponseWriter, r *http.Request) {
+	user, ok := s.authenticate(w, r)
+	if !ok {
+		return
+	}
+	var sourcePath, systemPath string
+
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		r.Body = http.MaxBytesReader(w, r.Body, s.config.MaxUpload)
+		if err := r.ParseMultipartForm(32 << 20); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid multipart upload: %v", err))
+			return
+		}
+		defer r.MultipartForm.RemoveAll()
+
+		dir := filepath.Join(s.config.UploadDir, newUploadID())
+		var err error
+		if sourcePath, err = saveUpload(r.MultipartForm, "source", dir); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if systemPath, err = saveUpload(r.MultipartForm, "system", dir); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	} else {
+		var req reconciliationRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid request body: %v", err))
+			return
+		}
+		var err error
+		if sourcePath, err = s.resolveDataPath(req.SourcePath); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("sourcePath: %v", err))
+			return
+		}
+		if systemPath, err = s.resolveDataPath(req.SystemPath); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("systemPath: %v", err))
+			return
+		}
+	}
+
+	job, err := s.config.Jobs.Submit(sourcePath, systemPath, user)
+	if err != nil {
+		writeJobError(w, err)
+		return
+	}
+
+	w.Header().Set("Location", "/v1/reconciliations/"+job.ID)
+	writeJSON(w, http.StatusAccepted, job)
+}
+
+// handleReconcilePartition reconciles an uploaded partition for a coordinator and returns the result in
+// the response, rather than through a job, so a failed worker leaves nothing behind to clean up
+func (s *Server) handleReconcilePartition(w http.ResponseWriter, r *http.Request) {
+	if _, ok := s.authenticate(w, r); !ok {
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, s.config.MaxUpload)
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid multipart upload: %v", err))
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	dir := filepath.Join(s.config.UploadDir, newUploadID())
+	defer os.RemoveAll(dir)
+	sourcePath, err := saveUpload(r.MultipartForm, "source", dir)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	systemPath, err := saveUpload(r.MultipartForm, "system", dir)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	result, err := s.service.ProcessReconciliationContext(r.Context(), sourcePath, systemPath, nil)
+	if err != nil {
+		if r.Context().Err() != nil {
+			return
+		}
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, newPartitionResult(result))
+}
+
+// handleListReconciliations lists the jobs, newest first
+func (s *Server) handleListReconciliations(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.config.Jobs.List())
+}
+
+// handleGetReconciliation returns the status and progress of a job
+func (s *Server) handleGetReconciliation(w http.ResponseWriter, r *http.Request) {
+	job, err := s.config.Jobs.Get(r.PathValue("id"))
+	if err != nil {
+		writeJobError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, job)
+}
+
+// handleCancelReconciliation cancels a queued or running job
+func (s *Server) handleCancelReconciliation(w http.ResponseWriter, r *http.Request) {
+	if _, ok := s.authenticate(w, r); !ok {
+		return
+	}
+	job, err := s.config.Jobs.Cancel(r.PathValue("id"))
+	if err != nil {
+		writeJobError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, job)
+}
+
+// handleReconciliationEvents streams the phase changes and progress of a job as Server-Sent Events.
+// The current state is sent first, the stream ends after the event carrying the final state.
+func (s *Server) handleReconciliationEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "streaming is not supported")
+		return
+	}
+
+	id := r.PathValue("id")
+	job, events, unsubscribe, err := s.config.Jobs.Subscribe(id)
+	if err != nil {
+		writeJobError(w, err)
+		return
+	}
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	send := func(event JobEvent) bool {
+		data, err := json.Marshal(event.Job)
+		if err != nil {
+			return false
+		}
+		if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data); err != nil {
+			return false
+		}
+		flusher.Flush()
+		return true
+	}
+
+	if !send(JobEvent{Type: JobEventStatus, Job: job}) || job.IsFinished() {
+		return
+	}
+
+	heartbeat := time.NewTicker(15 * time.Second)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			// A comment line keeps proxies from closing an idle stream
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case event, ok := <-events:
+			if !ok {
+				// Events may have been dropped, so the final state is read from the queue
+				if final, err := s.config.Jobs.Get(id); err == nil {
+					send(JobEvent{Type: JobEventStatus, Job: final})
+				}
+				return
+			}
+			if !send(event) || event.Job.IsFinished() {
+				return
+			}
+		}
+	}
+}
+
+// handleGetReport returns the report of a finished job
+func (s *Server) handleGetReport(w http.ResponseWriter, r *http.Request) {
+	result, err := s.config.Jobs.Result(r.PathValue("id"))
+	if err != nil {
+		writeJobError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "reconciliation_report.json"))
+	writeJSON(w, http.StatusOK, s.service.BuildReport(result))
+}
+
+// handleListCases lists cases, filtered by the status, owner and kind query parameters
+func (s *Server) handleListCases(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	writeJSON(w, http.StatusOK, s.config.Cases.List(CaseFilter{
+		Status: CaseStatus(query.Get("status")),
+		Owner:  query.Get("owner"),
+		Kind:   ExceptionKind(query.Get("kind")),
+	}))
+}
+
+// handleGetCase returns a single case
+func (s *Server) handleGetCase(w http.ResponseWriter, r *http.Request) {
+	c, err := s.config.Cases.Get(r.PathValue("id"))
+	if err != nil {
+		writeCaseError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, c)
+}
+
+// caseRequest is the body of the case update endpoints, each endpoint reads the fields it needs
+type caseRequest struct {
+	Owner      string     `json:"owner"`
+	Status     CaseStatus `json:"status"`
+	Text       string     `json:"text"`
+	Resolution Resolution `json:"resolution"`
+	Note       string     `json:"note"`
+}
+
+// handleAssignCase assigns a case to an owner
+func (s *Server) handleAssignCase(w http.ResponseWriter, r *http.Request) {
+	s.updateCase(w, r, func(id, user string, req caseRequest) (Case, error) {
+		return s.config.Cases.Assign(id, req.Owner, user)
+	})
+}
+
+// handleCaseStatus moves a case between open and investigating
+func (s *Server) handleCaseStatus(w http.ResponseWriter, r *http.Request) {
+	s.updateCase(w, r, func(id, user string, req caseRequest) (Case, error) {
+		return s.config.Cases.SetStatus(id, req.Status, user, req.Note)
+	})
+}
+
+// handleCommentCase adds a comment to a case
+func (s *Server) handleCommentCase(w http.ResponseWriter, r *http.Request) {
+	s.updateCase(w, r, func(id, user string, req caseRequest) (Case, error) {
+		return s.config.Cases.AddComment(id, user, req.Text)
+	})
+}
+
+// handleResolveCase resolves a case, or files a proposal when the resolution needs approval
+func (s *Server) handleResolveCase(w http.ResponseWriter, r *http.Request) {
+	user, req, ok := s.decodeCaseRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if s.config.Approvals == nil {
+		c, err := s.config.Cases.Resolve(r.PathValue("id"), req.Resolution, user, req.Note)
+		if err != nil {
+			writeCaseError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, c)
+		return
+	}
+
+	c, proposal, err := s.config.Approvals.Resolve(r.PathValue("id"), req.Resolution, user, req.Note)
+	if err != nil {
+		writeCaseError(w, err)
+		return
+	}
+	if proposal != nil {
+		writeJSON(w, http.StatusAccepted, map[string]interface{}{"case": c, "proposal": proposal})
+		return
+	}
+	writeJSON(w, http.StatusOK, c)
+}
+
+// handleListProposals lists resolution proposals, filtered by the status query parameter
+func (s *Server) handleListProposals(w http.ResponseWriter, r *http.Request) {
+	proposals, err := s.config.Approvals.Proposals(ProposalStatus(r.URL.Query().Get("status")))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, proposals)
+}
+
+// handleDecideProposal approves or rejects a proposal depending on the route
+func (s *Server) handleDecideProposal(w http.ResponseWriter, r *http.Request) {
+	user, req, ok := s.decodeCaseRequest(w, r)
+	if !ok {
+		return
+	}
+
+	decide := s.config.Approvals.Approve
+	if strings.HasSuffix(r.URL.Path, "/reject") {
+		decide = s.config.Approvals.Reject
+	}
+	c, err := decide(r.PathValue("id"), user, req.Note)
+	if err != nil {
+		writeCaseError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, c)
+}
+
+// updateCase decodes a case request and applies fn to the case named in the path
+func (s *Server) updateCase(w http.ResponseWriter, r *http.Request, fn func(id, user string, req caseRequest) (Case, error)) {
+	user, req, ok := s.decodeCaseRequest(w, r)
+	if !ok {
+		return
+	}
+	c, err := fn(r.PathValue("id"), user, req)
+	if err != nil {
+		writeCaseError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, c)
+}
+
+// handleWebhook verifies a provider event and applies it to the live reconciliation
+func (s *Server) handleWebhook(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 1<<20))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid request body: %v", err))
+		return
+	}
+
+	result, err := s.config.Webhooks.Receive(r.PathValue("provider"), r.Header, body)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrUnknownProvider):
+			writeError(w, http.StatusNotFound, err.Error())
+		case errors.Is(err, ErrInvalidSignature):
+			writeError(w, http.StatusUnauthorized, err.Error())
+		case errors.Is(err, ErrInvalidEvent):
+			writeError(w, http.StatusBadRequest, err.Error())
+		default:
+			writeError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// handleListLive lists the transactions updated by provider events, optionally filtered by ?state=
+func (s *Server) handleListLive(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.config.Live.List(LiveState(r.URL.Query().Get("state"))))
+}
+
+// handleGetLive returns the live reconciliation state of a transaction
+func (s *Server) handleGetLive(w http.ResponseWriter, r *http.Request) {
+	match, err := s.config.Live.Get(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, match)
+}
+
+// authenticate returns the user of the API token, answering 401 when it is not valid. Without users
+// configured every caller is let through as anonymous, the empty user.
+func (s *Server) authenticate(w http.ResponseWriter, r *http.Request) (string, bool) {
+	if s.config.Users == nil {
+		return "", true
+	}
+	user, err := s.config.Users.Authenticate(r)
+	if err != nil {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeError(w, http.StatusUnauthorized, err.Error())
+		return "", false
+	}
+	return user, true
+}
+
+// decodeCaseRequest authenticates the caller from the API token and reads the JSON body
+func (s *Server) decodeCaseRequest(w http.ResponseWriter, r *http.Request) (string, caseRequest, bool) {
+	var req caseRequest
+	user, ok := s.authenticate(w, r)
+	if !ok {
+		return "", req, false
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid request body: %v", err))
+		return "", req, false
+	}
+	return user, req, true
+}
+
+// writeCaseError maps case and approval errors to HTTP status codes
+func writeCaseError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrCaseNotFound), errors.Is(err, ErrProposalNotFound):
+		writeError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, ErrSelfApproval):
+		writeError(w, http.StatusForbidden, err.Error())
+	case errors.Is(err, ErrCaseClosed), errors.Is(err, ErrProposalDecided), errors.Is(err, ErrProposalPending), errors.Is(err, ErrProposalStale):
+		writeError(w, http.StatusConflict, err.Error())
+	case errors.Is(err, ErrInvalidStatus), errors.Is(err, ErrInvalidResolution), errors.Is(err, ErrMissingIdentity):
+		writeError(w, http.StatusBadRequest, err.Error())
+	default:
+		writeError(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// writeJobError maps job queue errors to HTTP status codes
+func writeJobError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrJobNotFound):
+		writeError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, ErrJobFinished), errors.Is(err, ErrJobNoResult):
+		writeError(w, http.StatusConflict, err.Error())
+	case errors.Is(err, ErrQueueFull), errors.Is(err, ErrQueueNotActive):
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+	default:
+		writeError(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// resolveDataPath checks that a referenced file lives under the data directory
+func (s *Server) resolveDataPath(path string) (string, error) {
+	if path == "" {
+		return "", fmt.Errorf("path is required")
+	}
+
+	root, err := filepath.Abs(s.config.DataDir)
+	if err != nil {
+		return "", err
+	}
+	full := path
+	if !filepath.IsAbs(full) {
+		full = filepath.Join(root, full)
+	}
+	full = filepath.Clean(full)
+
+	rel, err := filepath.Rel(root, full)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s is outside the data directory", path)
+	}
+	if _, err := os.Stat(full); err != nil {
+		return "", fmt.Errorf("%s does not exist", path)
+	}
+
+	return full, nil
+}
+
+// saveUpload stores the uploaded file of a form field in dir and returns its path
+func saveUpload(form *multipart.Form, field, dir string) (string, error) {
+	headers := form.File[field]
+	if len(headers) == 0 {
+		return "", fmt.Errorf("the %s file is required", field)
+	}
+
+	in, err := headers[0].Open()
+	if err != nil {
+		return "", fmt.Errorf("failed to read the %s file: %w", field, err)
+	}
+	defer in.Close()
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to store the %s file: %w", field, err)
+	}
+	path := filepath.Join(dir, field+uploadExtension(headers[0].Filename))
+	out, err := os.Create(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to store the %s file: %w", field, err)
+	}
+	defer out.Close()
+
+	if _, err := io.Copy(out, in); err != nil {
+		return "", fmt.Errorf("failed to store the %s file: %w", field, err)
+	}
+	return path, nil
+}
+
+// uploadExtension is the extension an upload is stored with, the one of the uploaded file when it tells
+// a format apart from CSV, so JSON, NDJSON and XLSX uploads are read in their format
+func uploadExtension(filename string) string {
+	switch ext := strings.ToLower(filepath.Ext(filename)); ext {
+	case ".json", ".ndjson", ".jsonl", ".xlsx":
+		return ext
+	}
+	return ".csv"
+}
+
+// writeJSON writes v as a JSON response
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(v); err != nil {
+		log.Printf("Warning: could not write response: %v", err)
+	}
+}
+
+// writeError writes an error response
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
+
+// newJobID returns a random job ID
+func newJobID() string {
+	return "rec-" + randomHex(8)
+}
+
+// newUploadID returns a random directory name for an upload
+func newUploadID() string {
+	return "upload-" + randomHex(8)
+}
+
+// randomHex returns n random bytes as hex
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
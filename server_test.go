@@ -0,0 +1,233 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeAPIUsers writes a users file giving each user the token named after them
+func writeAPIUsers(t *testing.T, users ...string) *APIUsers {
+	t.Helper()
+	var entries []string
+	for _, user := range users {
+		digest := sha256.Sum256([]byte(user + "-token"))
+		entries = append(entries, `"`+user+`": "`+hex.EncodeToString(digest[:])+`"`)
+	}
+	path := filepath.Join(t.TempDir(), "users.json")
+	if err := os.WriteFile(path, []byte("{"+strings.Join(entries, ", ")+"}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	apiUsers, err := LoadAPIUsers(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return apiUsers
+}
+
+func TestServerCaseChangesNeedAuthenticatedUser(t *testing.T) {
+	store, id := syncedStore(t)
+	workflow := NewApprovalWorkflow(store, NewApprovalLog(filepath.Join(t.TempDir(), "approvals.log")), ApprovalPolicy{Resolutions: []Resolution{ResolutionWriteOff}})
+	server := NewServer(NewTransactionReconciliationService(), ServerConfig{Cases: store, Approvals: workflow, Users: writeAPIUsers(t, "alice", "bob")})
+
+	do := func(path, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set("X-User", "bob")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		server.Handler().ServeHTTP(rec, req)
+		return rec
+	}
+
+	resolve := "/v1/cases/" + id + "/resolve"
+	if rec := do(resolve, "", `{"resolution": "write_off"}`); rec.Code != http.StatusUnauthorized {
+		t.Errorf("without a token: status %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+	if rec := do(resolve, "mallory-token", `{"resolution": "write_off"}`); rec.Code != http.StatusUnauthorized {
+		t.Errorf("unknown token: status %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+	if rec := do(resolve, "alice-token", `{"resolution": "write_off"}`); rec.Code != http.StatusAccepted {
+		t.Fatalf("alice resolving: status %d, want %d: %s", rec.Code, http.StatusAccepted, rec.Body)
+	}
+
+	proposals, err := workflow.Proposals(ProposalPending)
+	if err != nil || len(proposals) != 1 {
+		t.Fatalf("pending proposals = %v, %v, want 1", proposals, err)
+	}
+	if proposals[0].ProposedBy != "alice" {
+		t.Errorf("proposed by %q, want the token's user alice", proposals[0].ProposedBy)
+	}
+
+	// The X-User header is ignored, alice cannot approve as bob
+	approve := "/v1/proposals/" + proposals[0].ID + "/approve"
+	if rec := do(approve, "alice-token", ""); rec.Code != http.StatusForbidden {
+		t.Errorf("alice approving her own proposal: status %d, want %d", rec.Code, http.StatusForbidden)
+	}
+	if rec := do(approve, "bob-token", ""); rec.Code != http.StatusOK {
+		t.Errorf("bob approving: status %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+}
+
+func TestServerCasesReadOnlyWithoutUsers(t *testing.T) {
+	store, id := syncedStore(t)
+	server := NewServer(NewTransactionReconciliationService(), ServerConfig{Cases: store})
+
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/cases/"+id, nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("GET case: status %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/cases/"+id+"/resolve", strings.NewReader(`{"resolution": "write_off"}`))
+	req.Header.Set("X-User", "alice")
+	rec = httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, req)
+	if rec.Code == http.StatusOK {
+		t.Error("case resolved although no users are configured")
+	}
+}
+
+// jobServer starts a job queue opening cases like serve does and an API server over it
+func jobServer(t *testing.T, users *APIUsers) (*Server, *CaseStore) {
+	t.Helper()
+	service := NewTransactionReconciliationService()
+	queue, err := NewJobQueue(service, JobQueueConfig{Dir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := NewCaseStore(filepath.Join(t.TempDir(), "cases.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	queue.OnSuccess = openJobCases(store)
+	ctx, cancel := context.WithCancel(context.Background())
+	queue.Start(ctx)
+	t.Cleanup(func() {
+		cancel()
+		queue.Wait()
+	})
+
+	dataDir, err := filepath.Abs(filepath.Join("assets", "data", "csvs"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewServer(service, ServerConfig{Jobs: queue, DataDir: dataDir, UploadDir: t.TempDir(), Cases: store, Users: users}), store
+}
+
+// submitJob starts a reconciliation of the sample files and waits for it to finish
+func submitJob(t *testing.T, server *Server, token string) (int, ReconciliationJob) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/v1/reconciliations", strings.NewReader(`{"sourcePath": "source_transactions.csv", "systemPath": "system_transactions.csv"}`))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, req)
+	var job ReconciliationJob
+	if rec.Code != http.StatusAccepted {
+		return rec.Code, job
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&job); err != nil {
+		t.Fatal(err)
+	}
+	for deadline := time.Now().Add(10 * time.Second); !job.IsFinished() && time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		job, _ = server.config.Jobs.Get(job.ID)
+	}
+	if job.Status != JobSucceeded {
+		t.Fatalf("job is %s, want %s: %s", job.Status, JobSucceeded, job.Error)
+	}
+	return rec.Code, job
+}
+
+func TestServerJobsNeedAuthenticatedUser(t *testing.T) {
+	server, store := jobServer(t, writeAPIUsers(t, "alice"))
+
+	if code, _ := submitJob(t, server, ""); code != http.StatusUnauthorized {
+		t.Errorf("starting a job without a token: status %d, want %d", code, http.StatusUnauthorized)
+	}
+	for _, path := range []string{"/v1/reconciliations/any/cancel", "/v1/partitions"} {
+		rec := httptest.NewRecorder()
+		server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, nil))
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("POST %s without a token: status %d, want %d", path, rec.Code, http.StatusUnauthorized)
+		}
+	}
+	if len(store.List(CaseFilter{})) != 0 {
+		t.Fatal("cases opened without an authenticated job")
+	}
+
+	code, job := submitJob(t, server, "alice-token")
+	if code != http.StatusAccepted || job.SubmittedBy != "alice" {
+		t.Fatalf("starting a job with a token: status %d, submitted by %q, want %d by alice", code, job.SubmittedBy, http.StatusAccepted)
+	}
+	cases := store.List(CaseFilter{})
+	if len(cases) == 0 {
+		t.Fatal("no cases opened for the job of alice")
+	}
+	if opened := cases[0].History[0]; opened.Actor != "alice" {
+		t.Errorf("case opened by %q, want alice", opened.Actor)
+	}
+}
+
+func TestServerAnonymousJobsOpenNoCases(t *testing.T) {
+	server, store := jobServer(t, nil)
+
+	code, job := submitJob(t, server, "")
+	if code != http.StatusAccepted || job.SubmittedBy != "" {
+		t.Fatalf("starting a job: status %d, submitted by %q, want %d anonymously", code, job.SubmittedBy, http.StatusAccepted)
+	}
+	if cases := store.List(CaseFilter{}); len(cases) != 0 {
+		t.Errorf("anonymous job opened %d cases", len(cases))
+	}
+}
+
+func TestServerUploadKeepsFormat(t *testing.T) {
+	server, _ := jobServer(t, nil)
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	for _, upload := range []struct{ field, filename, content string }{
+		{"source", "provider.JSON", `[{"providerTransactionId": "txn-1", "amount": 10, "currency": "USD", "status": "completed", "createdAt": "2024-01-01T00:00:00Z", "updatedAt": "2024-01-01T00:00:00Z"}]`},
+		{"system", "ledger.csv", "transactionId,userId,amount,currency,status,paymentMethod,createdAt,updatedAt,referenceId,metadata_orderId,metadata_description\ntxn-1,,10,USD,completed,,2024-01-01T00:00:00Z,2024-01-01T00:00:00Z,,,\n"},
+	} {
+		part, err := form.CreateFormFile(upload.field, upload.filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		part.Write([]byte(upload.content))
+	}
+	form.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/partitions", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	var result partitionResult
+	if err := json.NewDecoder(rec.Body).Decode(&result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Summary.SuccessfullyMatchedCount != 1 {
+		t.Errorf("summary = %+v, want the JSON source transaction matched", result.Summary)
+	}
+
+	for filename, want := range map[string]string{"a.XLSX": ".xlsx", "a.jsonl": ".jsonl", "a.txt": ".csv", "a": ".csv", "../a.json": ".json"} {
+		if got := uploadExtension(filename); got != want {
+			t.Errorf("uploadExtension(%q) = %q, want %q", filename, got, want)
+		}
+	}
+}
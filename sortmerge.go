@@ -0,0 +1,460 @@
+package main
+
+import (
+	"bufio"
+	"container/heap"
+	"context"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/devhindo/TransactionReconcilerService/reconcile"
+)
+
+// Defaults of the sort-merge reconciliation
+const (
+	defaultSortChunkRows = 500000 // rows sorted in memory before they are spilled to a run file
+	defaultSortFanIn     = 64     // run files merged at once, more runs are merged in several passes
+)
+
+// SortMergeConfig configures the external sort-merge reconciliation
+type SortMergeConfig struct {
+	TempDir   string // spill files are written under this directory, the system default when empty
+	ChunkRows int    // rows held in memory per side while sorting, bounds the memory used
+	FanIn     int    // run files open at once while merging
+}
+
+// SortMergeReconciler reconciles inputs too large for an in-memory index. Both sides are sorted by
+// transaction ID into run files on local disk, then the sorted streams are merge-joined in a single pass,
+// so memory is bounded by ChunkRows whatever the input size. As with Reconcile, the last row read wins
+// when a transaction ID appears more than once on one side.
+type SortMergeReconciler struct {
+	reconciler *TransactionReconciler
+	config     SortMergeConfig
+}
+
+// NewSortMergeReconciler creates a sort-merge reconciler, zero config fields use the defaults
+func NewSortMergeReconciler(reconciler *TransactionReconciler, config SortMergeConfig) *SortMergeReconciler {
+	if config.ChunkRows <= 0 {
+		config.ChunkRows = defaultSortChunkRows
+	}
+	if config.FanIn < 2 {
+		config.FanIn = defaultSortFanIn
+	}
+	return &SortMergeReconciler{reconciler: reconciler, config: config}
+}
+
+// Reconcile sorts both inputs and joins them, calling onException for every exception in transaction ID
+// order. onPhase, when not nil, is called with PhaseReadingSource, PhaseReadingSystem and PhaseMatching
+// as the reconciliation moves on. The spill files are removed before it returns.
+func (smr *SortMergeReconciler) Reconcile(ctx context.Context, source iter.Seq2[SourceTransaction, error], system iter.Seq2[SystemTransaction, error], onPhase func(phase string), onException func(Exception) error) (ReconciliationSummary, error) {
+	var summary ReconciliationSummary
+	phase := func(name string) {
+		if onPhase != nil {
+			onPhase(name)
+		}
+	}
+
+	dir, err := os.MkdirTemp(smr.config.TempDir, "reconcile-sort-")
+	if err != nil {
+		return summary, fmt.Errorf("failed to create spill directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	phase(PhaseReadingSource)
+	sourceRuns := newExternalSorter(dir, "source", smr.config, func(a, b *SourceTransaction) int {
+		return strings.Compare(a.ProviderTransactionID, b.ProviderTransactionID)
+	})
+	for txn, err := range source {
+		if err != nil {
+			return summary, fmt.Errorf("failed to read source transactions: %w", err)
+		}
+		if err := sourceRuns.add(txn); err != nil {
+			return summary, err
+		}
+	}
+	summary.TotalSourceTransactions = int(sourceRuns.seq)
+
+	phase(PhaseReadingSystem)
+	systemRuns := newExternalSorter(dir, "system", smr.config, func(a, b *SystemTransaction) int {
+		return strings.Compare(a.TransactionID, b.TransactionID)
+	})
+	for txn, err := range system {
+		if err != nil {
+			return summary, fmt.Errorf("failed to read system transactions: %w", err)
+		}
+		if err := systemRuns.add(txn); err != nil {
+			return summary, err
+		}
+	}
+	summary.TotalSystemTransactions = int(systemRuns.seq)
+
+	phase(PhaseMatching)
+	nextSource, stopSource := iter.Pull2(lastOfEach(sourceRuns.sorted(ctx), func(txn *SourceTransaction) string { return txn.ProviderTransactionID }))
+	defer stopSource()
+	nextSystem, stopSystem := iter.Pull2(lastOfEach(systemRuns.sorted(ctx), func(txn *SystemTransaction) string { return txn.TransactionID }))
+	defer stopSystem()
+
+	sourceTxn, err, sourceOK := nextSource()
+	if err != nil {
+		return summary, err
+	}
+	systemTxn, err, systemOK := nextSystem()
+	if err != nil {
+		return summary, err
+	}
+
+	for sourceOK || systemOK {
+		var exception *Exception
+		switch {
+		case sourceOK && systemOK && sourceTxn.ProviderTransactionID == systemTxn.TransactionID:
+			discrepancies := smr.reconciler.Compare(sourceTxn, systemTxn)
+			if len(discrepancies) > 0 {
+				sourceCopy, systemCopy := sourceTxn, systemTxn
+				exception = &Exception{Kind: ExceptionMismatched, Mismatch: &MismatchedTransaction{
+					TransactionID: sourceTxn.ProviderTransactionID,
+					Discrepancies: discrepancies,
+					Source:        &sourceCopy,
+					System:        &systemCopy,
+				}}
+				summary.MismatchedTransactionsCount++
+			} else {
+				summary.SuccessfullyMatchedCount++
+			}
+			if sourceTxn, err, sourceOK = nextSource(); err != nil {
+				return summary, err
+			}
+			if systemTxn, err, systemOK = nextSystem(); err != nil {
+				return summary, err
+			}
+
+		case sourceOK && (!systemOK || sourceTxn.ProviderTransactionID < systemTxn.TransactionID):
+			sourceCopy := sourceTxn
+			exception = &Exception{Kind: ExceptionMissingInInternal, Source: &sourceCopy}
+			summary.MissingInInternalCount++
+			if sourceTxn, err, sourceOK = nextSource(); err != nil {
+				return summary, err
+			}
+
+		default:
+			systemCopy := systemTxn
+			exception = &Exception{Kind: ExceptionMissingInSource, System: &systemCopy}
+			summary.MissingInSourceCount++
+			if systemTxn, err, systemOK = nextSystem(); err != nil {
+				return summary, err
+			}
+		}
+
+		if exception != nil && onException != nil {
+			if err := onException(*exception); err != nil {
+				return summary, err
+			}
+		}
+	}
+
+	return summary, nil
+}
+
+// WriteReport reconciles like Reconcile and writes the report of reconciliation_report.json to w, each
+// section in the given report order. The exceptions are sorted through run files like the inputs, so they
+// are not held in memory either. onException, when not nil, still sees every exception in transaction ID
+// order, before the report is written.
+func (smr *SortMergeReconciler) WriteReport(ctx context.Context, source iter.Seq2[SourceTransaction, error], system iter.Seq2[SystemTransaction, error], order ReportOrder, w io.Writer, onPhase func(phase string), onException func(Exception) error) (ReconciliationSummary, error) {
+	dir, err := os.MkdirTemp(smr.config.TempDir, "reconcile-report-")
+	if err != nil {
+		return ReconciliationSummary{}, fmt.Errorf("failed to create spill directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	missingInInternal := newExternalSorter(dir, "missing-in-internal", smr.config, func(a, b *SourceTransaction) int {
+		return order.CompareMissingInInternal(*a, *b)
+	})
+	missingInSource := newExternalSorter(dir, "missing-in-source", smr.config, func(a, b *SystemTransaction) int {
+		return order.CompareMissingInSource(*a, *b)
+	})
+	mismatched := newExternalSorter(dir, "mismatched", smr.config, func(a, b *MismatchedTransaction) int {
+		return order.CompareMismatched(*a, *b)
+	})
+
+	summary, err := smr.Reconcile(ctx, source, system, onPhase, func(exception Exception) error {
+		if onException != nil {
+			if err := onException(exception); err != nil {
+				return err
+			}
+		}
+		switch exception.Kind {
+		case ExceptionMissingInInternal:
+			return missingInInternal.add(*exception.Source)
+		case ExceptionMissingInSource:
+			return missingInSource.add(*exception.System)
+		default:
+			return mismatched.add(*exception.Mismatch)
+		}
+	})
+	if err != nil {
+		return summary, err
+	}
+
+	err = reconcile.StreamReport(w, missingInInternal.sorted(ctx), missingInSource.sorted(ctx), mismatched.sorted(ctx))
+	return summary, err
+}
+
+// lastOfEach yields the last of the consecutive records sharing a key, so a sorted side keeps only the
+// last row read of each transaction ID like the in-memory index of Reconcile
+func lastOfEach[T any](records iter.Seq2[T, error], key func(*T) string) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var pending T
+		havePending := false
+		for record, err := range records {
+			if err != nil {
+				yield(record, err)
+				return
+			}
+			if havePending && key(&pending) != key(&record) {
+				if !yield(pending, nil) {
+					return
+				}
+			}
+			pending, havePending = record, true
+		}
+		if havePending {
+			yield(pending, nil)
+		}
+	}
+}
+
+// spillRecord is a record in a run file, seq keeps the input order among records comparing equal
+type spillRecord[T any] struct {
+	Seq int64
+	Txn T
+}
+
+// externalSorter sorts records, transactions or exceptions, through run files of at most ChunkRows rows
+type externalSorter[T any] struct {
+	dir     string
+	prefix  string
+	config  SortMergeConfig
+	compare func(a, b *T) int
+	buffer  []spillRecord[T]
+	runs    []string
+	seq     int64
+}
+
+// newExternalSorter creates a sorter ordering records by compare, spilling to files named after prefix in dir
+func newExternalSorter[T any](dir, prefix string, config SortMergeConfig, compare func(a, b *T) int) *externalSorter[T] {
+	return &externalSorter[T]{dir: dir, prefix: prefix, config: config, compare: compare}
+}
+
+// add buffers a transaction, spilling the buffer to a sorted run once it is full
+func (s *externalSorter[T]) add(txn T) error {
+	s.buffer = append(s.buffer, spillRecord[T]{Seq: s.seq, Txn: txn})
+	s.seq++
+	if len(s.buffer) >= s.config.ChunkRows {
+		return s.spill()
+	}
+	return nil
+}
+
+// less orders records by compare, then by input order
+func (s *externalSorter[T]) less(a, b *spillRecord[T]) bool {
+	if cmp := s.compare(&a.Txn, &b.Txn); cmp != 0 {
+		return cmp < 0
+	}
+	return a.Seq < b.Seq
+}
+
+// spill sorts the buffer and writes it to a new run file
+func (s *externalSorter[T]) spill() error {
+	sort.Slice(s.buffer, func(i, j int) bool { return s.less(&s.buffer[i], &s.buffer[j]) })
+
+	path, err := s.writeRun(func(yield func(spillRecord[T], error) bool) {
+		for _, record := range s.buffer {
+			if !yield(record, nil) {
+				return
+			}
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	s.runs = append(s.runs, path)
+	s.buffer = s.buffer[:0]
+	return nil
+}
+
+// writeRun writes sorted records to a new run file and returns its path
+func (s *externalSorter[T]) writeRun(records iter.Seq2[spillRecord[T], error]) (string, error) {
+	file, err := os.CreateTemp(s.dir, s.prefix+"-*.run")
+	if err != nil {
+		return "", fmt.Errorf("failed to create spill file: %w", err)
+	}
+	defer file.Close()
+
+	out := bufio.NewWriterSize(file, 1<<20)
+	encoder := gob.NewEncoder(out)
+	for record, err := range records {
+		if err != nil {
+			return "", err
+		}
+		if err := encoder.Encode(&record); err != nil {
+			return "", fmt.Errorf("failed to write spill file: %w", err)
+		}
+	}
+	if err := out.Flush(); err != nil {
+		return "", fmt.Errorf("failed to write spill file: %w", err)
+	}
+	return file.Name(), file.Close()
+}
+
+// sorted yields every record added in order, those comparing equal in the order they were added. Runs
+// beyond the fan-in are first merged into larger runs, so no more than FanIn files are open at once.
+func (s *externalSorter[T]) sorted(ctx context.Context) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var zero T
+
+		var records iter.Seq2[spillRecord[T], error]
+		if len(s.runs) == 0 {
+			// Everything fit in one chunk, no need to touch the disk
+			sort.Slice(s.buffer, func(i, j int) bool { return s.less(&s.buffer[i], &s.buffer[j]) })
+			records = func(yield func(spillRecord[T], error) bool) {
+				for _, record := range s.buffer {
+					if !yield(record, nil) {
+						return
+					}
+				}
+			}
+		} else {
+			if len(s.buffer) > 0 {
+				if err := s.spill(); err != nil {
+					yield(zero, err)
+					return
+				}
+			}
+			for len(s.runs) > s.config.FanIn {
+				var merged []string
+				for start := 0; start < len(s.runs); start += s.config.FanIn {
+					end := min(start+s.config.FanIn, len(s.runs))
+					path, err := s.writeRun(s.merge(ctx, s.runs[start:end]))
+					if err != nil {
+						yield(zero, err)
+						return
+					}
+					for _, run := range s.runs[start:end] {
+						os.Remove(run)
+					}
+					merged = append(merged, path)
+				}
+				s.runs = merged
+			}
+			records = s.merge(ctx, s.runs)
+		}
+
+		for record, err := range records {
+			if err != nil {
+				yield(zero, err)
+				return
+			}
+			if !yield(record.Txn, nil) {
+				return
+			}
+		}
+	}
+}
+
+// merge yields the records of sorted run files in order, checking ctx every progressInterval records
+func (s *externalSorter[T]) merge(ctx context.Context, runs []string) iter.Seq2[spillRecord[T], error] {
+	return func(yield func(spillRecord[T], error) bool) {
+		cursors := &runHeap[T]{less: s.less}
+		defer func() {
+			for _, cursor := range cursors.cursors {
+				cursor.file.Close()
+			}
+		}()
+
+		for _, path := range runs {
+			file, err := os.Open(path)
+			if err != nil {
+				yield(spillRecord[T]{}, fmt.Errorf("failed to open spill file: %w", err))
+				return
+			}
+			cursor := &runCursor[T]{file: file, decoder: gob.NewDecoder(bufio.NewReaderSize(file, 256<<10))}
+			if ok, err := cursor.next(); err != nil {
+				file.Close()
+				yield(spillRecord[T]{}, err)
+				return
+			} else if !ok {
+				file.Close()
+				continue
+			}
+			cursors.cursors = append(cursors.cursors, cursor)
+		}
+		heap.Init(cursors)
+
+		for count := 0; cursors.Len() > 0; count++ {
+			if count%progressInterval == 0 {
+				if err := ctx.Err(); err != nil {
+					yield(spillRecord[T]{}, err)
+					return
+				}
+			}
+
+			cursor := cursors.cursors[0]
+			if !yield(cursor.current, nil) {
+				return
+			}
+			ok, err := cursor.next()
+			if err != nil {
+				yield(spillRecord[T]{}, err)
+				return
+			}
+			if ok {
+				heap.Fix(cursors, 0)
+			} else {
+				cursor.file.Close()
+				heap.Pop(cursors)
+			}
+		}
+	}
+}
+
+// runCursor reads the records of one run file in order
+type runCursor[T any] struct {
+	file    *os.File
+	decoder *gob.Decoder
+	current spillRecord[T]
+}
+
+// next reads the following record, reporting false at the end of the run
+func (c *runCursor[T]) next() (bool, error) {
+	c.current = spillRecord[T]{}
+	if err := c.decoder.Decode(&c.current); err != nil {
+		if errors.Is(err, io.EOF) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to read spill file: %w", err)
+	}
+	return true, nil
+}
+
+// runHeap orders the open runs by their current record
+type runHeap[T any] struct {
+	cursors []*runCursor[T]
+	less    func(a, b *spillRecord[T]) bool
+}
+
+func (h *runHeap[T]) Len() int           { return len(h.cursors) }
+func (h *runHeap[T]) Less(i, j int) bool { return h.less(&h.cursors[i].current, &h.cursors[j].current) }
+func (h *runHeap[T]) Swap(i, j int)      { h.cursors[i], h.cursors[j] = h.cursors[j], h.cursors[i] }
+func (h *runHeap[T]) Push(x any)         { h.cursors = append(h.cursors, x.(*runCursor[T])) }
+func (h *runHeap[T]) Pop() any {
+	old := h.cursors
+	cursor := old[len(old)-1]
+	h.cursors = old[:len(old)-1]
+	return cursor
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/devhindo/TransactionReconcilerService/reconcile"
+)
+
+// appendDuplicates appends a second row for the first n transactions of a CSV file, with the amount
+// column changed, so the last row read of these IDs differs from the first one
+func appendDuplicates(t *testing.T, path string, n, amountColumn int) {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(file).ReadAll()
+	file.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+	writer := csv.NewWriter(out)
+	for _, record := range records[1 : n+1] {
+		amount, _ := strconv.ParseFloat(record[amountColumn], 64)
+		record[amountColumn] = strconv.FormatFloat(amount+1, 'f', 2, 64)
+		writer.Write(record)
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSortMergeMatchesBatchReconciliation(t *testing.T) {
+	dir := t.TempDir()
+	sourcePath, systemPath, err := writeBenchDataset(dir, 300, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	appendDuplicates(t, sourcePath, 5, 4)
+	appendDuplicates(t, systemPath, 3, 2)
+
+	for _, files := range []struct{ name, source, system string }{
+		{"generated with duplicates", sourcePath, systemPath},
+		{"samples", "assets/data/csvs/source_transactions.csv", "assets/data/csvs/system_transactions.csv"},
+	} {
+		t.Run(files.name, func(t *testing.T) {
+			service := NewTransactionReconciliationService()
+			want, err := service.ProcessReconciliation(files.source, files.system)
+			if err != nil {
+				t.Fatal(err)
+			}
+			wantReport, err := reconcile.MarshalReport(want)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Chunks of 16 rows make dozens of runs per side, merged in several passes of two
+			config := SortMergeConfig{TempDir: t.TempDir(), ChunkRows: 16, FanIn: 2}
+			var report bytes.Buffer
+			var batched ReconciliationSummary
+			summary, err := service.ProcessReconciliationSortMerge(context.Background(), files.source, files.system, config, &report,
+				func(batch *ReconciliationResult) error {
+					if size := len(batch.MissingInInternal) + len(batch.MissingInSource) + len(batch.MismatchedTransactions); size > config.ChunkRows {
+						t.Errorf("batch of %d exceptions, want at most %d", size, config.ChunkRows)
+					}
+					batched.MissingInInternalCount += len(batch.MissingInInternal)
+					batched.MissingInSourceCount += len(batch.MissingInSource)
+					batched.MismatchedTransactionsCount += len(batch.MismatchedTransactions)
+					return nil
+				}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if summary != want.Summary {
+				t.Errorf("summary = %+v, want %+v", summary, want.Summary)
+			}
+			if batched.MissingInInternalCount != want.Summary.MissingInInternalCount || batched.MissingInSourceCount != want.Summary.MissingInSourceCount ||
+				batched.MismatchedTransactionsCount != want.Summary.MismatchedTransactionsCount {
+				t.Errorf("batches held %+v exceptions, want the counts of %+v", batched, want.Summary)
+			}
+			if !bytes.Equal(report.Bytes(), wantReport) {
+				t.Errorf("sort-merge report differs from the batch report:\n%s\nwant:\n%s", report.Bytes(), wantReport)
+			}
+			if spill, _ := filepath.Glob(filepath.Join(config.TempDir, "*")); len(spill) != 0 {
+				t.Errorf("spill files left behind: %v", spill)
+			}
+		})
+	}
+}
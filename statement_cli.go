@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"text/tabwriter"
+
+	"github.com/devhindo/TransactionReconcilerService/reconcile"
+)
+
+// runStatements reads bank statement files and lists their statements with balances, so a file can be
+// checked before it is reconciled
+func runStatements(args []string) {
+	fs := flag.NewFlagSet("statements", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "print the statements with their entries as JSON")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: TransactionReconcilerService statements [-json] <file>...")
+		fmt.Fprintln(os.Stderr, "the format follows the extension: .xml for camt, .sta, .mt940 or .940 for MT940, .bai or .bai2 for BAI2")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var statements []reconcile.Statement
+	for _, path := range fs.Args() {
+		source, err := reconcile.StatementFile(path)
+		if err != nil {
+			log.Fatalf("Failed to read %s: %v", path, err)
+		}
+		for statement, err := range source.Statements(ctx) {
+			if err != nil {
+				log.Fatalf("Failed to read %s: %v", path, err)
+			}
+			statements = append(statements, statement)
+		}
+	}
+
+	if *asJSON {
+		printJSON(statements)
+	} else {
+		printStatementTable(statements)
+	}
+
+	// A statement whose lines do not add up to its closing balance is missing lines or has extra ones
+	for _, statement := range statements {
+		if !statement.Balanced() {
+			os.Exit(1)
+		}
+	}
+}
+
+// printStatementTable prints one line per statement
+func printStatementTable(statements []reconcile.Statement) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "STATEMENT\tACCOUNT\tOPENING\tCLOSING\tENTRIES\tMOVEMENT\tBALANCED")
+	for _, s := range statements {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%.2f %s\t%s\n", s.ID, s.Account, formatBalance(s.Opening), formatBalance(s.Closing), len(s.Entries), s.Movement(), s.Currency, formatBalanced(s))
+	}
+	w.Flush()
+}
+
+// formatBalance writes a balance with its date, - when the statement has none
+func formatBalance(balance *reconcile.Balance) string {
+	if balance == nil {
+		return "-"
+	}
+	return fmt.Sprintf("%.2f %s on %s", balance.Amount, balance.Currency, balance.Date.Format("2006-01-02"))
+}
+
+// formatBalanced tells whether the statement adds up, - when it lacks a balance to check
+func formatBalanced(s reconcile.Statement) string {
+	switch {
+	case s.Opening == nil || s.Closing == nil:
+		return "-"
+	case s.Balanced():
+		return "yes"
+	}
+	return "NO"
+}
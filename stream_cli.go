@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+)
+
+// streamEvent is one input line of the stream command: a transaction of either side, or a watermark
+type streamEvent struct {
+	Source    *SourceTransaction `json:"source,omitempty"`
+	System    *SystemTransaction `json:"system,omitempty"`
+	Watermark *time.Time         `json:"watermark,omitempty"`
+}
+
+// runStream reconciles transactions read as NDJSON, one per line, and writes every result as soon as it is known
+func runStream(args []string) {
+	fs := flag.NewFlagSet("stream", flag.ExitOnError)
+	inPath := fs.String("in", "", "NDJSON file of {\"source\":...}, {\"system\":...} or {\"watermark\":...} lines, stdin when empty")
+	lateness := fs.Duration("lateness", defaultStreamingLateness, "how long past its createdAt a transaction waits for its counterpart")
+	flush := fs.Bool("flush", true, "declare the transactions still waiting missing when the input ends")
+	fs.Parse(args)
+
+	var in io.Reader = os.Stdin
+	if *inPath != "" {
+		file, err := os.Open(*inPath)
+		if err != nil {
+			log.Fatalf("Failed to open input: %v", err)
+		}
+		defer file.Close()
+		in = file
+	}
+
+	engine := NewStreamingReconciler(NewTransactionReconciler(), StreamingConfig{AllowedLateness: *lateness})
+	out := bufio.NewWriter(os.Stdout)
+	encoder := json.NewEncoder(out)
+	superseded := 0
+	emit := func(results []StreamResult) {
+		for _, result := range results {
+			if result.Outcome == StreamSuperseded {
+				superseded++
+			}
+			if err := encoder.Encode(result); err != nil {
+				log.Fatalf("Failed to write result: %v", err)
+			}
+		}
+		// Results are flushed per input line so a downstream consumer sees them right away
+		out.Flush()
+	}
+
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var event streamEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			log.Fatalf("Invalid event at line %d: %v", line, err)
+		}
+
+		var results []StreamResult
+		var err error
+		switch {
+		case event.Source != nil:
+			results, err = engine.AddSource(*event.Source)
+		case event.System != nil:
+			results, err = engine.AddSystem(*event.System)
+		case event.Watermark != nil:
+			results, err = engine.AdvanceWatermark(*event.Watermark)
+		default:
+			log.Fatalf("Invalid event at line %d: expected source, system or watermark", line)
+		}
+		if err != nil {
+			log.Fatalf("Failed to reconcile line %d: %v", line, err)
+		}
+		emit(results)
+	}
+	if err := scanner.Err(); err != nil {
+		log.Fatalf("Failed to read input: %v", err)
+	}
+
+	if *flush {
+		results, err := engine.Flush()
+		if err != nil {
+			log.Fatalf("Failed to flush: %v", err)
+		}
+		emit(results)
+	}
+
+	summary := engine.Summary()
+	fmt.Fprintf(os.Stderr, "matched %d, mismatched %d, missing in internal %d, missing in source %d, superseded %d\n",
+		summary.SuccessfullyMatchedCount, summary.MismatchedTransactionsCount, summary.MissingInInternalCount, summary.MissingInSourceCount, superseded)
+}
@@ -0,0 +1,357 @@
+package main
+
+import (
+	"container/heap"
+	"time"
+)
+
+// StreamOutcome is what the streaming reconciler decided about a transaction
+type StreamOutcome string
+
+const (
+	StreamMatched           StreamOutcome = "matched"
+	StreamMismatched        StreamOutcome = "mismatched"
+	StreamMissingInInternal StreamOutcome = "missing_in_internal"
+	StreamMissingInSource   StreamOutcome = "missing_in_source"
+	// StreamSuperseded is a waiting transaction replaced by a later one with the same ID on the same side,
+	// which is reconciled instead, as the last row read is in a batch run
+	StreamSuperseded StreamOutcome = "superseded"
+)
+
+// StreamResult is emitted once per transaction received, when its counterpart arrives, the watermark gives
+// up on it or a later transaction with the same ID supersedes it
+type StreamResult struct {
+	Outcome       StreamOutcome      `json:"outcome"`
+	TransactionID string             `json:"transactionId"`
+	Discrepancies DiscrepancyMap     `json:"discrepancies,omitempty"`
+	Source        *SourceTransaction `json:"source,omitempty"`
+	System        *SystemTransaction `json:"system,omitempty"`
+	Watermark     time.Time          `json:"watermark"`
+	Late          bool               `json:"late,omitempty"` // the transaction arrived after the watermark had passed it
+}
+
+// StreamStateStore keeps the transactions still waiting for their counterpart
+type StreamStateStore interface {
+	PutSource(txn SourceTransaction) error
+	PutSystem(txn SystemTransaction) error
+	// TakeSource removes and returns the waiting source transaction with the ID, if any
+	TakeSource(id string) (SourceTransaction, bool, error)
+	// TakeSystem removes and returns the waiting system transaction with the ID, if any
+	TakeSystem(id string) (SystemTransaction, bool, error)
+	// ExpireSources removes and returns the waiting source transactions created before the cutoff, oldest first
+	ExpireSources(cutoff time.Time) ([]SourceTransaction, error)
+	// ExpireSystems removes and returns the waiting system transactions created before the cutoff, oldest first
+	ExpireSystems(cutoff time.Time) ([]SystemTransaction, error)
+	// Len returns the number of waiting source and system transactions
+	Len() (int, int)
+}
+
+// StreamingConfig configures the streaming reconciler
+type StreamingConfig struct {
+	// AllowedLateness is how long past its CreatedAt a transaction waits for its counterpart,
+	// measured against the watermark rather than the wall clock
+	AllowedLateness time.Duration
+	// Store keeps the unmatched transactions, nil keeps them in memory
+	Store StreamStateStore
+}
+
+// StreamingReconciler reconciles source and system transactions arriving one by one in any order.
+// Pairs are emitted as soon as both sides are known; a transaction without a counterpart is declared
+// missing once the event-time watermark passes its CreatedAt plus the allowed lateness.
+// The watermark is the latest CreatedAt seen on either side, or later if moved by AdvanceWatermark.
+// It is not safe for concurrent use.
+type StreamingReconciler struct {
+	reconciler *TransactionReconciler
+	config     StreamingConfig
+	watermark  time.Time
+	summary    ReconciliationSummary
+}
+
+// NewStreamingReconciler creates a streaming reconciler with an empty state
+func NewStreamingReconciler(reconciler *TransactionReconciler, config StreamingConfig) *StreamingReconciler {
+	if config.Store == nil {
+		config.Store = NewMemoryStateStore()
+	}
+	return &StreamingReconciler{
+		reconciler: reconciler,
+		config:     config,
+	}
+}
+
+// Watermark returns the current event-time watermark
+func (sr *StreamingReconciler) Watermark() time.Time {
+	return sr.watermark
+}
+
+// Summary returns the counts of what has been emitted so far, the totals count every transaction received,
+// superseded ones included
+func (sr *StreamingReconciler) Summary() ReconciliationSummary {
+	return sr.summary
+}
+
+// AddSource takes a source transaction and returns the results it produced
+func (sr *StreamingReconciler) AddSource(txn SourceTransaction) ([]StreamResult, error) {
+	sr.summary.TotalSourceTransactions++
+
+	var results []StreamResult
+	if system, ok, err := sr.config.Store.TakeSystem(txn.ProviderTransactionID); err != nil {
+		return nil, err
+	} else if ok {
+		results = append(results, sr.pair(txn, system))
+	} else if sr.expired(txn.CreatedAt) {
+		// Its counterpart would have been declared missing already, so it cannot match any more
+		results = append(results, sr.missingInInternal(txn, true))
+	} else {
+		if previous, ok, err := sr.config.Store.TakeSource(txn.ProviderTransactionID); err != nil {
+			return nil, err
+		} else if ok {
+			results = append(results, StreamResult{Outcome: StreamSuperseded, TransactionID: previous.ProviderTransactionID, Source: &previous, Watermark: sr.watermark})
+		}
+		if err := sr.config.Store.PutSource(txn); err != nil {
+			return nil, err
+		}
+	}
+
+	expired, err := sr.advance(txn.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return append(results, expired...), nil
+}
+
+// AddSystem takes a system transaction and returns the results it produced
+func (sr *StreamingReconciler) AddSystem(txn SystemTransaction) ([]StreamResult, error) {
+	sr.summary.TotalSystemTransactions++
+
+	var results []StreamResult
+	if source, ok, err := sr.config.Store.TakeSource(txn.TransactionID); err != nil {
+		return nil, err
+	} else if ok {
+		results = append(results, sr.pair(source, txn))
+	} else if sr.expired(txn.CreatedAt) {
+		results = append(results, sr.missingInSource(txn, true))
+	} else {
+		if previous, ok, err := sr.config.Store.TakeSystem(txn.TransactionID); err != nil {
+			return nil, err
+		} else if ok {
+			results = append(results, StreamResult{Outcome: StreamSuperseded, TransactionID: previous.TransactionID, System: &previous, Watermark: sr.watermark})
+		}
+		if err := sr.config.Store.PutSystem(txn); err != nil {
+			return nil, err
+		}
+	}
+
+	expired, err := sr.advance(txn.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return append(results, expired...), nil
+}
+
+// AdvanceWatermark moves the watermark to t when it is later, for instance when both feeds are known
+// to be complete up to t but idle, and returns the transactions declared missing as a result
+func (sr *StreamingReconciler) AdvanceWatermark(t time.Time) ([]StreamResult, error) {
+	return sr.advance(t)
+}
+
+// Flush declares every transaction still waiting as missing, at the end of a finite stream
+func (sr *StreamingReconciler) Flush() ([]StreamResult, error) {
+	// A cutoff far past any CreatedAt expires everything
+	return sr.expire(time.Unix(1<<62, 0))
+}
+
+// advance moves the watermark forward and expires what it passed
+func (sr *StreamingReconciler) advance(t time.Time) ([]StreamResult, error) {
+	if !t.After(sr.watermark) {
+		return nil, nil
+	}
+	sr.watermark = t
+	return sr.expire(t.Add(-sr.config.AllowedLateness))
+}
+
+// expired reports whether the watermark has already passed a transaction created at t
+func (sr *StreamingReconciler) expired(t time.Time) bool {
+	return t.Before(sr.watermark.Add(-sr.config.AllowedLateness))
+}
+
+// expire declares the waiting transactions created before the cutoff missing
+func (sr *StreamingReconciler) expire(cutoff time.Time) ([]StreamResult, error) {
+	sources, err := sr.config.Store.ExpireSources(cutoff)
+	if err != nil {
+		return nil, err
+	}
+	systems, err := sr.config.Store.ExpireSystems(cutoff)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]StreamResult, 0, len(sources)+len(systems))
+	for _, txn := range sources {
+		results = append(results, sr.missingInInternal(txn, false))
+	}
+	for _, txn := range systems {
+		results = append(results, sr.missingInSource(txn, false))
+	}
+	return results, nil
+}
+
+// pair compares both sides of a transaction
+func (sr *StreamingReconciler) pair(source SourceTransaction, system SystemTransaction) StreamResult {
+	result := StreamResult{
+		Outcome:       StreamMatched,
+		TransactionID: source.ProviderTransactionID,
+		Source:        &source,
+		System:        &system,
+		Watermark:     sr.watermark,
+	}
+	if discrepancies := sr.reconciler.Compare(source, system); len(discrepancies) > 0 {
+		result.Outcome = StreamMismatched
+		result.Discrepancies = discrepancies
+		sr.summary.MismatchedTransactionsCount++
+	} else {
+		sr.summary.SuccessfullyMatchedCount++
+	}
+	return result
+}
+
+// missingInInternal builds the result for a source transaction that never got a counterpart
+func (sr *StreamingReconciler) missingInInternal(txn SourceTransaction, late bool) StreamResult {
+	sr.summary.MissingInInternalCount++
+	return StreamResult{
+		Outcome:       StreamMissingInInternal,
+		TransactionID: txn.ProviderTransactionID,
+		Source:        &txn,
+		Watermark:     sr.watermark,
+		Late:          late,
+	}
+}
+
+// missingInSource builds the result for a system transaction that never got a counterpart
+func (sr *StreamingReconciler) missingInSource(txn SystemTransaction, late bool) StreamResult {
+	sr.summary.MissingInSourceCount++
+	return StreamResult{
+		Outcome:       StreamMissingInSource,
+		TransactionID: txn.TransactionID,
+		System:        &txn,
+		Watermark:     sr.watermark,
+		Late:          late,
+	}
+}
+
+// MemoryStateStore keeps the waiting transactions in maps, indexed by CreatedAt for expiry
+type MemoryStateStore struct {
+	sources     map[string]SourceTransaction
+	systems     map[string]SystemTransaction
+	sourceOrder expiryHeap
+	systemOrder expiryHeap
+}
+
+// NewMemoryStateStore creates an empty in-memory state store
+func NewMemoryStateStore() *MemoryStateStore {
+	return &MemoryStateStore{
+		sources: make(map[string]SourceTransaction),
+		systems: make(map[string]SystemTransaction),
+	}
+}
+
+// PutSource stores a source transaction, replacing a waiting one with the same ID
+func (m *MemoryStateStore) PutSource(txn SourceTransaction) error {
+	m.sources[txn.ProviderTransactionID] = txn
+	heap.Push(&m.sourceOrder, expiryEntry{id: txn.ProviderTransactionID, createdAt: txn.CreatedAt})
+	return nil
+}
+
+// PutSystem stores a system transaction, replacing a waiting one with the same ID
+func (m *MemoryStateStore) PutSystem(txn SystemTransaction) error {
+	m.systems[txn.TransactionID] = txn
+	heap.Push(&m.systemOrder, expiryEntry{id: txn.TransactionID, createdAt: txn.CreatedAt})
+	return nil
+}
+
+// TakeSource removes and returns a waiting source transaction. Its heap entry is dropped lazily, the heap
+// is rebuilt once stale entries outnumber the waiting transactions so it does not grow without expiry.
+func (m *MemoryStateStore) TakeSource(id string) (SourceTransaction, bool, error) {
+	txn, ok := m.sources[id]
+	delete(m.sources, id)
+	if len(m.sourceOrder) > 2*len(m.sources)+1024 {
+		m.sourceOrder = m.sourceOrder[:0]
+		for _, waiting := range m.sources {
+			m.sourceOrder = append(m.sourceOrder, expiryEntry{id: waiting.ProviderTransactionID, createdAt: waiting.CreatedAt})
+		}
+		heap.Init(&m.sourceOrder)
+	}
+	return txn, ok, nil
+}
+
+// TakeSystem removes and returns a waiting system transaction, like TakeSource
+func (m *MemoryStateStore) TakeSystem(id string) (SystemTransaction, bool, error) {
+	txn, ok := m.systems[id]
+	delete(m.systems, id)
+	if len(m.systemOrder) > 2*len(m.systems)+1024 {
+		m.systemOrder = m.systemOrder[:0]
+		for _, waiting := range m.systems {
+			m.systemOrder = append(m.systemOrder, expiryEntry{id: waiting.TransactionID, createdAt: waiting.CreatedAt})
+		}
+		heap.Init(&m.systemOrder)
+	}
+	return txn, ok, nil
+}
+
+// ExpireSources removes and returns the source transactions created before the cutoff
+func (m *MemoryStateStore) ExpireSources(cutoff time.Time) ([]SourceTransaction, error) {
+	var expired []SourceTransaction
+	for _, id := range m.sourceOrder.popBefore(cutoff) {
+		// Entries of transactions taken or replaced since are stale
+		if txn, ok := m.sources[id]; ok && txn.CreatedAt.Before(cutoff) {
+			expired = append(expired, txn)
+			delete(m.sources, id)
+		}
+	}
+	return expired, nil
+}
+
+// ExpireSystems removes and returns the system transactions created before the cutoff
+func (m *MemoryStateStore) ExpireSystems(cutoff time.Time) ([]SystemTransaction, error) {
+	var expired []SystemTransaction
+	for _, id := range m.systemOrder.popBefore(cutoff) {
+		if txn, ok := m.systems[id]; ok && txn.CreatedAt.Before(cutoff) {
+			expired = append(expired, txn)
+			delete(m.systems, id)
+		}
+	}
+	return expired, nil
+}
+
+// Len returns the number of waiting source and system transactions
+func (m *MemoryStateStore) Len() (int, int) {
+	return len(m.sources), len(m.systems)
+}
+
+// expiryEntry orders a waiting transaction by creation time
+type expiryEntry struct {
+	id        string
+	createdAt time.Time
+}
+
+// expiryHeap is a min-heap of waiting transactions by creation time
+type expiryHeap []expiryEntry
+
+func (h expiryHeap) Len() int           { return len(h) }
+func (h expiryHeap) Less(i, j int) bool { return h[i].createdAt.Before(h[j].createdAt) }
+func (h expiryHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *expiryHeap) Push(x any)        { *h = append(*h, x.(expiryEntry)) }
+func (h *expiryHeap) Pop() any {
+	old := *h
+	entry := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return entry
+}
+
+// popBefore removes and returns the IDs of the entries created before the cutoff, oldest first
+func (h *expiryHeap) popBefore(cutoff time.Time) []string {
+	var ids []string
+	for h.Len() > 0 && (*h)[0].createdAt.Before(cutoff) {
+		ids = append(ids, heap.Pop(h).(expiryEntry).id)
+	}
+	return ids
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// streamPair returns the two sides of a transaction that match, created at the given time
+func streamPair(id string, createdAt time.Time) (SourceTransaction, SystemTransaction) {
+	source := SourceTransaction{ProviderTransactionID: id, UserID: "usr-1", Amount: 10, Currency: "USD", Status: "completed",
+		PaymentMethod: "credit_card", CreatedAt: createdAt, UpdatedAt: createdAt, ProviderReference: "ref-" + id}
+	system := SystemTransaction{TransactionID: id, UserID: "usr-1", Amount: 10, Currency: "USD", Status: "completed",
+		PaymentMethod: "credit_card", CreatedAt: createdAt, UpdatedAt: createdAt, ReferenceID: "ref-" + id}
+	return source, system
+}
+
+// outcomes returns a function giving the outcome of every result by transaction ID, failing the test on
+// an error or on a second result for a transaction
+func outcomes(t *testing.T) func(results []StreamResult, err error) map[string]StreamOutcome {
+	return func(results []StreamResult, err error) map[string]StreamOutcome {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := make(map[string]StreamOutcome)
+		for _, result := range results {
+			if previous, ok := got[result.TransactionID]; ok {
+				t.Fatalf("%s decided twice, %s then %s", result.TransactionID, previous, result.Outcome)
+			}
+			got[result.TransactionID] = result.Outcome
+		}
+		return got
+	}
+}
+
+func TestStreamingReconcilerOutOfOrder(t *testing.T) {
+	day := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	engine := NewStreamingReconciler(NewTransactionReconciler(), StreamingConfig{AllowedLateness: time.Hour})
+
+	// The system side of a arrives first, b arrives source first with an older createdAt than a
+	sourceA, systemA := streamPair("a", day.Add(30*time.Minute))
+	sourceB, systemB := streamPair("b", day)
+	systemB.Amount = 12
+
+	var results []StreamResult
+	for _, add := range []func() ([]StreamResult, error){
+		func() ([]StreamResult, error) { return engine.AddSystem(systemA) },
+		func() ([]StreamResult, error) { return engine.AddSource(sourceB) },
+		func() ([]StreamResult, error) { return engine.AddSource(sourceA) },
+		func() ([]StreamResult, error) { return engine.AddSystem(systemB) },
+	} {
+		added, err := add()
+		if err != nil {
+			t.Fatal(err)
+		}
+		results = append(results, added...)
+	}
+
+	got := outcomes(t)(results, nil)
+	if got["a"] != StreamMatched || got["b"] != StreamMismatched || len(got) != 2 {
+		t.Errorf("outcomes = %v, want a matched and b mismatched", got)
+	}
+	if sources, systems := engine.config.Store.Len(); sources+systems != 0 {
+		t.Errorf("%d source and %d system transactions still waiting, want none", sources, systems)
+	}
+	if summary := engine.Summary(); summary.SuccessfullyMatchedCount != 1 || summary.MismatchedTransactionsCount != 1 ||
+		summary.TotalSourceTransactions != 2 || summary.TotalSystemTransactions != 2 {
+		t.Errorf("summary = %+v", summary)
+	}
+}
+
+func TestStreamingReconcilerWatermarkExpiry(t *testing.T) {
+	day := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	engine := NewStreamingReconciler(NewTransactionReconciler(), StreamingConfig{AllowedLateness: time.Hour})
+	decide := outcomes(t)
+
+	sourceA, _ := streamPair("a", day)
+	_, systemB := streamPair("b", day.Add(10*time.Minute))
+	if got := decide(engine.AddSource(sourceA)); len(got) != 0 {
+		t.Fatalf("outcomes = %v, want a waiting", got)
+	}
+	if got := decide(engine.AddSystem(systemB)); len(got) != 0 {
+		t.Fatalf("outcomes = %v, want b waiting", got)
+	}
+
+	// Up to the lateness past its createdAt, a transaction still waits
+	if got := decide(engine.AdvanceWatermark(day.Add(time.Hour))); len(got) != 0 {
+		t.Errorf("at the lateness: outcomes = %v, want a and b waiting", got)
+	}
+	// A watermark going back changes nothing
+	if got := decide(engine.AdvanceWatermark(day)); len(got) != 0 || !engine.Watermark().Equal(day.Add(time.Hour)) {
+		t.Errorf("moving back: outcomes = %v and watermark %v, want nothing and %v", got, engine.Watermark(), day.Add(time.Hour))
+	}
+
+	sourceC, systemC := streamPair("c", day.Add(time.Hour+5*time.Minute))
+	got := decide(engine.AddSource(sourceC))
+	if got["a"] != StreamMissingInInternal || len(got) != 1 {
+		t.Errorf("past the lateness of a: outcomes = %v, want a missing in internal", got)
+	}
+	got = decide(engine.AddSystem(systemC))
+	if got["c"] != StreamMatched || len(got) != 1 {
+		t.Errorf("outcomes = %v, want c matched and b still waiting", got)
+	}
+
+	got = decide(engine.Flush())
+	if got["b"] != StreamMissingInSource || len(got) != 1 {
+		t.Errorf("flush: outcomes = %v, want b missing in source", got)
+	}
+}
+
+func TestStreamingReconcilerLateEvents(t *testing.T) {
+	day := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	engine := NewStreamingReconciler(NewTransactionReconciler(), StreamingConfig{AllowedLateness: time.Hour})
+	decide := outcomes(t)
+
+	sourceA, systemA := streamPair("a", day)
+	if _, err := engine.AddSource(sourceA); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := engine.AdvanceWatermark(day.Add(2 * time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	// a was given up on, its counterpart and a transaction as old are both late
+	_, systemLate := streamPair("late", day.Add(30*time.Minute))
+	for _, system := range []SystemTransaction{systemA, systemLate} {
+		results, err := engine.AddSystem(system)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != 1 || results[0].Outcome != StreamMissingInSource || !results[0].Late {
+			t.Errorf("%s: results = %+v, want one late missing in source", system.TransactionID, results)
+		}
+	}
+
+	// Within the lateness, a transaction older than the watermark still waits
+	sourceOnTime, systemOnTime := streamPair("on-time", day.Add(90*time.Minute))
+	if got := decide(engine.AddSource(sourceOnTime)); len(got) != 0 {
+		t.Errorf("outcomes = %v, want on-time waiting", got)
+	}
+	if results, err := engine.AddSystem(systemOnTime); err != nil || len(results) != 1 || results[0].Outcome != StreamMatched || results[0].Late {
+		t.Errorf("results = %+v, %v, want on-time matched", results, err)
+	}
+}
+
+func TestStreamingReconcilerSupersedes(t *testing.T) {
+	day := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	engine := NewStreamingReconciler(NewTransactionReconciler(), StreamingConfig{AllowedLateness: time.Hour})
+	decide := outcomes(t)
+
+	first, system := streamPair("a", day)
+	first.Amount = 12
+	last, _ := streamPair("a", day)
+	if got := decide(engine.AddSource(first)); len(got) != 0 {
+		t.Fatalf("outcomes = %v, want a waiting", got)
+	}
+	results, err := engine.AddSource(last)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Outcome != StreamSuperseded || results[0].Source.Amount != 12 {
+		t.Fatalf("results = %+v, want the first row superseded", results)
+	}
+
+	// The last row read is the one reconciled, every transaction received got one result
+	if got := decide(engine.AddSystem(system)); got["a"] != StreamMatched {
+		t.Errorf("outcomes = %v, want a matched", got)
+	}
+	if summary := engine.Summary(); summary.TotalSourceTransactions != 2 || summary.SuccessfullyMatchedCount != 1 || summary.MissingInInternalCount != 0 {
+		t.Errorf("summary = %+v, want 2 source transactions and 1 match", summary)
+	}
+}
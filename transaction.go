@@ -1,11 +1,17 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"iter"
 	"log"
 	"os"
 	"strings"
+	"time"
+
+	"github.com/devhindo/TransactionReconcilerService/reconcile"
 )
 
 // TransactionReconciliationService is the main service that generates the reconciliation report
@@ -14,19 +20,59 @@ type TransactionReconciliationService struct {
 	reconciler *TransactionReconciler // a reconciler to handle the logic
 }
 
-// Constructor: NewTransactionReconciliationService creates a new service instance
-func NewTransactionReconciliationService() *TransactionReconciliationService {
+// Constructor: NewTransactionReconciliationService creates a new service instance, the options configure its reconciler
+func NewTransactionReconciliationService(opts ...reconcile.Option) *TransactionReconciliationService {
 	return &TransactionReconciliationService{
 		csvReader:  NewCSVReader(),
-		reconciler: NewTransactionReconciler(),
+		reconciler: NewTransactionReconciler(opts...),
 	}
 }
 
+// Reconciliation phases reported while ProcessReconciliationContext runs
+const (
+	PhaseReadingSource = "reading_source"
+	PhaseReadingSystem = "reading_system"
+	PhaseMatching      = "matching"
+	PhaseWritingReport = "writing_report"
+	PhaseDone          = "done"
+)
+
+// Progress is a snapshot of a running reconciliation
+type Progress struct {
+	Phase             string  `json:"phase"`
+	Percent           float64 `json:"percent"`
+	SourceRows        int     `json:"sourceRows"`
+	SystemRows        int     `json:"systemRows"`
+	PairsCompared     int     `json:"pairsCompared"`
+	PairsTotal        int     `json:"pairsTotal"`
+	MissingInInternal int     `json:"missingInInternal"`
+	MissingInSource   int     `json:"missingInSource"`
+	Mismatched        int     `json:"mismatched"`
+}
+
 // ProcessReconciliation reads transactions from CSV files, reconciles them, and returns the result
 func (s *TransactionReconciliationService) ProcessReconciliation(sourceFilePath, systemFilePath string) (*ReconciliationResult, error) {
+	return s.ProcessReconciliationContext(context.Background(), sourceFilePath, systemFilePath, nil)
+}
+
+// ProcessReconciliationContext runs ProcessReconciliation until ctx is done, calling onProgress, when not nil,
+// as rows are parsed and pairs are compared. Reading counts for the first 70% and matching for the rest.
+func (s *TransactionReconciliationService) ProcessReconciliationContext(ctx context.Context, sourceFilePath, systemFilePath string, onProgress func(Progress)) (*ReconciliationResult, error) {
+	var progress Progress
+	report := func() {
+		if onProgress != nil {
+			onProgress(progress)
+		}
+	}
+
 	// Read source transactions
 	log.Printf("Reading source transactions from: %s", sourceFilePath)
-	sourceTransactions, err := s.csvReader.ReadSourceTransactions(sourceFilePath)
+	progress.Phase = PhaseReadingSource
+	report()
+	sourceTransactions, err := s.csvReader.ReadSourceTransactionsContext(ctx, sourceFilePath, func(rows int) {
+		progress.SourceRows = rows
+		report()
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to read source transactions: %w", err)
 	}
@@ -34,7 +80,13 @@ func (s *TransactionReconciliationService) ProcessReconciliation(sourceFilePath,
 
 	// Read system transactions
 	log.Printf("Reading system transactions from: %s", systemFilePath)
-	systemTransactions, err := s.csvReader.ReadSystemTransactions(systemFilePath)
+	progress.Phase = PhaseReadingSystem
+	progress.Percent = 35
+	report()
+	systemTransactions, err := s.csvReader.ReadSystemTransactionsContext(ctx, systemFilePath, func(rows int) {
+		progress.SystemRows = rows
+		report()
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to read system transactions: %w", err)
 	}
@@ -42,70 +94,362 @@ func (s *TransactionReconciliationService) ProcessReconciliation(sourceFilePath,
 
 	// Perform reconciliation
 	log.Println("Starting reconciliation process...")
-	result := s.reconciler.Reconcile(sourceTransactions, systemTransactions)
+	progress.Phase = PhaseMatching
+	progress.Percent = 70
+	report()
+	result, err := s.reconciler.ReconcileWithProgress(ctx, sourceTransactions, systemTransactions, func(m MatchProgress) {
+		progress.PairsCompared = m.PairsCompared
+		progress.PairsTotal = m.PairsTotal
+		progress.MissingInInternal = m.MissingInInternal
+		progress.MissingInSource = m.MissingInSource
+		progress.Mismatched = m.Mismatched
+		if m.PairsTotal > 0 {
+			progress.Percent = 70 + 30*float64(m.PairsCompared)/float64(m.PairsTotal)
+		}
+		report()
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reconciliation interrupted: %w", err)
+	}
 	log.Println("Reconciliation completed")
 
 	return result, nil
 }
 
-// OutputReconciliationResult outputs the reconciliation result in JSON format
-func (s *TransactionReconciliationService) OutputReconciliationResult(result *ReconciliationResult) error {
-	// Transform missing_in_internal to simplified format
-	missingInInternal := make([]map[string]interface{}, len(result.MissingInInternal))
-	for i, txn := range result.MissingInInternal {
-		missingInInternal[i] = map[string]interface{}{
-			"providerTransactionId": txn.ProviderTransactionID,
-			"amount":                txn.Amount,
-			"currency":              txn.Currency,
-			"status":                txn.Status,
+// defaultStreamingLateness is how long past its createdAt a streamed transaction waits for its counterpart
+const defaultStreamingLateness = 24 * time.Hour
+
+// ErrLatenessExceeded is what the streaming pass stops on when the files are further out of order than the
+// allowed lateness, ProcessReconciliationStreaming then falls back to a sort-merge run
+var ErrLatenessExceeded = errors.New("transactions are further out of createdAt order than the allowed lateness")
+
+// ProcessReconciliationStreaming reconciles two CSV files without loading either of them: both are read
+// row by row, in CreatedAt order across the two files, and a transaction waits for its counterpart until the
+// watermark is lateness past its CreatedAt. Only the waiting transactions are kept, along with the exceptions
+// for the report, so memory scales with the number of open items rather than with the file sizes.
+//
+// Streaming needs each file in CreatedAt order give or take lateness, and the two sides of a transaction
+// created less than lateness apart. Both are checked: when a transaction arrives after the watermark passed
+// it, or the counterpart of one already declared missing shows up, the files are reconciled again with a
+// sort-merge run in the system temp directory, which takes them in any order. Either way the result is the
+// one of ProcessReconciliation, with one exception: a transaction ID appearing again on one side after it was
+// paired is reported missing, where ProcessReconciliation pairs its last row.
+func (s *TransactionReconciliationService) ProcessReconciliationStreaming(ctx context.Context, sourceFilePath, systemFilePath string, lateness time.Duration, onProgress func(Progress)) (*ReconciliationResult, error) {
+	result, err := s.streamReconciliation(ctx, sourceFilePath, systemFilePath, lateness, onProgress)
+	if errors.Is(err, ErrLatenessExceeded) {
+		log.Printf("Warning: %v, reconciling the files again sorted on disk", err)
+		return s.sortMergeResult(ctx, sourceFilePath, systemFilePath, onProgress)
+	}
+	return result, err
+}
+
+// streamReconciliation is the streaming pass of ProcessReconciliationStreaming, failing with
+// ErrLatenessExceeded when the files are too far out of order for it
+func (s *TransactionReconciliationService) streamReconciliation(ctx context.Context, sourceFilePath, systemFilePath string, lateness time.Duration, onProgress func(Progress)) (*ReconciliationResult, error) {
+	log.Printf("Streaming source transactions from %s and system transactions from %s", sourceFilePath, systemFilePath)
+
+	nextSource, stopSource := iter.Pull2(s.csvReader.SourceTransactions(ctx, sourceFilePath))
+	defer stopSource()
+	nextSystem, stopSystem := iter.Pull2(s.csvReader.SystemTransactions(ctx, systemFilePath))
+	defer stopSystem()
+
+	engine := NewStreamingReconciler(s.reconciler, StreamingConfig{AllowedLateness: lateness})
+	result := &ReconciliationResult{}
+	// The IDs declared missing on each side, a counterpart showing up later means it came too late
+	missingSource, missingSystem := make(map[string]bool), make(map[string]bool)
+	collect := func(results []StreamResult) error {
+		for _, r := range results {
+			if r.Late {
+				return fmt.Errorf("%w: %s arrived after the watermark passed its createdAt", ErrLatenessExceeded, r.TransactionID)
+			}
+			switch r.Outcome {
+			case StreamMissingInInternal:
+				if missingSystem[r.TransactionID] {
+					return fmt.Errorf("%w: the two sides of %s were created further apart", ErrLatenessExceeded, r.TransactionID)
+				}
+				missingSource[r.TransactionID] = true
+				result.MissingInInternal = append(result.MissingInInternal, *r.Source)
+			case StreamMissingInSource:
+				if missingSource[r.TransactionID] {
+					return fmt.Errorf("%w: the two sides of %s were created further apart", ErrLatenessExceeded, r.TransactionID)
+				}
+				missingSystem[r.TransactionID] = true
+				result.MissingInSource = append(result.MissingInSource, *r.System)
+			case StreamMismatched:
+				result.MismatchedTransactions = append(result.MismatchedTransactions, MismatchedTransaction{
+					TransactionID: r.TransactionID,
+					Discrepancies: r.Discrepancies,
+					Source:        r.Source,
+					System:        r.System,
+				})
+			}
 		}
+		return nil
 	}
 
-	// Transform missing_in_source to simplified format
-	missingInSource := make([]map[string]interface{}, len(result.MissingInSource))
-	for i, txn := range result.MissingInSource {
-		missingInSource[i] = map[string]interface{}{
-			"transactionId": txn.TransactionID,
-			"amount":        txn.Amount,
-			"currency":      txn.Currency,
-			"status":        txn.Status,
+	progress := Progress{Phase: PhaseMatching}
+	report := func() {
+		if onProgress != nil {
+			summary := engine.Summary()
+			progress.SourceRows = summary.TotalSourceTransactions
+			progress.SystemRows = summary.TotalSystemTransactions
+			progress.PairsCompared = summary.SuccessfullyMatchedCount + summary.MismatchedTransactionsCount
+			progress.MissingInInternal = summary.MissingInInternalCount
+			progress.MissingInSource = summary.MissingInSourceCount
+			progress.Mismatched = summary.MismatchedTransactionsCount
+			onProgress(progress)
 		}
 	}
+	report()
 
-	// Create ordered output structure to ensure proper JSON field order
-	type OrderedOutput struct {
-		MissingInInternal      []map[string]interface{} `json:"missing_in_internal"`
-		MissingInSource        []map[string]interface{} `json:"missing_in_source"`
-		MismatchedTransactions []MismatchedTransaction  `json:"mismatched_transactions"`
+	source, sourceErr, sourceOK := nextSource()
+	system, systemErr, systemOK := nextSystem()
+	for rows := 0; sourceOK || systemOK; rows++ {
+		if sourceErr != nil {
+			return nil, fmt.Errorf("failed to read source transactions: %w", sourceErr)
+		}
+		if systemErr != nil {
+			return nil, fmt.Errorf("failed to read system transactions: %w", systemErr)
+		}
+
+		var results []StreamResult
+		var err error
+		if sourceOK && (!systemOK || !system.CreatedAt.Before(source.CreatedAt)) {
+			results, err = engine.AddSource(source)
+			source, sourceErr, sourceOK = nextSource()
+		} else {
+			results, err = engine.AddSystem(system)
+			system, systemErr, systemOK = nextSystem()
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reconciliation interrupted: %w", err)
+		}
+		if err := collect(results); err != nil {
+			return nil, err
+		}
+
+		if rows%progressInterval == 0 {
+			report()
+		}
 	}
 
-	output := OrderedOutput{
-		MissingInInternal:      missingInInternal,
-		MissingInSource:        missingInSource,
-		MismatchedTransactions: result.MismatchedTransactions,
+	remaining, err := engine.Flush()
+	if err != nil {
+		return nil, fmt.Errorf("reconciliation interrupted: %w", err)
 	}
+	if err := collect(remaining); err != nil {
+		return nil, err
+	}
+	result.Summary = engine.Summary()
+	s.reconciler.Order().Sort(result)
+
+	progress.Percent = 100
+	report()
+	log.Printf("Streamed %d source and %d system transactions", result.Summary.TotalSourceTransactions, result.Summary.TotalSystemTransactions)
 
-	// Convert to JSON with pretty printing
-	jsonOutput, err := json.MarshalIndent(output, "", "  ")
+	return result, nil
+}
+
+// sortMergeResult reconciles two CSV files with a sort-merge run, collecting the exceptions into a result
+// in report order
+func (s *TransactionReconciliationService) sortMergeResult(ctx context.Context, sourceFilePath, systemFilePath string, onProgress func(Progress)) (*ReconciliationResult, error) {
+	var progress Progress
+	percents := map[string]float64{PhaseReadingSource: 0, PhaseReadingSystem: 35, PhaseMatching: 70}
+	result := &ReconciliationResult{}
+	engine := NewSortMergeReconciler(s.reconciler, SortMergeConfig{})
+	summary, err := engine.Reconcile(ctx,
+		s.csvReader.SourceTransactions(ctx, sourceFilePath),
+		s.csvReader.SystemTransactions(ctx, systemFilePath),
+		func(phase string) {
+			if onProgress != nil {
+				progress.Phase = phase
+				progress.Percent = percents[phase]
+				onProgress(progress)
+			}
+		},
+		func(exception Exception) error {
+			switch exception.Kind {
+			case ExceptionMissingInInternal:
+				result.MissingInInternal = append(result.MissingInInternal, *exception.Source)
+			case ExceptionMissingInSource:
+				result.MissingInSource = append(result.MissingInSource, *exception.System)
+			case ExceptionMismatched:
+				result.MismatchedTransactions = append(result.MismatchedTransactions, *exception.Mismatch)
+			}
+			return nil
+		})
 	if err != nil {
-		return fmt.Errorf("failed to marshal result to JSON: %w", err)
+		return nil, fmt.Errorf("reconciliation interrupted: %w", err)
+	}
+	result.Summary = summary
+	s.reconciler.Order().Sort(result)
+
+	if onProgress != nil {
+		progress.SourceRows = summary.TotalSourceTransactions
+		progress.SystemRows = summary.TotalSystemTransactions
+		progress.PairsCompared = summary.SuccessfullyMatchedCount + summary.MismatchedTransactionsCount
+		progress.MissingInInternal = summary.MissingInInternalCount
+		progress.MissingInSource = summary.MissingInSourceCount
+		progress.Mismatched = summary.MismatchedTransactionsCount
+		progress.Percent = 100
+		onProgress(progress)
 	}
+	log.Printf("Sort-merge reconciled %d source and %d system transactions", summary.TotalSourceTransactions, summary.TotalSystemTransactions)
 
-	// Print to console
-	fmt.Println(string(jsonOutput))
+	return result, nil
+}
+
+// ProcessReconciliationParallel reconciles like ProcessReconciliationContext with both files parsed and the
+// pairs matched on several workers, zero meaning one per CPU. See ReconcileParallel for the ordering of the result.
+func (s *TransactionReconciliationService) ProcessReconciliationParallel(ctx context.Context, sourceFilePath, systemFilePath string, workers int, onProgress func(Progress)) (*ReconciliationResult, error) {
+	var progress Progress
+	report := func() {
+		if onProgress != nil {
+			onProgress(progress)
+		}
+	}
+	workers = parallelWorkers(workers)
+
+	log.Printf("Reading source transactions from %s on %d workers", sourceFilePath, workers)
+	progress.Phase = PhaseReadingSource
+	report()
+	sourceTransactions, err := s.csvReader.ReadSourceTransactionsParallel(ctx, sourceFilePath, workers)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read source transactions: %w", err)
+	}
+	progress.SourceRows = len(sourceTransactions)
+
+	log.Printf("Reading system transactions from %s on %d workers", systemFilePath, workers)
+	progress.Phase = PhaseReadingSystem
+	progress.Percent = 35
+	report()
+	systemTransactions, err := s.csvReader.ReadSystemTransactionsParallel(ctx, systemFilePath, workers)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read system transactions: %w", err)
+	}
+	progress.SystemRows = len(systemTransactions)
+
+	log.Printf("Matching %d source and %d system transactions on %d workers", len(sourceTransactions), len(systemTransactions), workers)
+	progress.Phase = PhaseMatching
+	progress.Percent = 70
+	report()
+	result, err := s.reconciler.ReconcileParallel(ctx, sourceTransactions, systemTransactions, workers)
+	if err != nil {
+		return nil, fmt.Errorf("reconciliation interrupted: %w", err)
+	}
+
+	progress.PairsCompared = result.Summary.SuccessfullyMatchedCount + result.Summary.MismatchedTransactionsCount
+	progress.MissingInInternal = result.Summary.MissingInInternalCount
+	progress.MissingInSource = result.Summary.MissingInSourceCount
+	progress.Mismatched = result.Summary.MismatchedTransactionsCount
+	progress.Percent = 100
+	report()
+	log.Println("Reconciliation completed")
+
+	return result, nil
+}
+
+// ProcessReconciliationSortMerge reconciles two files of any size and order by sorting both on disk by
+// transaction ID and merge-joining them, see SortMergeReconciler, and writes the report of
+// reconciliation_report.json to report. The exceptions are sorted on disk as well; onExceptions, when not
+// nil, gets them in results of at most ChunkRows exceptions, e.g. to open cases, so no more than that is
+// held in memory.
+func (s *TransactionReconciliationService) ProcessReconciliationSortMerge(ctx context.Context, sourceFilePath, systemFilePath string, config SortMergeConfig, report io.Writer, onExceptions func(*ReconciliationResult) error, onProgress func(Progress)) (ReconciliationSummary, error) {
+	log.Printf("Sort-merge reconciling source transactions from %s and system transactions from %s", sourceFilePath, systemFilePath)
+
+	var progress Progress
+	reportProgress := func() {
+		if onProgress != nil {
+			onProgress(progress)
+		}
+	}
+	percents := map[string]float64{PhaseReadingSource: 0, PhaseReadingSystem: 35, PhaseMatching: 70}
+
+	engine := NewSortMergeReconciler(s.reconciler, config)
+	batch := &ReconciliationResult{}
+	batched := 0
+	flush := func() error {
+		if onExceptions == nil || batched == 0 {
+			return nil
+		}
+		err := onExceptions(batch)
+		batch, batched = &ReconciliationResult{}, 0
+		return err
+	}
+
+	summary, err := engine.WriteReport(ctx,
+		s.csvReader.SourceTransactions(ctx, sourceFilePath),
+		s.csvReader.SystemTransactions(ctx, systemFilePath),
+		s.reconciler.Order(), report,
+		func(phase string) {
+			progress.Phase = phase
+			progress.Percent = percents[phase]
+			reportProgress()
+		},
+		func(exception Exception) error {
+			switch exception.Kind {
+			case ExceptionMissingInInternal:
+				progress.MissingInInternal++
+			case ExceptionMissingInSource:
+				progress.MissingInSource++
+			case ExceptionMismatched:
+				progress.Mismatched++
+			}
+			if onExceptions == nil {
+				return nil
+			}
+			switch exception.Kind {
+			case ExceptionMissingInInternal:
+				batch.MissingInInternal = append(batch.MissingInInternal, *exception.Source)
+			case ExceptionMissingInSource:
+				batch.MissingInSource = append(batch.MissingInSource, *exception.System)
+			case ExceptionMismatched:
+				batch.MismatchedTransactions = append(batch.MismatchedTransactions, *exception.Mismatch)
+			}
+			if batched++; batched >= engine.config.ChunkRows {
+				return flush()
+			}
+			return nil
+		})
+	if err == nil {
+		err = flush()
+	}
+	if err != nil {
+		return summary, fmt.Errorf("reconciliation interrupted: %w", err)
+	}
+
+	progress.SourceRows = summary.TotalSourceTransactions
+	progress.SystemRows = summary.TotalSystemTransactions
+	progress.PairsCompared = summary.SuccessfullyMatchedCount + summary.MismatchedTransactionsCount
+	progress.Percent = 100
+	reportProgress()
+	log.Printf("Sort-merge reconciled %d source and %d system transactions", summary.TotalSourceTransactions, summary.TotalSystemTransactions)
+
+	return summary, nil
+}
+
+// BuildReport transforms the reconciliation result into the simplified report format
+func (s *TransactionReconciliationService) BuildReport(result *ReconciliationResult) ReconciliationReport {
+	return reconcile.NewReport(result)
+}
+
+// OutputReconciliationResult prints the report in JSON format and saves it to reconciliation_report.json
+func (s *TransactionReconciliationService) OutputReconciliationResult(result *ReconciliationResult) error {
+	ctx := context.Background()
+	if err := (reconcile.WriterSink{W: os.Stdout}).Write(ctx, result); err != nil {
+		return err
+	}
 
 	// Also save to file
 	outputFile := "reconciliation_report.json"
-	err = os.WriteFile(outputFile, jsonOutput, 0644)
-	if err != nil {
+	if err := (reconcile.FileSink{Path: outputFile}).Write(ctx, result); err != nil {
 		log.Printf("Warning: Could not save report to file %s: %v", outputFile, err)
 	} else {
 		log.Printf("Reconciliation report saved to: %s", outputFile)
 	}
 
 	// Save summary to separate file
-	err = s.OutputSummaryToFile(result)
-	if err != nil {
+	if err := s.OutputSummaryToFile(result); err != nil {
 		log.Printf("Warning: Could not save summary to file: %v", err)
 	}
 
@@ -129,13 +473,7 @@ func (s *TransactionReconciliationService) OutputSummaryToFile(result *Reconcili
 	summaryContent.WriteString(separator + "\n")
 
 	// Calculate reconciliation rate
-	totalPossibleMatches := result.Summary.TotalSourceTransactions
-	if result.Summary.TotalSystemTransactions < totalPossibleMatches {
-		totalPossibleMatches = result.Summary.TotalSystemTransactions
-	}
-
-	if totalPossibleMatches > 0 {
-		matchRate := float64(result.Summary.SuccessfullyMatchedCount) / float64(totalPossibleMatches) * 100
+	if matchRate, ok := result.Summary.MatchRate(); ok {
 		summaryContent.WriteString(fmt.Sprintf("Reconciliation Rate:            %.2f%%\n", matchRate))
 	}
 	summaryContent.WriteString(separator + "\n")
@@ -166,13 +504,7 @@ func (s *TransactionReconciliationService) PrintSummary(result *ReconciliationRe
 	fmt.Println(separator)
 
 	// Calculate reconciliation rate
-	totalPossibleMatches := result.Summary.TotalSourceTransactions
-	if result.Summary.TotalSystemTransactions < totalPossibleMatches {
-		totalPossibleMatches = result.Summary.TotalSystemTransactions
-	}
-
-	if totalPossibleMatches > 0 {
-		matchRate := float64(result.Summary.SuccessfullyMatchedCount) / float64(totalPossibleMatches) * 100
+	if matchRate, ok := result.Summary.MatchRate(); ok {
 		fmt.Printf("Reconciliation Rate:            %.2f%%\n", matchRate)
 	}
 	fmt.Println(separator)
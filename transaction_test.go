@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/devhindo/TransactionReconcilerService/reconcile"
+)
+
+// writeStreamingFiles writes one source and one system transaction per createdAt pair, in file order
+func writeStreamingFiles(t *testing.T, pairs [][2]time.Time) (string, string) {
+	t.Helper()
+	dir := t.TempDir()
+	write := func(name string, header []string, row func(id string, createdAt time.Time) []string, side int) string {
+		path := filepath.Join(dir, name)
+		file, err := os.Create(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer file.Close()
+		writer := csv.NewWriter(file)
+		writer.Write(header)
+		for i, pair := range pairs {
+			writer.Write(row("txn-"+string(rune('a'+i)), pair[side]))
+		}
+		writer.Flush()
+		if err := writer.Error(); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	sourcePath := write("source.csv", reconcile.SourceCSVHeader, func(id string, createdAt time.Time) []string {
+		at := createdAt.Format(time.RFC3339)
+		return []string{id, "customer@example.com", "usr-1", "Stripe", "10.00", "USD", "completed", "payment", "credit_card", at, at, "pref", "low", "inv", "Customer", "payment"}
+	}, 0)
+	systemPath := write("system.csv", reconcile.SystemCSVHeader, func(id string, createdAt time.Time) []string {
+		at := createdAt.Format(time.RFC3339)
+		return []string{id, "usr-1", "10.00", "USD", "completed", "credit_card", at, at, "ref", "ord", "order"}
+	}, 1)
+	return sourcePath, systemPath
+}
+
+func TestProcessReconciliationStreamingLateness(t *testing.T) {
+	day := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	service := NewTransactionReconciliationService()
+
+	t.Run("in order", func(t *testing.T) {
+		sourcePath, systemPath := writeStreamingFiles(t, [][2]time.Time{{day, day.Add(time.Hour)}, {day.Add(2 * time.Hour), day.Add(2 * time.Hour)}})
+		result, err := service.ProcessReconciliationStreaming(context.Background(), sourcePath, systemPath, time.Hour, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(result.MissingInInternal)+len(result.MissingInSource) != 0 {
+			t.Errorf("missing %v and %v, want every transaction paired", result.MissingInInternal, result.MissingInSource)
+		}
+	})
+
+	t.Run("sides created further apart", func(t *testing.T) {
+		sourcePath, systemPath := writeStreamingFiles(t, [][2]time.Time{{day, day.Add(5 * time.Hour)}, {day.Add(3 * time.Hour), day.Add(3 * time.Hour)}})
+		result, err := service.ProcessReconciliationStreaming(context.Background(), sourcePath, systemPath, time.Hour, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(result.MissingInInternal)+len(result.MissingInSource) != 0 || result.Summary.TotalSourceTransactions != 2 {
+			t.Errorf("missing %v and %v of %+v, want every transaction paired", result.MissingInInternal, result.MissingInSource, result.Summary)
+		}
+	})
+
+	t.Run("files out of order", func(t *testing.T) {
+		sourcePath, systemPath := "assets/data/csvs/source_transactions.csv", "assets/data/csvs/system_transactions.csv"
+		want, err := service.ProcessReconciliation(sourcePath, systemPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		wantReport, err := reconcile.MarshalReport(want)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, lateness := range []time.Duration{defaultStreamingLateness, 2 * 365 * 24 * time.Hour} {
+			got, err := service.ProcessReconciliationStreaming(context.Background(), sourcePath, systemPath, lateness, nil)
+			if err != nil {
+				t.Fatalf("lateness %v: %v", lateness, err)
+			}
+			if got.Summary != want.Summary {
+				t.Errorf("lateness %v: summary = %+v, want %+v", lateness, got.Summary, want.Summary)
+			}
+			if report, err := reconcile.MarshalReport(got); err != nil || !bytes.Equal(report, wantReport) {
+				t.Errorf("lateness %v: report differs from the one of ProcessReconciliation (%v)", lateness, err)
+			}
+		}
+	})
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+)
+
+// runWatch follows the live progress of a reconciliation job running on an API server
+func runWatch(args []string) {
+	fs := flag.NewFlagSet("watch", flag.ExitOnError)
+	serverURL := fs.String("url", "http://localhost:8080", "base URL of the API server")
+	fs.Parse(args)
+
+	if fs.NArg() < 1 {
+		fmt.Fprintln(os.Stderr, "usage: TransactionReconcilerService watch [-url http://localhost:8080] <job-id>")
+		os.Exit(2)
+	}
+	jobID := fs.Arg(0)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	job, err := watchJob(ctx, strings.TrimRight(*serverURL, "/"), jobID, printJobProgress)
+	fmt.Println()
+	if err != nil {
+		log.Fatalf("Failed to watch job %s: %v", jobID, err)
+	}
+
+	fmt.Printf("Job %s %s\n", job.ID, job.Status)
+	if job.Error != "" {
+		fmt.Printf("Error: %s\n", job.Error)
+	}
+	if job.Summary != nil {
+		separator := strings.Repeat("=", 60)
+		fmt.Println(separator)
+		fmt.Printf("Total Source Transactions:      %d\n", job.Summary.TotalSourceTransactions)
+		fmt.Printf("Total System Transactions:      %d\n", job.Summary.TotalSystemTransactions)
+		fmt.Printf("Successfully Matched:           %d\n", job.Summary.SuccessfullyMatchedCount)
+		fmt.Printf("Missing in Internal System:     %d\n", job.Summary.MissingInInternalCount)
+		fmt.Printf("Missing in Source:              %d\n", job.Summary.MissingInSourceCount)
+		fmt.Printf("Mismatched Transactions:        %d\n", job.Summary.MismatchedTransactionsCount)
+		fmt.Println(separator)
+	}
+	if job.Status != JobSucceeded {
+		os.Exit(1)
+	}
+}
+
+// watchJob reads the event stream of a job, calling onEvent for every event, and returns the last state seen
+func watchJob(ctx context.Context, serverURL, jobID string, onEvent func(JobEvent)) (ReconciliationJob, error) {
+	var last ReconciliationJob
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, serverURL+"/v1/reconciliations/"+jobID+"/events", nil)
+	if err != nil {
+		return last, err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return last, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		json.NewDecoder(resp.Body).Decode(&apiErr)
+		return last, fmt.Errorf("server answered %s: %s", resp.Status, apiErr.Error)
+	}
+
+	// Server-Sent Events: "event:" and "data:" lines, terminated by an empty line
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	eventType, data := "", ""
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "event:"):
+			eventType = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			data += strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		case line == "":
+			if data != "" {
+				var job ReconciliationJob
+				if err := json.Unmarshal([]byte(data), &job); err != nil {
+					return last, fmt.Errorf("invalid event: %w", err)
+				}
+				last = job
+				onEvent(JobEvent{Type: eventType, Job: job})
+			}
+			eventType, data = "", ""
+		}
+	}
+	if err := scanner.Err(); err != nil && ctx.Err() == nil {
+		return last, err
+	}
+
+	return last, ctx.Err()
+}
+
+// printJobProgress redraws a single progress line
+func printJobProgress(event JobEvent) {
+	p := event.Job.Progress
+	phase := p.Phase
+	if phase == "" {
+		phase = string(event.Job.Status)
+	}
+	fmt.Printf("\r\033[K[%-14s] %5.1f%%  source rows %d  system rows %d  compared %d/%d  missing internal %d  missing source %d  mismatched %d",
+		phase, p.Percent, p.SourceRows, p.SystemRows, p.PairsCompared, p.PairsTotal, p.MissingInInternal, p.MissingInSource, p.Mismatched)
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// holdProgress makes the queue hang on writing the first progress record of a job until release is
+// closed, keeping the job running; blocked is closed once it hangs
+func holdProgress(queue *JobQueue) (blocked, release chan struct{}) {
+	blocked, release = make(chan struct{}), make(chan struct{})
+	var once sync.Once
+	queue.write = func(path string, data []byte) error {
+		var job ReconciliationJob
+		if err := json.Unmarshal(data, &job); err == nil && job.Progress.Phase == PhaseReadingSource {
+			once.Do(func() {
+				close(blocked)
+				<-release
+			})
+		}
+		return writeFileAtomic(path, data)
+	}
+	return blocked, release
+}
+
+// nextEvent reads one Server-Sent Event as framed on the wire, skipping comments, and returns io.EOF
+// once the stream ends between events
+func nextEvent(r *bufio.Reader) (JobEvent, error) {
+	var event JobEvent
+	var data string
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			if err == io.EOF && line == "" && event.Type == "" && data == "" {
+				return event, io.EOF
+			}
+			return event, fmt.Errorf("stream cut in the middle of an event: %w", err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			event.Type = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			data = strings.TrimPrefix(line, "data: ")
+		case strings.HasPrefix(line, ":"):
+		case line == "":
+			if event.Type == "" || data == "" {
+				return event, errors.New("event without an event or data line")
+			}
+			return event, json.Unmarshal([]byte(data), &event.Job)
+		default:
+			return event, fmt.Errorf("unexpected line %q", line)
+		}
+	}
+}
+
+// runningJob starts an API server and a job held running until release is closed
+func runningJob(t *testing.T) (api *httptest.Server, queue *JobQueue, job ReconciliationJob, release chan struct{}) {
+	t.Helper()
+	server, _ := jobServer(t, nil)
+	queue = server.config.Jobs
+	blocked, release := holdProgress(queue)
+	api = httptest.NewServer(server.Handler())
+	t.Cleanup(api.Close)
+
+	sourcePath, systemPath, err := writeBenchDataset(t.TempDir(), 20000, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job, err = queue.Submit(sourcePath, systemPath, ""); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-blocked:
+	case <-time.After(10 * time.Second):
+		t.Fatal("the job did not start")
+	}
+	return api, queue, job, release
+}
+
+func TestReconciliationEventsStream(t *testing.T) {
+	api, _, job, release := runningJob(t)
+
+	resp, err := http.Get(api.URL + "/v1/reconciliations/" + job.ID + "/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if got := resp.Header.Get("Content-Type"); resp.StatusCode != http.StatusOK || got != "text/event-stream" {
+		t.Fatalf("status %d, content type %q, want 200 and text/event-stream", resp.StatusCode, got)
+	}
+	stream := bufio.NewReader(resp.Body)
+
+	// The current state is flushed right away, while the job is still held running
+	first := make(chan JobEvent, 1)
+	go func() {
+		event, err := nextEvent(stream)
+		if err != nil {
+			t.Error(err)
+		}
+		first <- event
+	}()
+	select {
+	case event := <-first:
+		if event.Type != JobEventStatus || event.Job.Status != JobRunning {
+			t.Fatalf("first event = %s of a %s job, want the running status", event.Type, event.Job.Status)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the first event was not flushed")
+	}
+	close(release)
+
+	var events []JobEvent
+	for {
+		event, err := nextEvent(stream)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, event)
+	}
+	if len(events) == 0 {
+		t.Fatal("the stream ended without the final state")
+	}
+	last := events[len(events)-1]
+	if last.Type != JobEventStatus || last.Job.Status != JobSucceeded || last.Job.Summary == nil {
+		t.Errorf("last event = %s of a %s job, want the succeeded status with a summary", last.Type, last.Job.Status)
+	}
+	progress := 0
+	for _, event := range events[:len(events)-1] {
+		if event.Job.IsFinished() {
+			t.Errorf("%s event of a finished job before the last one", event.Type)
+		}
+		if event.Type == JobEventProgress {
+			progress++
+		}
+	}
+	if progress == 0 {
+		t.Error("no progress events while the job ran")
+	}
+
+	// A finished job sends its final state and ends the stream
+	resp, err = http.Get(api.URL + "/v1/reconciliations/" + job.ID + "/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	stream = bufio.NewReader(resp.Body)
+	if event, err := nextEvent(stream); err != nil || event.Job.Status != JobSucceeded {
+		t.Errorf("finished job: first event %s of a %s job, %v, want the succeeded status", event.Type, event.Job.Status, err)
+	}
+	if _, err := nextEvent(stream); err != io.EOF {
+		t.Errorf("finished job: stream goes on after the final state: %v", err)
+	}
+}
+
+func TestReconciliationEventsClientDisconnect(t *testing.T) {
+	api, queue, job, release := runningJob(t)
+	defer close(release)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events := make(chan JobEvent, 64)
+	done := make(chan error, 1)
+	go func() {
+		_, err := watchJob(ctx, api.URL, job.ID, func(event JobEvent) { events <- event })
+		done <- err
+	}()
+	select {
+	case <-events:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event received")
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("watchJob() = %v, want %v", err, context.Canceled)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("watchJob did not return after its context was cancelled")
+	}
+
+	// The server notices the disconnect and drops the subscription
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		queue.mu.RLock()
+		watchers := len(queue.watches[job.ID])
+		queue.mu.RUnlock()
+		if watchers == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the subscription of the disconnected client is still open")
+		}
+	}
+}
+
+func TestWatchJob(t *testing.T) {
+	api, _, job, release := runningJob(t)
+
+	var events []JobEvent
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		close(release)
+	}()
+	last, err := watchJob(context.Background(), api.URL, job.ID, func(event JobEvent) { events = append(events, event) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last.Status != JobSucceeded || len(events) < 2 || events[len(events)-1].Job.Status != JobSucceeded {
+		t.Errorf("watchJob() = %s after %d events, want the succeeded job last", last.Status, len(events))
+	}
+
+	if _, err := watchJob(context.Background(), api.URL, "job-unknown", func(JobEvent) {}); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("unknown job: watchJob() = %v, want a 404 error", err)
+	}
+}
@@ -0,0 +1,588 @@
+package main
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/devhindo/TransactionReconcilerService/reconcile"
+)
+
+// defaultWebhookLogPath is where received provider events are appended for replay
+const defaultWebhookLogPath = "webhook_events.log"
+
+// signatureTolerance is how far a signed Stripe timestamp or PayPal transmission time may be from now,
+// to refuse replayed requests
+const signatureTolerance = 5 * time.Minute
+
+var (
+	ErrUnknownProvider  = errors.New("unknown webhook provider")
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+	ErrInvalidEvent     = errors.New("invalid webhook event")
+)
+
+// WebhookEvent is a verified provider event, as kept in the event log
+type WebhookEvent struct {
+	ID         string          `json:"id"`
+	Provider   string          `json:"provider"`
+	Type       string          `json:"type"`
+	ReceivedAt time.Time       `json:"receivedAt"`
+	Payload    json.RawMessage `json:"payload"`
+}
+
+// WebhookProvider verifies and decodes the events pushed by one payment provider
+type WebhookProvider interface {
+	// Name is the provider as it appears in the webhook URL and in SourceTransaction.Provider
+	Name() string
+	// Verify checks the signature of a request body with the shared secret
+	Verify(header http.Header, body []byte, secret string, now time.Time) error
+	// Decode reads the event ID and type from a verified body
+	Decode(body []byte) (WebhookEvent, error)
+	// Updates turns an event into source transaction updates, events that do not touch a transaction yield none
+	Updates(event WebhookEvent) ([]SourceUpdate, error)
+}
+
+// WebhookEventLog is an append-only file of the received events, replayed to rebuild the live state
+type WebhookEventLog struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewWebhookEventLog opens the event log at path, the file is created on the first append
+func NewWebhookEventLog(path string) *WebhookEventLog {
+	return &WebhookEventLog{path: path}
+}
+
+// Append writes an event to the end of the log and syncs it to disk
+func (l *WebhookEventLog) Append(event WebhookEvent) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	line, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal webhook event: %w", err)
+	}
+
+	file, err := os.OpenFile(l.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open webhook event log: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to append to webhook event log: %w", err)
+	}
+	if err := file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync webhook event log: %w", err)
+	}
+
+	return nil
+}
+
+// Events reads every event in the log in the order they were received
+func (l *WebhookEventLog) Events() ([]WebhookEvent, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	file, err := os.Open(l.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open webhook event log: %w", err)
+	}
+	defer file.Close()
+
+	var events []WebhookEvent
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var event WebhookEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			return nil, fmt.Errorf("invalid webhook event at line %d: %w", line, err)
+		}
+		events = append(events, event)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read webhook event log: %w", err)
+	}
+
+	return events, nil
+}
+
+// WebhookResult is what receiving an event did
+type WebhookResult struct {
+	Event        WebhookEvent `json:"event"`
+	Duplicate    bool         `json:"duplicate"` // the event was received before and was not applied again
+	Transactions []LiveMatch  `json:"transactions"`
+}
+
+// WebhookReceiver verifies provider events, logs them and feeds them into the live reconciliation
+type WebhookReceiver struct {
+	providers map[string]WebhookProvider
+	secrets   map[string]string
+	log       *WebhookEventLog
+	live      *LiveReconciler
+	mu        sync.Mutex
+	seen      map[string]bool
+	now       func() time.Time
+}
+
+// NewWebhookReceiver creates a receiver for the providers that have a secret, secrets are keyed by provider name
+func NewWebhookReceiver(live *LiveReconciler, log *WebhookEventLog, secrets map[string]string) *WebhookReceiver {
+	receiver := &WebhookReceiver{
+		providers: make(map[string]WebhookProvider),
+		secrets:   make(map[string]string),
+		log:       log,
+		live:      live,
+		seen:      make(map[string]bool),
+		now:       func() time.Time { return time.Now().UTC() },
+	}
+	for _, provider := range []WebhookProvider{StripeWebhooks{}, PayPalWebhooks{}} {
+		if secret := secrets[provider.Name()]; secret != "" {
+			receiver.providers[provider.Name()] = provider
+			receiver.secrets[provider.Name()] = secret
+		}
+	}
+	return receiver
+}
+
+// Providers returns the names of the providers events are accepted from
+func (wr *WebhookReceiver) Providers() []string {
+	names := make([]string, 0, len(wr.providers))
+	for name := range wr.providers {
+		names = append(names, name)
+	}
+	return names
+}
+
+// Receive verifies an event pushed by a provider, appends it to the log and applies it to the live reconciliation.
+// Events already received are acknowledged without being applied twice, as providers retry deliveries.
+func (wr *WebhookReceiver) Receive(providerName string, header http.Header, body []byte) (WebhookResult, error) {
+	provider, ok := wr.providers[providerName]
+	if !ok {
+		return WebhookResult{}, fmt.Errorf("%w: %s", ErrUnknownProvider, providerName)
+	}
+	if err := provider.Verify(header, body, wr.secrets[providerName], wr.now()); err != nil {
+		return WebhookResult{}, err
+	}
+
+	event, err := provider.Decode(body)
+	if err != nil {
+		return WebhookResult{}, err
+	}
+	event.Provider = providerName
+	event.ReceivedAt = wr.now()
+
+	updates, err := provider.Updates(event)
+	if err != nil {
+		return WebhookResult{}, err
+	}
+
+	wr.mu.Lock()
+	defer wr.mu.Unlock()
+
+	key := providerName + ":" + event.ID
+	if wr.seen[key] {
+		return WebhookResult{Event: event, Duplicate: true, Transactions: []LiveMatch{}}, nil
+	}
+	if err := wr.log.Append(event); err != nil {
+		return WebhookResult{}, err
+	}
+	wr.seen[key] = true
+
+	result := WebhookResult{Event: event, Transactions: make([]LiveMatch, 0, len(updates))}
+	for _, update := range updates {
+		result.Transactions = append(result.Transactions, wr.live.Apply(update))
+	}
+	return result, nil
+}
+
+// Replay applies every logged event to the live reconciliation again and returns how many were replayed.
+// It rebuilds the live state after a restart; events from providers no longer configured are skipped.
+func (wr *WebhookReceiver) Replay() (int, error) {
+	events, err := wr.log.Events()
+	if err != nil {
+		return 0, err
+	}
+
+	wr.mu.Lock()
+	defer wr.mu.Unlock()
+
+	replayed := 0
+	for _, event := range events {
+		provider, ok := wr.providers[event.Provider]
+		if !ok {
+			continue
+		}
+		updates, err := provider.Updates(event)
+		if err != nil {
+			return replayed, fmt.Errorf("failed to replay event %s: %w", event.ID, err)
+		}
+		for _, update := range updates {
+			wr.live.Apply(update)
+		}
+		wr.seen[event.Provider+":"+event.ID] = true
+		replayed++
+	}
+
+	return replayed, nil
+}
+
+// StripeWebhooks handles Stripe events signed with the endpoint secret in the Stripe-Signature header
+type StripeWebhooks struct{}
+
+// Name returns the provider name used in the source files
+func (StripeWebhooks) Name() string { return "Stripe" }
+
+// Verify checks the v1 signature, an HMAC-SHA256 of "<timestamp>.<body>", and the age of the timestamp
+func (StripeWebhooks) Verify(header http.Header, body []byte, secret string, now time.Time) error {
+	var timestamp string
+	var signatures []string
+	for _, part := range strings.Split(header.Get("Stripe-Signature"), ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+	if timestamp == "" || len(signatures) == 0 {
+		return fmt.Errorf("%w: missing timestamp or v1 signature", ErrInvalidSignature)
+	}
+
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: invalid timestamp", ErrInvalidSignature)
+	}
+	if age := now.Sub(time.Unix(seconds, 0)); age > signatureTolerance || age < -signatureTolerance {
+		return fmt.Errorf("%w: timestamp outside the tolerance", ErrInvalidSignature)
+	}
+
+	expected := signPayload(secret, timestamp+"."+string(body))
+	for _, signature := range signatures {
+		if hmac.Equal([]byte(signature), []byte(expected)) {
+			return nil
+		}
+	}
+	return ErrInvalidSignature
+}
+
+// stripeEvent is the envelope of every Stripe event
+type stripeEvent struct {
+	ID      string `json:"id"`
+	Type    string `json:"type"`
+	Created int64  `json:"created"`
+	Data    struct {
+		Object json.RawMessage `json:"object"`
+	} `json:"data"`
+}
+
+// stripeCharge holds the fields of a Stripe charge object that map to a source transaction
+type stripeCharge struct {
+	ID                   string            `json:"id"`
+	Amount               int64             `json:"amount"`
+	Currency             string            `json:"currency"`
+	Status               string            `json:"status"`
+	Created              int64             `json:"created"`
+	Refunded             bool              `json:"refunded"`
+	Disputed             bool              `json:"disputed"`
+	Description          string            `json:"description"`
+	ReceiptEmail         string            `json:"receipt_email"`
+	BalanceTransaction   string            `json:"balance_transaction"`
+	Metadata             map[string]string `json:"metadata"`
+	PaymentMethodDetails struct {
+		Type string `json:"type"`
+	} `json:"payment_method_details"`
+	BillingDetails struct {
+		Email string `json:"email"`
+		Name  string `json:"name"`
+	} `json:"billing_details"`
+	Outcome struct {
+		RiskLevel string `json:"risk_level"`
+	} `json:"outcome"`
+}
+
+// stripeDispute holds the fields of a Stripe dispute object
+type stripeDispute struct {
+	Charge string `json:"charge"`
+	Status string `json:"status"`
+}
+
+// Decode reads the event envelope
+func (StripeWebhooks) Decode(body []byte) (WebhookEvent, error) {
+	var envelope stripeEvent
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return WebhookEvent{}, fmt.Errorf("%w: %v", ErrInvalidEvent, err)
+	}
+	if envelope.ID == "" || envelope.Type == "" {
+		return WebhookEvent{}, fmt.Errorf("%w: id and type are required", ErrInvalidEvent)
+	}
+	return WebhookEvent{ID: envelope.ID, Type: envelope.Type, Payload: json.RawMessage(body)}, nil
+}
+
+// Updates maps charge events to the charge they carry and dispute events to a status change of the disputed charge
+func (StripeWebhooks) Updates(event WebhookEvent) ([]SourceUpdate, error) {
+	var envelope stripeEvent
+	if err := json.Unmarshal(event.Payload, &envelope); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidEvent, err)
+	}
+	occurredAt := time.Unix(envelope.Created, 0).UTC()
+
+	switch {
+	case strings.HasPrefix(envelope.Type, "charge.dispute."):
+		var dispute stripeDispute
+		if err := json.Unmarshal(envelope.Data.Object, &dispute); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidEvent, err)
+		}
+		if dispute.Charge == "" {
+			return nil, fmt.Errorf("%w: dispute without a charge", ErrInvalidEvent)
+		}
+		status := "disputed"
+		if envelope.Type == "charge.dispute.closed" && dispute.Status == "won" {
+			status = "succeeded"
+		}
+		return []SourceUpdate{{
+			Transaction: SourceTransaction{ProviderTransactionID: dispute.Charge, Provider: "Stripe", Status: status, UpdatedAt: occurredAt},
+			StatusOnly:  true,
+		}}, nil
+
+	case strings.HasPrefix(envelope.Type, "charge."):
+		var charge stripeCharge
+		if err := json.Unmarshal(envelope.Data.Object, &charge); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidEvent, err)
+		}
+		if charge.ID == "" {
+			return nil, fmt.Errorf("%w: charge without an id", ErrInvalidEvent)
+		}
+
+		status := charge.Status
+		switch {
+		case charge.Disputed:
+			status = "disputed"
+		case charge.Refunded || envelope.Type == "charge.refunded":
+			status = "refunded"
+		}
+		email := charge.BillingDetails.Email
+		if email == "" {
+			email = charge.ReceiptEmail
+		}
+
+		return []SourceUpdate{{Transaction: SourceTransaction{
+			ProviderTransactionID: charge.ID,
+			Email:                 email,
+			UserID:                charge.Metadata["userId"],
+			Provider:              "Stripe",
+			Amount:                stripeAmount(charge.Amount, charge.Currency),
+			Currency:              strings.ToUpper(charge.Currency),
+			Status:                status,
+			TransactionType:       "charge",
+			PaymentMethod:         charge.PaymentMethodDetails.Type,
+			CreatedAt:             time.Unix(charge.Created, 0).UTC(),
+			UpdatedAt:             occurredAt,
+			ProviderReference:     charge.BalanceTransaction,
+			FraudRisk:             charge.Outcome.RiskLevel,
+			DetailsInvoiceID:      charge.Metadata["invoiceId"],
+			DetailsCustomerName:   charge.BillingDetails.Name,
+			DetailsDescription:    charge.Description,
+		}}}, nil
+	}
+
+	return nil, nil
+}
+
+// stripeExponents are the currencies Stripe gives in other units than their ISO 4217 minor unit: MGA in
+// whole units and ISK, kept in hundredths for backwards compatibility
+var stripeExponents = map[string]int{"MGA": 0, "ISK": 2}
+
+// stripeAmount converts an amount in the smallest currency unit to the decimal amount used in the source files
+func stripeAmount(amount int64, currency string) float64 {
+	exponent, ok := stripeExponents[strings.ToUpper(currency)]
+	if !ok {
+		if exponent, ok = reconcile.CurrencyExponent(currency); !ok {
+			exponent = 2
+		}
+	}
+	return float64(amount) / math.Pow10(exponent)
+}
+
+// PayPalWebhooks handles PayPal events relayed with a shared-secret signature: Paypal-Transmission-Sig holds
+// the HMAC-SHA256 of "<Paypal-Transmission-Id>|<Paypal-Transmission-Time>|<body>"
+type PayPalWebhooks struct{}
+
+// Name returns the provider name used in the source files
+func (PayPalWebhooks) Name() string { return "PayPal" }
+
+// Verify checks the transmission signature and the age of the transmission time
+func (PayPalWebhooks) Verify(header http.Header, body []byte, secret string, now time.Time) error {
+	id := header.Get("Paypal-Transmission-Id")
+	transmitted := header.Get("Paypal-Transmission-Time")
+	signature := header.Get("Paypal-Transmission-Sig")
+	if id == "" || transmitted == "" || signature == "" {
+		return fmt.Errorf("%w: missing transmission headers", ErrInvalidSignature)
+	}
+
+	at, err := time.Parse(time.RFC3339, transmitted)
+	if err != nil {
+		return fmt.Errorf("%w: invalid transmission time", ErrInvalidSignature)
+	}
+	if age := now.Sub(at); age > signatureTolerance || age < -signatureTolerance {
+		return fmt.Errorf("%w: transmission time outside the tolerance", ErrInvalidSignature)
+	}
+
+	expected := signPayload(secret, id+"|"+transmitted+"|"+string(body))
+	if !hmac.Equal([]byte(strings.ToLower(signature)), []byte(expected)) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// paypalEvent is the envelope of every PayPal event
+type paypalEvent struct {
+	ID         string          `json:"id"`
+	EventType  string          `json:"event_type"`
+	CreateTime time.Time       `json:"create_time"`
+	Resource   json.RawMessage `json:"resource"`
+}
+
+// paypalCapture holds the fields of a PayPal capture or refund resource
+type paypalCapture struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+	Amount struct {
+		CurrencyCode string `json:"currency_code"`
+		Value        string `json:"value"`
+	} `json:"amount"`
+	InvoiceID  string    `json:"invoice_id"`
+	CustomID   string    `json:"custom_id"`
+	CreateTime time.Time `json:"create_time"`
+	UpdateTime time.Time `json:"update_time"`
+	Links      []struct {
+		Href string `json:"href"`
+		Rel  string `json:"rel"`
+	} `json:"links"`
+}
+
+// paypalDispute holds the fields of a PayPal dispute resource
+type paypalDispute struct {
+	DisputedTransactions []struct {
+		SellerTransactionID string `json:"seller_transaction_id"`
+	} `json:"disputed_transactions"`
+}
+
+// Decode reads the event envelope
+func (PayPalWebhooks) Decode(body []byte) (WebhookEvent, error) {
+	var envelope paypalEvent
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return WebhookEvent{}, fmt.Errorf("%w: %v", ErrInvalidEvent, err)
+	}
+	if envelope.ID == "" || envelope.EventType == "" {
+		return WebhookEvent{}, fmt.Errorf("%w: id and event_type are required", ErrInvalidEvent)
+	}
+	return WebhookEvent{ID: envelope.ID, Type: envelope.EventType, Payload: json.RawMessage(body)}, nil
+}
+
+// paypalCaptureStatuses maps the capture events to the statuses used in the source files
+var paypalCaptureStatuses = map[string]string{
+	"PAYMENT.CAPTURE.COMPLETED": "succeeded",
+	"PAYMENT.CAPTURE.PENDING":   "pending",
+	"PAYMENT.CAPTURE.DENIED":    "failed",
+	"PAYMENT.CAPTURE.DECLINED":  "failed",
+	"PAYMENT.CAPTURE.REFUNDED":  "refunded",
+	"PAYMENT.CAPTURE.REVERSED":  "refunded",
+}
+
+// Updates maps capture events to the capture they carry, refunds and disputes to a status change of the capture
+func (PayPalWebhooks) Updates(event WebhookEvent) ([]SourceUpdate, error) {
+	var envelope paypalEvent
+	if err := json.Unmarshal(event.Payload, &envelope); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidEvent, err)
+	}
+	occurredAt := envelope.CreateTime.UTC()
+
+	if strings.HasPrefix(envelope.EventType, "CUSTOMER.DISPUTE.") {
+		var dispute paypalDispute
+		if err := json.Unmarshal(envelope.Resource, &dispute); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidEvent, err)
+		}
+		var updates []SourceUpdate
+		for _, txn := range dispute.DisputedTransactions {
+			if txn.SellerTransactionID == "" {
+				return nil, fmt.Errorf("%w: disputed transaction without a seller_transaction_id", ErrInvalidEvent)
+			}
+			updates = append(updates, SourceUpdate{
+				Transaction: SourceTransaction{ProviderTransactionID: txn.SellerTransactionID, Provider: "PayPal", Status: "disputed", UpdatedAt: occurredAt},
+				StatusOnly:  true,
+			})
+		}
+		return updates, nil
+	}
+
+	status, ok := paypalCaptureStatuses[envelope.EventType]
+	if !ok {
+		return nil, nil
+	}
+	var capture paypalCapture
+	if err := json.Unmarshal(envelope.Resource, &capture); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidEvent, err)
+	}
+
+	// The resource of a refund event is the refund, the capture it belongs to is its "up" link
+	if envelope.EventType == "PAYMENT.CAPTURE.REFUNDED" {
+		for _, link := range capture.Links {
+			if link.Rel == "up" {
+				return []SourceUpdate{{
+					Transaction: SourceTransaction{ProviderTransactionID: path.Base(link.Href), Provider: "PayPal", Status: status, UpdatedAt: occurredAt},
+					StatusOnly:  true,
+				}}, nil
+			}
+		}
+		return nil, fmt.Errorf("%w: refund without a capture link", ErrInvalidEvent)
+	}
+
+	if capture.ID == "" {
+		return nil, fmt.Errorf("%w: capture without an id", ErrInvalidEvent)
+	}
+	amount, err := strconv.ParseFloat(capture.Amount.Value, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid amount %q", ErrInvalidEvent, capture.Amount.Value)
+	}
+
+	return []SourceUpdate{{Transaction: SourceTransaction{
+		ProviderTransactionID: capture.ID,
+		UserID:                capture.CustomID,
+		Provider:              "PayPal",
+		Amount:                amount,
+		Currency:              capture.Amount.CurrencyCode,
+		Status:                status,
+		TransactionType:       "charge",
+		CreatedAt:             capture.CreateTime.UTC(),
+		UpdatedAt:             occurredAt,
+		DetailsInvoiceID:      capture.InvoiceID,
+	}}}, nil
+}
+
+// signPayload returns the hex HMAC-SHA256 of payload with the shared secret
+func signPayload(secret, payload string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestPayPalWebhooksVerify(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	body := []byte(`{"id":"WH-1","event_type":"PAYMENT.CAPTURE.COMPLETED"}`)
+
+	tests := []struct {
+		name        string
+		transmitted string
+		secret      string
+		wantErr     bool
+	}{
+		{"fresh", now.Add(-time.Minute).Format(time.RFC3339), "secret", false},
+		{"stale", now.Add(-10 * time.Minute).Format(time.RFC3339), "secret", true},
+		{"from the future", now.Add(10 * time.Minute).Format(time.RFC3339), "secret", true},
+		{"not a time", "yesterday", "secret", true},
+		{"wrong secret", now.Format(time.RFC3339), "other", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			header.Set("Paypal-Transmission-Id", "tx-1")
+			header.Set("Paypal-Transmission-Time", tt.transmitted)
+			header.Set("Paypal-Transmission-Sig", signPayload(tt.secret, "tx-1|"+tt.transmitted+"|"+string(body)))
+
+			err := PayPalWebhooks{}.Verify(header, body, "secret", now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Verify() = %v, want error %t", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidSignature) {
+				t.Errorf("Verify() = %v, want %v", err, ErrInvalidSignature)
+			}
+		})
+	}
+}
+
+func TestLiveReconcilerKeepsPartialRecordsPending(t *testing.T) {
+	live := NewLiveReconciler(NewTransactionReconciliationService().reconciler)
+	created := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	live.SetSystemTransactions([]SystemTransaction{{TransactionID: "txn-1", Amount: 25, Currency: "USD", Status: "completed", CreatedAt: created, UpdatedAt: created}})
+
+	// A dispute arrives before the charge
+	match := live.Apply(SourceUpdate{StatusOnly: true, Transaction: SourceTransaction{ProviderTransactionID: "txn-1", Status: "disputed", UpdatedAt: created.Add(time.Hour)}})
+	if match.State != LivePending || match.Discrepancies != nil {
+		t.Fatalf("status-only update: state %s with %v, want %s without discrepancies", match.State, match.Discrepancies, LivePending)
+	}
+	live.SetSystemTransactions([]SystemTransaction{{TransactionID: "txn-1", Amount: 25, Currency: "USD", Status: "completed", CreatedAt: created, UpdatedAt: created}})
+	if match, _ := live.Get("txn-1"); match.State != LivePending {
+		t.Errorf("after a refresh of the internal side: state %s, want %s", match.State, LivePending)
+	}
+
+	// The charge completes the record, the newer dispute status is kept
+	match = live.Apply(SourceUpdate{Transaction: SourceTransaction{ProviderTransactionID: "txn-1", Amount: 25, Currency: "USD", Status: "completed", CreatedAt: created, UpdatedAt: created}})
+	if match.State != LiveMismatched || match.Source.Status != "disputed" {
+		t.Fatalf("full record: state %s, status %s, want %s on the status", match.State, match.Source.Status, LiveMismatched)
+	}
+	if _, ok := match.Discrepancies["amount"]; ok {
+		t.Errorf("amount reported as a discrepancy: %v", match.Discrepancies)
+	}
+}
+
+func TestStripeAmount(t *testing.T) {
+	tests := []struct {
+		amount   int64
+		currency string
+		want     float64
+	}{
+		{1050, "usd", 10.5},
+		{1050, "JPY", 1050},
+		{1050, "kwd", 1.05},
+		{12345, "BHD", 12.345},
+		{1050, "clp", 1050},
+		{1050, "mga", 1050},
+		{1050, "isk", 10.5},
+	}
+	for _, tt := range tests {
+		if got := stripeAmount(tt.amount, tt.currency); got != tt.want {
+			t.Errorf("stripeAmount(%d, %s) = %v, want %v", tt.amount, tt.currency, got, tt.want)
+		}
+	}
+}
+
+func TestWebhookUpdatesRejectDisputesWithoutTransaction(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider WebhookProvider
+		payload  string
+	}{
+		{"stripe", StripeWebhooks{}, `{"id":"evt_1","type":"charge.dispute.created","created":1760000000,"data":{"object":{"status":"needs_response"}}}`},
+		{"paypal", PayPalWebhooks{}, `{"id":"WH-1","event_type":"CUSTOMER.DISPUTE.CREATED","create_time":"2026-10-18T12:00:00Z","resource":{"disputed_transactions":[{"seller_transaction_id":""}]}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			updates, err := tt.provider.Updates(WebhookEvent{Payload: []byte(tt.payload)})
+			if !errors.Is(err, ErrInvalidEvent) {
+				t.Errorf("Updates() = %v, %v, want %v", updates, err, ErrInvalidEvent)
+			}
+		})
+	}
+}
@@ -0,0 +1,460 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SystemWriter pushes corrections to the internal system. Every call carries an idempotency key,
+// implementations must make sure a repeated key does not apply the same change twice.
+type SystemWriter interface {
+	CreateTransaction(ctx context.Context, txn SystemTransaction, idempotencyKey string) error
+	UpdateTransaction(ctx context.Context, transactionID string, fields map[string]interface{}, idempotencyKey string) error
+	DeleteTransaction(ctx context.Context, transactionID string, idempotencyKey string) error
+}
+
+// CorrectionOp is the kind of change a correction makes to the internal system
+type CorrectionOp string
+
+const (
+	CorrectionCreate CorrectionOp = "create"
+	CorrectionUpdate CorrectionOp = "update"
+	CorrectionDelete CorrectionOp = "delete"
+)
+
+// CorrectionStatus is the outcome of a correction within a run
+type CorrectionStatus string
+
+const (
+	CorrectionPlanned    CorrectionStatus = "planned" // dry-run, nothing was sent
+	CorrectionApplied    CorrectionStatus = "applied"
+	CorrectionFailed     CorrectionStatus = "failed"
+	CorrectionRolledBack CorrectionStatus = "rolled_back"
+	CorrectionNotSent    CorrectionStatus = "not_sent" // the run was interrupted before it
+)
+
+// ErrRunNotFound is returned when rolling back a run that has no correction log
+var ErrRunNotFound = errors.New("correction run not found")
+
+// Correction is a single change pushed to the internal system for a resolved case
+type Correction struct {
+	IdempotencyKey string                 `json:"idempotencyKey"`
+	CaseID         string                 `json:"caseId"`
+	Op             CorrectionOp           `json:"op"`
+	TransactionID  string                 `json:"transactionId"`
+	Transaction    *SystemTransaction     `json:"transaction,omitempty"` // the transaction to create
+	Fields         map[string]interface{} `json:"fields,omitempty"`      // the fields to update
+	Previous       map[string]interface{} `json:"previous,omitempty"`    // the values the update replaced, used for rollback
+	Status         CorrectionStatus       `json:"status"`
+	Error          string                 `json:"error,omitempty"`
+	AppliedAt      time.Time              `json:"appliedAt,omitzero"`
+}
+
+// CorrectionRun is the per-run correction log
+type CorrectionRun struct {
+	RunID        string       `json:"runId"`
+	DryRun       bool         `json:"dryRun"`
+	StartedAt    time.Time    `json:"startedAt"`
+	FinishedAt   time.Time    `json:"finishedAt"`
+	RolledBackAt time.Time    `json:"rolledBackAt,omitzero"`
+	Interrupted  bool         `json:"interrupted,omitempty"` // cancelled before every correction was sent
+	Corrections  []Correction `json:"corrections"`
+}
+
+// PlanCorrections works out the corrections for the cases resolved in favour of the provider.
+// A transaction missing internally is created, a mismatched one gets the provider's values.
+// Cases that were already corrected by an earlier run are skipped.
+func PlanCorrections(cases []Case) []Correction {
+	var corrections []Correction
+
+	for _, c := range cases {
+		corrected, rollbacks := correctionState(c)
+		if c.Status != CaseStatusResolved || c.Resolution != ResolutionBookSource || corrected {
+			continue
+		}
+		planned := len(corrections)
+
+		switch c.Kind {
+		case ExceptionMissingInInternal:
+			if c.Source == nil {
+				continue
+			}
+			txn := systemTransactionFromSource(*c.Source)
+			corrections = append(corrections, Correction{
+				CaseID:        c.ID,
+				Op:            CorrectionCreate,
+				TransactionID: txn.TransactionID,
+				Transaction:   &txn,
+			})
+
+		case ExceptionMismatched:
+			if c.Source == nil || c.System == nil {
+				continue
+			}
+			fields, previous := correctedFields(*c.Source, *c.System, c.Discrepancies)
+			if len(fields) == 0 {
+				continue
+			}
+			corrections = append(corrections, Correction{
+				CaseID:        c.ID,
+				Op:            CorrectionUpdate,
+				TransactionID: c.TransactionID,
+				Fields:        fields,
+				Previous:      previous,
+			})
+		}
+
+		if len(corrections) > planned {
+			corrections[planned].IdempotencyKey = correctionKey(corrections[planned], rollbacks)
+		}
+	}
+
+	return corrections
+}
+
+// systemTransactionFromSource builds the internal record for a transaction only the provider has
+func systemTransactionFromSource(source SourceTransaction) SystemTransaction {
+	return SystemTransaction{
+		TransactionID:       source.ProviderTransactionID,
+		UserID:              source.UserID,
+		Amount:              source.Amount,
+		Currency:            source.Currency,
+		Status:              source.Status,
+		PaymentMethod:       source.PaymentMethod,
+		CreatedAt:           source.CreatedAt,
+		UpdatedAt:           source.UpdatedAt,
+		ReferenceID:         source.ProviderReference,
+		MetadataDescription: source.DetailsDescription,
+	}
+}
+
+// correctedFields returns the provider's values for the fields that differ, along with the values they replace
+func correctedFields(source SourceTransaction, system SystemTransaction, discrepancies map[string]Discrepancy) (map[string]interface{}, map[string]interface{}) {
+	fields := make(map[string]interface{})
+	previous := make(map[string]interface{})
+
+	set := func(field string, sourceValue, systemValue interface{}) {
+		if _, ok := discrepancies[field]; ok {
+			fields[field] = sourceValue
+			previous[field] = systemValue
+		}
+	}
+	set("userId", source.UserID, system.UserID)
+	set("amount", source.Amount, system.Amount)
+	set("currency", source.Currency, system.Currency)
+	set("status", source.Status, system.Status)
+	set("paymentMethod", source.PaymentMethod, system.PaymentMethod)
+	set("referenceId", source.ProviderReference, system.ReferenceID)
+
+	return fields, previous
+}
+
+// correctionKey derives a stable idempotency key, so a correction re-sent by a later run is recognised.
+// The number of rollbacks is part of the key so that a correction can be applied again after a rollback.
+func correctionKey(c Correction, rollbacks int) string {
+	payload, _ := json.Marshal(struct {
+		CaseID    string
+		Op        CorrectionOp
+		ID        string
+		Fields    map[string]interface{}
+		Rollbacks int
+	}{c.CaseID, c.Op, c.TransactionID, c.Fields, rollbacks})
+	sum := sha256.Sum256(payload)
+	return "corr-" + hex.EncodeToString(sum[:12])
+}
+
+// correctionState reports whether an earlier run already applied the corrections of the case
+// and how many times they were rolled back
+func correctionState(c Case) (corrected bool, rollbacks int) {
+	for _, event := range c.History {
+		switch event.Action {
+		case "corrected":
+			corrected = true
+		case "correction_rolled_back":
+			corrected = false
+			rollbacks++
+		}
+	}
+	return corrected, rollbacks
+}
+
+// Corrector applies planned corrections through a SystemWriter and keeps a log per run
+type Corrector struct {
+	writer SystemWriter
+	cases  *CaseStore
+	logDir string
+	dryRun bool
+	now    func() time.Time
+}
+
+// NewCorrector creates a corrector writing its run logs to logDir; in dry-run mode nothing is sent to the writer
+func NewCorrector(writer SystemWriter, cases *CaseStore, logDir string, dryRun bool) *Corrector {
+	return &Corrector{
+		writer: writer,
+		cases:  cases,
+		logDir: logDir,
+		dryRun: dryRun,
+		now:    func() time.Time { return time.Now().UTC() },
+	}
+}
+
+// Apply pushes the corrections for the resolved cases and returns the run log. A failed correction
+// does not stop the run, it is recorded in the log and the run carries on with the next one. A cancelled
+// context stops it, the log marking it interrupted and the corrections left as not sent.
+func (c *Corrector) Apply(ctx context.Context, actor string) (*CorrectionRun, error) {
+	run := &CorrectionRun{
+		RunID:       c.now().Format("20060102T150405.000000000Z"),
+		DryRun:      c.dryRun,
+		StartedAt:   c.now(),
+		Corrections: PlanCorrections(c.cases.List(CaseFilter{Status: CaseStatusResolved})),
+	}
+
+	for i := range run.Corrections {
+		correction := &run.Corrections[i]
+
+		if c.dryRun {
+			correction.Status = CorrectionPlanned
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			for j := i; j < len(run.Corrections); j++ {
+				run.Corrections[j].Status = CorrectionNotSent
+			}
+			run.Interrupted = true
+			run.FinishedAt = c.now()
+			return run, errors.Join(err, c.save(run))
+		}
+
+		err := c.send(ctx, *correction)
+		if err != nil {
+			correction.Status = CorrectionFailed
+			correction.Error = err.Error()
+		} else {
+			correction.Status = CorrectionApplied
+			correction.AppliedAt = c.now()
+		}
+
+		// Persist after every correction, before the case is touched, so that neither a crash nor a case
+		// store error loses track of what was applied
+		if err := c.save(run); err != nil {
+			return run, err
+		}
+		if correction.Status != CorrectionApplied {
+			continue
+		}
+		if _, err := c.cases.Record(correction.CaseID, actor, "corrected", fmt.Sprintf("%s %s in run %s", correction.Op, correction.TransactionID, run.RunID)); err != nil {
+			// The case will be planned again by the next run, which sends the same idempotency key
+			correction.Error = fmt.Sprintf("applied, but the case could not be updated: %v", err)
+			return run, errors.Join(err, c.save(run))
+		}
+	}
+
+	run.FinishedAt = c.now()
+	return run, c.save(run)
+}
+
+// Rollback undoes the applied corrections of a run in reverse order
+func (c *Corrector) Rollback(ctx context.Context, runID, actor string) (*CorrectionRun, error) {
+	run, err := c.LoadRun(runID)
+	if err != nil {
+		return nil, err
+	}
+
+	var failed []string
+	for i := len(run.Corrections) - 1; i >= 0; i-- {
+		correction := &run.Corrections[i]
+		if correction.Status != CorrectionApplied {
+			continue
+		}
+
+		inverse := Correction{
+			IdempotencyKey: correction.IdempotencyKey + "-rollback",
+			CaseID:         correction.CaseID,
+			TransactionID:  correction.TransactionID,
+		}
+		switch correction.Op {
+		case CorrectionCreate:
+			inverse.Op = CorrectionDelete
+		case CorrectionUpdate:
+			inverse.Op = CorrectionUpdate
+			inverse.Fields = correction.Previous
+		default:
+			continue
+		}
+
+		if c.dryRun {
+			continue
+		}
+		if err := c.send(ctx, inverse); err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", correction.TransactionID, err))
+			continue
+		}
+		correction.Status = CorrectionRolledBack
+		if err := c.save(run); err != nil {
+			return run, err
+		}
+		if _, err := c.cases.Record(correction.CaseID, actor, "correction_rolled_back", "run "+run.RunID); err != nil {
+			return run, err
+		}
+	}
+
+	if c.dryRun {
+		return run, nil
+	}
+	if len(failed) > 0 {
+		return run, fmt.Errorf("failed to roll back %d corrections: %s", len(failed), strings.Join(failed, "; "))
+	}
+
+	run.RolledBackAt = c.now()
+	return run, c.save(run)
+}
+
+// Runs lists the IDs of the logged correction runs, oldest first
+func (c *Corrector) Runs() ([]string, error) {
+	matches, err := filepath.Glob(filepath.Join(c.logDir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	runs := make([]string, 0, len(matches))
+	for _, match := range matches {
+		runs = append(runs, strings.TrimSuffix(filepath.Base(match), ".json"))
+	}
+	sort.Strings(runs)
+	return runs, nil
+}
+
+// LoadRun reads the correction log of a run
+func (c *Corrector) LoadRun(runID string) (*CorrectionRun, error) {
+	data, err := os.ReadFile(c.runPath(runID))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrRunNotFound, runID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read correction log: %w", err)
+	}
+
+	var run CorrectionRun
+	if err := json.Unmarshal(data, &run); err != nil {
+		return nil, fmt.Errorf("failed to parse correction log: %w", err)
+	}
+	return &run, nil
+}
+
+// send dispatches one correction to the writer
+func (c *Corrector) send(ctx context.Context, correction Correction) error {
+	switch correction.Op {
+	case CorrectionCreate:
+		return c.writer.CreateTransaction(ctx, *correction.Transaction, correction.IdempotencyKey)
+	case CorrectionUpdate:
+		return c.writer.UpdateTransaction(ctx, correction.TransactionID, correction.Fields, correction.IdempotencyKey)
+	case CorrectionDelete:
+		return c.writer.DeleteTransaction(ctx, correction.TransactionID, correction.IdempotencyKey)
+	}
+	return fmt.Errorf("unknown correction op %q", correction.Op)
+}
+
+// save writes the run log to disk
+func (c *Corrector) save(run *CorrectionRun) error {
+	if err := os.MkdirAll(c.logDir, 0755); err != nil {
+		return fmt.Errorf("failed to create correction log directory: %w", err)
+	}
+	data, err := json.MarshalIndent(run, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal correction log: %w", err)
+	}
+	if err := os.WriteFile(c.runPath(run.RunID), data, 0644); err != nil {
+		return fmt.Errorf("failed to write correction log: %w", err)
+	}
+	return nil
+}
+
+// runPath is the log file of a run
+func (c *Corrector) runPath(runID string) string {
+	return filepath.Join(c.logDir, runID+".json")
+}
+
+// HTTPSystemWriter writes corrections to an internal system exposing a JSON API:
+// POST /transactions, PATCH /transactions/{id} and DELETE /transactions/{id}
+type HTTPSystemWriter struct {
+	baseURL string
+	client  *http.Client
+	token   string
+}
+
+// NewHTTPSystemWriter creates a writer for the API at baseURL, a nil client uses a client with a 30 second timeout
+func NewHTTPSystemWriter(baseURL, token string, client *http.Client) *HTTPSystemWriter {
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	return &HTTPSystemWriter{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		client:  client,
+		token:   token,
+	}
+}
+
+// CreateTransaction creates a missing transaction in the internal system
+func (w *HTTPSystemWriter) CreateTransaction(ctx context.Context, txn SystemTransaction, idempotencyKey string) error {
+	return w.do(ctx, http.MethodPost, "/transactions", txn, idempotencyKey)
+}
+
+// UpdateTransaction changes the given fields of an internal transaction
+func (w *HTTPSystemWriter) UpdateTransaction(ctx context.Context, transactionID string, fields map[string]interface{}, idempotencyKey string) error {
+	return w.do(ctx, http.MethodPatch, "/transactions/"+url.PathEscape(transactionID), fields, idempotencyKey)
+}
+
+// DeleteTransaction removes an internal transaction, used to roll back a created one
+func (w *HTTPSystemWriter) DeleteTransaction(ctx context.Context, transactionID string, idempotencyKey string) error {
+	return w.do(ctx, http.MethodDelete, "/transactions/"+url.PathEscape(transactionID), nil, idempotencyKey)
+}
+
+// do sends a JSON request and treats any non-2xx response as an error
+func (w *HTTPSystemWriter) do(ctx context.Context, method, path string, body interface{}, idempotencyKey string) error {
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to marshal request: %w", err)
+		}
+		reader = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, w.baseURL+path, reader)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Idempotency-Key", idempotencyKey)
+	if w.token != "" {
+		req.Header.Set("Authorization", "Bearer "+w.token)
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s %s failed: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		excerpt, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s %s failed with status %d: %s", method, path, resp.StatusCode, strings.TrimSpace(string(excerpt)))
+	}
+	io.Copy(io.Discard, resp.Body)
+
+	return nil
+}
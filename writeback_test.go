@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// ledgerStub is an internal system API keeping transactions in memory, applying each idempotency key once
+type ledgerStub struct {
+	mu           sync.Mutex
+	transactions map[string]map[string]interface{}
+	keys         map[string]bool
+	requests     []string // method, path and idempotency key of every request
+	applied      int
+}
+
+func newLedgerStub(transactions map[string]map[string]interface{}) (*ledgerStub, *httptest.Server) {
+	stub := &ledgerStub{transactions: transactions, keys: make(map[string]bool)}
+	return stub, httptest.NewServer(stub)
+}
+
+func (l *ledgerStub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	key := r.Header.Get("Idempotency-Key")
+	l.requests = append(l.requests, r.Method+" "+r.URL.Path+" "+key)
+	if key == "" {
+		http.Error(w, "missing Idempotency-Key", http.StatusBadRequest)
+		return
+	}
+	if l.keys[key] {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	var body map[string]interface{}
+	if r.Method != http.MethodDelete {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	id := strings.TrimPrefix(r.URL.Path, "/transactions/")
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/transactions":
+		l.transactions[body["transactionId"].(string)] = body
+	case r.Method == http.MethodPatch && l.transactions[id] != nil:
+		for field, value := range body {
+			l.transactions[id][field] = value
+		}
+	case r.Method == http.MethodDelete && l.transactions[id] != nil:
+		delete(l.transactions, id)
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	l.keys[key] = true
+	l.applied++
+	w.WriteHeader(http.StatusOK)
+}
+
+// correctableStore opens a store with a missing-in-internal and a mismatched case, both resolved as book_source
+func correctableStore(t *testing.T) *CaseStore {
+	t.Helper()
+	store, err := NewCaseStore(filepath.Join(t.TempDir(), "cases.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := &ReconciliationResult{
+		MissingInInternal: []SourceTransaction{{ProviderTransactionID: "txn-1", Amount: 10, Currency: "USD", Status: "completed"}},
+		MismatchedTransactions: []MismatchedTransaction{{
+			TransactionID: "txn-2",
+			Discrepancies: DiscrepancyMap{"amount": {Source: 25.0, System: 20.0}},
+			Source:        &SourceTransaction{ProviderTransactionID: "txn-2", Amount: 25, Currency: "USD"},
+			System:        &SystemTransaction{TransactionID: "txn-2", Amount: 20, Currency: "USD"},
+		}},
+	}
+	if _, err := store.Sync(result, "tester"); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{CaseID(ExceptionMissingInInternal, "txn-1"), CaseID(ExceptionMismatched, "txn-2")} {
+		if _, err := store.Resolve(id, ResolutionBookSource, "tester", ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return store
+}
+
+func TestCorrectorDryRunSendsNothing(t *testing.T) {
+	stub, server := newLedgerStub(map[string]map[string]interface{}{"txn-2": {"amount": 20.0}})
+	defer server.Close()
+	store := correctableStore(t)
+	corrector := NewCorrector(NewHTTPSystemWriter(server.URL, "", nil), store, t.TempDir(), true)
+
+	run, err := corrector.Apply(context.Background(), "tester")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(run.Corrections) != 2 {
+		t.Fatalf("got %d corrections, want 2", len(run.Corrections))
+	}
+	for _, correction := range run.Corrections {
+		if correction.Status != CorrectionPlanned {
+			t.Errorf("%s is %s, want %s", correction.TransactionID, correction.Status, CorrectionPlanned)
+		}
+	}
+	if len(stub.requests) != 0 {
+		t.Errorf("dry run sent %v", stub.requests)
+	}
+	if again := PlanCorrections(store.List(CaseFilter{})); len(again) != 2 {
+		t.Errorf("dry run marked cases corrected, %d corrections left", len(again))
+	}
+}
+
+func TestCorrectorReusesIdempotencyKeyAfterCaseStoreFailure(t *testing.T) {
+	stub, server := newLedgerStub(map[string]map[string]interface{}{"txn-2": {"amount": 20.0}})
+	defer server.Close()
+	store := correctableStore(t)
+	logDir := t.TempDir()
+	corrector := NewCorrector(NewHTTPSystemWriter(server.URL, "", nil), store, logDir, false)
+
+	// The first correction reaches the ledger but cannot be recorded on its case
+	store.write = func(string, []byte) error { return errors.New("disk full") }
+	run, err := corrector.Apply(context.Background(), "tester")
+	if err == nil {
+		t.Fatal("Apply() succeeded although the case store could not be saved")
+	}
+	logged, err := corrector.LoadRun(run.RunID)
+	if err != nil {
+		t.Fatalf("run log was not saved: %v", err)
+	}
+	if logged.Corrections[0].Status != CorrectionApplied {
+		t.Errorf("logged correction is %s, want %s", logged.Corrections[0].Status, CorrectionApplied)
+	}
+	firstKey := logged.Corrections[0].IdempotencyKey
+
+	store.write = writeFileAtomic
+	run, err = corrector.Apply(context.Background(), "tester")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(run.Corrections) != 2 || run.Corrections[0].IdempotencyKey != firstKey {
+		t.Fatalf("second run corrections %v, want both again starting with key %s", run.Corrections, firstKey)
+	}
+	if stub.applied != 2 {
+		t.Errorf("ledger applied %d changes, want 2 (requests %v)", stub.applied, stub.requests)
+	}
+	if stub.transactions["txn-1"] == nil || stub.transactions["txn-2"]["amount"] != 25.0 {
+		t.Errorf("ledger = %v, want txn-1 created and txn-2 at 25", stub.transactions)
+	}
+	if again := PlanCorrections(store.List(CaseFilter{})); len(again) != 0 {
+		t.Errorf("%d corrections planned after every case was corrected", len(again))
+	}
+}
+
+func TestCorrectorRollback(t *testing.T) {
+	stub, server := newLedgerStub(map[string]map[string]interface{}{"txn-2": {"amount": 20.0}})
+	defer server.Close()
+	store := correctableStore(t)
+	corrector := NewCorrector(NewHTTPSystemWriter(server.URL, "", nil), store, t.TempDir(), false)
+
+	run, err := corrector.Apply(context.Background(), "tester")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rolledBack, err := corrector.Rollback(context.Background(), run.RunID, "tester")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rolledBack.RolledBackAt.IsZero() {
+		t.Error("run has no rollback time")
+	}
+	for _, correction := range rolledBack.Corrections {
+		if correction.Status != CorrectionRolledBack {
+			t.Errorf("%s is %s, want %s", correction.TransactionID, correction.Status, CorrectionRolledBack)
+		}
+	}
+	if stub.transactions["txn-1"] != nil || stub.transactions["txn-2"]["amount"] != 20.0 {
+		t.Errorf("ledger = %v, want txn-1 deleted and txn-2 back at 20", stub.transactions)
+	}
+	// Rolled back in reverse order, with keys of their own
+	last := stub.requests[len(stub.requests)-2:]
+	if !strings.HasPrefix(last[0], "PATCH /transactions/txn-2 ") || !strings.HasPrefix(last[1], "DELETE /transactions/txn-1 ") || !strings.HasSuffix(last[1], "-rollback") {
+		t.Errorf("rollback requests = %v", last)
+	}
+
+	// Rolled back corrections can be applied again under new keys
+	again, err := corrector.Apply(context.Background(), "tester")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(again.Corrections) != 2 || again.Corrections[0].IdempotencyKey == run.Corrections[0].IdempotencyKey {
+		t.Errorf("reapplied corrections %v, want both with new keys", again.Corrections)
+	}
+	if stub.transactions["txn-1"] == nil {
+		t.Error("txn-1 was not created again")
+	}
+}
+
+// cancellingWriter cancels the run once the wrapped writer has created a transaction
+type cancellingWriter struct {
+	SystemWriter
+	cancel context.CancelFunc
+}
+
+func (w cancellingWriter) CreateTransaction(ctx context.Context, txn SystemTransaction, idempotencyKey string) error {
+	defer w.cancel()
+	return w.SystemWriter.CreateTransaction(ctx, txn, idempotencyKey)
+}
+
+func TestCorrectorCancelledRunIsInterrupted(t *testing.T) {
+	stub, server := newLedgerStub(map[string]map[string]interface{}{"txn-2": {"amount": 20.0}})
+	defer server.Close()
+	store := correctableStore(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	corrector := NewCorrector(cancellingWriter{NewHTTPSystemWriter(server.URL, "", nil), cancel}, store, t.TempDir(), false)
+
+	run, err := corrector.Apply(ctx, "tester")
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Apply() = %v, want %v", err, context.Canceled)
+	}
+	logged, err := corrector.LoadRun(run.RunID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !logged.Interrupted || logged.FinishedAt.IsZero() {
+		t.Errorf("run log interrupted %t finished at %v, want an interrupted run with its end", logged.Interrupted, logged.FinishedAt)
+	}
+	if logged.Corrections[0].Status != CorrectionApplied || logged.Corrections[1].Status != CorrectionNotSent {
+		t.Errorf("corrections are %s and %s, want %s and %s", logged.Corrections[0].Status, logged.Corrections[1].Status, CorrectionApplied, CorrectionNotSent)
+	}
+	if stub.applied != 1 {
+		t.Errorf("ledger applied %d changes, want 1", stub.applied)
+	}
+}